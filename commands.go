@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/chesireabel/Technical-Interview/database"
+	"github.com/chesireabel/Technical-Interview/internal/repositories"
+	"github.com/chesireabel/Technical-Interview/internal/services"
+)
+
+// runCommand executes a one-off maintenance command instead of starting the server.
+// Usage: go run . <command> [args...]
+func runCommand(ctx context.Context, name string, args []string) error {
+	switch name {
+	case "backfill-phones":
+		return backfillPhones(ctx)
+	case "import-customers":
+		return importCustomers(ctx, args)
+	default:
+		return fmt.Errorf("unknown command %q (available: backfill-phones, import-customers)", name)
+	}
+}
+
+// backfillPhones normalises stored customer phone numbers to E.164 and prints
+// a JSON report listing the rows that need manual attention
+func backfillPhones(ctx context.Context) error {
+	customerService := services.NewCustomerService(repositories.NewCustomerRepository(database.DB), nil)
+
+	report, err := customerService.BackfillPhones(ctx)
+	if report != nil {
+		log.Printf("📞 Phone backfill: scanned %d, updated %d, unchanged %d, failed %d",
+			report.Scanned, report.Updated, report.Unchanged, len(report.Failed))
+
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if encErr := encoder.Encode(report); encErr != nil {
+			return fmt.Errorf("failed to write report: %w", encErr)
+		}
+	}
+
+	return err
+}
+
+// importCustomers is the CLI equivalent of POST /customers/import.
+// Usage: go run . import-customers -file customers.csv [-dry-run] [-mapping "customer_name=Full Name"] [-report rejected.csv]
+func importCustomers(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("import-customers", flag.ContinueOnError)
+	path := fs.String("file", "", "CSV file to import")
+	dryRun := fs.Bool("dry-run", false, "validate rows without inserting them")
+	rawMapping := fs.String("mapping", "", "column mapping, e.g. customer_name=Full Name,phone=Mobile")
+	chunkSize := fs.Int("chunk-size", 0, "rows inserted per transaction (default 500)")
+	reportPath := fs.String("report", "", "write rejected rows to this CSV file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *path == "" {
+		return fmt.Errorf("-file is required")
+	}
+
+	mapping, err := services.ParseColumnMapping(*rawMapping)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(*path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", *path, err)
+	}
+	defer file.Close()
+
+	customerService := services.NewCustomerService(repositories.NewCustomerRepository(database.DB), nil)
+	report, err := customerService.ImportCustomers(ctx, file, services.ImportOptions{
+		Mapping:   mapping,
+		DryRun:    *dryRun,
+		ChunkSize: *chunkSize,
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("📥 Customer import %d (dry run: %v): %d row(s), %d valid, %d imported, %d rejected",
+		report.ID, report.DryRun, report.TotalRows, report.Valid, report.Imported, len(report.Rejected))
+	for _, rej := range report.Rejected {
+		log.Printf("   row %d: %s", rej.Row, rej.Reason)
+	}
+
+	if *reportPath != "" {
+		out, err := os.Create(*reportPath)
+		if err != nil {
+			return fmt.Errorf("failed to create report %s: %w", *reportPath, err)
+		}
+		defer out.Close()
+		if err := report.WriteRejectedCSV(out); err != nil {
+			return fmt.Errorf("failed to write report: %w", err)
+		}
+	}
+
+	return nil
+}
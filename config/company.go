@@ -0,0 +1,44 @@
+package config
+
+import (
+	"os"
+	"strings"
+)
+
+const defaultInvoicePrefix = "INV-"
+
+// Company is the seller shown in the header of invoices
+type Company struct {
+	Name    string
+	Address []string
+	Phone   string
+	Email   string
+	// TaxPIN is the company's tax registration number, e.g. the KRA PIN
+	TaxPIN string
+	// InvoicePrefix is put in front of invoice numbers, e.g. INV-000042
+	InvoicePrefix string
+}
+
+// GetCompany reads the company details from COMPANY_NAME, COMPANY_ADDRESS
+// (lines separated by "|"), COMPANY_PHONE, COMPANY_EMAIL, COMPANY_TAX_PIN and
+// INVOICE_PREFIX
+func GetCompany() Company {
+	company := Company{
+		Name:          strings.TrimSpace(os.Getenv("COMPANY_NAME")),
+		Phone:         strings.TrimSpace(os.Getenv("COMPANY_PHONE")),
+		Email:         strings.TrimSpace(os.Getenv("COMPANY_EMAIL")),
+		TaxPIN:        strings.TrimSpace(os.Getenv("COMPANY_TAX_PIN")),
+		InvoicePrefix: strings.TrimSpace(os.Getenv("INVOICE_PREFIX")),
+	}
+
+	for _, line := range strings.Split(os.Getenv("COMPANY_ADDRESS"), "|") {
+		if line = strings.TrimSpace(line); line != "" {
+			company.Address = append(company.Address, line)
+		}
+	}
+	if company.InvoicePrefix == "" {
+		company.InvoicePrefix = defaultInvoicePrefix
+	}
+
+	return company
+}
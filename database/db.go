@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 
 	"github.com/joho/godotenv"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -12,6 +13,9 @@ import (
 
 var DB *pgxpool.Pool
 
+// defaultMaxConns is the pool size when DB_MAX_CONNS is not set
+const defaultMaxConns = 10
+
 func ConnectDB() {
 	// Loading .env variables
 	err := godotenv.Load()
@@ -28,8 +32,20 @@ func ConnectDB() {
 		os.Getenv("DB_NAME"),
 	)
 
+	config, err := pgxpool.ParseConfig(connectionString)
+	if err != nil {
+		log.Fatalf("Invalid database settings: %v", err)
+	}
+	// The pool default depends on the CPU count; pick the size explicitly so
+	// it can be matched to the database's max_connections across replicas.
+	// Leader locks are held on one further connection outside the pool.
+	config.MaxConns, err = maxConns()
+	if err != nil {
+		log.Fatalf("Invalid database settings: %v", err)
+	}
+
 	// new connection pool
-	DB, err = pgxpool.New(context.Background(), connectionString)
+	DB, err = pgxpool.NewWithConfig(context.Background(), config)
 	if err != nil {
 		log.Fatalf("Unable to connect to database: %v", err)
 	}
@@ -43,6 +59,19 @@ func ConnectDB() {
 	fmt.Println("Database connection successful!")
 }
 
+// maxConns reads the pool size from DB_MAX_CONNS, defaulting to defaultMaxConns
+func maxConns() (int32, error) {
+	v := os.Getenv("DB_MAX_CONNS")
+	if v == "" {
+		return defaultMaxConns, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 32)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("DB_MAX_CONNS must be a positive integer, got %q", v)
+	}
+	return int32(n), nil
+}
+
 func CloseDB() {
 	if DB != nil {
 		DB.Close()
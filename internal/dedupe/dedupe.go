@@ -0,0 +1,232 @@
+// Package dedupe finds customer records that probably belong to the same
+// person.
+//
+// Two records are candidates when they share a phone number or an email
+// address after normalisation. Each candidate pair is scored from 0 to 1:
+//
+//	same phone    +0.45
+//	same email    +0.45
+//	similar name  up to +0.35, when the names are at least 80% alike
+//
+// A shared phone or email alone scores 0.45, since families and offices
+// share them; with a similar name, or with both, a pair passes the default
+// threshold of 0.6. Names alone never make two records candidates.
+package dedupe
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	// DefaultMinScore is the score from which a pair is reported by default
+	DefaultMinScore = 0.6
+
+	phoneWeight = 0.45
+	emailWeight = 0.45
+	nameWeight  = 0.35
+	// similarNameThreshold is the name similarity below which names do not count
+	similarNameThreshold = 0.8
+	// maxBlockSize skips phones and emails shared by more records than this;
+	// such values are placeholders like +254700000000, not one person
+	maxBlockSize = 50
+)
+
+// Record is a customer as compared by Find. Phone should already be in
+// E.164 form; an empty Phone or Email never matches.
+type Record struct {
+	ID    int64
+	Name  string
+	Email string
+	Phone string
+}
+
+// Match is a pair of records that are probably the same person, with A < B
+type Match struct {
+	A       int64
+	B       int64
+	Score   float64
+	Reasons []string
+}
+
+// Find scores every candidate pair among records and returns those scoring
+// at least minScore, best first
+func Find(records []Record, minScore float64) []Match {
+	byPhone := make(map[string][]int)
+	byEmail := make(map[string][]int)
+	for i, r := range records {
+		if phone := strings.TrimSpace(r.Phone); phone != "" {
+			byPhone[phone] = append(byPhone[phone], i)
+		}
+		if email := NormalizeEmail(r.Email); email != "" {
+			byEmail[email] = append(byEmail[email], i)
+		}
+	}
+
+	seen := make(map[[2]int64]bool)
+	matches := []Match{}
+	for _, blocks := range []map[string][]int{byPhone, byEmail} {
+		for _, block := range blocks {
+			if len(block) < 2 || len(block) > maxBlockSize {
+				continue
+			}
+			for i := 0; i < len(block); i++ {
+				for j := i + 1; j < len(block); j++ {
+					a, b := records[block[i]], records[block[j]]
+					if a.ID > b.ID {
+						a, b = b, a
+					}
+					key := [2]int64{a.ID, b.ID}
+					if a.ID == b.ID || seen[key] {
+						continue
+					}
+					seen[key] = true
+
+					score, reasons := Score(a, b)
+					if score >= minScore {
+						matches = append(matches, Match{A: a.ID, B: b.ID, Score: score, Reasons: reasons})
+					}
+				}
+			}
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		if matches[i].A != matches[j].A {
+			return matches[i].A < matches[j].A
+		}
+		return matches[i].B < matches[j].B
+	})
+	return matches
+}
+
+// Score rates how likely a and b are the same person and explains why
+func Score(a, b Record) (float64, []string) {
+	score := 0.0
+	reasons := []string{}
+
+	if phone := strings.TrimSpace(a.Phone); phone != "" && phone == strings.TrimSpace(b.Phone) {
+		score += phoneWeight
+		reasons = append(reasons, "same phone")
+	}
+	if email := NormalizeEmail(a.Email); email != "" && email == NormalizeEmail(b.Email) {
+		score += emailWeight
+		reasons = append(reasons, "same email")
+	}
+
+	similarity := NameSimilarity(a.Name, b.Name)
+	switch {
+	case similarity == 1:
+		score += nameWeight
+		reasons = append(reasons, "same name")
+	case similarity >= similarNameThreshold:
+		score += nameWeight * similarity
+		reasons = append(reasons, fmt.Sprintf("similar name (%.0f%%)", similarity*100))
+	}
+
+	if score > 1 {
+		score = 1
+	}
+	// Two decimal places are plenty to rank pairs and read well in responses
+	return float64(int(score*100+0.5)) / 100, reasons
+}
+
+// NormalizeEmail lower-cases email and drops what mail providers ignore: a
+// +tag in the local part and, for Gmail, dots, so "Jane.Doe+shop@gmail.com"
+// becomes "janedoe@gmail.com"
+func NormalizeEmail(email string) string {
+	email = strings.ToLower(strings.TrimSpace(email))
+	local, domain, ok := strings.Cut(email, "@")
+	if !ok || local == "" || domain == "" {
+		return email
+	}
+
+	if i := strings.IndexByte(local, '+'); i > 0 {
+		local = local[:i]
+	}
+	if domain == "googlemail.com" {
+		domain = "gmail.com"
+	}
+	if domain == "gmail.com" {
+		local = strings.ReplaceAll(local, ".", "")
+	}
+	return local + "@" + domain
+}
+
+// NormalizeName lower-cases name, drops punctuation and sorts its words, so
+// "Doe, Jane" and "jane  doe" are the same
+func NormalizeName(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	sort.Strings(words)
+	return strings.Join(words, " ")
+}
+
+// NameSimilarity compares the normalised names with Jaro-Winkler similarity,
+// from 0 for nothing in common to 1 for the same name
+func NameSimilarity(a, b string) float64 {
+	a, b = NormalizeName(a), NormalizeName(b)
+	if a == "" || b == "" {
+		return 0
+	}
+	return jaroWinkler([]rune(a), []rune(b))
+}
+
+func jaroWinkler(a, b []rune) float64 {
+	if string(a) == string(b) {
+		return 1
+	}
+
+	window := max(len(a), len(b))/2 - 1
+	if window < 0 {
+		window = 0
+	}
+
+	aMatched := make([]bool, len(a))
+	bMatched := make([]bool, len(b))
+	matches := 0
+	for i := range a {
+		lo, hi := max(0, i-window), min(len(b), i+window+1)
+		for j := lo; j < hi; j++ {
+			if !bMatched[j] && a[i] == b[j] {
+				aMatched[i], bMatched[j] = true, true
+				matches++
+				break
+			}
+		}
+	}
+	if matches == 0 {
+		return 0
+	}
+
+	transpositions := 0
+	j := 0
+	for i := range a {
+		if !aMatched[i] {
+			continue
+		}
+		for !bMatched[j] {
+			j++
+		}
+		if a[i] != b[j] {
+			transpositions++
+		}
+		j++
+	}
+
+	m := float64(matches)
+	jaro := (m/float64(len(a)) + m/float64(len(b)) + (m-float64(transpositions)/2)/m) / 3
+
+	// Winkler's boost for a shared prefix of up to four characters
+	prefix := 0
+	for prefix < min(4, len(a), len(b)) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
@@ -0,0 +1,68 @@
+package dedupe
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFind(t *testing.T) {
+	records := []Record{
+		{ID: 1, Name: "Jane Wanjiru", Email: "jane@example.com", Phone: "+254712345678"},
+		{ID: 2, Name: "Jane Wanjru", Email: "jw@example.com", Phone: "+254712345678"},
+		{ID: 3, Name: "Peter Otieno", Email: "peter@example.com", Phone: "+254712345678"},
+		{ID: 4, Name: "Wanjiru, Jane", Email: "Jane.W+shop@gmail.com", Phone: "+254799999999"},
+		{ID: 5, Name: "Jane W", Email: "janew@gmail.com", Phone: "+254799999999"},
+		{ID: 6, Name: "Jane Wanjiru", Email: "other@example.com", Phone: ""},
+	}
+
+	matches := Find(records, DefaultMinScore)
+
+	assert.Len(t, matches, 2)
+	assert.Equal(t, int64(4), matches[0].A)
+	assert.Equal(t, int64(5), matches[0].B)
+	assert.Equal(t, []string{"same phone", "same email"}, matches[0].Reasons[:2])
+	assert.Equal(t, int64(1), matches[1].A)
+	assert.Equal(t, int64(2), matches[1].B)
+	assert.Contains(t, matches[1].Reasons, "same phone")
+}
+
+func TestFind_SkipsPlaceholderValues(t *testing.T) {
+	records := make([]Record, 0, maxBlockSize+1)
+	for i := 0; i <= maxBlockSize; i++ {
+		records = append(records, Record{ID: int64(i + 1), Name: "Walk-in customer", Email: fmt.Sprintf("walkin%d@example.com", i), Phone: "+254700000000"})
+	}
+
+	assert.Empty(t, Find(records, DefaultMinScore))
+}
+
+func TestScore(t *testing.T) {
+	score, reasons := Score(
+		Record{Name: "Jane Wanjiru", Phone: "+254712345678"},
+		Record{Name: "jane  wanjiru", Phone: "+254712345678"},
+	)
+	assert.Equal(t, 0.8, score)
+	assert.Equal(t, []string{"same phone", "same name"}, reasons)
+
+	score, reasons = Score(
+		Record{Name: "Jane Wanjiru", Phone: "+254712345678"},
+		Record{Name: "Peter Otieno", Phone: "+254712345678"},
+	)
+	assert.Equal(t, 0.45, score)
+	assert.Equal(t, []string{"same phone"}, reasons)
+}
+
+func TestNormalizeEmail(t *testing.T) {
+	assert.Equal(t, "janedoe@gmail.com", NormalizeEmail(" Jane.Doe+shop@GoogleMail.com "))
+	assert.Equal(t, "jane.doe@example.com", NormalizeEmail("Jane.Doe+news@example.com"))
+	assert.Equal(t, "not-an-email", NormalizeEmail("not-an-email"))
+}
+
+func TestNameSimilarity(t *testing.T) {
+	assert.Equal(t, 1.0, NameSimilarity("Doe, Jane", "jane doe"))
+	assert.InDelta(t, 0.961, NameSimilarity("Martha", "Marhta"), 0.001)
+	assert.Greater(t, NameSimilarity("Jane Wanjiru", "Jane Wanjru"), similarNameThreshold)
+	assert.Less(t, NameSimilarity("Jane Wanjiru", "Peter Otieno"), similarNameThreshold)
+	assert.Equal(t, 0.0, NameSimilarity("", "Jane"))
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/chesireabel/Technical-Interview/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type AddressHandler struct {
+	service services.AddressService
+}
+
+func NewAddressHandler(s services.AddressService) *AddressHandler {
+	return &AddressHandler{service: s}
+}
+
+// addressIDs reads the customer and address IDs from the path, writing a 400 when either is invalid
+func addressIDs(c *gin.Context) (customerID, addressID int64, ok bool) {
+	customerID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid customer ID"})
+		return 0, 0, false
+	}
+	if c.Param("address_id") == "" {
+		return customerID, 0, true
+	}
+	addressID, err = strconv.ParseInt(c.Param("address_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid address ID"})
+		return 0, 0, false
+	}
+	return customerID, addressID, true
+}
+
+// CreateAddress adds an address to the customer's address book, e.g.
+// {"label": "Home", "line1": "Kindaruma Rd", "town": "Nairobi", "county": "Nairobi",
+// "latitude": -1.2921, "longitude": 36.7830, "is_default": true}
+func (h *AddressHandler) CreateAddress(c *gin.Context) {
+	customerID, _, ok := addressIDs(c)
+	if !ok {
+		return
+	}
+
+	var address models.CustomerAddress
+	if err := c.ShouldBindJSON(&address); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	address.CustomerID = customerID
+
+	err := h.service.CreateAddress(c.Request.Context(), &address)
+	if respondConflict(c, err) {
+		return
+	}
+	if errors.Is(err, models.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Customer not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, address)
+}
+
+// ListAddresses returns the customer's addresses, default first
+func (h *AddressHandler) ListAddresses(c *gin.Context) {
+	customerID, _, ok := addressIDs(c)
+	if !ok {
+		return
+	}
+
+	addresses, err := h.service.ListAddresses(c.Request.Context(), customerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch addresses"})
+		return
+	}
+
+	c.JSON(http.StatusOK, addresses)
+}
+
+func (h *AddressHandler) GetAddress(c *gin.Context) {
+	customerID, addressID, ok := addressIDs(c)
+	if !ok {
+		return
+	}
+
+	address, err := h.service.GetAddress(c.Request.Context(), customerID, addressID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Address not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, address)
+}
+
+// UpdateAddress replaces the address. Setting is_default makes it the
+// customer's default in place of the previous one.
+func (h *AddressHandler) UpdateAddress(c *gin.Context) {
+	customerID, addressID, ok := addressIDs(c)
+	if !ok {
+		return
+	}
+
+	var address models.CustomerAddress
+	if err := c.ShouldBindJSON(&address); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	address.ID = addressID
+	address.CustomerID = customerID
+
+	err := h.service.UpdateAddress(c.Request.Context(), &address)
+	if respondConflict(c, err) {
+		return
+	}
+	if errors.Is(err, models.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Address not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, address)
+}
+
+// DeleteAddress removes the address. Orders already placed keep their copy of it.
+func (h *AddressHandler) DeleteAddress(c *gin.Context) {
+	customerID, addressID, ok := addressIDs(c)
+	if !ok {
+		return
+	}
+
+	err := h.service.DeleteAddress(c.Request.Context(), customerID, addressID)
+	if errors.Is(err, models.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Address not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete address"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Address deleted successfully"})
+}
@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"errors"
+	"io"
 	"net/http"
 	"strconv"
 
@@ -17,20 +19,46 @@ func NewCustomerHandler(s services.CustomerService) *CustomerHandler {
 	return &CustomerHandler{service: s}
 }
 
+// createCustomerRequest is a customer, optionally with the first order to place for them
+type createCustomerRequest struct {
+	models.Customer
+	FirstOrder *models.Order `json:"first_order"`
+}
+
+// CreateCustomer creates a customer. When the body has a first_order the
+// customer and the order are created together or not at all.
 func (h *CustomerHandler) CreateCustomer(c *gin.Context) {
-	var customer models.Customer
-	if err := c.ShouldBindJSON(&customer); err != nil {
+	var req createCustomerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
 		return
 	}
+	customer := &req.Customer
+
+	if req.FirstOrder != nil {
+		id, orderID, err := h.service.CreateCustomerWithOrder(c.Request.Context(), customer, req.FirstOrder)
+		if respondConflict(c, err) || respondOutOfStock(c, err) {
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"id": id, "code": customer.Code, "order_id": orderID})
+		return
+	}
 
-	id, err := h.service.CreateCustomer(c.Request.Context(), &customer)
+	id, err := h.service.CreateCustomer(c.Request.Context(), customer)
+	if respondConflict(c, err) {
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"id": id})
+	c.JSON(http.StatusCreated, gin.H{"id": id, "code": customer.Code})
 }
 
 func (h *CustomerHandler) GetCustomer(c *gin.Context) {
@@ -50,7 +78,13 @@ func (h *CustomerHandler) GetCustomer(c *gin.Context) {
 }
 
 func (h *CustomerHandler) GetAllCustomers(c *gin.Context) {
-	customers, err := h.service.GetAllCustomers(c.Request.Context())
+	withDeleted, err := includeDeleted(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid include_deleted value"})
+		return
+	}
+
+	customers, err := h.service.GetAllCustomers(c.Request.Context(), withDeleted)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch customers"})
 		return
@@ -75,6 +109,13 @@ func (h *CustomerHandler) UpdateCustomer(c *gin.Context) {
 	customer.ID = id
 
 	err = h.service.UpdateCustomer(c.Request.Context(), &customer)
+	if respondConflict(c, err) {
+		return
+	}
+	if errors.Is(err, models.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Customer not found"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -91,10 +132,143 @@ func (h *CustomerHandler) DeleteCustomer(c *gin.Context) {
 	}
 
 	err = h.service.DeleteCustomer(c.Request.Context(), id)
+	if errors.Is(err, models.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Customer not found"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	c.Status(http.StatusNoContent)
-}
\ No newline at end of file
+}
+
+func (h *CustomerHandler) RestoreCustomer(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid customer ID"})
+		return
+	}
+
+	err = h.service.RestoreCustomer(c.Request.Context(), id)
+	if respondConflict(c, err) || respondOutOfStock(c, err) {
+		return
+	}
+	if errors.Is(err, models.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deleted customer not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Customer restored successfully"})
+}
+
+// ExportCustomers streams customers as CSV or NDJSON (?format=csv|ndjson)
+func (h *CustomerHandler) ExportCustomers(c *gin.Context) {
+	withDeleted, err := includeDeleted(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid include_deleted value"})
+		return
+	}
+
+	format, ok := startExport(c, "customers")
+	if !ok {
+		return
+	}
+
+	if err := h.service.ExportCustomers(c.Request.Context(), c.Writer, format, withDeleted); err != nil {
+		abortExport(c, "customers", err)
+	}
+}
+
+// ImportCustomers accepts a CSV either as the raw request body or as a multipart
+// "file" field. Query parameters: dry_run=true validates without inserting,
+// mapping=customer_name=Full Name,phone=Mobile maps CSV headers to fields, and
+// report=csv downloads the rejected rows instead of returning JSON. The report
+// is stored under its id, see GetImportReport.
+func (h *CustomerHandler) ImportCustomers(c *gin.Context) {
+	dryRun, err := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dry_run value"})
+		return
+	}
+
+	mapping, err := services.ParseColumnMapping(c.Query("mapping"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var body io.Reader = c.Request.Body
+	if fileHeader, err := c.FormFile("file"); err == nil {
+		file, err := fileHeader.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Could not read uploaded file"})
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+
+	report, err := h.service.ImportCustomers(c.Request.Context(), body, services.ImportOptions{
+		Mapping: mapping,
+		DryRun:  dryRun,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	respondImportReport(c, report)
+}
+
+// GetImportReport returns the report of an earlier import, as JSON or with
+// report=csv as the rejected rows
+func (h *CustomerHandler) GetImportReport(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("import_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid import ID"})
+		return
+	}
+
+	report, err := h.service.GetImportReport(c.Request.Context(), id)
+	if errors.Is(err, models.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Import not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch import"})
+		return
+	}
+
+	respondImportReport(c, report)
+}
+
+func respondImportReport(c *gin.Context, report *services.ImportReport) {
+	if c.Query("report") == "csv" {
+		c.Header("Content-Type", "text/csv")
+		c.Header("Content-Disposition", `attachment; filename="customer-import-rejected.csv"`)
+		c.Status(http.StatusOK)
+		if err := report.WriteRejectedCSV(c.Writer); err != nil {
+			c.Error(err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// respondConflict writes a 409 naming the conflicting field and reports whether it did
+func respondConflict(c *gin.Context, err error) bool {
+	var conflict *models.ConflictError
+	if !errors.As(err, &conflict) {
+		return false
+	}
+
+	c.JSON(http.StatusConflict, gin.H{"error": conflict.Error(), "field": conflict.Field})
+	return true
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/chesireabel/Technical-Interview/internal/middleware"
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/chesireabel/Technical-Interview/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	idempotentReplayedHeader = "Idempotent-Replayed"
+)
+
+// responseRecorder keeps a copy of everything the handler writes so it can be stored for replays
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// idempotencyClient identifies who an idempotency key belongs to
+func idempotencyClient(c *gin.Context) string {
+	if actor, ok := middleware.SignedInActor(c); ok {
+		return "user:" + actor
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// Idempotent makes the wrapped route safe to retry when the client sends an
+// Idempotency-Key header. Requests without the header run normally. Keys are
+// scoped to the route and the signed in user, or to the client IP address for
+// requests without a session. Clients sharing an address without signing in
+// share keys, so they should send random ones such as UUIDs.
+//
+// A retry with the same key and body gets the original status and body back
+// without running the handler again; the same key with a different body is
+// rejected with 422. Server errors are not stored so the client can retry them.
+func Idempotent(svc services.IdempotencyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" || svc == nil {
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		scope := c.Request.Method + " " + c.FullPath() + " " + idempotencyClient(c)
+
+		stored, err := svc.Begin(c.Request.Context(), scope, key, body)
+		switch {
+		case errors.Is(err, models.ErrIdempotencyKeyReused):
+			c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, models.ErrIdempotencyInProgress):
+			c.Header("Retry-After", "1")
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		case err != nil:
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		case stored != nil:
+			c.Header(idempotentReplayedHeader, "true")
+			c.Data(stored.StatusCode, "application/json; charset=utf-8", stored.ResponseBody)
+			c.Abort()
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		// Store the outcome even if the client has gone away, that is the case retries exist for
+		ctx := context.WithoutCancel(c.Request.Context())
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError {
+			if err := svc.Release(ctx, scope, key); err != nil {
+				log.Printf("⚠️ Failed to release idempotency key %q: %v", key, err)
+			}
+			return
+		}
+		if err := svc.Complete(ctx, scope, key, status, recorder.body.Bytes()); err != nil {
+			log.Printf("⚠️ Failed to store response for idempotency key %q: %v", key, err)
+		}
+	}
+}
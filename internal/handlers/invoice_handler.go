@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/chesireabel/Technical-Interview/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type InvoiceHandler struct {
+	service services.InvoiceService
+}
+
+func NewInvoiceHandler(s services.InvoiceService) *InvoiceHandler {
+	return &InvoiceHandler{service: s}
+}
+
+// GetInvoicePDF downloads the order's invoice, issuing it on the first request
+func (h *InvoiceHandler) GetInvoicePDF(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	reference, pdf, err := h.service.InvoicePDF(c.Request.Context(), id)
+	if errors.Is(err, models.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if errors.Is(err, models.ErrNotInvoiceable) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`inline; filename="%s.pdf"`, reference))
+	c.Data(http.StatusOK, "application/pdf", pdf)
+}
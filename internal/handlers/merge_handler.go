@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/chesireabel/Technical-Interview/internal/middleware"
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/chesireabel/Technical-Interview/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type MergeHandler struct {
+	service services.MergeService
+}
+
+func NewMergeHandler(s services.MergeService) *MergeHandler {
+	return &MergeHandler{service: s}
+}
+
+// FindDuplicates lists pairs of customers that are probably the same person,
+// best first. ?min_score= (0 to 1, default 0.6) sets how alike they must be.
+func (h *MergeHandler) FindDuplicates(c *gin.Context) {
+	minScore := 0.0
+	if raw := c.Query("min_score"); raw != "" {
+		var err error
+		if minScore, err = strconv.ParseFloat(raw, 64); err != nil || minScore < 0 || minScore > 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid min_score"})
+			return
+		}
+	}
+
+	pairs, err := h.service.FindDuplicates(c.Request.Context(), minScore)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find duplicate customers"})
+		return
+	}
+
+	c.JSON(http.StatusOK, pairs)
+}
+
+// MergeCustomer merges a duplicate into the customer in the path, e.g.
+// {"duplicate_id": 17}. The duplicate's orders, notes, addresses and
+// subscriptions move across and the duplicate is deleted. The merge record
+// lists the orders that moved and which of them were invoiced.
+func (h *MergeHandler) MergeCustomer(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid customer ID"})
+		return
+	}
+
+	var req struct {
+		DuplicateID int64 `json:"duplicate_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	merge, err := h.service.MergeCustomers(c.Request.Context(), id, req.DuplicateID, middleware.CurrentActor(c))
+	if errors.Is(err, models.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Customer not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, merge)
+}
+
+// ListMerges returns the merges the customer took part in, as winner or loser
+func (h *MergeHandler) ListMerges(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid customer ID"})
+		return
+	}
+
+	merges, err := h.service.ListMerges(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch merges"})
+		return
+	}
+
+	c.JSON(http.StatusOK, merges)
+}
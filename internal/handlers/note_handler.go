@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/chesireabel/Technical-Interview/internal/middleware"
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/chesireabel/Technical-Interview/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type NoteHandler struct {
+	service  services.NoteService
+	timeline services.TimelineService
+}
+
+func NewNoteHandler(s services.NoteService, timeline services.TimelineService) *NoteHandler {
+	return &NoteHandler{service: s, timeline: timeline}
+}
+
+// noteRequest is the editable part of a note; the author is always the caller
+type noteRequest struct {
+	Body   string `json:"body"`
+	Pinned bool   `json:"pinned"`
+}
+
+// noteAuthor is the signed-in caller, writing a 401 when there is none, since
+// every note change is attributed to the person who made it
+func noteAuthor(c *gin.Context) (string, bool) {
+	author, ok := middleware.SignedInActor(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Sign in to change notes"})
+	}
+	return author, ok
+}
+
+// noteIDs reads the customer and note IDs from the path, writing a 400 when either is invalid
+func noteIDs(c *gin.Context) (customerID, noteID int64, ok bool) {
+	customerID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid customer ID"})
+		return 0, 0, false
+	}
+	if c.Param("note_id") == "" {
+		return customerID, 0, true
+	}
+	noteID, err = strconv.ParseInt(c.Param("note_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid note ID"})
+		return 0, 0, false
+	}
+	return customerID, noteID, true
+}
+
+// CreateNote records a note against the customer, e.g.
+// {"body": "Called about a late delivery, promised a callback by Friday", "pinned": false}
+func (h *NoteHandler) CreateNote(c *gin.Context) {
+	author, ok := noteAuthor(c)
+	if !ok {
+		return
+	}
+	customerID, _, ok := noteIDs(c)
+	if !ok {
+		return
+	}
+
+	var req noteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	note := models.CustomerNote{
+		CustomerID: customerID,
+		Author:     author,
+		Body:       req.Body,
+		Pinned:     req.Pinned,
+	}
+	err := h.service.CreateNote(c.Request.Context(), &note)
+	if errors.Is(err, models.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Customer not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, note)
+}
+
+// ListNotes returns the customer's notes, pinned first
+func (h *NoteHandler) ListNotes(c *gin.Context) {
+	customerID, _, ok := noteIDs(c)
+	if !ok {
+		return
+	}
+
+	notes, err := h.service.ListNotes(c.Request.Context(), customerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, notes)
+}
+
+func (h *NoteHandler) GetNote(c *gin.Context) {
+	customerID, noteID, ok := noteIDs(c)
+	if !ok {
+		return
+	}
+
+	note, err := h.service.GetNote(c.Request.Context(), customerID, noteID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, note)
+}
+
+// UpdateNote replaces the note's body and pinned flag, keeping its author
+func (h *NoteHandler) UpdateNote(c *gin.Context) {
+	if _, ok := noteAuthor(c); !ok {
+		return
+	}
+	customerID, noteID, ok := noteIDs(c)
+	if !ok {
+		return
+	}
+
+	var req noteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	note := models.CustomerNote{
+		ID:         noteID,
+		CustomerID: customerID,
+		Body:       req.Body,
+		Pinned:     req.Pinned,
+	}
+	err := h.service.UpdateNote(c.Request.Context(), &note)
+	if errors.Is(err, models.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, note)
+}
+
+func (h *NoteHandler) DeleteNote(c *gin.Context) {
+	if _, ok := noteAuthor(c); !ok {
+		return
+	}
+	customerID, noteID, ok := noteIDs(c)
+	if !ok {
+		return
+	}
+
+	err := h.service.DeleteNote(c.Request.Context(), customerID, noteID)
+	if errors.Is(err, models.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete note"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Note deleted successfully"})
+}
+
+// GetTimeline returns the customer's notes, orders, status changes and SMS
+// messages newest first. Pass the response's next_cursor as ?before= to get
+// the next page; ?limit= sets the page size (default 50, at most 200).
+func (h *NoteHandler) GetTimeline(c *gin.Context) {
+	customerID, _, ok := noteIDs(c)
+	if !ok {
+		return
+	}
+
+	limit := 0
+	if raw := c.Query("limit"); raw != "" {
+		var err error
+		if limit, err = strconv.Atoi(raw); err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+	}
+
+	page, err := h.timeline.GetTimeline(c.Request.Context(), customerID, c.Query("before"), limit)
+	if errors.Is(err, models.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Customer not found"})
+		return
+	}
+	if errors.Is(err, services.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch timeline"})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
@@ -2,9 +2,12 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/chesireabel/Technical-Interview/internal/middleware"
 	"github.com/chesireabel/Technical-Interview/internal/models"
 	"github.com/chesireabel/Technical-Interview/internal/services"
 	"github.com/gin-gonic/gin"
@@ -26,6 +29,9 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 	}
 
 	id, err := h.service.CreateOrder(c.Request.Context(), &order)
+	if respondOutOfStock(c, err) {
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -60,7 +66,13 @@ func (h *OrderHandler) GetOrdersByCustomer(c *gin.Context) {
 		return
 	}
 
-	orders, err := h.service.GetOrdersByCustomer(c.Request.Context(), customerID)
+	withDeleted, err := includeDeleted(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid include_deleted value"})
+		return
+	}
+
+	orders, err := h.service.GetOrdersByCustomer(c.Request.Context(), customerID, withDeleted)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -70,7 +82,13 @@ func (h *OrderHandler) GetOrdersByCustomer(c *gin.Context) {
 }
 
 func (h *OrderHandler) GetAllOrders(c *gin.Context) {
-	orders, err := h.service.GetAllOrders(c.Request.Context())
+	withDeleted, err := includeDeleted(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid include_deleted value"})
+		return
+	}
+
+	orders, err := h.service.GetAllOrders(c.Request.Context(), withDeleted)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch orders"})
 		return
@@ -79,6 +97,34 @@ func (h *OrderHandler) GetAllOrders(c *gin.Context) {
 	c.JSON(http.StatusOK, orders)
 }
 
+// ExportOrders streams orders as CSV or NDJSON (?format=csv|ndjson), optionally
+// limited to one customer with ?customer_id=
+func (h *OrderHandler) ExportOrders(c *gin.Context) {
+	withDeleted, err := includeDeleted(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid include_deleted value"})
+		return
+	}
+
+	var customerID int64
+	if raw := c.Query("customer_id"); raw != "" {
+		customerID, err = strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid customer ID"})
+			return
+		}
+	}
+
+	format, ok := startExport(c, "orders")
+	if !ok {
+		return
+	}
+
+	if err := h.service.ExportOrders(c.Request.Context(), c.Writer, format, customerID, withDeleted); err != nil {
+		abortExport(c, "orders", err)
+	}
+}
+
 func (h *OrderHandler) UpdateOrder(c *gin.Context) {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
@@ -95,6 +141,17 @@ func (h *OrderHandler) UpdateOrder(c *gin.Context) {
 	order.ID = id
 
 	err = h.service.UpdateOrder(c.Request.Context(), &order)
+	if errors.Is(err, models.ErrNotEditable) || errors.Is(err, models.ErrPaymentInProgress) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, models.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if respondOutOfStock(c, err) {
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -111,10 +168,198 @@ func (h *OrderHandler) DeleteOrder(c *gin.Context) {
 	}
 
 	err = h.service.DeleteOrder(c.Request.Context(), id)
+	if errors.Is(err, models.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	c.Status(http.StatusNoContent)
-}
\ No newline at end of file
+}
+
+func (h *OrderHandler) RestoreOrder(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	err = h.service.RestoreOrder(c.Request.Context(), id)
+	if respondOutOfStock(c, err) {
+		return
+	}
+	if errors.Is(err, models.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deleted order not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Order restored successfully"})
+}
+
+type transitionRequest struct {
+	Status models.OrderStatus `json:"status" binding:"required"`
+	Reason string             `json:"reason"`
+}
+
+// TransitionOrder moves an order along its lifecycle, e.g. {"status": "shipped"}.
+// Orders are marked paid by their payment and cancelled through CancelOrder.
+func (h *OrderHandler) TransitionOrder(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	var req transitionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	order, err := h.service.TransitionOrder(c.Request.Context(), id, req.Status, middleware.CurrentActor(c), req.Reason)
+	if respondTransitionError(c, err) {
+		return
+	}
+	if errors.Is(err, models.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+type cancelRequest struct {
+	Reason string `json:"reason"`
+	Refund bool   `json:"refund"`
+}
+
+// CancelOrder cancels an order with a reason, e.g. {"reason": "out of stock", "refund": true}.
+// With refund set, everything not yet refunded is refunded and the refund is returned.
+func (h *OrderHandler) CancelOrder(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	var req cancelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	order, refund, err := h.service.CancelOrder(c.Request.Context(), id, middleware.CurrentActor(c), req.Reason, req.Refund)
+	if respondTransitionError(c, err) {
+		return
+	}
+	if errors.Is(err, models.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"order": order, "refund": refund})
+}
+
+type rescheduleRequest struct {
+	ScheduledFor time.Time `json:"scheduled_for"`
+}
+
+// RescheduleOrder moves a scheduled order that has not fired yet, e.g.
+// {"scheduled_for": "2026-11-02T08:00:00+03:00"}. Use CancelOrder to call it off.
+func (h *OrderHandler) RescheduleOrder(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	var req rescheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	order, err := h.service.RescheduleOrder(c.Request.Context(), id, req.ScheduledFor)
+	if errors.Is(err, models.ErrNotScheduled) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, models.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+func (h *OrderHandler) GetOrderStatusHistory(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	history, err := h.service.GetOrderStatusHistory(c.Request.Context(), id)
+	if errors.Is(err, models.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+// respondTransitionError writes a 409 with the statuses the order may move to
+// and reports whether it did
+func respondTransitionError(c *gin.Context, err error) bool {
+	var transitionErr *models.TransitionError
+	if !errors.As(err, &transitionErr) {
+		return false
+	}
+
+	c.JSON(http.StatusConflict, gin.H{
+		"error":   transitionErr.Error(),
+		"status":  transitionErr.From,
+		"allowed": transitionErr.Allowed,
+	})
+	return true
+}
+
+// respondOutOfStock writes a 409 naming the product that could not be reserved
+// and reports whether it did
+func respondOutOfStock(c *gin.Context, err error) bool {
+	var stockErr *models.StockError
+	if !errors.As(err, &stockErr) {
+		return false
+	}
+
+	c.JSON(http.StatusConflict, gin.H{
+		"error":      stockErr.Error(),
+		"product_id": stockErr.ProductID,
+		"requested":  stockErr.Requested,
+		"available":  stockErr.Available,
+	})
+	return true
+}
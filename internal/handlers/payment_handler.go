@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/chesireabel/Technical-Interview/internal/payments"
+	"github.com/chesireabel/Technical-Interview/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type PaymentHandler struct {
+	service services.PaymentService
+}
+
+func NewPaymentHandler(s services.PaymentService) *PaymentHandler {
+	return &PaymentHandler{service: s}
+}
+
+type startPaymentRequest struct {
+	Provider string `json:"provider"`
+	Phone    string `json:"phone"`
+}
+
+// StartPayment starts collecting the order's amount, e.g.
+// {"provider": "mpesa", "phone": "0712345678"}. The provider defaults to
+// M-Pesa and the phone to the customer's number. The payment completes when
+// the provider calls back, so the response is 202 with the pending payment.
+func (h *PaymentHandler) StartPayment(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	var req startPaymentRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+	}
+
+	payment, err := h.service.StartPayment(c.Request.Context(), id, req.Provider, req.Phone)
+	if errors.Is(err, models.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if errors.Is(err, models.ErrNotPayable) || errors.Is(err, models.ErrPaymentInProgress) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, models.ErrPaymentProvider) {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, payment)
+}
+
+func (h *PaymentHandler) ListPayments(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	payments, err := h.service.ListPayments(c.Request.Context(), id)
+	if errors.Is(err, models.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, payments)
+}
+
+// Callback receives payment results from the provider named in the URL, e.g.
+// /payments/mpesa/callback. It is not behind login; each provider verifies
+// its own webhooks. Responses use Daraja's acknowledgement format, which
+// other providers only look at for the status code.
+func (h *PaymentHandler) Callback(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"ResultCode": 1, "ResultDesc": "Could not read body"})
+		return
+	}
+
+	hook := payments.Webhook{Header: c.Request.Header, Query: c.Request.URL.Query(), Body: body}
+	err = h.service.HandleWebhook(c.Request.Context(), c.Param("provider"), hook)
+	if errors.Is(err, payments.ErrUnknownProvider) {
+		c.JSON(http.StatusNotFound, gin.H{"ResultCode": 1, "ResultDesc": "Unknown provider"})
+		return
+	}
+	if errors.Is(err, payments.ErrInvalidSignature) {
+		c.JSON(http.StatusUnauthorized, gin.H{"ResultCode": 1, "ResultDesc": "Rejected"})
+		return
+	}
+	if errors.Is(err, models.ErrNotFound) {
+		log.Printf("%s callback for unknown payment: %v", c.Param("provider"), err)
+		c.JSON(http.StatusNotFound, gin.H{"ResultCode": 1, "ResultDesc": "Unknown payment"})
+		return
+	}
+	if err != nil {
+		log.Printf("%s callback failed: %v", c.Param("provider"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"ResultCode": 1, "ResultDesc": "Failed"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ResultCode": 0, "ResultDesc": "Accepted"})
+}
+
+// ListMismatches lists payments reconciliation found disagreeing with their
+// provider. ?include_resolved=true adds the ones already dealt with.
+func (h *PaymentHandler) ListMismatches(c *gin.Context) {
+	includeResolved, err := strconv.ParseBool(c.DefaultQuery("include_resolved", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid include_resolved value"})
+		return
+	}
+
+	mismatches, err := h.service.ListMismatches(c.Request.Context(), includeResolved)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, mismatches)
+}
+
+func (h *PaymentHandler) ResolveMismatch(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid mismatch ID"})
+		return
+	}
+
+	err = h.service.ResolveMismatch(c.Request.Context(), id)
+	if errors.Is(err, models.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Open mismatch not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Mismatch resolved"})
+}
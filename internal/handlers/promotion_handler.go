@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/chesireabel/Technical-Interview/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type PromotionHandler struct {
+	service services.PromotionService
+}
+
+func NewPromotionHandler(s services.PromotionService) *PromotionHandler {
+	return &PromotionHandler{service: s}
+}
+
+// CreatePromotion creates a discount code, e.g.
+// {"code": "JAMHURI", "kind": "percentage", "value": "10", "ends_at": "2026-12-13T00:00:00+03:00"}
+func (h *PromotionHandler) CreatePromotion(c *gin.Context) {
+	var promotion models.Promotion
+	if err := c.ShouldBindJSON(&promotion); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	id, err := h.service.CreatePromotion(c.Request.Context(), &promotion)
+	if respondConflict(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"id": id, "code": promotion.Code})
+}
+
+func (h *PromotionHandler) GetPromotion(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid promotion ID"})
+		return
+	}
+
+	promotion, err := h.service.GetPromotion(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Promotion not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, promotion)
+}
+
+// ListPromotions returns active promotions; ?include_inactive=true adds deactivated ones
+func (h *PromotionHandler) ListPromotions(c *gin.Context) {
+	includeInactive, err := strconv.ParseBool(c.DefaultQuery("include_inactive", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid include_inactive value"})
+		return
+	}
+
+	promotions, err := h.service.ListPromotions(c.Request.Context(), includeInactive)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch promotions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, promotions)
+}
+
+// DeletePromotion deactivates the code; orders already placed with it keep their discount
+func (h *PromotionHandler) DeletePromotion(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid promotion ID"})
+		return
+	}
+
+	err = h.service.DeactivatePromotion(c.Request.Context(), id)
+	if errors.Is(err, models.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Promotion not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
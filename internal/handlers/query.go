@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/chesireabel/Technical-Interview/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// includeDeleted reads the admin ?include_deleted=true flag that adds soft deleted records to list responses
+func includeDeleted(c *gin.Context) (bool, error) {
+	value := c.Query("include_deleted")
+	if value == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(value)
+}
+
+// startExport validates ?format= and writes the download headers for an export.
+// It returns false after responding with 400 when the format is not supported.
+func startExport(c *gin.Context, name string) (services.ExportFormat, bool) {
+	format, err := services.ParseExportFormat(c.Query("format"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return "", false
+	}
+
+	filename := fmt.Sprintf("%s-%s.%s", name, time.Now().UTC().Format("20060102-150405"), format.Extension())
+	c.Header("Content-Type", format.ContentType())
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Status(http.StatusOK)
+	return format, true
+}
+
+// abortExport logs a failure that happened after the response started streaming.
+// The status line is already sent, so the truncated body is all the client sees.
+func abortExport(c *gin.Context, name string, err error) {
+	log.Printf("⚠️ %s export failed after %d bytes: %v", name, c.Writer.Size(), err)
+	c.Abort()
+}
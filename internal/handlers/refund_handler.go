@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/chesireabel/Technical-Interview/internal/middleware"
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/chesireabel/Technical-Interview/internal/payments"
+	"github.com/chesireabel/Technical-Interview/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+type RefundHandler struct {
+	service services.RefundService
+	payer   services.RefundPayer
+}
+
+func NewRefundHandler(s services.RefundService, payer services.RefundPayer) *RefundHandler {
+	return &RefundHandler{service: s, payer: payer}
+}
+
+type refundRequest struct {
+	Amount decimal.Decimal `json:"amount"`
+	Reason string          `json:"reason"`
+}
+
+// RefundOrder refunds part of an order, e.g. {"amount": "250.00", "reason": "damaged"}.
+// Leaving out the amount refunds everything that has not been refunded yet.
+func (h *RefundHandler) RefundOrder(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	var req refundRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	refund := &models.Refund{
+		OrderID:   id,
+		Amount:    req.Amount,
+		Reason:    req.Reason,
+		CreatedBy: middleware.CurrentActor(c),
+	}
+	err = h.service.RefundOrder(c.Request.Context(), refund)
+	var refundErr *models.RefundError
+	if errors.As(err, &refundErr) {
+		c.JSON(http.StatusConflict, gin.H{"error": refundErr.Error(), "refundable": refundErr.Refundable})
+		return
+	}
+	if errors.Is(err, models.ErrPartialRefund) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, models.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, refund)
+}
+
+func (h *RefundHandler) ListRefunds(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	refunds, err := h.service.ListRefunds(c.Request.Context(), id)
+	if errors.Is(err, models.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, refunds)
+}
+
+// PayoutResult receives the result of a refund payout from the provider named
+// in the URL, e.g. /payments/mpesa/refund-result, which is where
+// MPESA_RESULT_URL should point. Like the payment callback it is not behind
+// login and answers in Daraja's acknowledgement format.
+func (h *RefundHandler) PayoutResult(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"ResultCode": 1, "ResultDesc": "Could not read body"})
+		return
+	}
+
+	hook := payments.Webhook{Header: c.Request.Header, Query: c.Request.URL.Query(), Body: body}
+	err = h.payer.HandlePayoutWebhook(c.Request.Context(), c.Param("provider"), hook)
+	if errors.Is(err, payments.ErrUnknownProvider) {
+		c.JSON(http.StatusNotFound, gin.H{"ResultCode": 1, "ResultDesc": "Unknown provider"})
+		return
+	}
+	if errors.Is(err, payments.ErrInvalidSignature) {
+		c.JSON(http.StatusUnauthorized, gin.H{"ResultCode": 1, "ResultDesc": "Rejected"})
+		return
+	}
+	if errors.Is(err, models.ErrNotFound) {
+		log.Printf("%s refund result for unknown payout: %v", c.Param("provider"), err)
+		c.JSON(http.StatusNotFound, gin.H{"ResultCode": 1, "ResultDesc": "Unknown payout"})
+		return
+	}
+	if err != nil {
+		log.Printf("%s refund result failed: %v", c.Param("provider"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"ResultCode": 1, "ResultDesc": "Failed"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ResultCode": 0, "ResultDesc": "Accepted"})
+}
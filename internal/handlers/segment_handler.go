@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/chesireabel/Technical-Interview/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type SegmentHandler struct {
+	service services.SegmentService
+}
+
+func NewSegmentHandler(s services.SegmentService) *SegmentHandler {
+	return &SegmentHandler{service: s}
+}
+
+// CreateSegment saves a segment and fills in its members, e.g.
+// {"name": "Nairobi big spenders", "rule": "town = \"Nairobi\" AND order_total(30d) > 5000"}
+func (h *SegmentHandler) CreateSegment(c *gin.Context) {
+	var segment models.Segment
+	if err := c.ShouldBindJSON(&segment); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	_, err := h.service.CreateSegment(c.Request.Context(), &segment)
+	if respondConflict(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, segment)
+}
+
+func (h *SegmentHandler) ListSegments(c *gin.Context) {
+	list, err := h.service.ListSegments(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch segments"})
+		return
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+func (h *SegmentHandler) GetSegment(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid segment ID"})
+		return
+	}
+
+	segment, err := h.service.GetSegment(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Segment not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, segment)
+}
+
+func (h *SegmentHandler) UpdateSegment(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid segment ID"})
+		return
+	}
+
+	var segment models.Segment
+	if err := c.ShouldBindJSON(&segment); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	segment.ID = id
+
+	err = h.service.UpdateSegment(c.Request.Context(), &segment)
+	if respondConflict(c, err) {
+		return
+	}
+	if errors.Is(err, models.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Segment not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, segment)
+}
+
+func (h *SegmentHandler) DeleteSegment(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid segment ID"})
+		return
+	}
+
+	err = h.service.DeleteSegment(c.Request.Context(), id)
+	if errors.Is(err, models.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Segment not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete segment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Segment deleted successfully"})
+}
+
+// RefreshSegment recomputes the segment's members now rather than waiting for the refresh job
+func (h *SegmentHandler) RefreshSegment(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid segment ID"})
+		return
+	}
+
+	segment, err := h.service.RefreshSegment(c.Request.Context(), id)
+	if errors.Is(err, models.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Segment not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh segment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, segment)
+}
+
+// ListMembers returns the customers in the segment as of its last refresh
+func (h *SegmentHandler) ListMembers(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid segment ID"})
+		return
+	}
+
+	customers, err := h.service.ListMembers(c.Request.Context(), id)
+	if errors.Is(err, models.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Segment not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch segment members"})
+		return
+	}
+
+	c.JSON(http.StatusOK, customers)
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/chesireabel/Technical-Interview/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type SubscriptionHandler struct {
+	service services.SubscriptionService
+}
+
+func NewSubscriptionHandler(s services.SubscriptionService) *SubscriptionHandler {
+	return &SubscriptionHandler{service: s}
+}
+
+// CreateSubscription sets up a recurring order, e.g.
+// {"customer_id": 4, "schedule": "0 8 * * MON", "items": [{"product_id": 2, "quantity": 10}]}.
+// schedule may also be an interval such as "@every 168h".
+func (h *SubscriptionHandler) CreateSubscription(c *gin.Context) {
+	var subscription models.Subscription
+	if err := c.ShouldBindJSON(&subscription); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	id, err := h.service.CreateSubscription(c.Request.Context(), &subscription)
+	if errors.Is(err, models.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"id": id, "schedule": subscription.Schedule, "next_run_at": subscription.NextRunAt})
+}
+
+func (h *SubscriptionHandler) GetSubscription(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid subscription ID"})
+		return
+	}
+
+	subscription, err := h.service.GetSubscription(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Subscription not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, subscription)
+}
+
+// ListSubscriptions returns all subscriptions; ?customer_id= limits them to one customer
+func (h *SubscriptionHandler) ListSubscriptions(c *gin.Context) {
+	var customerID int64
+	if v := c.Query("customer_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid customer_id value"})
+			return
+		}
+		customerID = id
+	}
+
+	subscriptions, err := h.service.ListSubscriptions(c.Request.Context(), customerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch subscriptions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, subscriptions)
+}
+
+func (h *SubscriptionHandler) PauseSubscription(c *gin.Context) {
+	h.setStatus(c, h.service.PauseSubscription)
+}
+
+func (h *SubscriptionHandler) ResumeSubscription(c *gin.Context) {
+	h.setStatus(c, h.service.ResumeSubscription)
+}
+
+func (h *SubscriptionHandler) CancelSubscription(c *gin.Context) {
+	h.setStatus(c, h.service.CancelSubscription)
+}
+
+func (h *SubscriptionHandler) setStatus(c *gin.Context, change func(ctx context.Context, id int64) (*models.Subscription, error)) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid subscription ID"})
+		return
+	}
+
+	subscription, err := change(c.Request.Context(), id)
+	if errors.Is(err, models.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Subscription not found"})
+		return
+	}
+	if errors.Is(err, models.ErrSubscriptionCancelled) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, subscription)
+}
+
+// ListRuns returns the subscription's runs, newest first, with the order each placed or why it failed
+func (h *SubscriptionHandler) ListRuns(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid subscription ID"})
+		return
+	}
+
+	runs, err := h.service.ListRuns(c.Request.Context(), id)
+	if errors.Is(err, models.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Subscription not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch subscription runs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, runs)
+}
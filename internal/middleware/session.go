@@ -43,3 +43,25 @@ type SessionInfo struct {
 	UserPicture   string    `json:"user_picture,omitempty"`
 	ExpiresAt     time.Time `json:"expires_at,omitempty"`
 }
+
+// CurrentActor identifies the signed-in user for audit records, preferring their
+// email over the OIDC subject. Requests without a session are attributed to "anonymous".
+func CurrentActor(c *gin.Context) string {
+	if actor, ok := SignedInActor(c); ok {
+		return actor
+	}
+	return "anonymous"
+}
+
+// SignedInActor is CurrentActor for records that must name a real user: it
+// reports false instead of falling back to "anonymous" when there is no session
+func SignedInActor(c *gin.Context) (string, bool) {
+	session := sessions.Default(c)
+	if email, ok := session.Get("user_email").(string); ok && email != "" {
+		return email, true
+	}
+	if sub, ok := session.Get("user_sub").(string); ok && sub != "" {
+		return sub, true
+	}
+	return "", false
+}
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_orders_deleted_at;
+DROP INDEX IF EXISTS idx_customers_deleted_at;
+
+ALTER TABLE orders
+DROP COLUMN IF EXISTS deleted_at;
+
+ALTER TABLE customers
+DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,6 @@
+DROP SEQUENCE IF EXISTS customer_code_seq;
+
+DROP INDEX IF EXISTS idx_customers_email_lower;
+
+ALTER TABLE customers
+ALTER COLUMN email TYPE VARCHAR(30);
@@ -0,0 +1,33 @@
+-- The unique index cannot be built while live customers share an email
+-- in different cases. Fail with the list of them rather than a bare
+-- unique violation; fix them (change the email, or soft delete the extra
+-- customer by setting deleted_at) and run the migration again.
+DO $$
+DECLARE
+    duplicates TEXT;
+BEGIN
+    SELECT string_agg(format('%s (customer ids %s)', email, ids), '; ')
+    INTO duplicates
+    FROM (
+        SELECT LOWER(email) AS email, string_agg(id::text, ', ' ORDER BY id) AS ids
+        FROM customers
+        WHERE deleted_at IS NULL
+        GROUP BY LOWER(email)
+        HAVING COUNT(*) > 1
+    ) d;
+
+    IF duplicates IS NOT NULL THEN
+        RAISE EXCEPTION 'cannot make customer emails unique, these are used by more than one customer: %', duplicates
+            USING HINT = 'Change the email of, or soft delete, all but one customer in each group, then rerun the migration.';
+    END IF;
+END
+$$;
+
+ALTER TABLE customers
+ALTER COLUMN email TYPE VARCHAR(255);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_customers_email_lower
+ON customers (LOWER(email))
+WHERE deleted_at IS NULL;
+
+CREATE SEQUENCE IF NOT EXISTS customer_code_seq;
@@ -0,0 +1,2 @@
+ALTER TABLE customers
+DROP COLUMN IF EXISTS country_code;
@@ -0,0 +1,2 @@
+ALTER TABLE customers
+ADD COLUMN IF NOT EXISTS country_code VARCHAR(2);
@@ -0,0 +1,9 @@
+ALTER TABLE order_items
+ALTER COLUMN unit_price TYPE DECIMAL(10,2),
+ALTER COLUMN line_total TYPE DECIMAL(12,2);
+
+ALTER TABLE orders
+ALTER COLUMN amount TYPE DECIMAL(10,2);
+
+ALTER TABLE orders
+DROP COLUMN IF EXISTS currency;
@@ -0,0 +1,9 @@
+ALTER TABLE orders
+ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'KES';
+
+ALTER TABLE orders
+ALTER COLUMN amount TYPE DECIMAL(14,2);
+
+ALTER TABLE order_items
+ALTER COLUMN unit_price TYPE DECIMAL(14,2),
+ALTER COLUMN line_total TYPE DECIMAL(14,2);
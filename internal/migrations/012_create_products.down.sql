@@ -0,0 +1,4 @@
+ALTER TABLE order_items
+DROP COLUMN IF EXISTS product_id;
+
+DROP TABLE IF EXISTS products;
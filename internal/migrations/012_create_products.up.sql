@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS products (
+    id SERIAL PRIMARY KEY,
+    sku VARCHAR(64) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    price DECIMAL(14,2) NOT NULL CHECK (price >= 0),
+    currency CHAR(3) NOT NULL DEFAULT 'KES',
+    active BOOLEAN NOT NULL DEFAULT true,
+    categories TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_products_sku_upper ON products (UPPER(sku));
+CREATE INDEX IF NOT EXISTS idx_products_categories ON products USING GIN (categories);
+
+ALTER TABLE order_items
+ADD COLUMN IF NOT EXISTS product_id INT REFERENCES products(id);
+
+CREATE INDEX IF NOT EXISTS idx_order_items_product_id ON order_items (product_id);
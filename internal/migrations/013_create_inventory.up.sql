@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS stock_levels (
+    product_id INT NOT NULL REFERENCES products(id),
+    location VARCHAR(50) NOT NULL,
+    on_hand INT NOT NULL DEFAULT 0 CHECK (on_hand >= 0),
+    reserved INT NOT NULL DEFAULT 0 CHECK (reserved >= 0),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    PRIMARY KEY (product_id, location),
+    CONSTRAINT stock_levels_reserved_within_on_hand CHECK (reserved <= on_hand)
+);
+
+CREATE TABLE IF NOT EXISTS stock_movements (
+    id BIGSERIAL PRIMARY KEY,
+    product_id INT NOT NULL REFERENCES products(id),
+    location VARCHAR(50) NOT NULL,
+    order_id INT REFERENCES orders(id) ON DELETE SET NULL,
+    kind VARCHAR(20) NOT NULL
+        CHECK (kind IN ('receipt', 'adjustment', 'reservation', 'release', 'shipment')),
+    quantity INT NOT NULL,
+    note TEXT,
+    created_by VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_movements_product_id ON stock_movements (product_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_stock_movements_order_id ON stock_movements (order_id) WHERE order_id IS NOT NULL;
@@ -0,0 +1,7 @@
+ALTER TABLE orders
+DROP COLUMN IF EXISTS promo_code,
+DROP COLUMN IF EXISTS discount,
+DROP COLUMN IF EXISTS subtotal;
+
+DROP TABLE IF EXISTS promotion_redemptions;
+DROP TABLE IF EXISTS promotions;
@@ -0,0 +1,41 @@
+CREATE TABLE IF NOT EXISTS promotions (
+    id SERIAL PRIMARY KEY,
+    code VARCHAR(50) NOT NULL,
+    description TEXT,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('percentage', 'fixed')),
+    value DECIMAL(14,2) NOT NULL CHECK (value > 0),
+    currency CHAR(3),
+    min_order_amount DECIMAL(14,2) NOT NULL DEFAULT 0,
+    starts_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    ends_at TIMESTAMP WITH TIME ZONE,
+    max_uses INT CHECK (max_uses > 0),
+    max_uses_per_customer INT CHECK (max_uses_per_customer > 0),
+    uses INT NOT NULL DEFAULT 0,
+    active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    CHECK (kind <> 'percentage' OR value <= 100),
+    CHECK ((kind <> 'fixed' AND min_order_amount = 0) OR currency IS NOT NULL)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_promotions_code_upper ON promotions (UPPER(code));
+
+CREATE TABLE IF NOT EXISTS promotion_redemptions (
+    id SERIAL PRIMARY KEY,
+    promotion_id INT NOT NULL REFERENCES promotions(id),
+    order_id INT NOT NULL UNIQUE REFERENCES orders(id) ON DELETE CASCADE,
+    customer_id INT NOT NULL,
+    discount DECIMAL(14,2) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_promotion_redemptions_customer ON promotion_redemptions (promotion_id, customer_id);
+
+ALTER TABLE orders
+ADD COLUMN IF NOT EXISTS subtotal DECIMAL(14,2),
+ADD COLUMN IF NOT EXISTS discount DECIMAL(14,2) NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS promo_code VARCHAR(50);
+
+UPDATE orders SET subtotal = amount WHERE subtotal IS NULL;
+
+ALTER TABLE orders
+ALTER COLUMN subtotal SET NOT NULL;
@@ -0,0 +1,12 @@
+ALTER TABLE order_items
+DROP COLUMN IF EXISTS tax,
+DROP COLUMN IF EXISTS tax_inclusive,
+DROP COLUMN IF EXISTS tax_rate,
+DROP COLUMN IF EXISTS tax_name,
+DROP COLUMN IF EXISTS discount;
+
+ALTER TABLE orders
+DROP COLUMN IF EXISTS tax_region,
+DROP COLUMN IF EXISTS tax;
+
+DROP TABLE IF EXISTS tax_rates;
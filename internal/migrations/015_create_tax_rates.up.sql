@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS tax_rates (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(50) NOT NULL DEFAULT 'VAT',
+    region CHAR(2) NOT NULL,
+    category VARCHAR(100),
+    rate DECIMAL(6,3) NOT NULL CHECK (rate >= 0 AND rate <= 100),
+    inclusive BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+-- One standard rate per region (category NULL) and one rate per category
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tax_rates_region_category ON tax_rates (region, COALESCE(category, ''));
+
+ALTER TABLE orders
+ADD COLUMN IF NOT EXISTS tax DECIMAL(14,2) NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS tax_region CHAR(2);
+
+ALTER TABLE order_items
+ADD COLUMN IF NOT EXISTS discount DECIMAL(14,2) NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS tax_name VARCHAR(50),
+ADD COLUMN IF NOT EXISTS tax_rate DECIMAL(6,3) NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS tax_inclusive BOOLEAN NOT NULL DEFAULT false,
+ADD COLUMN IF NOT EXISTS tax DECIMAL(14,2) NOT NULL DEFAULT 0;
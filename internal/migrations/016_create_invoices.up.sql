@@ -0,0 +1,16 @@
+-- Invoice numbers must have no gaps, which a SEQUENCE cannot promise because
+-- nextval() is not rolled back. The single counter row is incremented in the
+-- same transaction that inserts the invoice, so a rollback returns the number.
+CREATE TABLE IF NOT EXISTS invoice_counter (
+    id BOOLEAN PRIMARY KEY DEFAULT true CHECK (id),
+    last_number BIGINT NOT NULL DEFAULT 0
+);
+
+INSERT INTO invoice_counter (id, last_number) VALUES (true, 0) ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS invoices (
+    id SERIAL PRIMARY KEY,
+    number BIGINT NOT NULL UNIQUE,
+    order_id INT NOT NULL UNIQUE REFERENCES orders(id),
+    issued_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS payments;
+
+UPDATE orders SET status = 'confirmed' WHERE status = 'paid';
+ALTER TABLE orders DROP CONSTRAINT IF EXISTS orders_status_check;
+ALTER TABLE orders ADD CONSTRAINT orders_status_check
+CHECK (status IN ('pending', 'confirmed', 'shipped', 'delivered', 'cancelled'));
@@ -0,0 +1,26 @@
+ALTER TABLE orders DROP CONSTRAINT IF EXISTS orders_status_check;
+ALTER TABLE orders ADD CONSTRAINT orders_status_check
+CHECK (status IN ('pending', 'paid', 'confirmed', 'shipped', 'delivered', 'cancelled'));
+
+CREATE TABLE IF NOT EXISTS payments (
+    id SERIAL PRIMARY KEY,
+    order_id INT NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    provider VARCHAR(20) NOT NULL,
+    phone VARCHAR(20) NOT NULL,
+    amount DECIMAL(14,2) NOT NULL CHECK (amount > 0),
+    currency CHAR(3) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'succeeded', 'failed')),
+    -- reference is the provider's id for the attempt, e.g. the M-Pesa CheckoutRequestID
+    reference VARCHAR(64),
+    receipt VARCHAR(64),
+    result_code INT,
+    result_desc TEXT,
+    paid_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_payments_order_id ON payments (order_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_payments_provider_reference ON payments (provider, reference);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_payments_provider_receipt ON payments (provider, receipt);
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS payment_mismatches (
+    id SERIAL PRIMARY KEY,
+    payment_id INT NOT NULL REFERENCES payments(id) ON DELETE CASCADE,
+    kind VARCHAR(30) NOT NULL,
+    local_status VARCHAR(20) NOT NULL,
+    provider_status VARCHAR(20),
+    detail TEXT NOT NULL,
+    detected_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    resolved_at TIMESTAMP WITH TIME ZONE
+);
+
+-- One open flag per payment and kind, however often reconciliation runs
+CREATE UNIQUE INDEX IF NOT EXISTS idx_payment_mismatches_open
+ON payment_mismatches (payment_id, kind) WHERE resolved_at IS NULL;
@@ -0,0 +1,44 @@
+CREATE TABLE IF NOT EXISTS subscriptions (
+    id SERIAL PRIMARY KEY,
+    customer_id INT NOT NULL REFERENCES customers(id) ON DELETE CASCADE,
+    currency CHAR(3) NOT NULL,
+    -- schedule is a cron expression ("0 8 * * MON") or an interval ("@every 168h")
+    schedule VARCHAR(100) NOT NULL,
+    timezone VARCHAR(64) NOT NULL DEFAULT 'Africa/Nairobi',
+    status VARCHAR(20) NOT NULL DEFAULT 'active'
+        CHECK (status IN ('active', 'paused', 'cancelled')),
+    next_run_at TIMESTAMP WITH TIME ZONE,
+    last_run_at TIMESTAMP WITH TIME ZONE,
+    last_order_id INT REFERENCES orders(id) ON DELETE SET NULL,
+    last_error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_customer_id ON subscriptions (customer_id);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_due ON subscriptions (next_run_at) WHERE status = 'active';
+
+CREATE TABLE IF NOT EXISTS subscription_items (
+    id SERIAL PRIMARY KEY,
+    subscription_id INT NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+    product_id INT REFERENCES products(id),
+    product_ref VARCHAR(64),
+    description VARCHAR(255),
+    quantity INT NOT NULL CHECK (quantity > 0),
+    -- unit_price is ignored for catalogue products, which are priced when each order is placed
+    unit_price DECIMAL(14,2)
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscription_items_subscription_id ON subscription_items (subscription_id);
+
+-- One row per scheduled run, so a run is never placed twice and failures are visible
+CREATE TABLE IF NOT EXISTS subscription_runs (
+    id SERIAL PRIMARY KEY,
+    subscription_id INT NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+    scheduled_for TIMESTAMP WITH TIME ZONE NOT NULL,
+    order_id INT REFERENCES orders(id) ON DELETE SET NULL,
+    error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    finished_at TIMESTAMP WITH TIME ZONE,
+    UNIQUE (subscription_id, scheduled_for)
+);
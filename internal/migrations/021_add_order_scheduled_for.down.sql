@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_orders_scheduled_for;
+
+-- Orders still waiting to fire are left for someone to confirm by hand
+UPDATE orders SET status = 'pending' WHERE status = 'scheduled';
+ALTER TABLE orders DROP CONSTRAINT IF EXISTS orders_status_check;
+ALTER TABLE orders ADD CONSTRAINT orders_status_check
+CHECK (status IN ('pending', 'paid', 'confirmed', 'shipped', 'delivered', 'cancelled'));
+
+ALTER TABLE orders DROP COLUMN IF EXISTS scheduled_for;
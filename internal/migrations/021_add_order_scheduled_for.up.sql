@@ -0,0 +1,8 @@
+ALTER TABLE orders DROP CONSTRAINT IF EXISTS orders_status_check;
+ALTER TABLE orders ADD CONSTRAINT orders_status_check
+CHECK (status IN ('scheduled', 'pending', 'paid', 'confirmed', 'shipped', 'delivered', 'cancelled'));
+
+ALTER TABLE orders
+ADD COLUMN IF NOT EXISTS scheduled_for TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_orders_scheduled_for ON orders (scheduled_for) WHERE status = 'scheduled';
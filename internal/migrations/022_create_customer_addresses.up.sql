@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS customer_addresses (
+    id SERIAL PRIMARY KEY,
+    customer_id INT NOT NULL REFERENCES customers(id) ON DELETE CASCADE,
+    label VARCHAR(50),
+    recipient VARCHAR(100),
+    phone VARCHAR(20),
+    line1 VARCHAR(255),
+    line2 VARCHAR(255),
+    town VARCHAR(100) NOT NULL,
+    county VARCHAR(100),
+    postal_code VARCHAR(20),
+    latitude DOUBLE PRECISION CHECK (latitude BETWEEN -90 AND 90),
+    longitude DOUBLE PRECISION CHECK (longitude BETWEEN -180 AND 180),
+    is_default BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    CHECK ((latitude IS NULL) = (longitude IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_customer_addresses_customer_id ON customer_addresses (customer_id);
+-- At most one default address per customer
+CREATE UNIQUE INDEX IF NOT EXISTS idx_customer_addresses_default ON customer_addresses (customer_id) WHERE is_default;
+
+-- Orders keep a copy of the address they were delivered to, so later edits
+-- to the customer's address book do not rewrite history
+ALTER TABLE orders
+ADD COLUMN IF NOT EXISTS address_id INT REFERENCES customer_addresses(id) ON DELETE SET NULL,
+ADD COLUMN IF NOT EXISTS delivery_address JSONB,
+ADD COLUMN IF NOT EXISTS delivery_instructions TEXT;
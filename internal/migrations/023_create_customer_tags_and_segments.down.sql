@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS segment_members;
+DROP TABLE IF EXISTS segments;
+DROP TABLE IF EXISTS customer_tags;
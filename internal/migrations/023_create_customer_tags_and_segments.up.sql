@@ -0,0 +1,33 @@
+-- Free-form labels on customers, e.g. "vip" or "wholesale". Tags are stored lower case.
+CREATE TABLE IF NOT EXISTS customer_tags (
+    customer_id INT NOT NULL REFERENCES customers(id) ON DELETE CASCADE,
+    tag VARCHAR(50) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    PRIMARY KEY (customer_id, tag)
+);
+
+CREATE INDEX IF NOT EXISTS idx_customer_tags_tag ON customer_tags (tag);
+
+-- A segment is a saved rule such as: town = "Nairobi" AND order_total(30d) > 5000
+CREATE TABLE IF NOT EXISTS segments (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    description TEXT,
+    rule TEXT NOT NULL,
+    member_count INT NOT NULL DEFAULT 0,
+    refreshed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_segments_name_lower ON segments (LOWER(name));
+
+-- Membership as of the segment's last refresh
+CREATE TABLE IF NOT EXISTS segment_members (
+    segment_id INT NOT NULL REFERENCES segments(id) ON DELETE CASCADE,
+    customer_id INT NOT NULL REFERENCES customers(id) ON DELETE CASCADE,
+    added_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    PRIMARY KEY (segment_id, customer_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_segment_members_customer_id ON segment_members (customer_id);
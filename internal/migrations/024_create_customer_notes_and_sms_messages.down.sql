@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS sms_messages;
+DROP TABLE IF EXISTS customer_notes;
@@ -0,0 +1,25 @@
+-- Notes support staff record against a customer, e.g. after a call
+CREATE TABLE IF NOT EXISTS customer_notes (
+    id SERIAL PRIMARY KEY,
+    customer_id INT NOT NULL REFERENCES customers(id) ON DELETE CASCADE,
+    author VARCHAR(255) NOT NULL,
+    body TEXT NOT NULL,
+    pinned BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_customer_notes_customer_id ON customer_notes (customer_id, created_at);
+
+-- Every SMS actually sent to a customer, for their timeline
+CREATE TABLE IF NOT EXISTS sms_messages (
+    id BIGSERIAL PRIMARY KEY,
+    customer_id INT NOT NULL REFERENCES customers(id) ON DELETE CASCADE,
+    order_id INT REFERENCES orders(id) ON DELETE SET NULL,
+    kind VARCHAR(30) NOT NULL CHECK (kind IN ('order_confirmation', 'order_update', 'refund_notice')),
+    phone TEXT NOT NULL,
+    body TEXT NOT NULL,
+    sent_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_sms_messages_customer_id ON sms_messages (customer_id, sent_at);
@@ -0,0 +1,21 @@
+-- Audit trail of duplicate customers merged into another. There are no
+-- foreign keys so the record outlives the merged customer being purged;
+-- the loser_ columns keep who they were.
+CREATE TABLE IF NOT EXISTS customer_merges (
+    id SERIAL PRIMARY KEY,
+    winner_id INT NOT NULL,
+    loser_id INT NOT NULL,
+    loser_name VARCHAR(255) NOT NULL,
+    loser_email VARCHAR(255) NOT NULL,
+    loser_phone TEXT,
+    loser_code VARCHAR(50) NOT NULL,
+    merged_by VARCHAR(255) NOT NULL,
+    orders_moved INT NOT NULL DEFAULT 0,
+    notes_moved INT NOT NULL DEFAULT 0,
+    addresses_moved INT NOT NULL DEFAULT 0,
+    subscriptions_moved INT NOT NULL DEFAULT 0,
+    merged_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_customer_merges_winner_id ON customer_merges (winner_id);
+CREATE INDEX IF NOT EXISTS idx_customer_merges_loser_id ON customer_merges (loser_id);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_refunds_payment_id;
+
+ALTER TABLE refunds
+DROP COLUMN IF EXISTS paid_out_at,
+DROP COLUMN IF EXISTS provider_reference,
+DROP COLUMN IF EXISTS payment_id;
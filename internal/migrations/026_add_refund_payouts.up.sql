@@ -0,0 +1,8 @@
+-- payment_id is the payment a refund is returned through; refunds recorded
+-- before payments existed have none
+ALTER TABLE refunds
+ADD COLUMN IF NOT EXISTS payment_id INT REFERENCES payments(id),
+ADD COLUMN IF NOT EXISTS provider_reference VARCHAR(64),
+ADD COLUMN IF NOT EXISTS paid_out_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_refunds_payment_id ON refunds (payment_id);
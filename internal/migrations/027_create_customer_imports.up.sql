@@ -0,0 +1,9 @@
+-- Reports of bulk customer imports, kept so the rejected rows can be
+-- downloaded again without re-running the import. Passwords are blanked
+-- out of the rejected rows before they are stored.
+CREATE TABLE IF NOT EXISTS customer_imports (
+    id SERIAL PRIMARY KEY,
+    header TEXT[] NOT NULL,
+    report JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
@@ -0,0 +1,6 @@
+ALTER TABLE invoices
+DROP COLUMN IF EXISTS order_snapshot,
+DROP COLUMN IF EXISTS customer_snapshot;
+
+-- NOT VALID so invoices of orders purged in the meantime do not block the rollback
+ALTER TABLE invoices ADD CONSTRAINT invoices_order_id_fkey FOREIGN KEY (order_id) REFERENCES orders(id) NOT VALID;
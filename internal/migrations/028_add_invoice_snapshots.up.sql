@@ -0,0 +1,51 @@
+-- An invoice keeps a copy of the order and customer as they were when it was
+-- issued, so it always prints the same details and outlives both. Invoices
+-- are kept for the tax authority after the order is purged, so order_id no
+-- longer references orders.
+ALTER TABLE invoices DROP CONSTRAINT IF EXISTS invoices_order_id_fkey;
+
+ALTER TABLE invoices
+ADD COLUMN IF NOT EXISTS order_snapshot JSONB,
+ADD COLUMN IF NOT EXISTS customer_snapshot JSONB;
+
+-- The keys match the JSON encoding of models.Order and models.Customer
+UPDATE invoices inv
+SET order_snapshot = json_build_object(
+        'id', o.id,
+        'customer_id', o.customer_id::TEXT,
+        'item', o.item,
+        'amount', o.amount,
+        'currency', o.currency,
+        'subtotal', o.subtotal,
+        'discount', o.discount,
+        'promo_code', COALESCE(o.promo_code, ''),
+        'tax', o.tax,
+        'tax_region', COALESCE(o.tax_region, ''),
+        'status', o.status,
+        'ordered_at', o.ordered_at,
+        'created_at', o.created_at,
+        'items', COALESCE((
+            SELECT json_agg(json_build_object(
+                'id', i.id, 'order_id', i.order_id, 'product_id', i.product_id, 'product_ref', COALESCE(i.product_ref, ''),
+                'description', i.description, 'quantity', i.quantity, 'unit_price', i.unit_price, 'line_total', i.line_total,
+                'discount', i.discount, 'tax_name', COALESCE(i.tax_name, ''), 'tax_rate', i.tax_rate,
+                'tax_inclusive', i.tax_inclusive, 'tax', i.tax
+            ) ORDER BY i.id)
+            FROM order_items i
+            WHERE i.order_id = o.id
+        ), '[]')
+    ),
+    customer_snapshot = json_build_object(
+        'id', c.id,
+        'customer_name', c.customer_name,
+        'email', c.email,
+        'phone', COALESCE(c.phone, ''),
+        'code', c.code
+    )
+FROM orders o
+JOIN customers c ON c.id = o.customer_id
+WHERE o.id = inv.order_id AND inv.order_snapshot IS NULL;
+
+ALTER TABLE invoices
+ALTER COLUMN order_snapshot SET NOT NULL,
+ALTER COLUMN customer_snapshot SET NOT NULL;
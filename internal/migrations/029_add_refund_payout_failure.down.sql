@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_refunds_provider_reference;
+
+ALTER TABLE refunds
+DROP COLUMN IF EXISTS payout_failure;
@@ -0,0 +1,7 @@
+-- payout_failure is set when the provider reports a payout it accepted has
+-- failed; such refunds no longer count against what is left to refund
+ALTER TABLE refunds
+ADD COLUMN IF NOT EXISTS payout_failure TEXT;
+
+-- Payout results from the provider are matched on its reference
+CREATE INDEX IF NOT EXISTS idx_refunds_provider_reference ON refunds (provider_reference);
@@ -0,0 +1,5 @@
+ALTER TABLE customer_merges
+DROP COLUMN IF EXISTS refunds_moved,
+DROP COLUMN IF EXISTS payments_moved,
+DROP COLUMN IF EXISTS invoiced_order_ids,
+DROP COLUMN IF EXISTS order_ids;
@@ -0,0 +1,8 @@
+-- The orders a merge moved, and which of them were invoiced, so invoices,
+-- payments and refunds that now sit under the winner can be traced back to
+-- the merged customer
+ALTER TABLE customer_merges
+ADD COLUMN IF NOT EXISTS order_ids INT[] NOT NULL DEFAULT '{}',
+ADD COLUMN IF NOT EXISTS invoiced_order_ids INT[] NOT NULL DEFAULT '{}',
+ADD COLUMN IF NOT EXISTS payments_moved INT NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS refunds_moved INT NOT NULL DEFAULT 0;
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// CustomerAddress is an entry in a customer's address book
+type CustomerAddress struct {
+	ID         int64  `json:"id" db:"id"`
+	CustomerID int64  `json:"customer_id" db:"customer_id"`
+	Label      string `json:"label,omitempty" db:"label"`
+	DeliveryAddress
+	IsDefault bool      `json:"is_default" db:"is_default"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// DeliveryAddress is where an order goes. Orders store a copy of it so later
+// changes to the customer's address book do not alter past orders.
+type DeliveryAddress struct {
+	// Recipient and Phone are who receives the delivery when it is not the customer
+	Recipient  string `json:"recipient,omitempty" db:"recipient"`
+	Phone      string `json:"phone,omitempty" db:"phone"`
+	Line1      string `json:"line1,omitempty" db:"line1"`
+	Line2      string `json:"line2,omitempty" db:"line2"`
+	Town       string `json:"town" db:"town"`
+	County     string `json:"county,omitempty" db:"county"`
+	PostalCode string `json:"postal_code,omitempty" db:"postal_code"`
+	// Latitude and Longitude pin the drop-off point, e.g. a gate with no street address
+	Latitude  *float64 `json:"latitude,omitempty" db:"latitude"`
+	Longitude *float64 `json:"longitude,omitempty" db:"longitude"`
+}
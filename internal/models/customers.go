@@ -8,6 +8,8 @@ type Customer struct {
 	Email string `json:"email" db:"email"` 
 	Password string `json:"password,omitempty"`
 	Phone string `json:"phone" db:"phone"`
+	CountryCode string `json:"country_code,omitempty" db:"country_code"`
 	Code string `json:"code" db:"code"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
 }
\ No newline at end of file
@@ -1 +1,120 @@
-package models
\ No newline at end of file
+package models
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	// ErrNotFound is returned when a record does not exist or has been soft deleted
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned when a write would violate a uniqueness rule
+	ErrConflict = errors.New("conflict")
+	// ErrInvalidTransition is returned when an order cannot move to the requested status
+	ErrInvalidTransition = errors.New("invalid status transition")
+	// ErrOutOfStock is returned when an order asks for more of a product than is available
+	ErrOutOfStock = errors.New("out of stock")
+	// ErrStockBelowReserved is returned when a stock adjustment would leave less on hand than is reserved
+	ErrStockBelowReserved = errors.New("adjustment would leave less stock on hand than is reserved")
+	// ErrPromotionNotApplicable is returned when a promo code cannot be used on an order
+	ErrPromotionNotApplicable = errors.New("promotion cannot be applied")
+	// ErrNotInvoiceable is returned when an invoice is requested for a cancelled order
+	ErrNotInvoiceable = errors.New("cancelled orders cannot be invoiced")
+	// ErrRefundExceedsPaid is returned when a refund is larger than what is left to refund on the order
+	ErrRefundExceedsPaid = errors.New("refund exceeds the amount paid")
+	// ErrPartialRefund is returned when part of a payment is refunded through a provider that only refunds in full
+	ErrPartialRefund = errors.New("payment can only be refunded in full")
+	// ErrStatusNotSettable is returned when a status that has its own flow, such
+	// as paid or cancelled, is set through a plain status change
+	ErrStatusNotSettable = errors.New("order status cannot be set directly")
+	// ErrNotPayable is returned when a payment is started for an order that is not awaiting payment
+	ErrNotPayable = errors.New("only pending orders can be paid")
+	// ErrPaymentInProgress is returned when an order has a payment attempt the customer may still be approving
+	ErrPaymentInProgress = errors.New("a payment for this order is still in progress")
+	// ErrPaymentProvider is returned when the payment provider rejects or fails a request
+	ErrPaymentProvider = errors.New("payment provider request failed")
+	// ErrNotEditable is returned when the lines of an order are changed after it has been paid for or dispatched
+	ErrNotEditable = errors.New("only pending or scheduled orders can be edited")
+	// ErrNotScheduled is returned when an order that is not waiting for its scheduled time is rescheduled
+	ErrNotScheduled = errors.New("only scheduled orders can be rescheduled")
+	// ErrSubscriptionCancelled is returned when a cancelled subscription is paused or resumed
+	ErrSubscriptionCancelled = errors.New("cancelled subscriptions cannot be changed")
+	// ErrIdempotencyKeyReused is returned when an Idempotency-Key is sent again with a different request
+	ErrIdempotencyKeyReused = errors.New("idempotency key was already used with a different request")
+	// ErrIdempotencyInProgress is returned when a retry arrives before the original request has finished
+	ErrIdempotencyInProgress = errors.New("a request with this idempotency key is still in progress")
+)
+
+// ConflictError reports which field collided with an existing record
+type ConflictError struct {
+	Field string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s already exists", e.Field)
+}
+
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// TransitionError reports an order status change the state machine does not allow
+type TransitionError struct {
+	From    OrderStatus
+	To      OrderStatus
+	Allowed []OrderStatus
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("cannot change order status from %s to %s", e.From, e.To)
+}
+
+func (e *TransitionError) Is(target error) bool {
+	return target == ErrInvalidTransition
+}
+
+// StockError reports the product an order could not reserve enough stock for
+type StockError struct {
+	ProductID int64
+	Requested int
+	Available int
+}
+
+func (e *StockError) Error() string {
+	return fmt.Sprintf("product %d is out of stock: requested %d, available %d", e.ProductID, e.Requested, e.Available)
+}
+
+func (e *StockError) Is(target error) bool {
+	return target == ErrOutOfStock
+}
+
+// PromotionError reports why a promo code was rejected for an order
+type PromotionError struct {
+	Code   string
+	Reason string
+}
+
+func (e *PromotionError) Error() string {
+	return fmt.Sprintf("promo code %s %s", e.Code, e.Reason)
+}
+
+func (e *PromotionError) Is(target error) bool {
+	return target == ErrPromotionNotApplicable
+}
+
+// RefundError reports how much can still be refunded on an order
+type RefundError struct {
+	OrderID    int64
+	Requested  decimal.Decimal
+	Refundable decimal.Decimal
+}
+
+func (e *RefundError) Error() string {
+	return fmt.Sprintf("cannot refund %s on order %d: only %s is refundable", e.Requested, e.OrderID, e.Refundable)
+}
+
+func (e *RefundError) Is(target error) bool {
+	return target == ErrRefundExceedsPaid
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// IdempotencyRecord is a stored Idempotency-Key together with the response it
+// produced. StatusCode is zero while the original request is still running.
+type IdempotencyRecord struct {
+	Scope        string    `json:"scope" db:"scope"`
+	Key          string    `json:"key" db:"key"`
+	RequestHash  string    `json:"request_hash" db:"request_hash"`
+	StatusCode   int       `json:"status_code" db:"status_code"`
+	ResponseBody []byte    `json:"response_body" db:"response_body"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	ExpiresAt    time.Time `json:"expires_at" db:"expires_at"`
+}
+
+// Completed reports whether the original request has finished and its response can be replayed
+func (r *IdempotencyRecord) Completed() bool {
+	return r.StatusCode != 0
+}
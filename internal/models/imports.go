@@ -0,0 +1,15 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// CustomerImport is a stored bulk customer import report
+type CustomerImport struct {
+	ID int64 `json:"id" db:"id"`
+	// Header is the CSV header the rejected rows are laid out in
+	Header    []string        `json:"header" db:"header"`
+	Report    json.RawMessage `json:"report" db:"report"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+type StockMovementKind string
+
+// Movement kinds. Receipts and adjustments change on_hand. Reservations and
+// releases change reserved. Shipments consume a reservation and lower both.
+const (
+	StockReceipt     StockMovementKind = "receipt"
+	StockAdjustment  StockMovementKind = "adjustment"
+	StockReservation StockMovementKind = "reservation"
+	StockRelease     StockMovementKind = "release"
+	StockShipment    StockMovementKind = "shipment"
+)
+
+// StockLevel is the stock of one product at one location
+type StockLevel struct {
+	ProductID int64     `json:"product_id" db:"product_id"`
+	Location  string    `json:"location" db:"location"`
+	OnHand    int       `json:"on_hand" db:"on_hand"`
+	Reserved  int       `json:"reserved" db:"reserved"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Available is the stock that can still be reserved
+func (l StockLevel) Available() int {
+	return l.OnHand - l.Reserved
+}
+
+// StockMovement is one entry of the stock ledger. Quantity is the signed change
+// to on_hand for receipts and adjustments and to reserved for reservations and
+// releases; shipments record the (negative) quantity taken from both.
+type StockMovement struct {
+	ID        int64             `json:"id" db:"id"`
+	ProductID int64             `json:"product_id" db:"product_id"`
+	Location  string            `json:"location" db:"location"`
+	OrderID   *int64            `json:"order_id,omitempty" db:"order_id"`
+	Kind      StockMovementKind `json:"kind" db:"kind"`
+	Quantity  int               `json:"quantity" db:"quantity"`
+	Note      string            `json:"note,omitempty" db:"note"`
+	CreatedBy string            `json:"created_by" db:"created_by"`
+	CreatedAt time.Time         `json:"created_at" db:"created_at"`
+}
+
+// ProductStock summarises a product's stock across locations
+type ProductStock struct {
+	ProductID int64           `json:"product_id"`
+	Tracked   bool            `json:"tracked"`
+	OnHand    int             `json:"on_hand"`
+	Reserved  int             `json:"reserved"`
+	Available int             `json:"available"`
+	Levels    []StockLevel    `json:"levels"`
+	Movements []StockMovement `json:"movements"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// Invoice is the invoice issued for an order. Number comes from a gap-free
+// counter, as the tax authority expects invoice numbers without holes.
+type Invoice struct {
+	ID       int64     `json:"id" db:"id"`
+	Number   int64     `json:"number" db:"number"`
+	OrderID  int64     `json:"order_id" db:"order_id"`
+	IssuedAt time.Time `json:"issued_at" db:"issued_at"`
+	// Order and Customer are copies taken when the invoice was issued, so the
+	// invoice prints the same however they change later, and after they are purged
+	Order    Order    `json:"order" db:"order_snapshot"`
+	Customer Customer `json:"customer" db:"customer_snapshot"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// DuplicatePair is two customers that are probably the same person
+type DuplicatePair struct {
+	// Score runs from 0 to 1; see package dedupe for how it is worked out
+	Score     float64    `json:"score"`
+	Reasons   []string   `json:"reasons"`
+	Customers []Customer `json:"customers"`
+}
+
+// CustomerMerge records a duplicate customer (the loser) merged into another
+// (the winner). The loser's orders, notes, addresses and subscriptions move
+// to the winner and the loser is deleted. Invoices, payments and refunds
+// follow their orders; OrderIDs and InvoicedOrderIDs record which ones.
+type CustomerMerge struct {
+	ID       int64 `json:"id" db:"id"`
+	WinnerID int64 `json:"winner_id" db:"winner_id"`
+	LoserID  int64 `json:"loser_id" db:"loser_id"`
+	// The loser's details as they were when merged
+	LoserName          string    `json:"loser_name" db:"loser_name"`
+	LoserEmail         string    `json:"loser_email" db:"loser_email"`
+	LoserPhone         string    `json:"loser_phone,omitempty" db:"loser_phone"`
+	LoserCode          string    `json:"loser_code" db:"loser_code"`
+	MergedBy           string    `json:"merged_by" db:"merged_by"`
+	OrdersMoved        int       `json:"orders_moved" db:"orders_moved"`
+	NotesMoved         int       `json:"notes_moved" db:"notes_moved"`
+	AddressesMoved     int       `json:"addresses_moved" db:"addresses_moved"`
+	SubscriptionsMoved int       `json:"subscriptions_moved" db:"subscriptions_moved"`
+	OrderIDs           []int64   `json:"order_ids" db:"order_ids"`
+	InvoicedOrderIDs   []int64   `json:"invoiced_order_ids" db:"invoiced_order_ids"`
+	PaymentsMoved      int       `json:"payments_moved" db:"payments_moved"`
+	RefundsMoved       int       `json:"refunds_moved" db:"refunds_moved"`
+	MergedAt           time.Time `json:"merged_at" db:"merged_at"`
+}
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+const defaultCurrencyCode = "KES"
+
+// Currency describes how amounts in an ISO 4217 currency are rounded and shown
+type Currency struct {
+	Code     string
+	Exponent int32 // digits after the decimal point in the minor unit
+}
+
+// currencies are the ISO 4217 currencies orders can be placed in
+var currencies = map[string]Currency{
+	"KES": {Code: "KES", Exponent: 2},
+	"UGX": {Code: "UGX", Exponent: 0},
+	"TZS": {Code: "TZS", Exponent: 2},
+	"RWF": {Code: "RWF", Exponent: 0},
+	"USD": {Code: "USD", Exponent: 2},
+}
+
+// LookupCurrency finds a supported currency by its ISO 4217 code
+func LookupCurrency(code string) (Currency, error) {
+	c, ok := currencies[strings.ToUpper(strings.TrimSpace(code))]
+	if !ok {
+		return Currency{}, fmt.Errorf("unsupported currency %q", code)
+	}
+	return c, nil
+}
+
+// DefaultCurrency reads DEFAULT_CURRENCY (default KES)
+func DefaultCurrency() Currency {
+	if c, err := LookupCurrency(os.Getenv("DEFAULT_CURRENCY")); err == nil {
+		return c
+	}
+	return currencies[defaultCurrencyCode]
+}
+
+// Round rounds a computed amount to the currency's minor unit using banker's
+// rounding (half to even), so repeated rounding does not drift in one direction
+func (c Currency) Round(amount decimal.Decimal) decimal.Decimal {
+	return amount.RoundBank(c.Exponent)
+}
+
+// Exact reports whether amount can be represented in the currency's minor unit
+// without rounding, e.g. 10.50 is exact in KES but not in UGX
+func (c Currency) Exact(amount decimal.Decimal) bool {
+	return amount.Equal(amount.Truncate(c.Exponent))
+}
+
+// Format renders an amount for people, e.g. "KES 1,500.00" or "UGX 150,000"
+func (c Currency) Format(amount decimal.Decimal) string {
+	return c.Code + " " + c.FormatAmount(amount)
+}
+
+// FormatAmount renders an amount without the currency code, e.g. "1,500.00",
+// for tables where the currency is shown once
+func (c Currency) FormatAmount(amount decimal.Decimal) string {
+	fixed := c.Round(amount).StringFixed(c.Exponent)
+
+	sign := ""
+	if strings.HasPrefix(fixed, "-") {
+		sign, fixed = "-", fixed[1:]
+	}
+
+	whole, frac, hasFrac := strings.Cut(fixed, ".")
+	var b strings.Builder
+	b.WriteString(sign)
+	for i, ch := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(ch)
+	}
+	if hasFrac {
+		b.WriteByte('.')
+		b.WriteString(frac)
+	}
+
+	return b.String()
+}
+
+// FormatMoney formats amount in the currency with the given code, falling back
+// to the default currency for unknown codes
+func FormatMoney(amount decimal.Decimal, code string) string {
+	c, err := LookupCurrency(code)
+	if err != nil {
+		c = DefaultCurrency()
+	}
+	return c.Format(amount)
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCurrencyRound(t *testing.T) {
+	kes, _ := LookupCurrency("KES")
+	ugx, _ := LookupCurrency("ugx")
+
+	tests := []struct {
+		currency Currency
+		in       string
+		want     string
+	}{
+		{kes, "10.125", "10.12"},
+		{kes, "10.135", "10.14"},
+		{kes, "-10.125", "-10.12"},
+		{kes, "0.005", "0"},
+		{ugx, "1500.5", "1500"},
+		{ugx, "1501.5", "1502"},
+	}
+
+	for _, tt := range tests {
+		got := tt.currency.Round(decimal.RequireFromString(tt.in))
+		assert.Equal(t, tt.want, got.String(), "%s %s", tt.currency.Code, tt.in)
+	}
+}
+
+func TestCurrencyFormat(t *testing.T) {
+	tests := []struct {
+		code string
+		in   string
+		want string
+	}{
+		{"KES", "1500", "KES 1,500.00"},
+		{"KES", "999.5", "KES 999.50"},
+		{"KES", "1234567.891", "KES 1,234,567.89"},
+		{"UGX", "150000", "UGX 150,000"},
+		{"TZS", "-2500.5", "TZS -2,500.50"},
+		{"XYZ", "10", "KES 10.00"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, FormatMoney(decimal.RequireFromString(tt.in), tt.code))
+	}
+}
+
+func TestCurrencyExact(t *testing.T) {
+	kes, _ := LookupCurrency("KES")
+	ugx, _ := LookupCurrency("UGX")
+
+	assert.True(t, kes.Exact(decimal.RequireFromString("10.50")))
+	assert.False(t, kes.Exact(decimal.RequireFromString("10.505")))
+	assert.True(t, ugx.Exact(decimal.RequireFromString("1500")))
+	assert.False(t, ugx.Exact(decimal.RequireFromString("1500.5")))
+
+	_, err := LookupCurrency("XYZ")
+	assert.Error(t, err)
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// CustomerNote is a note support staff recorded against a customer
+type CustomerNote struct {
+	ID         int64  `json:"id" db:"id"`
+	CustomerID int64  `json:"customer_id" db:"customer_id"`
+	Author     string `json:"author" db:"author"`
+	Body       string `json:"body" db:"body"`
+	// Pinned notes are listed first, e.g. "prefers calls after 5pm"
+	Pinned    bool      `json:"pinned" db:"pinned"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// SMSKind is what an SMS to a customer was about
+type SMSKind string
+
+const (
+	SMSKindOrderConfirmation SMSKind = "order_confirmation"
+	SMSKindOrderUpdate       SMSKind = "order_update"
+	SMSKindRefundNotice      SMSKind = "refund_notice"
+)
+
+// SMSMessage is an SMS that was sent to a customer
+type SMSMessage struct {
+	ID         int64     `json:"id" db:"id"`
+	CustomerID int64     `json:"customer_id" db:"customer_id"`
+	OrderID    *int64    `json:"order_id,omitempty" db:"order_id"`
+	Kind       SMSKind   `json:"kind" db:"kind"`
+	Phone      string    `json:"phone" db:"phone"`
+	Body       string    `json:"body" db:"body"`
+	SentAt     time.Time `json:"sent_at" db:"sent_at"`
+}
+
+// TimelineEventKind is the source of an entry on a customer's timeline
+type TimelineEventKind string
+
+const (
+	TimelineNote         TimelineEventKind = "note"
+	TimelineOrder        TimelineEventKind = "order"
+	TimelineStatusChange TimelineEventKind = "status_change"
+	TimelineSMS          TimelineEventKind = "sms"
+)
+
+// TimelineEvent is one entry on a customer's timeline. Which fields are set
+// depends on Kind; ID is the note, order, status history or SMS row's ID.
+type TimelineEvent struct {
+	Kind       TimelineEventKind `json:"kind"`
+	ID         int64             `json:"id"`
+	OccurredAt time.Time         `json:"occurred_at"`
+	Summary    string            `json:"summary"`
+	OrderID    *int64            `json:"order_id,omitempty"`
+	// Actor is the note's author or whoever changed the order's status
+	Actor      string           `json:"actor,omitempty"`
+	Body       string           `json:"body,omitempty"`
+	Pinned     bool             `json:"pinned,omitempty"`
+	Status     OrderStatus      `json:"status,omitempty"`
+	FromStatus OrderStatus      `json:"from_status,omitempty"`
+	ToStatus   OrderStatus      `json:"to_status,omitempty"`
+	Amount     *decimal.Decimal `json:"amount,omitempty"`
+	Currency   string           `json:"currency,omitempty"`
+	Phone      string           `json:"phone,omitempty"`
+	SMSKind    SMSKind          `json:"sms_kind,omitempty"`
+}
+
+// TimelineCursor marks the last event of a timeline page. The next page
+// starts with the event just before it.
+type TimelineCursor struct {
+	OccurredAt time.Time
+	Kind       TimelineEventKind
+	ID         int64
+}
+
+// TimelinePage is a page of a customer's timeline, newest first
+type TimelinePage struct {
+	Events []TimelineEvent `json:"events"`
+	// NextCursor fetches the following, older page and is empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
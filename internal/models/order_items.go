@@ -0,0 +1,22 @@
+package models
+
+import "github.com/shopspring/decimal"
+
+type OrderItem struct {
+	ID          int64           `json:"id" db:"id"`
+	OrderID     int64           `json:"order_id" db:"order_id"`
+	ProductID   *int64          `json:"product_id,omitempty" db:"product_id"`
+	ProductRef  string          `json:"product_ref,omitempty" db:"product_ref"`
+	Description string          `json:"description" db:"description"`
+	Quantity    int             `json:"quantity" db:"quantity"`
+	UnitPrice   decimal.Decimal `json:"unit_price" db:"unit_price"`
+	LineTotal   decimal.Decimal `json:"line_total" db:"line_total"`
+	// Discount is the share of the order discount taken off this line
+	Discount     decimal.Decimal `json:"discount" db:"discount"`
+	TaxName      string          `json:"tax_name,omitempty" db:"tax_name"`
+	TaxRate      decimal.Decimal `json:"tax_rate" db:"tax_rate"`
+	TaxInclusive bool            `json:"tax_inclusive" db:"tax_inclusive"`
+	Tax          decimal.Decimal `json:"tax" db:"tax"`
+	// Categories are the catalogue product's categories, used to pick the tax rate
+	Categories []string `json:"-" db:"-"`
+}
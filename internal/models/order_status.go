@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+type OrderStatus string
+
+const (
+	OrderStatusScheduled OrderStatus = "scheduled"
+	OrderStatusPending   OrderStatus = "pending"
+	OrderStatusPaid      OrderStatus = "paid"
+	OrderStatusConfirmed OrderStatus = "confirmed"
+	OrderStatusShipped   OrderStatus = "shipped"
+	OrderStatusDelivered OrderStatus = "delivered"
+	OrderStatusCancelled OrderStatus = "cancelled"
+)
+
+// OrderStatusChange is one row of an order's status history
+type OrderStatusChange struct {
+	ID         int64       `json:"id" db:"id"`
+	OrderID    int64       `json:"order_id" db:"order_id"`
+	FromStatus OrderStatus `json:"from_status" db:"from_status"`
+	ToStatus   OrderStatus `json:"to_status" db:"to_status"`
+	ChangedBy  string      `json:"changed_by" db:"changed_by"`
+	Reason     string      `json:"reason,omitempty" db:"reason"`
+	ChangedAt  time.Time   `json:"changed_at" db:"changed_at"`
+}
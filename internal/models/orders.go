@@ -1,11 +1,32 @@
 package models
-import "time"
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
 
 type Order struct {
 	ID int64 `json:"id" db:"id"`
 	CustomerID  string `json:"customer_id" db:"customer_id"`
 	Item       string    `json:"item" db:"item"`
-	Amount     float64   `json:"amount" db:"amount"`
+	Amount     decimal.Decimal `json:"amount" db:"amount"`
+	Currency   string    `json:"currency" db:"currency"`
+	Subtotal   decimal.Decimal `json:"subtotal" db:"subtotal"`
+	Discount   decimal.Decimal `json:"discount" db:"discount"`
+	PromoCode  string    `json:"promo_code,omitempty" db:"promo_code"`
+	Tax        decimal.Decimal `json:"tax" db:"tax"`
+	TaxRegion  string    `json:"tax_region,omitempty" db:"tax_region"`
+	TaxBreakdown []TaxLine `json:"tax_breakdown,omitempty" db:"-"`
+	Items      []OrderItem `json:"items" db:"-"`
+	Status     OrderStatus `json:"status" db:"status"`
+	// ScheduledFor is when a scheduled order is confirmed and the customer told about it
+	ScheduledFor *time.Time `json:"scheduled_for,omitempty" db:"scheduled_for"`
+	// AddressID is the address book entry the delivery address was copied from
+	AddressID            *int64           `json:"address_id,omitempty" db:"address_id"`
+	DeliveryAddress      *DeliveryAddress `json:"delivery_address,omitempty" db:"delivery_address"`
+	DeliveryInstructions string           `json:"delivery_instructions,omitempty" db:"delivery_instructions"`
 	OrderedAt  time.Time `json:"ordered_at" db:"ordered_at"`
     CreatedAt  time.Time `db:"created_at" json:"created_at"`
+	DeletedAt  *time.Time `db:"deleted_at" json:"deleted_at,omitempty"`
 }
\ No newline at end of file
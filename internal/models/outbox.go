@@ -0,0 +1,53 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type OutboxStatus string
+
+const (
+	OutboxStatusPending OutboxStatus = "pending"
+	OutboxStatusSent    OutboxStatus = "sent"
+	OutboxStatusDead    OutboxStatus = "dead"
+)
+
+// Outbox topics
+const (
+	TopicOrderCreated       = "order.created"
+	TopicOrderStatusChanged = "order.status_changed"
+	TopicOrderRefunded      = "order.refunded"
+)
+
+// OutboxMessage is a side effect recorded in the same transaction as the change
+// that caused it and delivered later by the outbox dispatcher
+type OutboxMessage struct {
+	ID          int64           `json:"id" db:"id"`
+	Topic       string          `json:"topic" db:"topic"`
+	AggregateID int64           `json:"aggregate_id" db:"aggregate_id"`
+	Payload     json.RawMessage `json:"payload" db:"payload"`
+	Status      OutboxStatus    `json:"status" db:"status"`
+	Attempts    int             `json:"attempts" db:"attempts"`
+	LastError   string          `json:"last_error,omitempty" db:"last_error"`
+	AvailableAt time.Time       `json:"available_at" db:"available_at"`
+	CreatedAt   time.Time       `json:"created_at" db:"created_at"`
+	ProcessedAt *time.Time      `json:"processed_at,omitempty" db:"processed_at"`
+}
+
+// OrderStatusChangedPayload is the payload of TopicOrderStatusChanged messages
+type OrderStatusChangedPayload struct {
+	From OrderStatus `json:"from"`
+	To   OrderStatus `json:"to"`
+}
+
+// OrderRefundedPayload is the payload of TopicOrderRefunded messages
+type OrderRefundedPayload struct {
+	RefundID  int64           `json:"refund_id"`
+	Amount    decimal.Decimal `json:"amount"`
+	Currency  string          `json:"currency"`
+	Reason    string          `json:"reason"`
+	Remaining decimal.Decimal `json:"remaining"`
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type PaymentStatus string
+
+const (
+	PaymentPending   PaymentStatus = "pending"
+	PaymentSucceeded PaymentStatus = "succeeded"
+	PaymentFailed    PaymentStatus = "failed"
+)
+
+// PaymentProviderMpesa is Safaricom M-Pesa via Daraja STK push
+const PaymentProviderMpesa = "mpesa"
+
+// RefundsWholePayments reports whether the provider can only return a
+// payment in full, as M-Pesa reversals do
+func RefundsWholePayments(provider string) bool {
+	return provider == PaymentProviderMpesa
+}
+
+// Payment is one attempt to collect an order's amount from the customer
+type Payment struct {
+	ID       int64           `json:"id" db:"id"`
+	OrderID  int64           `json:"order_id" db:"order_id"`
+	Provider string          `json:"provider" db:"provider"`
+	Phone    string          `json:"phone" db:"phone"`
+	Amount   decimal.Decimal `json:"amount" db:"amount"`
+	Currency string          `json:"currency" db:"currency"`
+	Status   PaymentStatus   `json:"status" db:"status"`
+	// Reference is the provider's id for the attempt, e.g. the M-Pesa CheckoutRequestID
+	Reference  string     `json:"reference,omitempty" db:"reference"`
+	Receipt    string     `json:"receipt,omitempty" db:"receipt"`
+	ResultCode *int       `json:"result_code,omitempty" db:"result_code"`
+	ResultDesc string     `json:"result_desc,omitempty" db:"result_desc"`
+	PaidAt     *time.Time `json:"paid_at,omitempty" db:"paid_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// PaymentResult is what the provider reports about an attempt
+type PaymentResult struct {
+	Provider  string
+	Reference string
+	// Status is pending while the provider is still waiting on the customer
+	Status     PaymentStatus
+	Amount     decimal.Decimal
+	Receipt    string
+	ResultCode int
+	ResultDesc string
+	PaidAt     time.Time
+}
+
+// Mismatch kinds found when reconciling payments with their provider
+const (
+	MismatchStatus  = "status"
+	MismatchAmount  = "amount"
+	MismatchReceipt = "receipt"
+	MismatchUnknown = "unknown_to_provider"
+)
+
+// PaymentMismatch flags a payment whose record disagrees with the provider
+type PaymentMismatch struct {
+	ID             int64         `json:"id" db:"id"`
+	PaymentID      int64         `json:"payment_id" db:"payment_id"`
+	OrderID        int64         `json:"order_id" db:"order_id"`
+	Provider       string        `json:"provider" db:"provider"`
+	Kind           string        `json:"kind" db:"kind"`
+	LocalStatus    PaymentStatus `json:"local_status" db:"local_status"`
+	ProviderStatus PaymentStatus `json:"provider_status,omitempty" db:"provider_status"`
+	Detail         string        `json:"detail" db:"detail"`
+	DetectedAt     time.Time     `json:"detected_at" db:"detected_at"`
+	ResolvedAt     *time.Time    `json:"resolved_at,omitempty" db:"resolved_at"`
+}
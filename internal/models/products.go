@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type Product struct {
+	ID          int64           `json:"id" db:"id"`
+	SKU         string          `json:"sku" db:"sku"`
+	Name        string          `json:"name" db:"name"`
+	Description string          `json:"description,omitempty" db:"description"`
+	Price       decimal.Decimal `json:"price" db:"price"`
+	Currency    string          `json:"currency" db:"currency"`
+	Active      *bool           `json:"active" db:"active"`
+	Categories  []string        `json:"categories" db:"categories"`
+	CreatedAt   time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+// IsActive reports whether the product can be ordered. Active is a pointer so
+// that a create request without the field can default to true.
+func (p *Product) IsActive() bool {
+	return p.Active == nil || *p.Active
+}
+
+// ProductFilter narrows a product listing
+type ProductFilter struct {
+	Category        string
+	IncludeInactive bool
+}
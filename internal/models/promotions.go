@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// PromotionKind is how a promotion's value is applied to an order
+type PromotionKind string
+
+const (
+	// PromotionPercentage takes Value percent off the order subtotal
+	PromotionPercentage PromotionKind = "percentage"
+	// PromotionFixed takes a fixed amount in the promotion's currency off the order
+	PromotionFixed PromotionKind = "fixed"
+)
+
+// Promotion is a discount code customers enter at checkout, e.g. JAMHURI for 10% off
+type Promotion struct {
+	ID          int64           `json:"id" db:"id"`
+	Code        string          `json:"code" db:"code"`
+	Description string          `json:"description,omitempty" db:"description"`
+	Kind        PromotionKind   `json:"kind" db:"kind"`
+	Value       decimal.Decimal `json:"value" db:"value"`
+	// Currency is required for fixed discounts and minimum order amounts and
+	// restricts the code to orders in that currency
+	Currency           string          `json:"currency,omitempty" db:"currency"`
+	MinOrderAmount     decimal.Decimal `json:"min_order_amount" db:"min_order_amount"`
+	StartsAt           time.Time       `json:"starts_at" db:"starts_at"`
+	EndsAt             *time.Time      `json:"ends_at,omitempty" db:"ends_at"`
+	MaxUses            *int            `json:"max_uses,omitempty" db:"max_uses"`
+	MaxUsesPerCustomer *int            `json:"max_uses_per_customer,omitempty" db:"max_uses_per_customer"`
+	Uses               int             `json:"uses" db:"uses"`
+	Active             *bool           `json:"active" db:"active"`
+	CreatedAt          time.Time       `json:"created_at" db:"created_at"`
+}
+
+// IsActive reports whether the code can be redeemed. Active is a pointer so
+// that a create request without the field can default to true.
+func (p *Promotion) IsActive() bool {
+	return p.Active == nil || *p.Active
+}
+
+// PromotionRedemption records a promotion used on an order and the discount it gave
+type PromotionRedemption struct {
+	ID          int64           `json:"id" db:"id"`
+	PromotionID int64           `json:"promotion_id" db:"promotion_id"`
+	OrderID     int64           `json:"order_id" db:"order_id"`
+	CustomerID  int64           `json:"customer_id" db:"customer_id"`
+	Discount    decimal.Decimal `json:"discount" db:"discount"`
+	CreatedAt   time.Time       `json:"created_at" db:"created_at"`
+}
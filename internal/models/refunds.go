@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Refund is money given back to the customer for an order, in the order's currency
+type Refund struct {
+	ID        int64           `json:"id" db:"id"`
+	OrderID   int64           `json:"order_id" db:"order_id"`
+	Amount    decimal.Decimal `json:"amount" db:"amount"`
+	Currency  string          `json:"currency" db:"currency"`
+	Reason    string          `json:"reason" db:"reason"`
+	CreatedBy string          `json:"created_by" db:"created_by"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+	// PaymentID is the payment the money is returned through
+	PaymentID *int64 `json:"payment_id,omitempty" db:"payment_id"`
+	// ProviderReference is the provider's id for the payout, set once it has
+	// been requested. PaidOutAt is when the money was returned, which for
+	// providers that report payouts later, like M-Pesa, is when they confirm
+	// it. PayoutFailure is why the provider reported the payout failed.
+	ProviderReference string     `json:"provider_reference,omitempty" db:"provider_reference"`
+	PaidOutAt         *time.Time `json:"paid_out_at,omitempty" db:"paid_out_at"`
+	PayoutFailure     string     `json:"payout_failure,omitempty" db:"payout_failure"`
+	// Remaining is what can still be refunded after this refund
+	Remaining decimal.Decimal `json:"remaining" db:"-"`
+}
+
+// Full reports whether the refund left nothing more to refund
+func (r *Refund) Full() bool {
+	return !r.Remaining.IsPositive()
+}
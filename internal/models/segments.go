@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// Segment is a saved group of customers chosen by a rule over their details
+// and orders. Its members are recomputed on every refresh.
+type Segment struct {
+	ID          int64  `json:"id" db:"id"`
+	Name        string `json:"name" db:"name"`
+	Description string `json:"description,omitempty" db:"description"`
+	// Rule is a filter such as: town = "Nairobi" AND order_total(30d) > 5000
+	Rule        string     `json:"rule" db:"rule"`
+	MemberCount int        `json:"member_count" db:"member_count"`
+	RefreshedAt *time.Time `json:"refreshed_at,omitempty" db:"refreshed_at"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type SubscriptionStatus string
+
+const (
+	SubscriptionActive    SubscriptionStatus = "active"
+	SubscriptionPaused    SubscriptionStatus = "paused"
+	SubscriptionCancelled SubscriptionStatus = "cancelled"
+)
+
+// Subscription places the same order for a customer on a schedule
+type Subscription struct {
+	ID         int64  `json:"id" db:"id"`
+	CustomerID int64  `json:"customer_id" db:"customer_id"`
+	Currency   string `json:"currency" db:"currency"`
+	// Schedule is a cron expression ("0 8 * * MON") or an interval ("@every 168h")
+	Schedule string `json:"schedule" db:"schedule"`
+	// Timezone is the IANA zone cron expressions are read in
+	Timezone    string             `json:"timezone" db:"timezone"`
+	Status      SubscriptionStatus `json:"status" db:"status"`
+	NextRunAt   *time.Time         `json:"next_run_at,omitempty" db:"next_run_at"`
+	LastRunAt   *time.Time         `json:"last_run_at,omitempty" db:"last_run_at"`
+	LastOrderID *int64             `json:"last_order_id,omitempty" db:"last_order_id"`
+	LastError   string             `json:"last_error,omitempty" db:"last_error"`
+	Items       []SubscriptionItem `json:"items" db:"-"`
+	CreatedAt   time.Time          `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time          `json:"updated_at" db:"updated_at"`
+}
+
+// SubscriptionItem is one line of the order a subscription places. Lines for
+// catalogue products are priced when each order is placed.
+type SubscriptionItem struct {
+	ID             int64           `json:"id" db:"id"`
+	SubscriptionID int64           `json:"subscription_id" db:"subscription_id"`
+	ProductID      *int64          `json:"product_id,omitempty" db:"product_id"`
+	ProductRef     string          `json:"product_ref,omitempty" db:"product_ref"`
+	Description    string          `json:"description,omitempty" db:"description"`
+	Quantity       int             `json:"quantity" db:"quantity"`
+	UnitPrice      decimal.Decimal `json:"unit_price" db:"unit_price"`
+}
+
+// Order builds the order the subscription places on each run
+func (s *Subscription) Order() *Order {
+	order := &Order{
+		CustomerID: strconv.FormatInt(s.CustomerID, 10),
+		Currency:   s.Currency,
+		Items:      make([]OrderItem, len(s.Items)),
+	}
+	for i, item := range s.Items {
+		order.Items[i] = OrderItem{
+			ProductID:   item.ProductID,
+			ProductRef:  item.ProductRef,
+			Description: item.Description,
+			Quantity:    item.Quantity,
+			UnitPrice:   item.UnitPrice,
+		}
+	}
+	return order
+}
+
+// SubscriptionRun is one scheduled run and the order it placed, or why it failed
+type SubscriptionRun struct {
+	ID             int64      `json:"id" db:"id"`
+	SubscriptionID int64      `json:"subscription_id" db:"subscription_id"`
+	ScheduledFor   time.Time  `json:"scheduled_for" db:"scheduled_for"`
+	OrderID        *int64     `json:"order_id,omitempty" db:"order_id"`
+	Error          string     `json:"error,omitempty" db:"error"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	FinishedAt     *time.Time `json:"finished_at,omitempty" db:"finished_at"`
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// TaxRate is the tax charged on goods sold into a region. A rate without a
+// category is the region's standard rate; category rates override it for
+// products in that category, e.g. zero-rated "food".
+type TaxRate struct {
+	ID       int64           `json:"id" db:"id"`
+	Name     string          `json:"name" db:"name"`
+	Region   string          `json:"region" db:"region"`
+	Category string          `json:"category,omitempty" db:"category"`
+	Rate     decimal.Decimal `json:"rate" db:"rate"`
+	// Inclusive means prices in the region already contain the tax
+	Inclusive bool      `json:"inclusive" db:"inclusive"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// TaxLine is one row of an order's tax breakdown: the lines taxed at the same rate
+type TaxLine struct {
+	Name      string          `json:"name"`
+	Rate      decimal.Decimal `json:"rate"`
+	Inclusive bool            `json:"inclusive"`
+	Net       decimal.Decimal `json:"net"`
+	Tax       decimal.Decimal `json:"tax"`
+}
+
+// TaxBreakdown groups the order lines by the tax they were charged. Net is the
+// amount after discount and before tax.
+func TaxBreakdown(items []OrderItem) []TaxLine {
+	var lines []TaxLine
+	for _, item := range items {
+		net := item.LineTotal.Sub(item.Discount)
+		if item.TaxInclusive {
+			net = net.Sub(item.Tax)
+		}
+
+		found := false
+		for i := range lines {
+			l := &lines[i]
+			if l.Name == item.TaxName && l.Rate.Equal(item.TaxRate) && l.Inclusive == item.TaxInclusive {
+				l.Net = l.Net.Add(net)
+				l.Tax = l.Tax.Add(item.Tax)
+				found = true
+				break
+			}
+		}
+		if !found {
+			lines = append(lines, TaxLine{
+				Name:      item.TaxName,
+				Rate:      item.TaxRate,
+				Inclusive: item.TaxInclusive,
+				Net:       net,
+				Tax:       item.Tax,
+			})
+		}
+	}
+	return lines
+}
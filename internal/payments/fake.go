@@ -0,0 +1,180 @@
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// FakeSignatureHeader carries the hex HMAC-SHA256 of a FakeProvider webhook body
+const FakeSignatureHeader = "X-Fake-Signature"
+
+// FakeProvider is an in-memory PaymentProvider for tests. Payments stay
+// pending until Settle, which returns the signed webhook the provider would
+// have sent. SetState changes the provider's view without a webhook, as
+// when one is lost.
+type FakeProvider struct {
+	name   string
+	secret []byte
+
+	// InitiateErr, when set, is returned by Initiate
+	InitiateErr error
+
+	mu       sync.Mutex
+	seq      int
+	payments map[string]*models.PaymentResult
+	refunds  []RefundRequest
+}
+
+var _ PaymentProvider = (*FakeProvider)(nil)
+
+func NewFakeProvider(name, secret string) *FakeProvider {
+	return &FakeProvider{name: name, secret: []byte(secret), payments: map[string]*models.PaymentResult{}}
+}
+
+func (f *FakeProvider) Name() string {
+	return f.name
+}
+
+func (f *FakeProvider) Initiate(ctx context.Context, req InitiateRequest) (*Initiation, error) {
+	if f.InitiateErr != nil {
+		return nil, f.InitiateErr
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.seq++
+	reference := fmt.Sprintf("%s_%d", f.name, f.seq)
+	f.payments[reference] = &models.PaymentResult{
+		Provider:  f.name,
+		Reference: reference,
+		Status:    models.PaymentPending,
+		Amount:    req.Amount,
+	}
+
+	return &Initiation{Reference: reference}, nil
+}
+
+func (f *FakeProvider) Query(ctx context.Context, reference string) (*models.PaymentResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	result, ok := f.payments[reference]
+	if !ok {
+		return nil, fmt.Errorf("%s payment %s: %w", f.name, reference, ErrUnknownPayment)
+	}
+	copied := *result
+	return &copied, nil
+}
+
+func (f *FakeProvider) Refund(ctx context.Context, req RefundRequest) (*RefundResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	result, ok := f.payments[req.Reference]
+	if !ok || result.Status != models.PaymentSucceeded {
+		return nil, fmt.Errorf("%w: %s payment %s is not paid", ErrInvalidRequest, f.name, req.Reference)
+	}
+	f.refunds = append(f.refunds, req)
+
+	return &RefundResult{Reference: fmt.Sprintf("%s_refund_%d", f.name, len(f.refunds))}, nil
+}
+
+// Refunds lists the refunds made so far
+func (f *FakeProvider) Refunds() []RefundRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]RefundRequest(nil), f.refunds...)
+}
+
+// fakeWebhookBody is the JSON the fake provider posts
+type fakeWebhookBody struct {
+	Reference string               `json:"reference"`
+	Status    models.PaymentStatus `json:"status"`
+	Amount    decimal.Decimal      `json:"amount"`
+	Receipt   string               `json:"receipt,omitempty"`
+	PaidAt    time.Time            `json:"paid_at"`
+}
+
+// Settle completes a payment and returns the signed webhook announcing it
+func (f *FakeProvider) Settle(reference string, status models.PaymentStatus, receipt string) (Webhook, error) {
+	result, err := f.SetState(reference, status, receipt)
+	if err != nil {
+		return Webhook{}, err
+	}
+
+	body, err := json.Marshal(fakeWebhookBody{
+		Reference: result.Reference,
+		Status:    result.Status,
+		Amount:    result.Amount,
+		Receipt:   result.Receipt,
+		PaidAt:    result.PaidAt,
+	})
+	if err != nil {
+		return Webhook{}, err
+	}
+
+	header := http.Header{}
+	header.Set(FakeSignatureHeader, f.sign(body))
+	return Webhook{Header: header, Body: body}, nil
+}
+
+// SetState changes what the provider reports for a payment without sending a webhook
+func (f *FakeProvider) SetState(reference string, status models.PaymentStatus, receipt string) (*models.PaymentResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	result, ok := f.payments[reference]
+	if !ok {
+		return nil, fmt.Errorf("%s payment %s: %w", f.name, reference, ErrUnknownPayment)
+	}
+	result.Status = status
+	result.Receipt = receipt
+	if status == models.PaymentSucceeded {
+		result.PaidAt = time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)
+	}
+
+	copied := *result
+	return &copied, nil
+}
+
+func (f *FakeProvider) VerifyWebhook(ctx context.Context, hook Webhook) (*models.PaymentResult, error) {
+	signature, err := hex.DecodeString(hook.Header.Get(FakeSignatureHeader))
+	if err != nil || !hmac.Equal(signature, f.mac(hook.Body)) {
+		return nil, ErrInvalidSignature
+	}
+
+	var body fakeWebhookBody
+	if err := json.Unmarshal(hook.Body, &body); err != nil {
+		return nil, fmt.Errorf("invalid %s webhook: %w", f.name, err)
+	}
+
+	return &models.PaymentResult{
+		Provider:  f.name,
+		Reference: body.Reference,
+		Status:    body.Status,
+		Amount:    body.Amount,
+		Receipt:   body.Receipt,
+		PaidAt:    body.PaidAt,
+	}, nil
+}
+
+func (f *FakeProvider) mac(body []byte) []byte {
+	h := hmac.New(sha256.New, f.secret)
+	h.Write(body)
+	return h.Sum(nil)
+}
+
+func (f *FakeProvider) sign(body []byte) string {
+	return hex.EncodeToString(f.mac(body))
+}
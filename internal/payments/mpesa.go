@@ -0,0 +1,440 @@
+// Package payments talks to payment providers. Mpesa starts Safaricom Daraja
+// STK push payments and parses their callbacks; Simulator stands in for
+// Daraja in tests and local development.
+package payments
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	defaultMpesaBaseURL = "https://sandbox.safaricom.co.ke"
+	// Daraja timestamps and transaction dates are East Africa Time
+	mpesaTimestampLayout = "20060102150405"
+	// tokenRefreshMargin renews the access token a little before Daraja expires it
+	tokenRefreshMargin = time.Minute
+)
+
+// eat is East Africa Time, which Daraja uses for timestamps
+var eat = time.FixedZone("EAT", 3*60*60)
+
+// MpesaConfig holds the Daraja credentials and the lipa na M-Pesa shortcode
+type MpesaConfig struct {
+	BaseURL        string
+	ConsumerKey    string
+	ConsumerSecret string
+	ShortCode      string
+	Passkey        string
+	// CallbackURL is where Daraja posts the result of each STK push
+	CallbackURL string
+	// CallbackToken is added to CallbackURL and checked on every callback,
+	// since Daraja does not sign them. It is required: without it anyone
+	// could post a successful payment.
+	CallbackToken string
+	// Initiator, SecurityCredential and ResultURL are only needed for
+	// reversals, which is how M-Pesa payments are refunded
+	Initiator          string
+	SecurityCredential string
+	ResultURL          string
+}
+
+// MpesaConfigFromEnv reads MPESA_BASE_URL (default the Daraja sandbox),
+// MPESA_CONSUMER_KEY, MPESA_CONSUMER_SECRET, MPESA_SHORTCODE, MPESA_PASSKEY,
+// MPESA_CALLBACK_URL and MPESA_CALLBACK_TOKEN, plus MPESA_INITIATOR,
+// MPESA_SECURITY_CREDENTIAL and MPESA_RESULT_URL for refunds
+func MpesaConfigFromEnv() MpesaConfig {
+	cfg := MpesaConfig{
+		BaseURL:        strings.TrimSpace(os.Getenv("MPESA_BASE_URL")),
+		ConsumerKey:    strings.TrimSpace(os.Getenv("MPESA_CONSUMER_KEY")),
+		ConsumerSecret: strings.TrimSpace(os.Getenv("MPESA_CONSUMER_SECRET")),
+		ShortCode:      strings.TrimSpace(os.Getenv("MPESA_SHORTCODE")),
+		Passkey:        strings.TrimSpace(os.Getenv("MPESA_PASSKEY")),
+		CallbackURL:    strings.TrimSpace(os.Getenv("MPESA_CALLBACK_URL")),
+		CallbackToken:  strings.TrimSpace(os.Getenv("MPESA_CALLBACK_TOKEN")),
+
+		Initiator:          strings.TrimSpace(os.Getenv("MPESA_INITIATOR")),
+		SecurityCredential: strings.TrimSpace(os.Getenv("MPESA_SECURITY_CREDENTIAL")),
+		ResultURL:          strings.TrimSpace(os.Getenv("MPESA_RESULT_URL")),
+	}
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = defaultMpesaBaseURL
+	}
+	return cfg
+}
+
+// Validate reports the first missing setting
+func (c MpesaConfig) Validate() error {
+	required := []struct{ name, value string }{
+		{"MPESA_CONSUMER_KEY", c.ConsumerKey},
+		{"MPESA_CONSUMER_SECRET", c.ConsumerSecret},
+		{"MPESA_SHORTCODE", c.ShortCode},
+		{"MPESA_PASSKEY", c.Passkey},
+		{"MPESA_CALLBACK_URL", c.CallbackURL},
+		{"MPESA_CALLBACK_TOKEN", c.CallbackToken},
+	}
+	for _, r := range required {
+		if r.value == "" {
+			return fmt.Errorf("%s must be set in environment", r.name)
+		}
+	}
+	return nil
+}
+
+// callbackURL is CallbackURL carrying CallbackToken, when one is set
+func (c MpesaConfig) callbackURL() (string, error) {
+	return c.withToken(c.CallbackURL, "MPESA_CALLBACK_URL")
+}
+
+// resultURL is ResultURL carrying CallbackToken, so reversal results are
+// checked the same way as STK callbacks
+func (c MpesaConfig) resultURL() (string, error) {
+	return c.withToken(c.ResultURL, "MPESA_RESULT_URL")
+}
+
+func (c MpesaConfig) withToken(raw, setting string) (string, error) {
+	if c.CallbackToken == "" {
+		return raw, nil
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s: %w", setting, err)
+	}
+	q := u.Query()
+	q.Set("token", c.CallbackToken)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// VerifyCallbackToken reports whether a callback carried the configured
+// token. Every callback is rejected when no token is configured.
+func (c MpesaConfig) VerifyCallbackToken(token string) bool {
+	if c.CallbackToken == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(c.CallbackToken)) == 1
+}
+
+// password is the STK push password: base64(shortcode + passkey + timestamp)
+func (c MpesaConfig) password(timestamp string) string {
+	return base64.StdEncoding.EncodeToString([]byte(c.ShortCode + c.Passkey + timestamp))
+}
+
+// STKPushRequest asks the customer's phone to approve a payment
+type STKPushRequest struct {
+	// Phone is the paying number without the +, e.g. 254712345678
+	Phone  string
+	Amount decimal.Decimal
+	// AccountReference is shown to the customer, e.g. the order number
+	AccountReference string
+	Description      string
+}
+
+// STKPushResponse is Daraja's acknowledgement that the prompt was sent
+type STKPushResponse struct {
+	MerchantRequestID   string `json:"MerchantRequestID"`
+	CheckoutRequestID   string `json:"CheckoutRequestID"`
+	ResponseCode        string `json:"ResponseCode"`
+	ResponseDescription string `json:"ResponseDescription"`
+	CustomerMessage     string `json:"CustomerMessage"`
+}
+
+// Mpesa is a Daraja API client
+type Mpesa struct {
+	cfg        MpesaConfig
+	httpClient *http.Client
+	now        func() time.Time
+
+	mu           sync.Mutex
+	token        string
+	tokenExpires time.Time
+}
+
+func NewMpesa(cfg MpesaConfig, httpClient *http.Client) *Mpesa {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &Mpesa{cfg: cfg, httpClient: httpClient, now: time.Now}
+}
+
+// accessToken returns a cached OAuth token, fetching a new one when it is about to expire
+func (m *Mpesa) accessToken(ctx context.Context) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.token != "" && m.now().Before(m.tokenExpires) {
+		return m.token, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, m.cfg.BaseURL+"/oauth/v1/generate?grant_type=client_credentials", nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.SetBasicAuth(m.cfg.ConsumerKey, m.cfg.ConsumerSecret)
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   string `json:"expires_in"`
+	}
+	if err := m.do(req, &body); err != nil {
+		return "", fmt.Errorf("failed to get M-Pesa access token: %w", err)
+	}
+
+	seconds, err := strconv.Atoi(body.ExpiresIn)
+	if err != nil || body.AccessToken == "" {
+		return "", errors.New("M-Pesa returned an invalid access token")
+	}
+	m.token = body.AccessToken
+	m.tokenExpires = m.now().Add(time.Duration(seconds)*time.Second - tokenRefreshMargin)
+
+	return m.token, nil
+}
+
+// STKPush sends the payment prompt to the customer's phone. The result
+// arrives later at the callback URL, keyed by the returned CheckoutRequestID.
+func (m *Mpesa) STKPush(ctx context.Context, push STKPushRequest) (*STKPushResponse, error) {
+	if !push.Amount.IsInteger() || !push.Amount.IsPositive() {
+		return nil, fmt.Errorf("M-Pesa amounts must be whole shillings, got %s", push.Amount)
+	}
+
+	callbackURL, err := m.cfg.callbackURL()
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp := m.now().In(eat).Format(mpesaTimestampLayout)
+	payload := map[string]any{
+		"BusinessShortCode": m.cfg.ShortCode,
+		"Password":          m.cfg.password(timestamp),
+		"Timestamp":         timestamp,
+		"TransactionType":   "CustomerPayBillOnline",
+		"Amount":            push.Amount.IntPart(),
+		"PartyA":            push.Phone,
+		"PartyB":            m.cfg.ShortCode,
+		"PhoneNumber":       push.Phone,
+		"CallBackURL":       callbackURL,
+		"AccountReference":  push.AccountReference,
+		"TransactionDesc":   push.Description,
+	}
+
+	var resp STKPushResponse
+	if err := m.post(ctx, "/mpesa/stkpush/v1/processrequest", payload, &resp); err != nil {
+		return nil, fmt.Errorf("STK push failed: %w", err)
+	}
+	if resp.ResponseCode != "0" {
+		return nil, fmt.Errorf("STK push rejected: %s (code: %s)", resp.ResponseDescription, resp.ResponseCode)
+	}
+
+	return &resp, nil
+}
+
+// darajaError is an error response from the Daraja API
+type darajaError struct {
+	Status  int
+	Code    string `json:"errorCode"`
+	Message string `json:"errorMessage"`
+}
+
+func (e *darajaError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("status %d: %s", e.Status, e.Message)
+	}
+	return fmt.Sprintf("status %d: %s (code: %s)", e.Status, e.Message, e.Code)
+}
+
+// post sends an authenticated JSON request to Daraja and decodes the response into out
+func (m *Mpesa) post(ctx context.Context, path string, payload, out any) error {
+	token, err := m.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.cfg.BaseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	return m.do(req, out)
+}
+
+// do sends req and decodes a 200 JSON response into out. Other responses
+// are returned as a *darajaError.
+func (m *Mpesa) do(req *http.Request, out any) error {
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		apiErr := &darajaError{Status: resp.StatusCode}
+		if json.Unmarshal(body, apiErr) != nil || apiErr.Message == "" {
+			apiErr.Message = string(body)
+		}
+		return apiErr
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
+
+// STKCallback is the outcome of an STK push as reported by Daraja
+type STKCallback struct {
+	MerchantRequestID string
+	CheckoutRequestID string
+	// ResultCode is 0 when the customer paid, e.g. 1032 when they cancelled the prompt
+	ResultCode int
+	ResultDesc string
+	// The fields below are only set for successful payments
+	Amount          decimal.Decimal
+	Receipt         string
+	TransactionDate time.Time
+	Phone           string
+}
+
+// Succeeded reports whether the customer paid
+func (c *STKCallback) Succeeded() bool {
+	return c.ResultCode == 0
+}
+
+// stkCallbackBody is the JSON Daraja posts to the callback URL
+type stkCallbackBody struct {
+	Body struct {
+		StkCallback struct {
+			MerchantRequestID string `json:"MerchantRequestID"`
+			CheckoutRequestID string `json:"CheckoutRequestID"`
+			ResultCode        int    `json:"ResultCode"`
+			ResultDesc        string `json:"ResultDesc"`
+			CallbackMetadata  struct {
+				Item []struct {
+					Name  string          `json:"Name"`
+					Value json.RawMessage `json:"Value"`
+				} `json:"Item"`
+			} `json:"CallbackMetadata"`
+		} `json:"stkCallback"`
+	} `json:"Body"`
+}
+
+// ParseSTKCallback decodes the body Daraja posts to the callback URL
+func ParseSTKCallback(body []byte) (*STKCallback, error) {
+	var raw stkCallbackBody
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, fmt.Errorf("invalid STK callback: %w", err)
+	}
+
+	stk := raw.Body.StkCallback
+	if stk.CheckoutRequestID == "" {
+		return nil, errors.New("invalid STK callback: CheckoutRequestID is missing")
+	}
+
+	cb := &STKCallback{
+		MerchantRequestID: stk.MerchantRequestID,
+		CheckoutRequestID: stk.CheckoutRequestID,
+		ResultCode:        stk.ResultCode,
+		ResultDesc:        stk.ResultDesc,
+	}
+
+	// Numbers in the metadata are JSON numbers and the receipt a string
+	for _, item := range stk.CallbackMetadata.Item {
+		value := strings.Trim(string(item.Value), `"`)
+		switch item.Name {
+		case "Amount":
+			amount, err := decimal.NewFromString(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid STK callback amount %q", value)
+			}
+			cb.Amount = amount
+		case "MpesaReceiptNumber":
+			cb.Receipt = value
+		case "TransactionDate":
+			date, err := time.ParseInLocation(mpesaTimestampLayout, value, eat)
+			if err != nil {
+				return nil, fmt.Errorf("invalid STK callback transaction date %q", value)
+			}
+			cb.TransactionDate = date
+		case "PhoneNumber":
+			cb.Phone = value
+		}
+	}
+
+	if cb.Succeeded() && cb.Receipt == "" {
+		return nil, errors.New("invalid STK callback: MpesaReceiptNumber is missing")
+	}
+
+	return cb, nil
+}
+
+// ReversalResult is the outcome of a reversal as reported by Daraja
+type ReversalResult struct {
+	// ConversationID matches the one returned when the reversal was requested
+	ConversationID           string
+	OriginatorConversationID string
+	// ResultCode is 0 when the money was returned
+	ResultCode    int
+	ResultDesc    string
+	TransactionID string
+}
+
+// Succeeded reports whether the payment was reversed
+func (r *ReversalResult) Succeeded() bool {
+	return r.ResultCode == 0
+}
+
+// reversalResultBody is the JSON Daraja posts to the result URL
+type reversalResultBody struct {
+	Result struct {
+		ResultType               int    `json:"ResultType"`
+		ResultCode               int    `json:"ResultCode"`
+		ResultDesc               string `json:"ResultDesc"`
+		OriginatorConversationID string `json:"OriginatorConversationID"`
+		ConversationID           string `json:"ConversationID"`
+		TransactionID            string `json:"TransactionID"`
+	} `json:"Result"`
+}
+
+// ParseReversalResult decodes the body Daraja posts to the result URL
+func ParseReversalResult(body []byte) (*ReversalResult, error) {
+	var raw reversalResultBody
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, fmt.Errorf("invalid reversal result: %w", err)
+	}
+	if raw.Result.ConversationID == "" {
+		return nil, errors.New("invalid reversal result: ConversationID is missing")
+	}
+
+	return &ReversalResult{
+		ConversationID:           raw.Result.ConversationID,
+		OriginatorConversationID: raw.Result.OriginatorConversationID,
+		ResultCode:               raw.Result.ResultCode,
+		ResultDesc:               raw.Result.ResultDesc,
+		TransactionID:            raw.Result.TransactionID,
+	}, nil
+}
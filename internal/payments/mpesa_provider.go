@@ -0,0 +1,195 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+)
+
+// Daraja error codes the provider acts on
+const (
+	darajaStillProcessing = "500.001.1001"
+	darajaInvalidRequest  = "400.002.02"
+)
+
+var (
+	_ PaymentProvider       = (*Mpesa)(nil)
+	_ RefundWebhookVerifier = (*Mpesa)(nil)
+)
+
+func (m *Mpesa) Name() string {
+	return models.PaymentProviderMpesa
+}
+
+// Initiate sends an STK push to the payer's phone. M-Pesa only collects whole
+// Kenyan shillings from Kenyan numbers.
+func (m *Mpesa) Initiate(ctx context.Context, req InitiateRequest) (*Initiation, error) {
+	if req.Currency != "KES" {
+		return nil, fmt.Errorf("%w: M-Pesa only accepts KES, order is in %s", ErrInvalidRequest, req.Currency)
+	}
+	if !req.Amount.IsInteger() {
+		return nil, fmt.Errorf("%w: M-Pesa only accepts whole shillings, order total is %s", ErrInvalidRequest, models.FormatMoney(req.Amount, req.Currency))
+	}
+	if req.Phone == "" {
+		return nil, fmt.Errorf("%w: M-Pesa payments need a phone number", ErrInvalidRequest)
+	}
+	if !strings.HasPrefix(req.Phone, "+254") {
+		return nil, fmt.Errorf("%w: M-Pesa payments need a Kenyan phone number, got %s", ErrInvalidRequest, req.Phone)
+	}
+
+	resp, err := m.STKPush(ctx, STKPushRequest{
+		Phone:            strings.TrimPrefix(req.Phone, "+"),
+		Amount:           req.Amount,
+		AccountReference: fmt.Sprintf("Order %d", req.OrderID),
+		Description:      req.Description,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Initiation{Reference: resp.CheckoutRequestID, CustomerMessage: resp.CustomerMessage}, nil
+}
+
+// Query asks Daraja for the outcome of an STK push. Daraja reports the
+// result code but not the receipt or amount, so those are left empty.
+func (m *Mpesa) Query(ctx context.Context, reference string) (*models.PaymentResult, error) {
+	timestamp := m.now().In(eat).Format(mpesaTimestampLayout)
+	payload := map[string]any{
+		"BusinessShortCode": m.cfg.ShortCode,
+		"Password":          m.cfg.password(timestamp),
+		"Timestamp":         timestamp,
+		"CheckoutRequestID": reference,
+	}
+
+	var resp struct {
+		ResponseCode string `json:"ResponseCode"`
+		ResultCode   string `json:"ResultCode"`
+		ResultDesc   string `json:"ResultDesc"`
+	}
+	err := m.post(ctx, "/mpesa/stkpushquery/v1/query", payload, &resp)
+
+	result := &models.PaymentResult{Provider: m.Name(), Reference: reference}
+	var apiErr *darajaError
+	switch {
+	case errors.As(err, &apiErr) && apiErr.Code == darajaStillProcessing:
+		result.Status = models.PaymentPending
+		return result, nil
+	case errors.As(err, &apiErr) && apiErr.Code == darajaInvalidRequest:
+		return nil, fmt.Errorf("M-Pesa checkout %s: %w", reference, ErrUnknownPayment)
+	case err != nil:
+		return nil, fmt.Errorf("STK push query failed: %w", err)
+	}
+
+	code, err := strconv.Atoi(resp.ResultCode)
+	if err != nil {
+		return nil, fmt.Errorf("STK push query returned result code %q", resp.ResultCode)
+	}
+	result.ResultCode = code
+	result.ResultDesc = resp.ResultDesc
+	result.Status = models.PaymentFailed
+	if code == ResultSuccess {
+		result.Status = models.PaymentSucceeded
+	}
+
+	return result, nil
+}
+
+// Refund asks Daraja to reverse the payment. Reversals always return the
+// whole payment and complete asynchronously, reporting to ResultURL, so the
+// refund is pending until VerifyRefundWebhook decodes the result.
+func (m *Mpesa) Refund(ctx context.Context, req RefundRequest) (*RefundResult, error) {
+	if m.cfg.Initiator == "" || m.cfg.SecurityCredential == "" || m.cfg.ResultURL == "" {
+		return nil, fmt.Errorf("%w: M-Pesa refunds need MPESA_INITIATOR, MPESA_SECURITY_CREDENTIAL and MPESA_RESULT_URL", ErrInvalidRequest)
+	}
+	if req.Receipt == "" {
+		return nil, fmt.Errorf("%w: only paid M-Pesa payments can be refunded", ErrInvalidRequest)
+	}
+	if !req.Amount.Equal(req.PaymentAmount) {
+		return nil, fmt.Errorf("%w: M-Pesa reversals return the whole payment of %s", ErrInvalidRequest, models.FormatMoney(req.PaymentAmount, req.Currency))
+	}
+
+	resultURL, err := m.cfg.resultURL()
+	if err != nil {
+		return nil, err
+	}
+
+	payload := map[string]any{
+		"Initiator":              m.cfg.Initiator,
+		"SecurityCredential":     m.cfg.SecurityCredential,
+		"CommandID":              "TransactionReversal",
+		"TransactionID":          req.Receipt,
+		"Amount":                 req.Amount.IntPart(),
+		"ReceiverParty":          m.cfg.ShortCode,
+		"RecieverIdentifierType": "11",
+		"ResultURL":              resultURL,
+		"QueueTimeOutURL":        resultURL,
+		"Remarks":                req.Reason,
+		"Occasion":               req.Reference,
+	}
+
+	var resp struct {
+		ConversationID      string `json:"ConversationID"`
+		ResponseCode        string `json:"ResponseCode"`
+		ResponseDescription string `json:"ResponseDescription"`
+	}
+	if err := m.post(ctx, "/mpesa/reversal/v1/request", payload, &resp); err != nil {
+		return nil, fmt.Errorf("M-Pesa reversal failed: %w", err)
+	}
+	if resp.ResponseCode != "0" {
+		return nil, fmt.Errorf("M-Pesa reversal rejected: %s (code: %s)", resp.ResponseDescription, resp.ResponseCode)
+	}
+
+	return &RefundResult{Reference: resp.ConversationID, Pending: true}, nil
+}
+
+// VerifyWebhook checks the callback token, as Daraja does not sign its
+// callbacks, and decodes the STK push result
+func (m *Mpesa) VerifyWebhook(ctx context.Context, hook Webhook) (*models.PaymentResult, error) {
+	if !m.cfg.VerifyCallbackToken(hook.Query.Get("token")) {
+		return nil, ErrInvalidSignature
+	}
+
+	callback, err := ParseSTKCallback(hook.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.PaymentResult{
+		Provider:   m.Name(),
+		Reference:  callback.CheckoutRequestID,
+		Status:     models.PaymentFailed,
+		Amount:     callback.Amount,
+		Receipt:    callback.Receipt,
+		ResultCode: callback.ResultCode,
+		ResultDesc: callback.ResultDesc,
+	}
+	if callback.Succeeded() {
+		result.Status = models.PaymentSucceeded
+		result.PaidAt = callback.TransactionDate
+	}
+
+	return result, nil
+}
+
+// VerifyRefundWebhook checks the callback token and decodes the result of a reversal
+func (m *Mpesa) VerifyRefundWebhook(ctx context.Context, hook Webhook) (*RefundOutcome, error) {
+	if !m.cfg.VerifyCallbackToken(hook.Query.Get("token")) {
+		return nil, ErrInvalidSignature
+	}
+
+	result, err := ParseReversalResult(hook.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RefundOutcome{
+		Reference:  result.ConversationID,
+		Succeeded:  result.Succeeded(),
+		ResultCode: result.ResultCode,
+		ResultDesc: result.ResultDesc,
+	}, nil
+}
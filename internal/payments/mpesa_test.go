@@ -0,0 +1,225 @@
+package payments
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestDaraja starts the simulator and a callback receiver, returning a
+// client wired to both and the channel callbacks arrive on
+func newTestDaraja(t *testing.T, cfg MpesaConfig) (*Mpesa, *Simulator, <-chan []byte) {
+	t.Helper()
+
+	callbacks := make(chan []byte, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if cfg.CallbackToken != "" && r.URL.Query().Get("token") != cfg.CallbackToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		callbacks <- body
+	}))
+	t.Cleanup(receiver.Close)
+
+	cfg = SimulatorDefaults(cfg, receiver.URL+"/payments/mpesa/callback")
+	simulator := NewSimulator(cfg)
+	simulator.Delay = 0
+	daraja := httptest.NewServer(simulator)
+	t.Cleanup(daraja.Close)
+
+	cfg.BaseURL = daraja.URL
+	return NewMpesa(cfg, daraja.Client()), simulator, callbacks
+}
+
+func TestSTKPush_Success(t *testing.T) {
+	client, simulator, callbacks := newTestDaraja(t, MpesaConfig{CallbackToken: "s3cret"})
+
+	resp, err := client.STKPush(context.Background(), STKPushRequest{
+		Phone:            "254712345678",
+		Amount:           decimal.NewFromInt(1500),
+		AccountReference: "Order 7",
+		Description:      "Payment for order 7",
+	})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, resp.CheckoutRequestID)
+
+	simulator.Wait()
+	cb, err := ParseSTKCallback(<-callbacks)
+	assert.NoError(t, err)
+
+	assert.True(t, cb.Succeeded())
+	assert.Equal(t, resp.CheckoutRequestID, cb.CheckoutRequestID)
+	assert.True(t, cb.Amount.Equal(decimal.NewFromInt(1500)))
+	assert.NotEmpty(t, cb.Receipt)
+	assert.Equal(t, "254712345678", cb.Phone)
+	assert.False(t, cb.TransactionDate.IsZero())
+}
+
+func TestSTKPush_CancelledByUser(t *testing.T) {
+	client, simulator, callbacks := newTestDaraja(t, MpesaConfig{})
+	simulator.SetResult("254700000001", ResultCancelledByUser)
+
+	_, err := client.STKPush(context.Background(), STKPushRequest{Phone: "254700000001", Amount: decimal.NewFromInt(10)})
+	assert.NoError(t, err)
+
+	simulator.Wait()
+	cb, err := ParseSTKCallback(<-callbacks)
+	assert.NoError(t, err)
+
+	assert.False(t, cb.Succeeded())
+	assert.Equal(t, ResultCancelledByUser, cb.ResultCode)
+	assert.Empty(t, cb.Receipt)
+}
+
+func TestSTKPush_ReusesAccessToken(t *testing.T) {
+	client, simulator, _ := newTestDaraja(t, MpesaConfig{})
+
+	for i := 0; i < 2; i++ {
+		_, err := client.STKPush(context.Background(), STKPushRequest{Phone: "254712345678", Amount: decimal.NewFromInt(10)})
+		assert.NoError(t, err)
+	}
+	simulator.Wait()
+
+	assert.Len(t, simulator.tokens, 1)
+}
+
+func TestSTKPush_Rejected(t *testing.T) {
+	client, _, _ := newTestDaraja(t, MpesaConfig{})
+
+	_, err := client.STKPush(context.Background(), STKPushRequest{Phone: "254712345678", Amount: decimal.RequireFromString("10.50")})
+	assert.EqualError(t, err, "M-Pesa amounts must be whole shillings, got 10.5")
+
+	// Credentials the simulator does not know are refused like Daraja would
+	client.cfg.ConsumerSecret = "wrong"
+	_, err = client.STKPush(context.Background(), STKPushRequest{Phone: "254712345678", Amount: decimal.NewFromInt(10)})
+	assert.ErrorContains(t, err, "failed to get M-Pesa access token: status 400")
+}
+
+func TestParseSTKCallback(t *testing.T) {
+	body := []byte(`{"Body":{"stkCallback":{"MerchantRequestID":"29115-34620561-1","CheckoutRequestID":"ws_CO_191220191020363925",
+		"ResultCode":0,"ResultDesc":"The service request is processed successfully.",
+		"CallbackMetadata":{"Item":[{"Name":"Amount","Value":1.00},{"Name":"MpesaReceiptNumber","Value":"NLJ7RT61SV"},
+		{"Name":"Balance"},{"Name":"TransactionDate","Value":20191219102115},{"Name":"PhoneNumber","Value":254708374149}]}}}}`)
+
+	cb, err := ParseSTKCallback(body)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "ws_CO_191220191020363925", cb.CheckoutRequestID)
+	assert.Equal(t, "NLJ7RT61SV", cb.Receipt)
+	assert.True(t, cb.Amount.Equal(decimal.NewFromInt(1)))
+	assert.Equal(t, "254708374149", cb.Phone)
+	assert.True(t, cb.TransactionDate.Equal(time.Date(2019, 12, 19, 7, 21, 15, 0, time.UTC)))
+
+	_, err = ParseSTKCallback([]byte(`{"Body":{"stkCallback":{"ResultCode":0}}}`))
+	assert.EqualError(t, err, "invalid STK callback: CheckoutRequestID is missing")
+}
+
+func TestVerifyCallbackToken(t *testing.T) {
+	assert.False(t, MpesaConfig{}.VerifyCallbackToken(""))
+	assert.True(t, MpesaConfig{CallbackToken: "abc"}.VerifyCallbackToken("abc"))
+	assert.False(t, MpesaConfig{CallbackToken: "abc"}.VerifyCallbackToken("abd"))
+}
+
+func TestMpesaConfigValidate(t *testing.T) {
+	cfg := SimulatorDefaults(MpesaConfig{}, "https://shop.example/payments/mpesa/callback")
+	assert.NoError(t, cfg.Validate())
+
+	cfg.CallbackToken = ""
+	assert.EqualError(t, cfg.Validate(), "MPESA_CALLBACK_TOKEN must be set in environment")
+}
+
+func TestMpesaQuery(t *testing.T) {
+	client, simulator, _ := newTestDaraja(t, MpesaConfig{})
+	simulator.Delay = time.Hour
+
+	initiation, err := client.Initiate(context.Background(), InitiateRequest{OrderID: 7, Amount: decimal.NewFromInt(100), Currency: "KES", Phone: "+254712345678"})
+	assert.NoError(t, err)
+
+	// The simulated customer has not answered the prompt yet
+	result, err := client.Query(context.Background(), initiation.Reference)
+	assert.NoError(t, err)
+	assert.Equal(t, models.PaymentPending, result.Status)
+
+	simulator.mu.Lock()
+	simulator.pushes[initiation.Reference].answered = true
+	simulator.mu.Unlock()
+
+	result, err = client.Query(context.Background(), initiation.Reference)
+	assert.NoError(t, err)
+	assert.Equal(t, models.PaymentSucceeded, result.Status)
+
+	_, err = client.Query(context.Background(), "ws_CO_unknown")
+	assert.ErrorIs(t, err, ErrUnknownPayment)
+}
+
+func TestMpesaInitiate_Invalid(t *testing.T) {
+	client := NewMpesa(MpesaConfig{}, nil)
+
+	tests := []struct {
+		name string
+		req  InitiateRequest
+		want string
+	}{
+		{"currency", InitiateRequest{Amount: decimal.NewFromInt(10), Currency: "UGX", Phone: "+254712345678"}, "invalid payment request: M-Pesa only accepts KES, order is in UGX"},
+		{"cents", InitiateRequest{Amount: decimal.RequireFromString("10.50"), Currency: "KES", Phone: "+254712345678"}, "invalid payment request: M-Pesa only accepts whole shillings, order total is KES 10.50"},
+		{"no phone", InitiateRequest{Amount: decimal.NewFromInt(10), Currency: "KES"}, "invalid payment request: M-Pesa payments need a phone number"},
+		{"foreign phone", InitiateRequest{Amount: decimal.NewFromInt(10), Currency: "KES", Phone: "+256772123456"}, "invalid payment request: M-Pesa payments need a Kenyan phone number, got +256772123456"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := client.Initiate(context.Background(), tt.req)
+			assert.EqualError(t, err, tt.want)
+		})
+	}
+}
+
+func TestMpesaRefund(t *testing.T) {
+	client, simulator, callbacks := newTestDaraja(t, MpesaConfig{Initiator: "api-op", SecurityCredential: "cred"})
+
+	initiation, err := client.Initiate(context.Background(), InitiateRequest{Amount: decimal.NewFromInt(100), Currency: "KES", Phone: "+254712345678"})
+	assert.NoError(t, err)
+	simulator.Wait()
+	cb, err := ParseSTKCallback(<-callbacks)
+	assert.NoError(t, err)
+
+	_, err = client.Refund(context.Background(), RefundRequest{Reference: initiation.Reference, Receipt: cb.Receipt, Amount: decimal.NewFromInt(40), PaymentAmount: decimal.NewFromInt(100), Currency: "KES"})
+	assert.EqualError(t, err, "invalid payment request: M-Pesa reversals return the whole payment of KES 100.00")
+
+	refund, err := client.Refund(context.Background(), RefundRequest{Reference: initiation.Reference, Receipt: cb.Receipt, Amount: decimal.NewFromInt(100), PaymentAmount: decimal.NewFromInt(100), Currency: "KES", Reason: "damaged"})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, refund.Reference)
+	assert.True(t, refund.Pending)
+	assert.Equal(t, []string{cb.Receipt}, simulator.Reversals())
+
+	// The result is posted to the result URL, carrying the callback token
+	simulator.Wait()
+	body := <-callbacks
+	outcome, err := client.VerifyRefundWebhook(context.Background(), Webhook{Query: url.Values{"token": {"simulator-token"}}, Body: body})
+	assert.NoError(t, err)
+	assert.Equal(t, refund.Reference, outcome.Reference)
+	assert.True(t, outcome.Succeeded)
+
+	_, err = client.VerifyRefundWebhook(context.Background(), Webhook{Query: url.Values{"token": {"wrong"}}, Body: body})
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+}
+
+func TestParseReversalResult(t *testing.T) {
+	result, err := ParseReversalResult([]byte(`{"Result":{"ResultType":0,"ResultCode":2001,"ResultDesc":"The initiator information is invalid.","OriginatorConversationID":"29112-34801843-1","ConversationID":"AG_20191219_00006c6fddb15123addf","TransactionID":"NLJ0000000"}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "AG_20191219_00006c6fddb15123addf", result.ConversationID)
+	assert.Equal(t, 2001, result.ResultCode)
+	assert.False(t, result.Succeeded())
+
+	_, err = ParseReversalResult([]byte(`{"Result":{"ResultCode":0}}`))
+	assert.EqualError(t, err, "invalid reversal result: ConversationID is missing")
+}
@@ -0,0 +1,143 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	// ErrInvalidRequest wraps requests a provider refuses before contacting its API
+	ErrInvalidRequest = errors.New("invalid payment request")
+	// ErrInvalidSignature is returned when a webhook was not sent by the provider
+	ErrInvalidSignature = errors.New("webhook signature is invalid")
+	// ErrUnknownProvider is returned for provider names that are not registered
+	ErrUnknownProvider = errors.New("unknown payment provider")
+	// ErrUnknownPayment is returned when the provider has no record of a reference
+	ErrUnknownPayment = errors.New("provider has no such payment")
+)
+
+// InitiateRequest asks a provider to collect an order's amount
+type InitiateRequest struct {
+	OrderID  int64
+	Amount   decimal.Decimal
+	Currency string
+	// Phone is the payer's number in E.164, for providers that charge a phone
+	Phone       string
+	Description string
+}
+
+// Initiation is the provider's acknowledgement of a payment it will complete later
+type Initiation struct {
+	// Reference is the provider's id for the attempt, used by webhooks and queries
+	Reference string
+	// CustomerMessage is what the provider says to tell the payer, if anything
+	CustomerMessage string
+}
+
+// RefundRequest asks a provider to return money from a completed payment
+type RefundRequest struct {
+	Reference string
+	Receipt   string
+	// Amount is what to refund and PaymentAmount what the customer originally paid
+	Amount        decimal.Decimal
+	PaymentAmount decimal.Decimal
+	Currency      string
+	Reason        string
+}
+
+// RefundResult is the provider's acknowledgement of a refund
+type RefundResult struct {
+	Reference string
+	// Pending is set when the provider has only accepted the refund and
+	// reports its result later through a webhook
+	Pending bool
+}
+
+// RefundOutcome is the result a provider reports for a pending refund
+type RefundOutcome struct {
+	// Reference is the RefundResult.Reference of the refund
+	Reference  string
+	Succeeded  bool
+	ResultCode int
+	ResultDesc string
+}
+
+// Webhook is an HTTP notification received from a provider
+type Webhook struct {
+	Header http.Header
+	Query  url.Values
+	Body   []byte
+}
+
+// PaymentProvider is a payment gateway. Payments are asynchronous: Initiate
+// starts one and the provider later reports the outcome through a webhook,
+// which VerifyWebhook authenticates and decodes. Query asks for the outcome
+// directly, which reconciliation uses to catch lost webhooks.
+type PaymentProvider interface {
+	// Name identifies the provider in URLs and in the payments table, e.g. mpesa
+	Name() string
+	Initiate(ctx context.Context, req InitiateRequest) (*Initiation, error)
+	Query(ctx context.Context, reference string) (*models.PaymentResult, error)
+	Refund(ctx context.Context, req RefundRequest) (*RefundResult, error)
+	VerifyWebhook(ctx context.Context, hook Webhook) (*models.PaymentResult, error)
+}
+
+// RefundWebhookVerifier is implemented by providers whose refunds can be
+// pending. VerifyRefundWebhook authenticates and decodes the webhook that
+// reports how such a refund ended.
+type RefundWebhookVerifier interface {
+	VerifyRefundWebhook(ctx context.Context, hook Webhook) (*RefundOutcome, error)
+}
+
+// Registry holds the configured payment providers by name
+type Registry struct {
+	mu        sync.RWMutex
+	providers map[string]PaymentProvider
+}
+
+func NewRegistry(providers ...PaymentProvider) *Registry {
+	r := &Registry{providers: map[string]PaymentProvider{}}
+	for _, p := range providers {
+		r.Register(p)
+	}
+	return r
+}
+
+// Register adds a provider, replacing any registered under the same name
+func (r *Registry) Register(p PaymentProvider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.providers[p.Name()] = p
+}
+
+func (r *Registry) Get(name string) (PaymentProvider, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	p, ok := r.providers[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownProvider, name)
+	}
+	return p, nil
+}
+
+// Names lists the registered providers in alphabetical order
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,48 @@
+package payments
+
+import (
+	"context"
+	"testing"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry(NewFakeProvider("card", "a"), NewMpesa(MpesaConfig{}, nil))
+
+	assert.Equal(t, []string{"card", "mpesa"}, registry.Names())
+
+	p, err := registry.Get("card")
+	assert.NoError(t, err)
+	assert.Equal(t, "card", p.Name())
+
+	_, err = registry.Get("paypal")
+	assert.ErrorIs(t, err, ErrUnknownProvider)
+	assert.EqualError(t, err, `unknown payment provider "paypal"`)
+}
+
+func TestFakeProvider(t *testing.T) {
+	fake := NewFakeProvider("card", "secret")
+
+	initiation, err := fake.Initiate(context.Background(), InitiateRequest{Amount: decimal.NewFromInt(250)})
+	assert.NoError(t, err)
+
+	hook, err := fake.Settle(initiation.Reference, models.PaymentSucceeded, "RCPT-1")
+	assert.NoError(t, err)
+
+	result, err := fake.VerifyWebhook(context.Background(), hook)
+	assert.NoError(t, err)
+	assert.Equal(t, models.PaymentSucceeded, result.Status)
+	assert.Equal(t, "RCPT-1", result.Receipt)
+	assert.True(t, result.Amount.Equal(decimal.NewFromInt(250)))
+
+	// Another provider's secret does not verify
+	_, err = NewFakeProvider("card", "other").VerifyWebhook(context.Background(), hook)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	_, err = fake.Refund(context.Background(), RefundRequest{Reference: initiation.Reference, Amount: decimal.NewFromInt(100)})
+	assert.NoError(t, err)
+	assert.Len(t, fake.Refunds(), 1)
+}
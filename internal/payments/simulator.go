@@ -0,0 +1,414 @@
+package payments
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Result codes the simulator can answer an STK push with
+const (
+	ResultSuccess             = 0
+	ResultInsufficientBalance = 1
+	ResultCancelledByUser     = 1032
+	ResultUnreachable         = 1037
+)
+
+var resultDescriptions = map[int]string{
+	ResultSuccess:             "The service request is processed successfully.",
+	ResultInsufficientBalance: "The balance is insufficient for the transaction.",
+	ResultCancelledByUser:     "Request cancelled by user",
+	ResultUnreachable:         "DS timeout user cannot be reached",
+}
+
+// SimulatorDefaults fills the settings cfg leaves empty with values the
+// simulator accepts, so local development needs no Daraja credentials
+func SimulatorDefaults(cfg MpesaConfig, callbackURL string) MpesaConfig {
+	defaults := []struct {
+		field *string
+		value string
+	}{
+		{&cfg.ConsumerKey, "simulator-key"},
+		{&cfg.ConsumerSecret, "simulator-secret"},
+		{&cfg.ShortCode, "174379"},
+		{&cfg.Passkey, "simulator-passkey"},
+		{&cfg.CallbackURL, callbackURL},
+		{&cfg.CallbackToken, "simulator-token"},
+		{&cfg.Initiator, "simulator-initiator"},
+		{&cfg.SecurityCredential, "simulator-credential"},
+		{&cfg.ResultURL, strings.TrimSuffix(callbackURL, "/callback") + "/refund-result"},
+	}
+	for _, d := range defaults {
+		if *d.field == "" {
+			*d.field = d.value
+		}
+	}
+	return cfg
+}
+
+// Simulator is an in-process stand-in for the Daraja API. It issues access
+// tokens, accepts STK pushes made with the configured credentials and, after
+// Delay, posts the callback a real customer's phone would have produced.
+// Every push succeeds unless SetResult says otherwise for the phone number.
+// Pushes can be queried and paid pushes reversed, the result of a reversal
+// being posted to its ResultURL after Delay.
+type Simulator struct {
+	// Delay is how long the simulated customer takes to answer the prompt
+	Delay time.Duration
+
+	cfg        MpesaConfig
+	httpClient *http.Client
+	server     *http.Server
+
+	mu        sync.Mutex
+	seq       int
+	tokens    map[string]bool
+	results   map[string]int
+	pushes    map[string]*simulatedPush
+	reversals []string
+	pending   sync.WaitGroup
+}
+
+// simulatedPush is the simulator's record of an STK push
+type simulatedPush struct {
+	resultCode int
+	receipt    string
+	// answered is set once the simulated customer has responded
+	answered bool
+}
+
+func NewSimulator(cfg MpesaConfig) *Simulator {
+	return &Simulator{
+		Delay:      2 * time.Second,
+		cfg:        cfg,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		tokens:     map[string]bool{},
+		results:    map[string]int{},
+		pushes:     map[string]*simulatedPush{},
+	}
+}
+
+// SetResult makes pushes to phone (e.g. 254712345678) end with resultCode
+func (s *Simulator) SetResult(phone string, resultCode int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results[phone] = resultCode
+}
+
+// Reversals lists the receipts of the payments reversed so far
+func (s *Simulator) Reversals() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.reversals...)
+}
+
+// Wait blocks until every callback the simulator owes has been posted
+func (s *Simulator) Wait() {
+	s.pending.Wait()
+}
+
+// Start serves the simulator on addr (e.g. 127.0.0.1:0) in the background and
+// returns its base URL
+func (s *Simulator) Start(addr string) (string, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", fmt.Errorf("failed to start M-Pesa simulator: %w", err)
+	}
+
+	s.server = &http.Server{Handler: s, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		if err := s.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Printf("M-Pesa simulator stopped: %v", err)
+		}
+	}()
+
+	return "http://" + listener.Addr().String(), nil
+}
+
+// Close stops a simulator started with Start
+func (s *Simulator) Close(ctx context.Context) error {
+	if s.server == nil {
+		return nil
+	}
+	return s.server.Shutdown(ctx)
+}
+
+func (s *Simulator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/oauth/v1/generate":
+		s.generateToken(w, r)
+	case r.Method == http.MethodPost && r.URL.Path == "/mpesa/stkpush/v1/processrequest":
+		s.stkPush(w, r)
+	case r.Method == http.MethodPost && r.URL.Path == "/mpesa/stkpushquery/v1/query":
+		s.stkQuery(w, r)
+	case r.Method == http.MethodPost && r.URL.Path == "/mpesa/reversal/v1/request":
+		s.reversal(w, r)
+	default:
+		writeSimulatorError(w, http.StatusNotFound, "404.001.03", "Invalid URL")
+	}
+}
+
+func (s *Simulator) generateToken(w http.ResponseWriter, r *http.Request) {
+	key, secret, ok := r.BasicAuth()
+	if !ok || key != s.cfg.ConsumerKey || secret != s.cfg.ConsumerSecret {
+		writeSimulatorError(w, http.StatusBadRequest, "400.008.01", "Invalid Authentication passed")
+		return
+	}
+
+	s.mu.Lock()
+	s.seq++
+	token := fmt.Sprintf("sim-token-%d", s.seq)
+	s.tokens[token] = true
+	s.mu.Unlock()
+
+	writeSimulatorJSON(w, map[string]string{"access_token": token, "expires_in": "3599"})
+}
+
+type simulatorPush struct {
+	BusinessShortCode string `json:"BusinessShortCode"`
+	Password          string `json:"Password"`
+	Timestamp         string `json:"Timestamp"`
+	Amount            int64  `json:"Amount"`
+	PhoneNumber       string `json:"PhoneNumber"`
+	CallBackURL       string `json:"CallBackURL"`
+}
+
+// authorised checks the bearer token and writes Daraja's error when it is not one we issued
+func (s *Simulator) authorised(w http.ResponseWriter, r *http.Request) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	s.mu.Lock()
+	ok := s.tokens[token]
+	s.mu.Unlock()
+	if !ok {
+		writeSimulatorError(w, http.StatusUnauthorized, "404.001.03", "Invalid Access Token")
+	}
+	return ok
+}
+
+func (s *Simulator) stkPush(w http.ResponseWriter, r *http.Request) {
+	if !s.authorised(w, r) {
+		return
+	}
+
+	var push simulatorPush
+	if err := json.NewDecoder(r.Body).Decode(&push); err != nil {
+		writeSimulatorError(w, http.StatusBadRequest, "400.002.02", "Bad Request - Invalid Body")
+		return
+	}
+	if push.BusinessShortCode != s.cfg.ShortCode || push.Password != s.cfg.password(push.Timestamp) {
+		writeSimulatorError(w, http.StatusBadRequest, "400.002.02", "Bad Request - Invalid Password")
+		return
+	}
+	if push.Amount <= 0 || push.PhoneNumber == "" || push.CallBackURL == "" {
+		writeSimulatorError(w, http.StatusBadRequest, "400.002.02", "Bad Request - Invalid Amount, PhoneNumber or CallBackURL")
+		return
+	}
+
+	s.mu.Lock()
+	s.seq++
+	seq := s.seq
+	resultCode := s.results[push.PhoneNumber]
+	merchantRequestID := fmt.Sprintf("sim-merchant-%d", seq)
+	checkoutRequestID := fmt.Sprintf("ws_CO_SIM_%08d", seq)
+	record := &simulatedPush{resultCode: resultCode}
+	if resultCode == ResultSuccess {
+		record.receipt = fmt.Sprintf("SIM%07d", seq)
+	}
+	s.pushes[checkoutRequestID] = record
+	s.mu.Unlock()
+
+	writeSimulatorJSON(w, STKPushResponse{
+		MerchantRequestID:   merchantRequestID,
+		CheckoutRequestID:   checkoutRequestID,
+		ResponseCode:        "0",
+		ResponseDescription: "Success. Request accepted for processing",
+		CustomerMessage:     "Success. Request accepted for processing",
+	})
+
+	callback := simulatorCallback(merchantRequestID, checkoutRequestID, record, push)
+	s.pending.Add(1)
+	go func() {
+		defer s.pending.Done()
+		time.Sleep(s.Delay)
+		s.mu.Lock()
+		record.answered = true
+		s.mu.Unlock()
+		s.postCallback(push.CallBackURL, callback)
+	}()
+}
+
+func (s *Simulator) stkQuery(w http.ResponseWriter, r *http.Request) {
+	if !s.authorised(w, r) {
+		return
+	}
+
+	var query struct {
+		BusinessShortCode string `json:"BusinessShortCode"`
+		Password          string `json:"Password"`
+		Timestamp         string `json:"Timestamp"`
+		CheckoutRequestID string `json:"CheckoutRequestID"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
+		writeSimulatorError(w, http.StatusBadRequest, "400.002.02", "Bad Request - Invalid Body")
+		return
+	}
+	if query.BusinessShortCode != s.cfg.ShortCode || query.Password != s.cfg.password(query.Timestamp) {
+		writeSimulatorError(w, http.StatusBadRequest, "400.002.02", "Bad Request - Invalid Password")
+		return
+	}
+
+	s.mu.Lock()
+	record, ok := s.pushes[query.CheckoutRequestID]
+	var answered bool
+	if ok {
+		answered = record.answered
+	}
+	s.mu.Unlock()
+
+	switch {
+	case !ok:
+		writeSimulatorError(w, http.StatusBadRequest, "400.002.02", "Bad Request - Invalid CheckoutRequestID")
+	case !answered:
+		writeSimulatorError(w, http.StatusInternalServerError, "500.001.1001", "The transaction is being processed")
+	default:
+		writeSimulatorJSON(w, map[string]string{
+			"ResponseCode":        "0",
+			"ResponseDescription": "The service request has been accepted successsfully",
+			"CheckoutRequestID":   query.CheckoutRequestID,
+			"ResultCode":          fmt.Sprint(record.resultCode),
+			"ResultDesc":          resultDescription(record.resultCode),
+		})
+	}
+}
+
+func (s *Simulator) reversal(w http.ResponseWriter, r *http.Request) {
+	if !s.authorised(w, r) {
+		return
+	}
+
+	var reversal struct {
+		TransactionID string `json:"TransactionID"`
+		ResultURL     string `json:"ResultURL"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&reversal); err != nil {
+		writeSimulatorError(w, http.StatusBadRequest, "400.002.02", "Bad Request - Invalid Body")
+		return
+	}
+
+	s.mu.Lock()
+	known := false
+	for _, record := range s.pushes {
+		if record.answered && record.receipt != "" && record.receipt == reversal.TransactionID {
+			known = true
+		}
+	}
+	if known {
+		s.seq++
+		s.reversals = append(s.reversals, reversal.TransactionID)
+	}
+	seq := s.seq
+	s.mu.Unlock()
+
+	if !known {
+		writeSimulatorError(w, http.StatusBadRequest, "400.002.02", "Bad Request - Invalid TransactionID")
+		return
+	}
+	originatorConversationID := fmt.Sprintf("sim-originator-%d", seq)
+	conversationID := fmt.Sprintf("AG_SIM_%08d", seq)
+	writeSimulatorJSON(w, map[string]string{
+		"OriginatorConversationID": originatorConversationID,
+		"ConversationID":           conversationID,
+		"ResponseCode":             "0",
+		"ResponseDescription":      "Accept the service request successfully.",
+	})
+
+	if reversal.ResultURL == "" {
+		return
+	}
+	result := map[string]any{"Result": map[string]any{
+		"ResultType":               0,
+		"ResultCode":               ResultSuccess,
+		"ResultDesc":               resultDescription(ResultSuccess),
+		"OriginatorConversationID": originatorConversationID,
+		"ConversationID":           conversationID,
+		"TransactionID":            fmt.Sprintf("SIMR%06d", seq),
+	}}
+	s.pending.Add(1)
+	go func() {
+		defer s.pending.Done()
+		time.Sleep(s.Delay)
+		s.postCallback(reversal.ResultURL, result)
+	}()
+}
+
+func resultDescription(resultCode int) string {
+	if desc, ok := resultDescriptions[resultCode]; ok {
+		return desc
+	}
+	return "The transaction failed."
+}
+
+// simulatorCallback builds the body Daraja posts for a push
+func simulatorCallback(merchantRequestID, checkoutRequestID string, record *simulatedPush, push simulatorPush) map[string]any {
+	resultCode := record.resultCode
+	desc := resultDescription(resultCode)
+
+	stk := map[string]any{
+		"MerchantRequestID": merchantRequestID,
+		"CheckoutRequestID": checkoutRequestID,
+		"ResultCode":        resultCode,
+		"ResultDesc":        desc,
+	}
+	if resultCode == ResultSuccess {
+		stk["CallbackMetadata"] = map[string]any{
+			"Item": []map[string]any{
+				{"Name": "Amount", "Value": push.Amount},
+				{"Name": "MpesaReceiptNumber", "Value": record.receipt},
+				{"Name": "TransactionDate", "Value": json.Number(time.Now().In(eat).Format(mpesaTimestampLayout))},
+				{"Name": "PhoneNumber", "Value": json.Number(push.PhoneNumber)},
+			},
+		}
+	}
+
+	return map[string]any{"Body": map[string]any{"stkCallback": stk}}
+}
+
+func (s *Simulator) postCallback(url string, callback map[string]any) {
+	body, err := json.Marshal(callback)
+	if err != nil {
+		log.Printf("M-Pesa simulator failed to encode callback: %v", err)
+		return
+	}
+
+	resp, err := s.httpClient.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Printf("M-Pesa simulator failed to post callback to %s: %v", url, err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("M-Pesa simulator callback to %s returned status %d", url, resp.StatusCode)
+	}
+}
+
+func writeSimulatorJSON(w http.ResponseWriter, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeSimulatorError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"requestId":    "sim",
+		"errorCode":    code,
+		"errorMessage": message,
+	})
+}
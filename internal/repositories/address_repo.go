@@ -0,0 +1,281 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/chesireabel/Technical-Interview/internal/models"
+)
+
+// AddressRepository stores customers' address books. Every method is scoped
+// to the customer, so another customer's address is reported as not found.
+// A customer has at most one default address; their first address becomes
+// the default and deleting the default promotes the newest remaining one.
+type AddressRepository interface {
+	Create(ctx context.Context, address *models.CustomerAddress) error
+	GetByID(ctx context.Context, customerID, id int64) (*models.CustomerAddress, error)
+	// ListByCustomer returns the default address first, then the newest
+	ListByCustomer(ctx context.Context, customerID int64) ([]models.CustomerAddress, error)
+	Update(ctx context.Context, address *models.CustomerAddress) error
+	Delete(ctx context.Context, customerID, id int64) error
+}
+
+type addressRepository struct {
+	db DBTX
+}
+
+func NewAddressRepository(db DBTX) AddressRepository {
+	return &addressRepository{db: db}
+}
+
+const addressColumns = `id, customer_id, COALESCE(label, ''), COALESCE(recipient, ''), COALESCE(phone, ''),
+	COALESCE(line1, ''), COALESCE(line2, ''), town, COALESCE(county, ''), COALESCE(postal_code, ''),
+	latitude, longitude, is_default, created_at, updated_at`
+
+func scanAddress(row pgx.Row, a *models.CustomerAddress) error {
+	return row.Scan(
+		&a.ID,
+		&a.CustomerID,
+		&a.Label,
+		&a.Recipient,
+		&a.Phone,
+		&a.Line1,
+		&a.Line2,
+		&a.Town,
+		&a.County,
+		&a.PostalCode,
+		&a.Latitude,
+		&a.Longitude,
+		&a.IsDefault,
+		&a.CreatedAt,
+		&a.UpdatedAt,
+	)
+}
+
+// lockCustomer locks the customer's row so concurrent changes to their
+// default address are serialised
+func lockCustomer(ctx context.Context, tx pgx.Tx, customerID int64) error {
+	var id int64
+	err := tx.QueryRow(ctx, "SELECT id FROM customers WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", customerID).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("customer with id %d: %w", customerID, models.ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock customer: %w", err)
+	}
+	return nil
+}
+
+// clearDefault unsets the customer's current default address
+func clearDefault(ctx context.Context, tx pgx.Tx, customerID int64) error {
+	_, err := tx.Exec(ctx, `
+		UPDATE customer_addresses SET is_default = false, updated_at = NOW()
+		WHERE customer_id = $1 AND is_default
+	`, customerID)
+	if err != nil {
+		return fmt.Errorf("failed to clear default address: %w", err)
+	}
+	return nil
+}
+
+func (r *addressRepository) Create(ctx context.Context, address *models.CustomerAddress) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockCustomer(ctx, tx, address.CustomerID); err != nil {
+		return err
+	}
+
+	if address.IsDefault {
+		if err := clearDefault(ctx, tx, address.CustomerID); err != nil {
+			return err
+		}
+	} else {
+		var hasDefault bool
+		err := tx.QueryRow(ctx,
+			"SELECT EXISTS (SELECT 1 FROM customer_addresses WHERE customer_id = $1 AND is_default)",
+			address.CustomerID,
+		).Scan(&hasDefault)
+		if err != nil {
+			return fmt.Errorf("failed to check default address: %w", err)
+		}
+		address.IsDefault = !hasDefault
+	}
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO customer_addresses (customer_id, label, recipient, phone, line1, line2, town, county, postal_code,
+			latitude, longitude, is_default, created_at, updated_at)
+		VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), NULLIF($4, ''), NULLIF($5, ''), NULLIF($6, ''), $7, NULLIF($8, ''),
+			NULLIF($9, ''), $10, $11, $12, NOW(), NOW())
+		RETURNING id, created_at, updated_at
+	`,
+		address.CustomerID,
+		address.Label,
+		address.Recipient,
+		address.Phone,
+		address.Line1,
+		address.Line2,
+		address.Town,
+		address.County,
+		address.PostalCode,
+		address.Latitude,
+		address.Longitude,
+		address.IsDefault,
+	).Scan(&address.ID, &address.CreatedAt, &address.UpdatedAt)
+	if conflict := asConflict(err); conflict != nil {
+		return conflict
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create address: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (r *addressRepository) GetByID(ctx context.Context, customerID, id int64) (*models.CustomerAddress, error) {
+	var a models.CustomerAddress
+	query := "SELECT " + addressColumns + " FROM customer_addresses WHERE id = $1 AND customer_id = $2"
+
+	err := scanAddress(r.db.QueryRow(ctx, query, id, customerID), &a)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("address with id %d: %w", id, models.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get address: %w", err)
+	}
+
+	return &a, nil
+}
+
+func (r *addressRepository) ListByCustomer(ctx context.Context, customerID int64) ([]models.CustomerAddress, error) {
+	addresses := []models.CustomerAddress{}
+
+	rows, err := r.db.Query(ctx,
+		"SELECT "+addressColumns+" FROM customer_addresses WHERE customer_id = $1 ORDER BY is_default DESC, created_at DESC, id DESC",
+		customerID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get addresses: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a models.CustomerAddress
+		if err := scanAddress(rows, &a); err != nil {
+			return nil, fmt.Errorf("failed to scan address: %w", err)
+		}
+		addresses = append(addresses, a)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating addresses: %w", err)
+	}
+
+	return addresses, nil
+}
+
+func (r *addressRepository) Update(ctx context.Context, address *models.CustomerAddress) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockCustomer(ctx, tx, address.CustomerID); err != nil {
+		return err
+	}
+
+	if address.IsDefault {
+		if err := clearDefault(ctx, tx, address.CustomerID); err != nil {
+			return err
+		}
+	}
+
+	err = tx.QueryRow(ctx, `
+		UPDATE customer_addresses
+		SET label = NULLIF($1, ''), recipient = NULLIF($2, ''), phone = NULLIF($3, ''), line1 = NULLIF($4, ''),
+			line2 = NULLIF($5, ''), town = $6, county = NULLIF($7, ''), postal_code = NULLIF($8, ''),
+			latitude = $9, longitude = $10, is_default = $11, updated_at = NOW()
+		WHERE id = $12 AND customer_id = $13
+		RETURNING created_at, updated_at
+	`,
+		address.Label,
+		address.Recipient,
+		address.Phone,
+		address.Line1,
+		address.Line2,
+		address.Town,
+		address.County,
+		address.PostalCode,
+		address.Latitude,
+		address.Longitude,
+		address.IsDefault,
+		address.ID,
+		address.CustomerID,
+	).Scan(&address.CreatedAt, &address.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("address with id %d: %w", address.ID, models.ErrNotFound)
+	}
+	if conflict := asConflict(err); conflict != nil {
+		return conflict
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update address: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (r *addressRepository) Delete(ctx context.Context, customerID, id int64) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockCustomer(ctx, tx, customerID); err != nil {
+		return err
+	}
+
+	var wasDefault bool
+	err = tx.QueryRow(ctx,
+		"DELETE FROM customer_addresses WHERE id = $1 AND customer_id = $2 RETURNING is_default",
+		id, customerID,
+	).Scan(&wasDefault)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("address with id %d: %w", id, models.ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete address: %w", err)
+	}
+
+	if wasDefault {
+		_, err := tx.Exec(ctx, `
+			UPDATE customer_addresses SET is_default = true, updated_at = NOW()
+			WHERE id = (
+				SELECT id FROM customer_addresses WHERE customer_id = $1
+				ORDER BY created_at DESC, id DESC
+				LIMIT 1
+			)
+		`, customerID)
+		if err != nil {
+			return fmt.Errorf("failed to promote default address: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
@@ -2,32 +2,46 @@ package repositories
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
-	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5"
 	"github.com/chesireabel/Technical-Interview/internal/models"
 )
 
 type CustomerRepository interface {
 	Create(ctx context.Context, customer *models.Customer) (int64, error)
 	GetByID(ctx context.Context, id int64) (*models.Customer, error)
-	GetAll(ctx context.Context) ([]models.Customer, error)
+	GetAll(ctx context.Context, includeDeleted bool) ([]models.Customer, error)
+	// Update keeps the stored phone when customer.Phone is empty
 	Update(ctx context.Context, customer *models.Customer) error
 	Delete(ctx context.Context, id int64) error
+	Restore(ctx context.Context, id int64) error
+	Purge(ctx context.Context, deletedBefore time.Time) (int64, error)
+	NextCodeSequence(ctx context.Context) (int64, error)
+	UpdatePhone(ctx context.Context, id int64, phone, countryCode string) error
+	FindExisting(ctx context.Context, emails, codes []string) (map[string]bool, map[string]bool, error)
+	BulkCreate(ctx context.Context, customers []models.Customer) (int64, error)
+	// SaveImport stores an import report and sets its ID and CreatedAt
+	SaveImport(ctx context.Context, report *models.CustomerImport) error
+	GetImport(ctx context.Context, id int64) (*models.CustomerImport, error)
+	Stream(ctx context.Context, includeDeleted bool, fn func(*models.Customer) error) error
 }
 
 type customerRepository struct {
-	db *pgxpool.Pool
+	db DBTX
 }
 
-func NewCustomerRepository(db *pgxpool.Pool) CustomerRepository {
+func NewCustomerRepository(db DBTX) CustomerRepository {
 	return &customerRepository{db: db}
 }
 
 func (r *customerRepository) Create(ctx context.Context, customer *models.Customer) (int64, error) {
 	query := `
-		INSERT INTO customers (customer_name, email, password, phone, code, created_at)
-		VALUES ($1, $2, $3, $4, $5, NOW())
+		INSERT INTO customers (customer_name, email, password, phone, country_code, code, created_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, NOW())
 		RETURNING id
 	`
 
@@ -37,9 +51,13 @@ func (r *customerRepository) Create(ctx context.Context, customer *models.Custom
 		customer.Email,
 		customer.Password,
 		customer.Phone,
+		customer.CountryCode,
 		customer.Code,
 	).Scan(&id)
 
+	if conflict := asConflict(err); conflict != nil {
+		return 0, conflict
+	}
 	if err != nil {
 		return 0, fmt.Errorf("failed to create customer: %w", err)
 	}
@@ -49,9 +67,9 @@ func (r *customerRepository) Create(ctx context.Context, customer *models.Custom
 func (r *customerRepository) GetByID(ctx context.Context, id int64) (*models.Customer, error) {
 	var c models.Customer
 	query := `
-		SELECT id, customer_name, email, password, phone, code, created_at 
+		SELECT id, customer_name, email, password, COALESCE(phone, ''), COALESCE(country_code, ''), code, created_at, deleted_at 
 		FROM customers 
-		WHERE id = $1
+		WHERE id = $1 AND deleted_at IS NULL
 	`
 	
 	err := r.db.QueryRow(ctx, query, id).Scan(
@@ -60,25 +78,31 @@ func (r *customerRepository) GetByID(ctx context.Context, id int64) (*models.Cus
 		&c.Email,
 		&c.Password,
 		&c.Phone,
+		&c.CountryCode,
 		&c.Code,
 		&c.CreatedAt,
+		&c.DeletedAt,
 	)
 	
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("customer with id %d: %w", id, models.ErrNotFound)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("customer not found: %w", err)
 	}
 	return &c, nil
 }
 
-func (r *customerRepository) GetAll(ctx context.Context) ([]models.Customer, error) {
+func (r *customerRepository) GetAll(ctx context.Context, includeDeleted bool) ([]models.Customer, error) {
 	var customers []models.Customer
 	query := `
-		SELECT id, customer_name, email, password, phone, code, created_at 
+		SELECT id, customer_name, email, password, COALESCE(phone, ''), COALESCE(country_code, ''), code, created_at, deleted_at 
 		FROM customers 
+		WHERE $1 OR deleted_at IS NULL
 		ORDER BY created_at DESC
 	`
 	
-	rows, err := r.db.Query(ctx, query)
+	rows, err := r.db.Query(ctx, query, includeDeleted)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get customers: %w", err)
 	}
@@ -92,8 +116,10 @@ func (r *customerRepository) GetAll(ctx context.Context) ([]models.Customer, err
 			&c.Email,
 			&c.Password,
 			&c.Phone,
+			&c.CountryCode,
 			&c.Code,
 			&c.CreatedAt,
+			&c.DeletedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan customer: %w", err)
@@ -111,8 +137,10 @@ func (r *customerRepository) GetAll(ctx context.Context) ([]models.Customer, err
 func (r *customerRepository) Update(ctx context.Context, customer *models.Customer) error {
 	query := `
 		UPDATE customers
-		SET customer_name = $1, email = $2, password = $3, phone = $4, code = $5
-		WHERE id = $6
+		SET customer_name = $1, email = $2, password = $3,
+			phone = COALESCE(NULLIF($4, ''), phone), country_code = CASE WHEN $4 = '' THEN country_code ELSE NULLIF($5, '') END,
+			code = COALESCE(NULLIF($6, ''), code)
+		WHERE id = $7 AND deleted_at IS NULL
 	`
 	
 	cmdTag, err := r.db.Exec(ctx, query,
@@ -120,32 +148,295 @@ func (r *customerRepository) Update(ctx context.Context, customer *models.Custom
 		customer.Email,
 		customer.Password,
 		customer.Phone,
+		customer.CountryCode,
 		customer.Code,
 		customer.ID,
 	)
 	
+	if conflict := asConflict(err); conflict != nil {
+		return conflict
+	}
 	if err != nil {
 		return fmt.Errorf("failed to update customer: %w", err)
 	}
 
 	if cmdTag.RowsAffected() == 0 {
-		return fmt.Errorf("customer with id %d not found", customer.ID)
+		return fmt.Errorf("customer with id %d: %w", customer.ID, models.ErrNotFound)
 	}
 
 	return nil
 }
 
+// Delete soft deletes the customer together with their live orders. The orders
+// share the customer's deleted_at timestamp so Restore can bring back exactly
+// the orders that went away with the customer.
 func (r *customerRepository) Delete(ctx context.Context, id int64) error {
-	query := "DELETE FROM customers WHERE id = $1"
-	
-	cmdTag, err := r.db.Exec(ctx, query, id)
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var deletedAt time.Time
+	err = tx.QueryRow(ctx, `
+		UPDATE customers
+		SET deleted_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING deleted_at
+	`, id).Scan(&deletedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("customer with id %d: %w", id, models.ErrNotFound)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to delete customer: %w", err)
 	}
 
+	_, err = tx.Exec(ctx, `
+		UPDATE orders
+		SET deleted_at = $2
+		WHERE customer_id = $1 AND deleted_at IS NULL
+	`, id, deletedAt)
+	if err != nil {
+		return fmt.Errorf("failed to delete customer orders: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit customer delete: %w", err)
+	}
+
+	return nil
+}
+
+// Restore undoes a soft delete, including the orders deleted along with the customer
+func (r *customerRepository) Restore(ctx context.Context, id int64) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var deletedAt time.Time
+	err = tx.QueryRow(ctx, `
+		SELECT deleted_at
+		FROM customers
+		WHERE id = $1 AND deleted_at IS NOT NULL
+		FOR UPDATE
+	`, id).Scan(&deletedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("deleted customer with id %d: %w", id, models.ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to restore customer: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE orders
+		SET deleted_at = NULL
+		WHERE customer_id = $1 AND deleted_at = $2
+	`, id, deletedAt)
+	if err != nil {
+		return fmt.Errorf("failed to restore customer orders: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, "UPDATE customers SET deleted_at = NULL WHERE id = $1", id)
+	if conflict := asConflict(err); conflict != nil {
+		return conflict
+	}
+	if err != nil {
+		return fmt.Errorf("failed to restore customer: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit customer restore: %w", err)
+	}
+
+	return nil
+}
+
+// Purge permanently removes customers soft deleted before the given time.
+// Their orders are removed by the ON DELETE CASCADE foreign key.
+func (r *customerRepository) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	query := "DELETE FROM customers WHERE deleted_at IS NOT NULL AND deleted_at < $1"
+
+	cmdTag, err := r.db.Exec(ctx, query, deletedBefore)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge customers: %w", err)
+	}
+
+	return cmdTag.RowsAffected(), nil
+}
+
+// NextCodeSequence returns the next value used to build generated customer codes
+func (r *customerRepository) NextCodeSequence(ctx context.Context) (int64, error) {
+	var seq int64
+	err := r.db.QueryRow(ctx, "SELECT nextval('customer_code_seq')").Scan(&seq)
+	if err != nil {
+		return 0, fmt.Errorf("failed to allocate customer code: %w", err)
+	}
+	return seq, nil
+}
+
+// UpdatePhone rewrites only the phone columns, including on soft deleted customers
+func (r *customerRepository) UpdatePhone(ctx context.Context, id int64, phone, countryCode string) error {
+	query := "UPDATE customers SET phone = $1, country_code = NULLIF($2, '') WHERE id = $3"
+
+	cmdTag, err := r.db.Exec(ctx, query, phone, countryCode, id)
+	if err != nil {
+		return fmt.Errorf("failed to update customer phone: %w", err)
+	}
+
 	if cmdTag.RowsAffected() == 0 {
-		return fmt.Errorf("customer with id %d not found", id)
+		return fmt.Errorf("customer with id %d: %w", id, models.ErrNotFound)
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+// FindExisting reports which of the given emails (case-insensitively) belong to
+// live customers and which codes are taken by any customer, deleted or not,
+// since codes stay unique across soft deletes. Email keys are returned lower-cased.
+func (r *customerRepository) FindExisting(ctx context.Context, emails, codes []string) (map[string]bool, map[string]bool, error) {
+	existingEmails := make(map[string]bool)
+	existingCodes := make(map[string]bool)
+
+	lowered := make([]string, len(emails))
+	for i, e := range emails {
+		lowered[i] = strings.ToLower(e)
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT
+			CASE WHEN LOWER(email) = ANY($1) AND deleted_at IS NULL THEN LOWER(email) END,
+			CASE WHEN code = ANY($2) THEN code END
+		FROM customers
+		WHERE (LOWER(email) = ANY($1) AND deleted_at IS NULL) OR code = ANY($2)
+	`, lowered, codes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to look up existing customers: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var email, code *string
+		if err := rows.Scan(&email, &code); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan existing customer: %w", err)
+		}
+		if email != nil {
+			existingEmails[*email] = true
+		}
+		if code != nil {
+			existingCodes[*code] = true
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error iterating existing customers: %w", err)
+	}
+
+	return existingEmails, existingCodes, nil
+}
+
+func (r *customerRepository) SaveImport(ctx context.Context, report *models.CustomerImport) error {
+	err := r.db.QueryRow(ctx, `
+		INSERT INTO customer_imports (header, report)
+		VALUES ($1, $2)
+		RETURNING id, created_at
+	`, report.Header, report.Report).Scan(&report.ID, &report.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save customer import: %w", err)
+	}
+	return nil
+}
+
+func (r *customerRepository) GetImport(ctx context.Context, id int64) (*models.CustomerImport, error) {
+	var report models.CustomerImport
+	err := r.db.QueryRow(ctx, `
+		SELECT id, header, report, created_at
+		FROM customer_imports
+		WHERE id = $1
+	`, id).Scan(&report.ID, &report.Header, &report.Report, &report.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("customer import with id %d: %w", id, models.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get customer import: %w", err)
+	}
+	return &report, nil
+}
+
+// BulkCreate inserts customers with COPY inside a single transaction, so either
+// every row in the batch is stored or none is
+func (r *customerRepository) BulkCreate(ctx context.Context, customers []models.Customer) (int64, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	columns := []string{"customer_name", "email", "password", "phone", "country_code", "code"}
+	count, err := tx.CopyFrom(ctx, pgx.Identifier{"customers"}, columns,
+		pgx.CopyFromSlice(len(customers), func(i int) ([]any, error) {
+			c := customers[i]
+			var countryCode any
+			if c.CountryCode != "" {
+				countryCode = c.CountryCode
+			}
+			return []any{c.Customer_name, c.Email, c.Password, c.Phone, countryCode, c.Code}, nil
+		}),
+	)
+	if conflict := asConflict(err); conflict != nil {
+		return 0, conflict
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to copy customers: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit customer import: %w", err)
+	}
+
+	return count, nil
+}
+
+// Stream calls fn for each customer as rows arrive from the database, without
+// building the full result set in memory. Returning an error from fn stops the scan.
+func (r *customerRepository) Stream(ctx context.Context, includeDeleted bool, fn func(*models.Customer) error) error {
+	query := `
+		SELECT id, customer_name, email, COALESCE(phone, ''), COALESCE(country_code, ''), code, created_at, deleted_at
+		FROM customers
+		WHERE $1 OR deleted_at IS NULL
+		ORDER BY id
+	`
+
+	rows, err := r.db.Query(ctx, query, includeDeleted)
+	if err != nil {
+		return fmt.Errorf("failed to stream customers: %w", err)
+	}
+	defer rows.Close()
+
+	var c models.Customer
+	for rows.Next() {
+		err := rows.Scan(
+			&c.ID,
+			&c.Customer_name,
+			&c.Email,
+			&c.Phone,
+			&c.CountryCode,
+			&c.Code,
+			&c.CreatedAt,
+			&c.DeletedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to scan customer: %w", err)
+		}
+		if err := fn(&c); err != nil {
+			return err
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("error iterating customers: %w", err)
+	}
+
+	return nil
+}
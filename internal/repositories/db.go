@@ -0,0 +1,20 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// DBTX is the part of pgx shared by *pgxpool.Pool and pgx.Tx. Repositories run
+// their statements against a DBTX so the same repository works on the pool or
+// inside a transaction opened by the TxManager. Begin on a pgx.Tx starts a
+// savepoint, so repository methods that need their own transaction nest safely.
+type DBTX interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Begin(ctx context.Context) (pgx.Tx, error)
+	CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error)
+}
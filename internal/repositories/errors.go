@@ -0,0 +1,37 @@
+package repositories
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/chesireabel/Technical-Interview/internal/models"
+)
+
+const uniqueViolationCode = "23505"
+
+// uniqueConstraintFields maps unique constraints and indexes to the API field they protect
+var uniqueConstraintFields = map[string]string{
+	"customers_code_key":             "code",
+	"idx_customers_email_lower":      "email",
+	"idx_products_sku_upper":         "sku",
+	"idx_promotions_code_upper":      "code",
+	"idx_tax_rates_region_category":  "category",
+	"idx_customer_addresses_default": "is_default",
+	"idx_segments_name_lower":        "name",
+}
+
+// asConflict converts a unique violation on a known constraint into a
+// *models.ConflictError and returns nil for any other error
+func asConflict(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != uniqueViolationCode {
+		return nil
+	}
+
+	field, ok := uniqueConstraintFields[pgErr.ConstraintName]
+	if !ok {
+		return nil
+	}
+
+	return &models.ConflictError{Field: field}
+}
@@ -0,0 +1,131 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/chesireabel/Technical-Interview/internal/models"
+)
+
+type IdempotencyRepository interface {
+	Reserve(ctx context.Context, record *models.IdempotencyRecord, staleBefore time.Time) (bool, error)
+	Get(ctx context.Context, scope, key string) (*models.IdempotencyRecord, error)
+	Complete(ctx context.Context, scope, key string, statusCode int, body []byte) error
+	Release(ctx context.Context, scope, key string) error
+	DeleteExpired(ctx context.Context, before time.Time) (int64, error)
+}
+
+type idempotencyRepository struct {
+	db DBTX
+}
+
+func NewIdempotencyRepository(db DBTX) IdempotencyRepository {
+	return &idempotencyRepository{db: db}
+}
+
+// Reserve claims a key for a new request. It returns false when the key is
+// already held by an unexpired record. An expired record is taken over, as is
+// a reservation still in progress that was made before staleBefore: its
+// request is taken to have died before completing or releasing the key.
+func (r *idempotencyRepository) Reserve(ctx context.Context, record *models.IdempotencyRecord, staleBefore time.Time) (bool, error) {
+	query := `
+		INSERT INTO idempotency_keys (scope, key, request_hash, created_at, expires_at)
+		VALUES ($1, $2, $3, NOW(), $4)
+		ON CONFLICT (scope, key) DO UPDATE
+		SET request_hash = EXCLUDED.request_hash,
+		    status_code = NULL,
+		    response_body = NULL,
+		    created_at = NOW(),
+		    expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at <= NOW()
+		   OR (idempotency_keys.status_code IS NULL AND idempotency_keys.created_at < $5)
+		RETURNING created_at
+	`
+
+	err := r.db.QueryRow(ctx, query, record.Scope, record.Key, record.RequestHash, record.ExpiresAt, staleBefore).Scan(&record.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+
+	return true, nil
+}
+
+func (r *idempotencyRepository) Get(ctx context.Context, scope, key string) (*models.IdempotencyRecord, error) {
+	var rec models.IdempotencyRecord
+	var statusCode *int
+	query := `
+		SELECT scope, key, request_hash, status_code, response_body, created_at, expires_at
+		FROM idempotency_keys
+		WHERE scope = $1 AND key = $2
+	`
+
+	err := r.db.QueryRow(ctx, query, scope, key).Scan(
+		&rec.Scope,
+		&rec.Key,
+		&rec.RequestHash,
+		&statusCode,
+		&rec.ResponseBody,
+		&rec.CreatedAt,
+		&rec.ExpiresAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("idempotency key %q: %w", key, models.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get idempotency key: %w", err)
+	}
+	if statusCode != nil {
+		rec.StatusCode = *statusCode
+	}
+
+	return &rec, nil
+}
+
+// Complete stores the response of the request that reserved the key
+func (r *idempotencyRepository) Complete(ctx context.Context, scope, key string, statusCode int, body []byte) error {
+	query := `
+		UPDATE idempotency_keys
+		SET status_code = $3, response_body = $4
+		WHERE scope = $1 AND key = $2
+	`
+
+	cmdTag, err := r.db.Exec(ctx, query, scope, key, statusCode, body)
+	if err != nil {
+		return fmt.Errorf("failed to store idempotent response: %w", err)
+	}
+
+	if cmdTag.RowsAffected() == 0 {
+		return fmt.Errorf("idempotency key %q: %w", key, models.ErrNotFound)
+	}
+
+	return nil
+}
+
+// Release drops a reservation whose request failed so the client can retry it
+func (r *idempotencyRepository) Release(ctx context.Context, scope, key string) error {
+	query := "DELETE FROM idempotency_keys WHERE scope = $1 AND key = $2 AND status_code IS NULL"
+
+	if _, err := r.db.Exec(ctx, query, scope, key); err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteExpired removes keys whose replay window ended before the given time
+func (r *idempotencyRepository) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	query := "DELETE FROM idempotency_keys WHERE expires_at < $1"
+
+	cmdTag, err := r.db.Exec(ctx, query, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired idempotency keys: %w", err)
+	}
+
+	return cmdTag.RowsAffected(), nil
+}
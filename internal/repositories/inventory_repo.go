@@ -0,0 +1,352 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/chesireabel/Technical-Interview/internal/models"
+)
+
+const checkViolationCode = "23514"
+
+type InventoryRepository interface {
+	Reserve(ctx context.Context, orderID int64, quantities map[int64]int, preferredLocation, actor string) error
+	Release(ctx context.Context, orderID int64, actor string) error
+	Fulfil(ctx context.Context, orderID int64, actor string) error
+	Adjust(ctx context.Context, movement *models.StockMovement) error
+	GetLevels(ctx context.Context, productID int64) ([]models.StockLevel, error)
+	GetMovements(ctx context.Context, productID int64, limit int) ([]models.StockMovement, error)
+}
+
+type inventoryRepository struct {
+	db DBTX
+}
+
+func NewInventoryRepository(db DBTX) InventoryRepository {
+	return &inventoryRepository{db: db}
+}
+
+// lockLevels locks the stock rows of the given products. Rows are always locked
+// in (product_id, location) order so concurrent orders cannot deadlock.
+func lockLevels(ctx context.Context, tx pgx.Tx, productIDs []int64) (map[int64][]models.StockLevel, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT product_id, location, on_hand, reserved, updated_at
+		FROM stock_levels
+		WHERE product_id = ANY($1)
+		ORDER BY product_id, location
+		FOR UPDATE
+	`, productIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock stock levels: %w", err)
+	}
+	defer rows.Close()
+
+	levels := make(map[int64][]models.StockLevel, len(productIDs))
+	for rows.Next() {
+		var l models.StockLevel
+		if err := rows.Scan(&l.ProductID, &l.Location, &l.OnHand, &l.Reserved, &l.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan stock level: %w", err)
+		}
+		levels[l.ProductID] = append(levels[l.ProductID], l)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating stock levels: %w", err)
+	}
+
+	return levels, nil
+}
+
+func insertMovement(ctx context.Context, tx pgx.Tx, m *models.StockMovement) error {
+	err := tx.QueryRow(ctx, `
+		INSERT INTO stock_movements (product_id, location, order_id, kind, quantity, note, created_by)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7)
+		RETURNING id, created_at
+	`, m.ProductID, m.Location, m.OrderID, m.Kind, m.Quantity, m.Note, m.CreatedBy).Scan(&m.ID, &m.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record stock movement: %w", err)
+	}
+	return nil
+}
+
+// Reserve sets aside stock for an order, taking it from preferredLocation first
+// and then from the locations with the most stock available. Products without
+// any stock rows are not tracked and need no reservation. If any product is
+// short, nothing is reserved and a *models.StockError is returned.
+func (r *inventoryRepository) Reserve(ctx context.Context, orderID int64, quantities map[int64]int, preferredLocation, actor string) error {
+	productIDs := make([]int64, 0, len(quantities))
+	for id := range quantities {
+		productIDs = append(productIDs, id)
+	}
+	slices.Sort(productIDs)
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	levels, err := lockLevels(ctx, tx, productIDs)
+	if err != nil {
+		return err
+	}
+
+	for _, productID := range productIDs {
+		productLevels, tracked := levels[productID]
+		if !tracked {
+			continue
+		}
+
+		requested := quantities[productID]
+		available := 0
+		for _, l := range productLevels {
+			available += l.Available()
+		}
+		if available < requested {
+			return &models.StockError{ProductID: productID, Requested: requested, Available: available}
+		}
+
+		slices.SortStableFunc(productLevels, func(a, b models.StockLevel) int {
+			if (a.Location == preferredLocation) != (b.Location == preferredLocation) {
+				if a.Location == preferredLocation {
+					return -1
+				}
+				return 1
+			}
+			return b.Available() - a.Available()
+		})
+
+		remaining := requested
+		for _, l := range productLevels {
+			take := min(remaining, l.Available())
+			if take <= 0 {
+				continue
+			}
+
+			_, err := tx.Exec(ctx, `
+				UPDATE stock_levels SET reserved = reserved + $3, updated_at = NOW()
+				WHERE product_id = $1 AND location = $2
+			`, productID, l.Location, take)
+			if err != nil {
+				return fmt.Errorf("failed to reserve stock: %w", err)
+			}
+
+			err = insertMovement(ctx, tx, &models.StockMovement{
+				ProductID: productID,
+				Location:  l.Location,
+				OrderID:   &orderID,
+				Kind:      models.StockReservation,
+				Quantity:  take,
+				CreatedBy: actor,
+			})
+			if err != nil {
+				return err
+			}
+
+			if remaining -= take; remaining == 0 {
+				break
+			}
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit stock reservation: %w", err)
+	}
+	return nil
+}
+
+// outstandingReservations returns what is still reserved for an order per product and location
+func outstandingReservations(ctx context.Context, tx pgx.Tx, orderID int64) ([]models.StockMovement, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT product_id, location, SUM(quantity)
+		FROM stock_movements
+		WHERE order_id = $1 AND kind IN ('reservation', 'release', 'shipment')
+		GROUP BY product_id, location
+		HAVING SUM(quantity) > 0
+		ORDER BY product_id, location
+	`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stock reservations: %w", err)
+	}
+	defer rows.Close()
+
+	var outstanding []models.StockMovement
+	for rows.Next() {
+		m := models.StockMovement{OrderID: &orderID}
+		if err := rows.Scan(&m.ProductID, &m.Location, &m.Quantity); err != nil {
+			return nil, fmt.Errorf("failed to scan stock reservation: %w", err)
+		}
+		outstanding = append(outstanding, m)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating stock reservations: %w", err)
+	}
+
+	return outstanding, nil
+}
+
+// settle closes an order's outstanding reservations, either returning the stock
+// (release) or taking it out of the warehouse (shipment)
+func (r *inventoryRepository) settle(ctx context.Context, orderID int64, kind models.StockMovementKind, actor string) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	outstanding, err := outstandingReservations(ctx, tx, orderID)
+	if err != nil {
+		return err
+	}
+	if len(outstanding) == 0 {
+		return nil
+	}
+
+	productIDs := make([]int64, 0, len(outstanding))
+	for _, m := range outstanding {
+		productIDs = append(productIDs, m.ProductID)
+	}
+	if _, err := lockLevels(ctx, tx, productIDs); err != nil {
+		return err
+	}
+
+	update := "UPDATE stock_levels SET reserved = reserved - $3, updated_at = NOW() WHERE product_id = $1 AND location = $2"
+	if kind == models.StockShipment {
+		update = "UPDATE stock_levels SET reserved = reserved - $3, on_hand = on_hand - $3, updated_at = NOW() WHERE product_id = $1 AND location = $2"
+	}
+
+	for _, m := range outstanding {
+		if _, err := tx.Exec(ctx, update, m.ProductID, m.Location, m.Quantity); err != nil {
+			return fmt.Errorf("failed to %s stock: %w", kind, err)
+		}
+
+		m.Kind = kind
+		m.Quantity = -m.Quantity
+		m.CreatedBy = actor
+		if err := insertMovement(ctx, tx, &m); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit stock %s: %w", kind, err)
+	}
+	return nil
+}
+
+// Release returns the stock reserved for an order, e.g. when it is cancelled
+func (r *inventoryRepository) Release(ctx context.Context, orderID int64, actor string) error {
+	return r.settle(ctx, orderID, models.StockRelease, actor)
+}
+
+// Fulfil takes the stock reserved for an order out of the warehouse when it ships
+func (r *inventoryRepository) Fulfil(ctx context.Context, orderID int64, actor string) error {
+	return r.settle(ctx, orderID, models.StockShipment, actor)
+}
+
+// Adjust applies a receipt or manual adjustment to the stock on hand at a
+// location, creating the stock row on first use
+func (r *inventoryRepository) Adjust(ctx context.Context, movement *models.StockMovement) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO stock_levels (product_id, location, on_hand, updated_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (product_id, location) DO UPDATE
+		SET on_hand = stock_levels.on_hand + EXCLUDED.on_hand, updated_at = NOW()
+	`, movement.ProductID, movement.Location, movement.Quantity)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == checkViolationCode {
+		return models.ErrStockBelowReserved
+	}
+	if err != nil {
+		return fmt.Errorf("failed to adjust stock: %w", err)
+	}
+
+	if err := insertMovement(ctx, tx, movement); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit stock adjustment: %w", err)
+	}
+	return nil
+}
+
+func (r *inventoryRepository) GetLevels(ctx context.Context, productID int64) ([]models.StockLevel, error) {
+	levels := []models.StockLevel{}
+	rows, err := r.db.Query(ctx, `
+		SELECT product_id, location, on_hand, reserved, updated_at
+		FROM stock_levels
+		WHERE product_id = $1
+		ORDER BY location
+	`, productID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stock levels: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var l models.StockLevel
+		if err := rows.Scan(&l.ProductID, &l.Location, &l.OnHand, &l.Reserved, &l.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan stock level: %w", err)
+		}
+		levels = append(levels, l)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating stock levels: %w", err)
+	}
+
+	return levels, nil
+}
+
+// GetMovements returns the most recent ledger entries for a product, newest first
+func (r *inventoryRepository) GetMovements(ctx context.Context, productID int64, limit int) ([]models.StockMovement, error) {
+	movements := []models.StockMovement{}
+	rows, err := r.db.Query(ctx, `
+		SELECT id, product_id, location, order_id, kind, quantity, COALESCE(note, ''), created_by, created_at
+		FROM stock_movements
+		WHERE product_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2
+	`, productID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stock movements: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m models.StockMovement
+		err := rows.Scan(
+			&m.ID,
+			&m.ProductID,
+			&m.Location,
+			&m.OrderID,
+			&m.Kind,
+			&m.Quantity,
+			&m.Note,
+			&m.CreatedBy,
+			&m.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan stock movement: %w", err)
+		}
+		movements = append(movements, m)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating stock movements: %w", err)
+	}
+
+	return movements, nil
+}
@@ -0,0 +1,104 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/chesireabel/Technical-Interview/internal/models"
+)
+
+type InvoiceRepository interface {
+	GetByOrderID(ctx context.Context, orderID int64) (*models.Invoice, error)
+	// Issue returns the order's invoice, allocating the next invoice number
+	// when the order has not been invoiced yet. A new invoice stores the given
+	// order and customer as its snapshot; an existing one keeps its own.
+	Issue(ctx context.Context, order *models.Order, customer *models.Customer) (*models.Invoice, error)
+}
+
+type invoiceRepository struct {
+	db DBTX
+}
+
+func NewInvoiceRepository(db DBTX) InvoiceRepository {
+	return &invoiceRepository{db: db}
+}
+
+func getInvoiceByOrderID(ctx context.Context, db DBTX, orderID int64) (*models.Invoice, error) {
+	var inv models.Invoice
+	var order, customer []byte
+	query := "SELECT id, number, order_id, issued_at, order_snapshot, customer_snapshot FROM invoices WHERE order_id = $1"
+
+	err := db.QueryRow(ctx, query, orderID).Scan(&inv.ID, &inv.Number, &inv.OrderID, &inv.IssuedAt, &order, &customer)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("invoice for order %d: %w", orderID, models.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invoice: %w", err)
+	}
+
+	if err := json.Unmarshal(order, &inv.Order); err != nil {
+		return nil, fmt.Errorf("failed to decode order snapshot of invoice %d: %w", inv.ID, err)
+	}
+	if err := json.Unmarshal(customer, &inv.Customer); err != nil {
+		return nil, fmt.Errorf("failed to decode customer snapshot of invoice %d: %w", inv.ID, err)
+	}
+
+	return &inv, nil
+}
+
+func (r *invoiceRepository) GetByOrderID(ctx context.Context, orderID int64) (*models.Invoice, error) {
+	return getInvoiceByOrderID(ctx, r.db, orderID)
+}
+
+func (r *invoiceRepository) Issue(ctx context.Context, order *models.Order, customer *models.Customer) (*models.Invoice, error) {
+	orderSnapshot, err := json.Marshal(order)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode order snapshot: %w", err)
+	}
+	customerSnapshot, err := json.Marshal(customer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode customer snapshot: %w", err)
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Taking the counter row lock serialises invoicing. A concurrent request
+	// for the same order waits here and then finds the invoice it committed,
+	// rolling back its increment so no number is skipped.
+	var number int64
+	err = tx.QueryRow(ctx, "UPDATE invoice_counter SET last_number = last_number + 1 RETURNING last_number").Scan(&number)
+	if err != nil {
+		return nil, fmt.Errorf("failed to allocate invoice number: %w", err)
+	}
+
+	existing, err := getInvoiceByOrderID(ctx, tx, order.ID)
+	if err == nil {
+		return existing, nil
+	}
+	if !errors.Is(err, models.ErrNotFound) {
+		return nil, err
+	}
+
+	inv := models.Invoice{Number: number, OrderID: order.ID, Order: *order, Customer: *customer}
+	query := `
+		INSERT INTO invoices (number, order_id, issued_at, order_snapshot, customer_snapshot)
+		VALUES ($1, $2, NOW(), $3, $4)
+		RETURNING id, issued_at
+	`
+	if err := tx.QueryRow(ctx, query, number, order.ID, orderSnapshot, customerSnapshot).Scan(&inv.ID, &inv.IssuedAt); err != nil {
+		return nil, fmt.Errorf("failed to create invoice: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit invoice: %w", err)
+	}
+
+	return &inv, nil
+}
@@ -0,0 +1,127 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// LeaderLock elects one replica to run a background job. It holds a session
+// level PostgreSQL advisory lock, so the lock is released when the holder
+// calls Release or its connection dies. A LeaderLock is not safe for
+// concurrent use.
+type LeaderLock interface {
+	// Acquire takes the lock if no other session holds it and reports whether
+	// this replica is the leader. Calling it while leading checks that the
+	// connection holding the lock is still alive.
+	Acquire(ctx context.Context) (bool, error)
+	Release(ctx context.Context) error
+}
+
+// LeaderSession is the single connection a replica holds all its leader
+// locks on. It is opened outside the pool, so the locks never take
+// connections away from request handling, and reopened when it dies, which
+// loses every lock held on it.
+type LeaderSession struct {
+	config *pgx.ConnConfig
+
+	mu   sync.Mutex
+	conn *pgx.Conn
+	// held names the locks taken on conn
+	held map[string]bool
+}
+
+// NewLeaderSession creates the session; it connects on the first Acquire
+func NewLeaderSession(config *pgx.ConnConfig) *LeaderSession {
+	return &LeaderSession{config: config, held: map[string]bool{}}
+}
+
+// Lock returns the lock for the job called name. Replicas using the same
+// name compete for the same lock.
+func (s *LeaderSession) Lock(name string) LeaderLock {
+	return &leaderLock{session: s, name: name}
+}
+
+// Close ends the session, releasing every lock held on it
+func (s *LeaderSession) Close(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close(ctx)
+	s.reset()
+	return err
+}
+
+// reset forgets the connection and the locks held on it; callers hold mu
+func (s *LeaderSession) reset() {
+	s.conn = nil
+	s.held = map[string]bool{}
+}
+
+// discard closes the connection after it failed, so that no lock is left
+// held on a session the replica no longer trusts; callers hold mu
+func (s *LeaderSession) discard(ctx context.Context) {
+	_ = s.conn.Close(ctx)
+	s.reset()
+}
+
+type leaderLock struct {
+	session *LeaderSession
+	name    string
+}
+
+func (l *leaderLock) Acquire(ctx context.Context) (bool, error) {
+	s := l.session
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.held[l.name] {
+		if err := s.conn.Ping(ctx); err == nil {
+			return true, nil
+		}
+		// The session, and every lock with it, is gone
+		s.discard(ctx)
+	}
+
+	if s.conn == nil {
+		conn, err := pgx.ConnectConfig(ctx, s.config)
+		if err != nil {
+			return false, fmt.Errorf("failed to connect for leader lock: %w", err)
+		}
+		s.conn = conn
+	}
+
+	var locked bool
+	if err := s.conn.QueryRow(ctx, "SELECT pg_try_advisory_lock(hashtext($1))", l.name).Scan(&locked); err != nil {
+		s.discard(ctx)
+		return false, fmt.Errorf("failed to take leader lock %s: %w", l.name, err)
+	}
+	if locked {
+		s.held[l.name] = true
+	}
+	return locked, nil
+}
+
+func (l *leaderLock) Release(ctx context.Context) error {
+	s := l.session
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.held[l.name] {
+		return nil
+	}
+
+	if _, err := s.conn.Exec(ctx, "SELECT pg_advisory_unlock(hashtext($1))", l.name); err != nil {
+		// Closing the session is the only other way to give the lock up
+		s.discard(ctx)
+		return fmt.Errorf("failed to release leader lock %s: %w", l.name, err)
+	}
+
+	delete(s.held, l.name)
+	return nil
+}
@@ -0,0 +1,243 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/chesireabel/Technical-Interview/internal/models"
+)
+
+// MergeRepository merges duplicate customers and keeps the audit trail of merges
+type MergeRepository interface {
+	// Merge moves everything belonging to merge.LoserID to merge.WinnerID,
+	// deletes the loser and records the merge, all in one transaction. It
+	// fills in the rest of merge. Both customers must exist and not be deleted.
+	Merge(ctx context.Context, merge *models.CustomerMerge) error
+	// ListByCustomer returns the merges the customer took part in, newest first
+	ListByCustomer(ctx context.Context, customerID int64) ([]models.CustomerMerge, error)
+}
+
+type mergeRepository struct {
+	db DBTX
+}
+
+func NewMergeRepository(db DBTX) MergeRepository {
+	return &mergeRepository{db: db}
+}
+
+// moveRows reassigns the loser's rows in table to the winner and returns how many moved
+func moveRows(ctx context.Context, tx pgx.Tx, table string, winnerID, loserID int64) (int, error) {
+	result, err := tx.Exec(ctx, "UPDATE "+table+" SET customer_id = $1 WHERE customer_id = $2", winnerID, loserID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to move %s: %w", table, err)
+	}
+	return int(result.RowsAffected()), nil
+}
+
+// moveOrders reassigns the loser's orders to the winner. Their invoices,
+// payments and refunds go with them, so merge records which orders moved,
+// which were invoiced and how many payments and refunds came along.
+func moveOrders(ctx context.Context, tx pgx.Tx, merge *models.CustomerMerge) error {
+	rows, err := tx.Query(ctx, "UPDATE orders SET customer_id = $1 WHERE customer_id = $2 RETURNING id", merge.WinnerID, merge.LoserID)
+	if err != nil {
+		return fmt.Errorf("failed to move orders: %w", err)
+	}
+	merge.OrderIDs = []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan moved order: %w", err)
+		}
+		merge.OrderIDs = append(merge.OrderIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating moved orders: %w", err)
+	}
+	merge.OrdersMoved = len(merge.OrderIDs)
+
+	err = tx.QueryRow(ctx, `
+		SELECT
+			COALESCE((SELECT array_agg(order_id ORDER BY order_id) FROM invoices WHERE order_id = ANY($1)), '{}'),
+			(SELECT COUNT(*) FROM payments WHERE order_id = ANY($1)),
+			(SELECT COUNT(*) FROM refunds WHERE order_id = ANY($1))
+	`, merge.OrderIDs).Scan(&merge.InvoicedOrderIDs, &merge.PaymentsMoved, &merge.RefundsMoved)
+	if err != nil {
+		return fmt.Errorf("failed to count what moved with the orders: %w", err)
+	}
+	return nil
+}
+
+func (r *mergeRepository) Merge(ctx context.Context, merge *models.CustomerMerge) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock both customers in id order so two merges of the same pair cannot deadlock
+	rows, err := tx.Query(ctx, `
+		SELECT id, customer_name, email, COALESCE(phone, ''), code FROM customers
+		WHERE id = ANY($1) AND deleted_at IS NULL
+		ORDER BY id
+		FOR UPDATE
+	`, []int64{merge.WinnerID, merge.LoserID})
+	if err != nil {
+		return fmt.Errorf("failed to lock customers: %w", err)
+	}
+	found := make(map[int64]bool, 2)
+	for rows.Next() {
+		var c models.Customer
+		if err := rows.Scan(&c.ID, &c.Customer_name, &c.Email, &c.Phone, &c.Code); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan customer: %w", err)
+		}
+		found[c.ID] = true
+		if c.ID == merge.LoserID {
+			merge.LoserName, merge.LoserEmail, merge.LoserPhone, merge.LoserCode = c.Customer_name, c.Email, c.Phone, c.Code
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating customers: %w", err)
+	}
+	for _, id := range []int64{merge.WinnerID, merge.LoserID} {
+		if !found[id] {
+			return fmt.Errorf("customer with id %d: %w", id, models.ErrNotFound)
+		}
+	}
+
+	if err := moveOrders(ctx, tx, merge); err != nil {
+		return err
+	}
+	if merge.NotesMoved, err = moveRows(ctx, tx, "customer_notes", merge.WinnerID, merge.LoserID); err != nil {
+		return err
+	}
+	if merge.SubscriptionsMoved, err = moveRows(ctx, tx, "subscriptions", merge.WinnerID, merge.LoserID); err != nil {
+		return err
+	}
+	// Redemptions count towards per-customer promotion limits, and SMS history
+	// belongs on the winner's timeline
+	if _, err = moveRows(ctx, tx, "promotion_redemptions", merge.WinnerID, merge.LoserID); err != nil {
+		return err
+	}
+	if _, err = moveRows(ctx, tx, "sms_messages", merge.WinnerID, merge.LoserID); err != nil {
+		return err
+	}
+
+	// The winner's default address stays the default; the loser's becomes
+	// the default only when the winner has none
+	result, err := tx.Exec(ctx, `
+		UPDATE customer_addresses
+		SET customer_id = $1,
+			is_default = is_default AND NOT EXISTS (
+				SELECT 1 FROM customer_addresses WHERE customer_id = $1 AND is_default
+			),
+			updated_at = NOW()
+		WHERE customer_id = $2
+	`, merge.WinnerID, merge.LoserID)
+	if err != nil {
+		return fmt.Errorf("failed to move customer_addresses: %w", err)
+	}
+	merge.AddressesMoved = int(result.RowsAffected())
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO customer_tags (customer_id, tag)
+		SELECT $1, tag FROM customer_tags WHERE customer_id = $2
+		ON CONFLICT (customer_id, tag) DO NOTHING
+	`, merge.WinnerID, merge.LoserID)
+	if err != nil {
+		return fmt.Errorf("failed to copy tags: %w", err)
+	}
+	if _, err := tx.Exec(ctx, "DELETE FROM customer_tags WHERE customer_id = $1", merge.LoserID); err != nil {
+		return fmt.Errorf("failed to remove merged tags: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, "UPDATE customers SET deleted_at = NOW() WHERE id = $1", merge.LoserID); err != nil {
+		return fmt.Errorf("failed to delete merged customer: %w", err)
+	}
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO customer_merges (winner_id, loser_id, loser_name, loser_email, loser_phone, loser_code, merged_by,
+			orders_moved, notes_moved, addresses_moved, subscriptions_moved,
+			order_ids, invoiced_order_ids, payments_moved, refunds_moved, merged_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, NOW())
+		RETURNING id, merged_at
+	`,
+		merge.WinnerID,
+		merge.LoserID,
+		merge.LoserName,
+		merge.LoserEmail,
+		merge.LoserPhone,
+		merge.LoserCode,
+		merge.MergedBy,
+		merge.OrdersMoved,
+		merge.NotesMoved,
+		merge.AddressesMoved,
+		merge.SubscriptionsMoved,
+		merge.OrderIDs,
+		merge.InvoicedOrderIDs,
+		merge.PaymentsMoved,
+		merge.RefundsMoved,
+	).Scan(&merge.ID, &merge.MergedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record merge: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (r *mergeRepository) ListByCustomer(ctx context.Context, customerID int64) ([]models.CustomerMerge, error) {
+	merges := []models.CustomerMerge{}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT id, winner_id, loser_id, loser_name, loser_email, COALESCE(loser_phone, ''), loser_code, merged_by,
+			orders_moved, notes_moved, addresses_moved, subscriptions_moved,
+			order_ids, invoiced_order_ids, payments_moved, refunds_moved, merged_at
+		FROM customer_merges
+		WHERE winner_id = $1 OR loser_id = $1
+		ORDER BY merged_at DESC, id DESC
+	`, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get merges: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m models.CustomerMerge
+		err := rows.Scan(
+			&m.ID,
+			&m.WinnerID,
+			&m.LoserID,
+			&m.LoserName,
+			&m.LoserEmail,
+			&m.LoserPhone,
+			&m.LoserCode,
+			&m.MergedBy,
+			&m.OrdersMoved,
+			&m.NotesMoved,
+			&m.AddressesMoved,
+			&m.SubscriptionsMoved,
+			&m.OrderIDs,
+			&m.InvoicedOrderIDs,
+			&m.PaymentsMoved,
+			&m.RefundsMoved,
+			&m.MergedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan merge: %w", err)
+		}
+		merges = append(merges, m)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating merges: %w", err)
+	}
+
+	return merges, nil
+}
@@ -0,0 +1,129 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/chesireabel/Technical-Interview/internal/models"
+)
+
+// NoteRepository stores the notes support staff record against customers.
+// Every method is scoped to the customer, so another customer's note is
+// reported as not found.
+type NoteRepository interface {
+	Create(ctx context.Context, note *models.CustomerNote) error
+	GetByID(ctx context.Context, customerID, id int64) (*models.CustomerNote, error)
+	// List returns pinned notes first, then the newest
+	List(ctx context.Context, customerID int64) ([]models.CustomerNote, error)
+	// Update changes the note's body and pinned flag
+	Update(ctx context.Context, note *models.CustomerNote) error
+	Delete(ctx context.Context, customerID, id int64) error
+}
+
+type noteRepository struct {
+	db DBTX
+}
+
+func NewNoteRepository(db DBTX) NoteRepository {
+	return &noteRepository{db: db}
+}
+
+const noteColumns = "id, customer_id, author, body, pinned, created_at, updated_at"
+
+func scanNote(row pgx.Row, n *models.CustomerNote) error {
+	return row.Scan(
+		&n.ID,
+		&n.CustomerID,
+		&n.Author,
+		&n.Body,
+		&n.Pinned,
+		&n.CreatedAt,
+		&n.UpdatedAt,
+	)
+}
+
+// Create reports models.ErrNotFound when the customer does not exist or is deleted
+func (r *noteRepository) Create(ctx context.Context, note *models.CustomerNote) error {
+	err := r.db.QueryRow(ctx, `
+		INSERT INTO customer_notes (customer_id, author, body, pinned, created_at, updated_at)
+		SELECT id, $2, $3, $4, NOW(), NOW() FROM customers WHERE id = $1 AND deleted_at IS NULL
+		RETURNING id, created_at, updated_at
+	`, note.CustomerID, note.Author, note.Body, note.Pinned).Scan(&note.ID, &note.CreatedAt, &note.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("customer with id %d: %w", note.CustomerID, models.ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create note: %w", err)
+	}
+	return nil
+}
+
+func (r *noteRepository) GetByID(ctx context.Context, customerID, id int64) (*models.CustomerNote, error) {
+	var n models.CustomerNote
+	query := "SELECT " + noteColumns + " FROM customer_notes WHERE id = $1 AND customer_id = $2"
+
+	err := scanNote(r.db.QueryRow(ctx, query, id, customerID), &n)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("note with id %d: %w", id, models.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get note: %w", err)
+	}
+
+	return &n, nil
+}
+
+func (r *noteRepository) List(ctx context.Context, customerID int64) ([]models.CustomerNote, error) {
+	notes := []models.CustomerNote{}
+
+	rows, err := r.db.Query(ctx,
+		"SELECT "+noteColumns+" FROM customer_notes WHERE customer_id = $1 ORDER BY pinned DESC, created_at DESC, id DESC",
+		customerID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notes: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var n models.CustomerNote
+		if err := scanNote(rows, &n); err != nil {
+			return nil, fmt.Errorf("failed to scan note: %w", err)
+		}
+		notes = append(notes, n)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating notes: %w", err)
+	}
+
+	return notes, nil
+}
+
+func (r *noteRepository) Update(ctx context.Context, note *models.CustomerNote) error {
+	err := r.db.QueryRow(ctx, `
+		UPDATE customer_notes SET body = $1, pinned = $2, updated_at = NOW()
+		WHERE id = $3 AND customer_id = $4
+		RETURNING author, created_at, updated_at
+	`, note.Body, note.Pinned, note.ID, note.CustomerID).Scan(&note.Author, &note.CreatedAt, &note.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("note with id %d: %w", note.ID, models.ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update note: %w", err)
+	}
+	return nil
+}
+
+func (r *noteRepository) Delete(ctx context.Context, customerID, id int64) error {
+	result, err := r.db.Exec(ctx, "DELETE FROM customer_notes WHERE id = $1 AND customer_id = $2", id, customerID)
+	if err != nil {
+		return fmt.Errorf("failed to delete note: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("note with id %d: %w", id, models.ErrNotFound)
+	}
+	return nil
+}
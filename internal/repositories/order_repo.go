@@ -2,58 +2,105 @@ package repositories
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"time"
 
-	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5"
 	"github.com/chesireabel/Technical-Interview/internal/models"
 )
 
 type OrderRepository interface {
 	Create(ctx context.Context, order *models.Order) (int64, error)
 	GetByID(ctx context.Context, id int64) (*models.Order, error)
-	GetByCustomerID(ctx context.Context, customerID int64) ([]models.Order, error)
-	GetAll(ctx context.Context) ([]models.Order, error)
+	GetByCustomerID(ctx context.Context, customerID int64, includeDeleted bool) ([]models.Order, error)
+	GetAll(ctx context.Context, includeDeleted bool) ([]models.Order, error)
 	Update(ctx context.Context, order *models.Order) error
 	Delete(ctx context.Context, id int64) error
+	Restore(ctx context.Context, id int64) error
+	Purge(ctx context.Context, deletedBefore time.Time) (int64, error)
+	Stream(ctx context.Context, customerID int64, includeDeleted bool, fn func(*models.Order) error) error
+	Transition(ctx context.Context, change *models.OrderStatusChange) error
+	GetStatusHistory(ctx context.Context, orderID int64) ([]models.OrderStatusChange, error)
+	// Reschedule moves a scheduled order to fire at scheduledFor
+	Reschedule(ctx context.Context, id int64, scheduledFor time.Time) error
+	// ListDueScheduled returns the IDs of scheduled orders due at or before now, oldest first
+	ListDueScheduled(ctx context.Context, now time.Time, limit int) ([]int64, error)
 }
 
 type orderRepository struct {
-	db *pgxpool.Pool
+	db DBTX
 }
 
-func NewOrderRepository(db *pgxpool.Pool) OrderRepository {
+func NewOrderRepository(db DBTX) OrderRepository {
 	return &orderRepository{db: db}
 }
 
+// Create inserts the order, its line items and, unless the order is
+// scheduled, the order.created outbox message in one transaction
 func (r *orderRepository) Create(ctx context.Context, order *models.Order) (int64, error) {
 	query := `
-		INSERT INTO orders (customer_id, item, amount, ordered_at, created_at)
-		VALUES ($1, $2, $3, $4, NOW())
+		INSERT INTO orders (customer_id, item, amount, currency, status, subtotal, discount, promo_code, tax, tax_region, scheduled_for,
+			address_id, delivery_address, delivery_instructions, ordered_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), $9, NULLIF($10, ''), $11, $12, $13, NULLIF($14, ''), $15, NOW())
 		RETURNING id
 	`
 
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
 	var id int64
-	err := r.db.QueryRow(
+	err = tx.QueryRow(
 		ctx,
 		query,
 		order.CustomerID,
 		order.Item,
 		order.Amount,
+		order.Currency,
+		order.Status,
+		order.Subtotal,
+		order.Discount,
+		order.PromoCode,
+		order.Tax,
+		order.TaxRegion,
+		order.ScheduledFor,
+		order.AddressID,
+		order.DeliveryAddress,
+		order.DeliveryInstructions,
 		order.OrderedAt,
 	).Scan(&id)
 
 	if err != nil {
 		return 0, fmt.Errorf("failed to create order: %w", err)
 	}
+
+	if err := insertOrderItems(ctx, tx, id, order.Items); err != nil {
+		return 0, err
+	}
+
+	// Scheduled orders are confirmed to the customer when they fire
+	if order.Status != models.OrderStatusScheduled {
+		if err := insertOutbox(ctx, tx, models.TopicOrderCreated, id, nil); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit order: %w", err)
+	}
 	return id, nil
 }
 
 func (r *orderRepository) GetByID(ctx context.Context, id int64) (*models.Order, error) {
 	var o models.Order
 	query := `
-		SELECT id, customer_id, item, amount, ordered_at, created_at 
+		SELECT id, customer_id, item, amount, currency, status, subtotal, discount, COALESCE(promo_code, ''), tax, COALESCE(tax_region, ''), scheduled_for, address_id, delivery_address, COALESCE(delivery_instructions, ''), ordered_at, created_at, deleted_at 
 		FROM orders 
-		WHERE id = $1
+		WHERE id = $1 AND deleted_at IS NULL
 	`
 	
 	err := r.db.QueryRow(ctx, query, id).Scan(
@@ -61,26 +108,48 @@ func (r *orderRepository) GetByID(ctx context.Context, id int64) (*models.Order,
 		&o.CustomerID,
 		&o.Item,
 		&o.Amount,
+		&o.Currency,
+		&o.Status,
+		&o.Subtotal,
+		&o.Discount,
+		&o.PromoCode,
+		&o.Tax,
+		&o.TaxRegion,
+		&o.ScheduledFor,
+		&o.AddressID,
+		&o.DeliveryAddress,
+		&o.DeliveryInstructions,
 		&o.OrderedAt,
 		&o.CreatedAt,
+		&o.DeletedAt,
 	)
 	
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("order with id %d: %w", id, models.ErrNotFound)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("order not found: %w", err)
 	}
+
+	items, err := r.itemsByOrder(ctx, []int64{o.ID})
+	if err != nil {
+		return nil, err
+	}
+	o.Items = items[o.ID]
+
 	return &o, nil
 }
 
-func (r *orderRepository) GetByCustomerID(ctx context.Context, customerID int64) ([]models.Order, error) {
+func (r *orderRepository) GetByCustomerID(ctx context.Context, customerID int64, includeDeleted bool) ([]models.Order, error) {
 	var orders []models.Order
 	query := `
-		SELECT id, customer_id, item, amount, ordered_at, created_at 
+		SELECT id, customer_id, item, amount, currency, status, subtotal, discount, COALESCE(promo_code, ''), tax, COALESCE(tax_region, ''), scheduled_for, address_id, delivery_address, COALESCE(delivery_instructions, ''), ordered_at, created_at, deleted_at 
 		FROM orders 
-		WHERE customer_id = $1
+		WHERE customer_id = $1 AND ($2 OR deleted_at IS NULL)
 		ORDER BY ordered_at DESC
 	`
 	
-	rows, err := r.db.Query(ctx, query, customerID)
+	rows, err := r.db.Query(ctx, query, customerID, includeDeleted)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get orders for customer: %w", err)
 	}
@@ -93,8 +162,20 @@ func (r *orderRepository) GetByCustomerID(ctx context.Context, customerID int64)
 			&o.CustomerID,
 			&o.Item,
 			&o.Amount,
+			&o.Currency,
+			&o.Status,
+			&o.Subtotal,
+			&o.Discount,
+			&o.PromoCode,
+			&o.Tax,
+			&o.TaxRegion,
+			&o.ScheduledFor,
+			&o.AddressID,
+			&o.DeliveryAddress,
+			&o.DeliveryInstructions,
 			&o.OrderedAt,
 			&o.CreatedAt,
+			&o.DeletedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan order: %w", err)
@@ -106,18 +187,23 @@ func (r *orderRepository) GetByCustomerID(ctx context.Context, customerID int64)
 		return nil, fmt.Errorf("error iterating orders: %w", err)
 	}
 
+	if err := r.attachItems(ctx, orders); err != nil {
+		return nil, err
+	}
+
 	return orders, nil
 }
 
-func (r *orderRepository) GetAll(ctx context.Context) ([]models.Order, error) {
+func (r *orderRepository) GetAll(ctx context.Context, includeDeleted bool) ([]models.Order, error) {
 	var orders []models.Order
 	query := `
-		SELECT id, customer_id, item, amount, ordered_at, created_at 
+		SELECT id, customer_id, item, amount, currency, status, subtotal, discount, COALESCE(promo_code, ''), tax, COALESCE(tax_region, ''), scheduled_for, address_id, delivery_address, COALESCE(delivery_instructions, ''), ordered_at, created_at, deleted_at 
 		FROM orders 
+		WHERE $1 OR deleted_at IS NULL
 		ORDER BY created_at DESC
 	`
 	
-	rows, err := r.db.Query(ctx, query)
+	rows, err := r.db.Query(ctx, query, includeDeleted)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get orders: %w", err)
 	}
@@ -130,8 +216,20 @@ func (r *orderRepository) GetAll(ctx context.Context) ([]models.Order, error) {
 			&o.CustomerID,
 			&o.Item,
 			&o.Amount,
+			&o.Currency,
+			&o.Status,
+			&o.Subtotal,
+			&o.Discount,
+			&o.PromoCode,
+			&o.Tax,
+			&o.TaxRegion,
+			&o.ScheduledFor,
+			&o.AddressID,
+			&o.DeliveryAddress,
+			&o.DeliveryInstructions,
 			&o.OrderedAt,
 			&o.CreatedAt,
+			&o.DeletedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan order: %w", err)
@@ -143,22 +241,37 @@ func (r *orderRepository) GetAll(ctx context.Context) ([]models.Order, error) {
 		return nil, fmt.Errorf("error iterating orders: %w", err)
 	}
 
+	if err := r.attachItems(ctx, orders); err != nil {
+		return nil, err
+	}
+
 	return orders, nil
 }
 
+// Update rewrites the order and replaces its line items in one transaction
 func (r *orderRepository) Update(ctx context.Context, order *models.Order) error {
 	query := `
 		UPDATE orders
-		SET customer_id = $1, item = $2, amount = $3, ordered_at = $4
-		WHERE id = $5
+		SET customer_id = $1, item = $2, amount = $3, currency = $4, subtotal = $5, discount = $6, tax = $7, ordered_at = $8
+		WHERE id = $9 AND deleted_at IS NULL
 	`
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
 	
-	cmdTag, err := r.db.Exec(
+	cmdTag, err := tx.Exec(
 		ctx,
 		query,
 		order.CustomerID,
 		order.Item,
 		order.Amount,
+		order.Currency,
+		order.Subtotal,
+		order.Discount,
+		order.Tax,
 		order.OrderedAt,
 		order.ID,
 	)
@@ -168,14 +281,26 @@ func (r *orderRepository) Update(ctx context.Context, order *models.Order) error
 	}
 
 	if cmdTag.RowsAffected() == 0 {
-		return fmt.Errorf("order with id %d not found", order.ID)
+		return fmt.Errorf("order with id %d: %w", order.ID, models.ErrNotFound)
+	}
+
+	if _, err := tx.Exec(ctx, "DELETE FROM order_items WHERE order_id = $1", order.ID); err != nil {
+		return fmt.Errorf("failed to replace order items: %w", err)
+	}
+
+	if err := insertOrderItems(ctx, tx, order.ID, order.Items); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit order update: %w", err)
 	}
 
 	return nil
 }
 
 func (r *orderRepository) Delete(ctx context.Context, id int64) error {
-	query := "DELETE FROM orders WHERE id = $1"
+	query := "UPDATE orders SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL"
 	
 	cmdTag, err := r.db.Exec(ctx, query, id)
 	if err != nil {
@@ -183,8 +308,348 @@ func (r *orderRepository) Delete(ctx context.Context, id int64) error {
 	}
 
 	if cmdTag.RowsAffected() == 0 {
-		return fmt.Errorf("order with id %d not found", id)
+		return fmt.Errorf("order with id %d: %w", id, models.ErrNotFound)
+	}
+
+	return nil
+}
+
+// Restore undoes a soft delete. Orders of a deleted customer stay deleted until
+// the customer is restored.
+func (r *orderRepository) Restore(ctx context.Context, id int64) error {
+	query := `
+		UPDATE orders o
+		SET deleted_at = NULL
+		FROM customers c
+		WHERE o.id = $1
+		  AND o.deleted_at IS NOT NULL
+		  AND c.id = o.customer_id
+		  AND c.deleted_at IS NULL
+	`
+
+	cmdTag, err := r.db.Exec(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to restore order: %w", err)
+	}
+
+	if cmdTag.RowsAffected() == 0 {
+		return fmt.Errorf("deleted order with id %d: %w", id, models.ErrNotFound)
+	}
+
+	return nil
+}
+
+// Purge permanently removes orders soft deleted before the given time
+func (r *orderRepository) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	query := "DELETE FROM orders WHERE deleted_at IS NOT NULL AND deleted_at < $1"
+
+	cmdTag, err := r.db.Exec(ctx, query, deletedBefore)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge orders: %w", err)
+	}
+
+	return cmdTag.RowsAffected(), nil
+}
+
+// Stream calls fn for each order as rows arrive from the database, without
+// building the full result set in memory. A customerID of 0 streams every
+// customer's orders. Items are filled in. Returning an error from fn stops the scan.
+func (r *orderRepository) Stream(ctx context.Context, customerID int64, includeDeleted bool, fn func(*models.Order) error) error {
+	query := `
+		SELECT id, customer_id, item, amount, currency, status, subtotal, discount, COALESCE(promo_code, ''), tax, COALESCE(tax_region, ''), scheduled_for, address_id, delivery_address, COALESCE(delivery_instructions, ''), ordered_at, created_at, deleted_at,
+		       COALESCE((
+		           SELECT json_agg(json_build_object(
+		               'id', i.id, 'order_id', i.order_id, 'product_id', i.product_id, 'product_ref', COALESCE(i.product_ref, ''),
+		               'description', i.description, 'quantity', i.quantity, 'unit_price', i.unit_price, 'line_total', i.line_total,
+		               'discount', i.discount, 'tax_name', COALESCE(i.tax_name, ''), 'tax_rate', i.tax_rate,
+		               'tax_inclusive', i.tax_inclusive, 'tax', i.tax
+		           ) ORDER BY i.id)
+		           FROM order_items i
+		           WHERE i.order_id = orders.id
+		       ), '[]')
+		FROM orders
+		WHERE ($1 = 0 OR customer_id = $1) AND ($2 OR deleted_at IS NULL)
+		ORDER BY id
+	`
+
+	// Items come back as JSON with each order, because the connection is busy
+	// with this cursor until the stream ends and cannot run a second query
+	rows, err := r.db.Query(ctx, query, customerID, includeDeleted)
+	if err != nil {
+		return fmt.Errorf("failed to stream orders: %w", err)
+	}
+	defer rows.Close()
+
+	var o models.Order
+	var items []byte
+	for rows.Next() {
+		err := rows.Scan(
+			&o.ID,
+			&o.CustomerID,
+			&o.Item,
+			&o.Amount,
+			&o.Currency,
+			&o.Status,
+			&o.Subtotal,
+			&o.Discount,
+			&o.PromoCode,
+			&o.Tax,
+			&o.TaxRegion,
+			&o.ScheduledFor,
+			&o.AddressID,
+			&o.DeliveryAddress,
+			&o.DeliveryInstructions,
+			&o.OrderedAt,
+			&o.CreatedAt,
+			&o.DeletedAt,
+			&items,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to scan order: %w", err)
+		}
+		o.Items = nil
+		if err := json.Unmarshal(items, &o.Items); err != nil {
+			return fmt.Errorf("failed to decode items of order %d: %w", o.ID, err)
+		}
+		if err := fn(&o); err != nil {
+			return err
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("error iterating orders: %w", err)
+	}
+
+	return nil
+}
+
+// insertOrderItems stores the order's line items and fills in their IDs
+func insertOrderItems(ctx context.Context, tx pgx.Tx, orderID int64, items []models.OrderItem) error {
+	query := `
+		INSERT INTO order_items (order_id, product_id, product_ref, description, quantity, unit_price, line_total,
+			discount, tax_name, tax_rate, tax_inclusive, tax)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, $7, $8, NULLIF($9, ''), $10, $11, $12)
+		RETURNING id
+	`
+
+	for i := range items {
+		item := &items[i]
+		err := tx.QueryRow(ctx, query,
+			orderID,
+			item.ProductID,
+			item.ProductRef,
+			item.Description,
+			item.Quantity,
+			item.UnitPrice,
+			item.LineTotal,
+			item.Discount,
+			item.TaxName,
+			item.TaxRate,
+			item.TaxInclusive,
+			item.Tax,
+		).Scan(&item.ID)
+		if err != nil {
+			return fmt.Errorf("failed to create order item: %w", err)
+		}
+		item.OrderID = orderID
+	}
+
+	return nil
+}
+
+// itemsByOrder loads the line items of the given orders keyed by order ID
+func (r *orderRepository) itemsByOrder(ctx context.Context, orderIDs []int64) (map[int64][]models.OrderItem, error) {
+	items := make(map[int64][]models.OrderItem, len(orderIDs))
+	if len(orderIDs) == 0 {
+		return items, nil
+	}
+
+	query := `
+		SELECT id, order_id, product_id, COALESCE(product_ref, ''), description, quantity, unit_price, line_total,
+		       discount, COALESCE(tax_name, ''), tax_rate, tax_inclusive, tax
+		FROM order_items
+		WHERE order_id = ANY($1)
+		ORDER BY order_id, id
+	`
+
+	rows, err := r.db.Query(ctx, query, orderIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order items: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item models.OrderItem
+		err := rows.Scan(
+			&item.ID,
+			&item.OrderID,
+			&item.ProductID,
+			&item.ProductRef,
+			&item.Description,
+			&item.Quantity,
+			&item.UnitPrice,
+			&item.LineTotal,
+			&item.Discount,
+			&item.TaxName,
+			&item.TaxRate,
+			&item.TaxInclusive,
+			&item.Tax,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan order item: %w", err)
+		}
+		items[item.OrderID] = append(items[item.OrderID], item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating order items: %w", err)
+	}
+
+	return items, nil
+}
+
+// attachItems fills in the line items of every order in one query
+func (r *orderRepository) attachItems(ctx context.Context, orders []models.Order) error {
+	ids := make([]int64, len(orders))
+	for i, o := range orders {
+		ids[i] = o.ID
+	}
+
+	items, err := r.itemsByOrder(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range orders {
+		orders[i].Items = items[orders[i].ID]
+	}
+	return nil
+}
+
+// Transition moves an order from change.FromStatus to change.ToStatus and records
+// the change in order_status_history and the outbox. The update only applies
+// while the order is still in FromStatus, so concurrent transitions cannot both succeed.
+func (r *orderRepository) Transition(ctx context.Context, change *models.OrderStatusChange) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	cmdTag, err := tx.Exec(ctx, `
+		UPDATE orders
+		SET status = $1
+		WHERE id = $2 AND status = $3 AND deleted_at IS NULL
+	`, change.ToStatus, change.OrderID, change.FromStatus)
+	if err != nil {
+		return fmt.Errorf("failed to update order status: %w", err)
+	}
+
+	if cmdTag.RowsAffected() == 0 {
+		return fmt.Errorf("order with id %d in status %s: %w", change.OrderID, change.FromStatus, models.ErrNotFound)
+	}
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO order_status_history (order_id, from_status, to_status, changed_by, reason)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''))
+		RETURNING id, changed_at
+	`, change.OrderID, change.FromStatus, change.ToStatus, change.ChangedBy, change.Reason).Scan(&change.ID, &change.ChangedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record order status change: %w", err)
+	}
+
+	payload := models.OrderStatusChangedPayload{From: change.FromStatus, To: change.ToStatus}
+	if err := insertOutbox(ctx, tx, models.TopicOrderStatusChanged, change.OrderID, payload); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit order status change: %w", err)
+	}
+
+	return nil
+}
+
+func (r *orderRepository) GetStatusHistory(ctx context.Context, orderID int64) ([]models.OrderStatusChange, error) {
+	changes := []models.OrderStatusChange{}
+	query := `
+		SELECT id, order_id, from_status, to_status, changed_by, COALESCE(reason, ''), changed_at
+		FROM order_status_history
+		WHERE order_id = $1
+		ORDER BY changed_at, id
+	`
+
+	rows, err := r.db.Query(ctx, query, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order status history: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c models.OrderStatusChange
+		err := rows.Scan(
+			&c.ID,
+			&c.OrderID,
+			&c.FromStatus,
+			&c.ToStatus,
+			&c.ChangedBy,
+			&c.Reason,
+			&c.ChangedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan order status change: %w", err)
+		}
+		changes = append(changes, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating order status history: %w", err)
+	}
+
+	return changes, nil
+}
+
+func (r *orderRepository) Reschedule(ctx context.Context, id int64, scheduledFor time.Time) error {
+	cmdTag, err := r.db.Exec(ctx, `
+		UPDATE orders SET scheduled_for = $1
+		WHERE id = $2 AND status = 'scheduled' AND deleted_at IS NULL
+	`, scheduledFor, id)
+	if err != nil {
+		return fmt.Errorf("failed to reschedule order: %w", err)
+	}
+
+	if cmdTag.RowsAffected() == 0 {
+		return fmt.Errorf("scheduled order with id %d: %w", id, models.ErrNotFound)
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+func (r *orderRepository) ListDueScheduled(ctx context.Context, now time.Time, limit int) ([]int64, error) {
+	ids := []int64{}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT id FROM orders
+		WHERE status = 'scheduled' AND scheduled_for <= $1 AND deleted_at IS NULL
+		ORDER BY scheduled_for, id
+		LIMIT $2
+	`, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get due scheduled orders: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan order id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating due scheduled orders: %w", err)
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,133 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/chesireabel/Technical-Interview/internal/models"
+)
+
+type OutboxRepository interface {
+	Claim(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxMessage, error)
+	MarkSent(ctx context.Context, id int64) error
+	Retry(ctx context.Context, id int64, lastError string, availableAt time.Time) error
+	MarkDead(ctx context.Context, id int64, lastError string) error
+}
+
+type outboxRepository struct {
+	db DBTX
+}
+
+func NewOutboxRepository(db DBTX) OutboxRepository {
+	return &outboxRepository{db: db}
+}
+
+// insertOutbox records a message inside the caller's transaction so it is only
+// delivered if the change that produced it commits
+func insertOutbox(ctx context.Context, tx pgx.Tx, topic string, aggregateID int64, payload any) error {
+	body := []byte("{}")
+	if payload != nil {
+		var err error
+		if body, err = json.Marshal(payload); err != nil {
+			return fmt.Errorf("failed to encode %s outbox payload: %w", topic, err)
+		}
+	}
+
+	_, err := tx.Exec(ctx, `
+		INSERT INTO outbox (topic, aggregate_id, payload)
+		VALUES ($1, $2, $3)
+	`, topic, aggregateID, body)
+	if err != nil {
+		return fmt.Errorf("failed to write %s to outbox: %w", topic, err)
+	}
+
+	return nil
+}
+
+// Claim takes up to limit due messages and hides them from other dispatchers
+// for the lease period. Rows locked by another replica are skipped rather than
+// waited on. A dispatcher that dies mid-batch loses its lease and the messages
+// become due again, so delivery is at least once.
+func (r *outboxRepository) Claim(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxMessage, error) {
+	query := `
+		UPDATE outbox
+		SET attempts = attempts + 1,
+		    available_at = NOW() + $2 * INTERVAL '1 millisecond'
+		WHERE id IN (
+			SELECT id FROM outbox
+			WHERE status = 'pending' AND available_at <= NOW()
+			ORDER BY available_at, id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, topic, aggregate_id, payload, status, attempts, COALESCE(last_error, ''), available_at, created_at, processed_at
+	`
+
+	rows, err := r.db.Query(ctx, query, limit, lease.Milliseconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim outbox messages: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []models.OutboxMessage
+	for rows.Next() {
+		var m models.OutboxMessage
+		err := rows.Scan(
+			&m.ID,
+			&m.Topic,
+			&m.AggregateID,
+			&m.Payload,
+			&m.Status,
+			&m.Attempts,
+			&m.LastError,
+			&m.AvailableAt,
+			&m.CreatedAt,
+			&m.ProcessedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan outbox message: %w", err)
+		}
+		messages = append(messages, m)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating outbox messages: %w", err)
+	}
+
+	return messages, nil
+}
+
+func (r *outboxRepository) MarkSent(ctx context.Context, id int64) error {
+	query := "UPDATE outbox SET status = 'sent', processed_at = NOW(), last_error = NULL WHERE id = $1"
+
+	if _, err := r.db.Exec(ctx, query, id); err != nil {
+		return fmt.Errorf("failed to mark outbox message %d sent: %w", id, err)
+	}
+
+	return nil
+}
+
+// Retry records a failed attempt and schedules the next one
+func (r *outboxRepository) Retry(ctx context.Context, id int64, lastError string, availableAt time.Time) error {
+	query := "UPDATE outbox SET last_error = $2, available_at = $3 WHERE id = $1 AND status = 'pending'"
+
+	if _, err := r.db.Exec(ctx, query, id, lastError, availableAt); err != nil {
+		return fmt.Errorf("failed to reschedule outbox message %d: %w", id, err)
+	}
+
+	return nil
+}
+
+// MarkDead parks a message that will never be delivered for manual inspection
+func (r *outboxRepository) MarkDead(ctx context.Context, id int64, lastError string) error {
+	query := "UPDATE outbox SET status = 'dead', last_error = $2, processed_at = NOW() WHERE id = $1"
+
+	if _, err := r.db.Exec(ctx, query, id, lastError); err != nil {
+		return fmt.Errorf("failed to dead-letter outbox message %d: %w", id, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,341 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/chesireabel/Technical-Interview/internal/models"
+)
+
+type PaymentRepository interface {
+	// Create records a new pending attempt. It fails with
+	// models.ErrPaymentInProgress when the order has another attempt started
+	// after activeSince that is still pending. The order row is locked for the
+	// check, so two attempts cannot both pass it.
+	Create(ctx context.Context, payment *models.Payment, activeSince time.Time) error
+	// HasPending reports whether the order has an attempt started after
+	// activeSince that is still pending
+	HasPending(ctx context.Context, orderID int64, activeSince time.Time) (bool, error)
+	// SetReference stores the provider's id for an attempt once the provider accepted it
+	SetReference(ctx context.Context, id int64, reference string) error
+	// MarkFailed fails an attempt the provider never accepted
+	MarkFailed(ctx context.Context, id int64, reason string) error
+	// Complete records the provider's result for a pending attempt, storing the
+	// amount the provider collected on success. It returns
+	// false with the stored payment when the attempt was already completed,
+	// so repeated callbacks are harmless.
+	Complete(ctx context.Context, result *models.PaymentResult) (*models.Payment, bool, error)
+	ListByOrder(ctx context.Context, orderID int64) ([]models.Payment, error)
+	// ListForReconciliation returns payments known to their provider that were
+	// started between from and to
+	ListForReconciliation(ctx context.Context, from, to time.Time) ([]models.Payment, error)
+	// FlagMismatch records a mismatch unless the same one is already open,
+	// reporting whether it was recorded
+	FlagMismatch(ctx context.Context, mismatch *models.PaymentMismatch) (bool, error)
+	ListMismatches(ctx context.Context, includeResolved bool) ([]models.PaymentMismatch, error)
+	ResolveMismatch(ctx context.Context, id int64) error
+}
+
+type paymentRepository struct {
+	db DBTX
+}
+
+func NewPaymentRepository(db DBTX) PaymentRepository {
+	return &paymentRepository{db: db}
+}
+
+const paymentColumns = `id, order_id, provider, phone, amount, currency, status, COALESCE(reference, ''),
+	COALESCE(receipt, ''), result_code, COALESCE(result_desc, ''), paid_at, created_at, updated_at`
+
+func scanPayment(row pgx.Row, payment *models.Payment) error {
+	return row.Scan(
+		&payment.ID,
+		&payment.OrderID,
+		&payment.Provider,
+		&payment.Phone,
+		&payment.Amount,
+		&payment.Currency,
+		&payment.Status,
+		&payment.Reference,
+		&payment.Receipt,
+		&payment.ResultCode,
+		&payment.ResultDesc,
+		&payment.PaidAt,
+		&payment.CreatedAt,
+		&payment.UpdatedAt,
+	)
+}
+
+func (r *paymentRepository) Create(ctx context.Context, payment *models.Payment, activeSince time.Time) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var locked int64
+	err = tx.QueryRow(ctx, "SELECT id FROM orders WHERE id = $1 FOR UPDATE", payment.OrderID).Scan(&locked)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("order with id %d: %w", payment.OrderID, models.ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock order: %w", err)
+	}
+
+	pending, err := hasPendingPayment(ctx, tx, payment.OrderID, activeSince)
+	if err != nil {
+		return err
+	}
+	if pending {
+		return models.ErrPaymentInProgress
+	}
+
+	query := `
+		INSERT INTO payments (order_id, provider, phone, amount, currency, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, 'pending', NOW(), NOW())
+		RETURNING id, status, created_at, updated_at
+	`
+
+	err = tx.QueryRow(ctx, query,
+		payment.OrderID,
+		payment.Provider,
+		payment.Phone,
+		payment.Amount,
+		payment.Currency,
+	).Scan(&payment.ID, &payment.Status, &payment.CreatedAt, &payment.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create payment: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit payment: %w", err)
+	}
+
+	return nil
+}
+
+func hasPendingPayment(ctx context.Context, db DBTX, orderID int64, activeSince time.Time) (bool, error) {
+	var pending bool
+	err := db.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM payments
+			WHERE order_id = $1 AND status = 'pending' AND created_at > $2
+		)
+	`, orderID, activeSince).Scan(&pending)
+	if err != nil {
+		return false, fmt.Errorf("failed to look up pending payments: %w", err)
+	}
+	return pending, nil
+}
+
+func (r *paymentRepository) HasPending(ctx context.Context, orderID int64, activeSince time.Time) (bool, error) {
+	return hasPendingPayment(ctx, r.db, orderID, activeSince)
+}
+
+func (r *paymentRepository) SetReference(ctx context.Context, id int64, reference string) error {
+	cmdTag, err := r.db.Exec(ctx, `
+		UPDATE payments SET reference = $1, updated_at = NOW()
+		WHERE id = $2
+	`, reference, id)
+	if err != nil {
+		return fmt.Errorf("failed to set payment reference: %w", err)
+	}
+
+	if cmdTag.RowsAffected() == 0 {
+		return fmt.Errorf("payment with id %d: %w", id, models.ErrNotFound)
+	}
+
+	return nil
+}
+
+func (r *paymentRepository) MarkFailed(ctx context.Context, id int64, reason string) error {
+	cmdTag, err := r.db.Exec(ctx, `
+		UPDATE payments SET status = 'failed', result_desc = $1, updated_at = NOW()
+		WHERE id = $2 AND status = 'pending'
+	`, reason, id)
+	if err != nil {
+		return fmt.Errorf("failed to mark payment failed: %w", err)
+	}
+
+	if cmdTag.RowsAffected() == 0 {
+		return fmt.Errorf("pending payment with id %d: %w", id, models.ErrNotFound)
+	}
+
+	return nil
+}
+
+func (r *paymentRepository) Complete(ctx context.Context, result *models.PaymentResult) (*models.Payment, bool, error) {
+	if result.Status != models.PaymentSucceeded && result.Status != models.PaymentFailed {
+		return nil, false, fmt.Errorf("cannot complete payment with status %q", result.Status)
+	}
+	var paidAt any
+	if result.Status == models.PaymentSucceeded {
+		paidAt = result.PaidAt
+	}
+
+	var payment models.Payment
+	err := scanPayment(r.db.QueryRow(ctx, `
+		UPDATE payments
+		SET status = $1, receipt = NULLIF($2, ''), result_code = $3, result_desc = $4, paid_at = $5, updated_at = NOW(),
+		    amount = CASE WHEN $1 = 'succeeded' THEN $8 ELSE amount END
+		WHERE provider = $6 AND reference = $7 AND status = 'pending'
+		RETURNING `+paymentColumns,
+		result.Status, result.Receipt, result.ResultCode, result.ResultDesc, paidAt, result.Provider, result.Reference, result.Amount,
+	), &payment)
+	if err == nil {
+		return &payment, true, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, false, fmt.Errorf("failed to complete payment: %w", err)
+	}
+
+	// Either the provider repeated its callback or the reference is unknown
+	err = scanPayment(r.db.QueryRow(ctx,
+		"SELECT "+paymentColumns+" FROM payments WHERE provider = $1 AND reference = $2",
+		result.Provider, result.Reference,
+	), &payment)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, false, fmt.Errorf("%s payment %s: %w", result.Provider, result.Reference, models.ErrNotFound)
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get payment: %w", err)
+	}
+
+	return &payment, false, nil
+}
+
+func (r *paymentRepository) ListByOrder(ctx context.Context, orderID int64) ([]models.Payment, error) {
+	payments := []models.Payment{}
+
+	rows, err := r.db.Query(ctx,
+		"SELECT "+paymentColumns+" FROM payments WHERE order_id = $1 ORDER BY created_at, id",
+		orderID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payments: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var payment models.Payment
+		if err := scanPayment(rows, &payment); err != nil {
+			return nil, fmt.Errorf("failed to scan payment: %w", err)
+		}
+		payments = append(payments, payment)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating payments: %w", err)
+	}
+
+	return payments, nil
+}
+
+func (r *paymentRepository) ListForReconciliation(ctx context.Context, from, to time.Time) ([]models.Payment, error) {
+	payments := []models.Payment{}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT `+paymentColumns+`
+		FROM payments
+		WHERE reference IS NOT NULL AND created_at >= $1 AND created_at < $2
+		ORDER BY created_at, id
+	`, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payments to reconcile: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var payment models.Payment
+		if err := scanPayment(rows, &payment); err != nil {
+			return nil, fmt.Errorf("failed to scan payment: %w", err)
+		}
+		payments = append(payments, payment)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating payments: %w", err)
+	}
+
+	return payments, nil
+}
+
+func (r *paymentRepository) FlagMismatch(ctx context.Context, mismatch *models.PaymentMismatch) (bool, error) {
+	err := r.db.QueryRow(ctx, `
+		INSERT INTO payment_mismatches (payment_id, kind, local_status, provider_status, detail, detected_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, NOW())
+		ON CONFLICT (payment_id, kind) WHERE resolved_at IS NULL DO NOTHING
+		RETURNING id, detected_at
+	`, mismatch.PaymentID, mismatch.Kind, mismatch.LocalStatus, mismatch.ProviderStatus, mismatch.Detail).Scan(&mismatch.ID, &mismatch.DetectedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to flag payment mismatch: %w", err)
+	}
+
+	return true, nil
+}
+
+func (r *paymentRepository) ListMismatches(ctx context.Context, includeResolved bool) ([]models.PaymentMismatch, error) {
+	mismatches := []models.PaymentMismatch{}
+	query := `
+		SELECT m.id, m.payment_id, p.order_id, p.provider, m.kind, m.local_status,
+			COALESCE(m.provider_status, ''), m.detail, m.detected_at, m.resolved_at
+		FROM payment_mismatches m
+		JOIN payments p ON p.id = m.payment_id
+		WHERE $1 OR m.resolved_at IS NULL
+		ORDER BY m.detected_at, m.id
+	`
+
+	rows, err := r.db.Query(ctx, query, includeResolved)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payment mismatches: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m models.PaymentMismatch
+		err := rows.Scan(
+			&m.ID,
+			&m.PaymentID,
+			&m.OrderID,
+			&m.Provider,
+			&m.Kind,
+			&m.LocalStatus,
+			&m.ProviderStatus,
+			&m.Detail,
+			&m.DetectedAt,
+			&m.ResolvedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan payment mismatch: %w", err)
+		}
+		mismatches = append(mismatches, m)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating payment mismatches: %w", err)
+	}
+
+	return mismatches, nil
+}
+
+func (r *paymentRepository) ResolveMismatch(ctx context.Context, id int64) error {
+	cmdTag, err := r.db.Exec(ctx, `
+		UPDATE payment_mismatches SET resolved_at = NOW()
+		WHERE id = $1 AND resolved_at IS NULL
+	`, id)
+	if err != nil {
+		return fmt.Errorf("failed to resolve payment mismatch: %w", err)
+	}
+
+	if cmdTag.RowsAffected() == 0 {
+		return fmt.Errorf("open payment mismatch with id %d: %w", id, models.ErrNotFound)
+	}
+
+	return nil
+}
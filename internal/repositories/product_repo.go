@@ -0,0 +1,195 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/chesireabel/Technical-Interview/internal/models"
+)
+
+type ProductRepository interface {
+	Create(ctx context.Context, product *models.Product) (int64, error)
+	GetByID(ctx context.Context, id int64) (*models.Product, error)
+	GetByIDs(ctx context.Context, ids []int64) (map[int64]*models.Product, error)
+	List(ctx context.Context, filter models.ProductFilter) ([]models.Product, error)
+	Update(ctx context.Context, product *models.Product) error
+	SetActive(ctx context.Context, id int64, active bool) error
+}
+
+type productRepository struct {
+	db DBTX
+}
+
+func NewProductRepository(db DBTX) ProductRepository {
+	return &productRepository{db: db}
+}
+
+const productColumns = `id, sku, name, COALESCE(description, ''), price, currency, active, categories, created_at, updated_at`
+
+func scanProduct(row pgx.Row, p *models.Product) error {
+	return row.Scan(
+		&p.ID,
+		&p.SKU,
+		&p.Name,
+		&p.Description,
+		&p.Price,
+		&p.Currency,
+		&p.Active,
+		&p.Categories,
+		&p.CreatedAt,
+		&p.UpdatedAt,
+	)
+}
+
+func (r *productRepository) Create(ctx context.Context, product *models.Product) (int64, error) {
+	query := `
+		INSERT INTO products (sku, name, description, price, currency, active, categories, created_at, updated_at)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, $7, NOW(), NOW())
+		RETURNING id
+	`
+
+	var id int64
+	err := r.db.QueryRow(ctx, query,
+		product.SKU,
+		product.Name,
+		product.Description,
+		product.Price,
+		product.Currency,
+		product.IsActive(),
+		product.Categories,
+	).Scan(&id)
+
+	if conflict := asConflict(err); conflict != nil {
+		return 0, conflict
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to create product: %w", err)
+	}
+	return id, nil
+}
+
+func (r *productRepository) GetByID(ctx context.Context, id int64) (*models.Product, error) {
+	var p models.Product
+	query := "SELECT " + productColumns + " FROM products WHERE id = $1"
+
+	err := scanProduct(r.db.QueryRow(ctx, query, id), &p)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("product with id %d: %w", id, models.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get product: %w", err)
+	}
+
+	return &p, nil
+}
+
+// GetByIDs loads several products at once keyed by ID; missing IDs are simply absent
+func (r *productRepository) GetByIDs(ctx context.Context, ids []int64) (map[int64]*models.Product, error) {
+	products := make(map[int64]*models.Product, len(ids))
+	if len(ids) == 0 {
+		return products, nil
+	}
+
+	query := "SELECT " + productColumns + " FROM products WHERE id = ANY($1)"
+
+	rows, err := r.db.Query(ctx, query, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get products: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p models.Product
+		if err := scanProduct(rows, &p); err != nil {
+			return nil, fmt.Errorf("failed to scan product: %w", err)
+		}
+		products[p.ID] = &p
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating products: %w", err)
+	}
+
+	return products, nil
+}
+
+func (r *productRepository) List(ctx context.Context, filter models.ProductFilter) ([]models.Product, error) {
+	products := []models.Product{}
+	query := "SELECT " + productColumns + ` FROM products
+		WHERE ($1 OR active)
+		  AND ($2 = '' OR $2 = ANY(categories))
+		ORDER BY name, id
+	`
+
+	rows, err := r.db.Query(ctx, query, filter.IncludeInactive, filter.Category)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get products: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p models.Product
+		if err := scanProduct(rows, &p); err != nil {
+			return nil, fmt.Errorf("failed to scan product: %w", err)
+		}
+		products = append(products, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating products: %w", err)
+	}
+
+	return products, nil
+}
+
+// Update replaces the product's details. A nil Active keeps the current flag.
+func (r *productRepository) Update(ctx context.Context, product *models.Product) error {
+	query := `
+		UPDATE products
+		SET sku = $1, name = $2, description = NULLIF($3, ''), price = $4, currency = $5,
+		    active = COALESCE($6, active), categories = $7, updated_at = NOW()
+		WHERE id = $8
+	`
+
+	cmdTag, err := r.db.Exec(ctx, query,
+		product.SKU,
+		product.Name,
+		product.Description,
+		product.Price,
+		product.Currency,
+		product.Active,
+		product.Categories,
+		product.ID,
+	)
+	if conflict := asConflict(err); conflict != nil {
+		return conflict
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update product: %w", err)
+	}
+
+	if cmdTag.RowsAffected() == 0 {
+		return fmt.Errorf("product with id %d: %w", product.ID, models.ErrNotFound)
+	}
+
+	return nil
+}
+
+// SetActive activates or archives a product. Products are never deleted because
+// past order lines keep referencing them.
+func (r *productRepository) SetActive(ctx context.Context, id int64, active bool) error {
+	query := "UPDATE products SET active = $1, updated_at = NOW() WHERE id = $2"
+
+	cmdTag, err := r.db.Exec(ctx, query, active, id)
+	if err != nil {
+		return fmt.Errorf("failed to update product: %w", err)
+	}
+
+	if cmdTag.RowsAffected() == 0 {
+		return fmt.Errorf("product with id %d: %w", id, models.ErrNotFound)
+	}
+
+	return nil
+}
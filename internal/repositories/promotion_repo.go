@@ -0,0 +1,222 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/chesireabel/Technical-Interview/internal/models"
+)
+
+type PromotionRepository interface {
+	Create(ctx context.Context, promotion *models.Promotion) (int64, error)
+	GetByID(ctx context.Context, id int64) (*models.Promotion, error)
+	GetByCode(ctx context.Context, code string) (*models.Promotion, error)
+	// LockByCode loads a promotion and locks it until the transaction ends so
+	// concurrent orders cannot both take the last use of a code
+	LockByCode(ctx context.Context, code string) (*models.Promotion, error)
+	List(ctx context.Context, includeInactive bool) ([]models.Promotion, error)
+	SetActive(ctx context.Context, id int64, active bool) error
+	CountRedemptions(ctx context.Context, promotionID, customerID int64) (int, error)
+	Redeem(ctx context.Context, redemption *models.PromotionRedemption) error
+	// Release gives back the use taken by an order, if it redeemed a promotion
+	Release(ctx context.Context, orderID int64) error
+}
+
+type promotionRepository struct {
+	db DBTX
+}
+
+func NewPromotionRepository(db DBTX) PromotionRepository {
+	return &promotionRepository{db: db}
+}
+
+const promotionColumns = `id, code, COALESCE(description, ''), kind, value, COALESCE(currency, ''), min_order_amount,
+	starts_at, ends_at, max_uses, max_uses_per_customer, uses, active, created_at`
+
+func scanPromotion(row pgx.Row, p *models.Promotion) error {
+	return row.Scan(
+		&p.ID,
+		&p.Code,
+		&p.Description,
+		&p.Kind,
+		&p.Value,
+		&p.Currency,
+		&p.MinOrderAmount,
+		&p.StartsAt,
+		&p.EndsAt,
+		&p.MaxUses,
+		&p.MaxUsesPerCustomer,
+		&p.Uses,
+		&p.Active,
+		&p.CreatedAt,
+	)
+}
+
+func (r *promotionRepository) Create(ctx context.Context, promotion *models.Promotion) (int64, error) {
+	query := `
+		INSERT INTO promotions (code, description, kind, value, currency, min_order_amount,
+			starts_at, ends_at, max_uses, max_uses_per_customer, active, created_at)
+		VALUES ($1, NULLIF($2, ''), $3, $4, NULLIF($5, ''), $6, $7, $8, $9, $10, $11, NOW())
+		RETURNING id
+	`
+
+	var id int64
+	err := r.db.QueryRow(ctx, query,
+		promotion.Code,
+		promotion.Description,
+		promotion.Kind,
+		promotion.Value,
+		promotion.Currency,
+		promotion.MinOrderAmount,
+		promotion.StartsAt,
+		promotion.EndsAt,
+		promotion.MaxUses,
+		promotion.MaxUsesPerCustomer,
+		promotion.IsActive(),
+	).Scan(&id)
+
+	if conflict := asConflict(err); conflict != nil {
+		return 0, conflict
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to create promotion: %w", err)
+	}
+	return id, nil
+}
+
+func (r *promotionRepository) GetByID(ctx context.Context, id int64) (*models.Promotion, error) {
+	var p models.Promotion
+	query := "SELECT " + promotionColumns + " FROM promotions WHERE id = $1"
+
+	err := scanPromotion(r.db.QueryRow(ctx, query, id), &p)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("promotion with id %d: %w", id, models.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get promotion: %w", err)
+	}
+
+	return &p, nil
+}
+
+func (r *promotionRepository) getByCode(ctx context.Context, code, lock string) (*models.Promotion, error) {
+	var p models.Promotion
+	query := "SELECT " + promotionColumns + " FROM promotions WHERE UPPER(code) = UPPER($1)" + lock
+
+	err := scanPromotion(r.db.QueryRow(ctx, query, code), &p)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("promotion %s: %w", code, models.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get promotion: %w", err)
+	}
+
+	return &p, nil
+}
+
+func (r *promotionRepository) GetByCode(ctx context.Context, code string) (*models.Promotion, error) {
+	return r.getByCode(ctx, code, "")
+}
+
+func (r *promotionRepository) LockByCode(ctx context.Context, code string) (*models.Promotion, error) {
+	return r.getByCode(ctx, code, " FOR UPDATE")
+}
+
+func (r *promotionRepository) List(ctx context.Context, includeInactive bool) ([]models.Promotion, error) {
+	promotions := []models.Promotion{}
+	query := "SELECT " + promotionColumns + " FROM promotions WHERE ($1 OR active) ORDER BY created_at DESC, id DESC"
+
+	rows, err := r.db.Query(ctx, query, includeInactive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get promotions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p models.Promotion
+		if err := scanPromotion(rows, &p); err != nil {
+			return nil, fmt.Errorf("failed to scan promotion: %w", err)
+		}
+		promotions = append(promotions, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating promotions: %w", err)
+	}
+
+	return promotions, nil
+}
+
+func (r *promotionRepository) SetActive(ctx context.Context, id int64, active bool) error {
+	cmdTag, err := r.db.Exec(ctx, "UPDATE promotions SET active = $1 WHERE id = $2", active, id)
+	if err != nil {
+		return fmt.Errorf("failed to update promotion: %w", err)
+	}
+
+	if cmdTag.RowsAffected() == 0 {
+		return fmt.Errorf("promotion with id %d: %w", id, models.ErrNotFound)
+	}
+
+	return nil
+}
+
+// CountRedemptions counts the orders a customer has used a promotion on
+func (r *promotionRepository) CountRedemptions(ctx context.Context, promotionID, customerID int64) (int, error) {
+	var count int
+	query := "SELECT COUNT(*) FROM promotion_redemptions WHERE promotion_id = $1 AND customer_id = $2"
+
+	if err := r.db.QueryRow(ctx, query, promotionID, customerID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count promotion redemptions: %w", err)
+	}
+	return count, nil
+}
+
+// Redeem records the redemption and counts it against the promotion's uses
+func (r *promotionRepository) Redeem(ctx context.Context, redemption *models.PromotionRedemption) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		INSERT INTO promotion_redemptions (promotion_id, order_id, customer_id, discount, created_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		RETURNING id, created_at
+	`
+	err = tx.QueryRow(ctx, query,
+		redemption.PromotionID,
+		redemption.OrderID,
+		redemption.CustomerID,
+		redemption.Discount,
+	).Scan(&redemption.ID, &redemption.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record promotion redemption: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, "UPDATE promotions SET uses = uses + 1 WHERE id = $1", redemption.PromotionID); err != nil {
+		return fmt.Errorf("failed to count promotion use: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *promotionRepository) Release(ctx context.Context, orderID int64) error {
+	query := `
+		WITH released AS (
+			DELETE FROM promotion_redemptions WHERE order_id = $1
+			RETURNING promotion_id
+		)
+		UPDATE promotions p
+		SET uses = p.uses - 1
+		FROM released
+		WHERE p.id = released.promotion_id
+	`
+
+	if _, err := r.db.Exec(ctx, query, orderID); err != nil {
+		return fmt.Errorf("failed to release promotion: %w", err)
+	}
+	return nil
+}
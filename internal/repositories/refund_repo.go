@@ -0,0 +1,316 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/shopspring/decimal"
+	"github.com/chesireabel/Technical-Interview/internal/models"
+)
+
+type RefundRepository interface {
+	// Create records a refund against the order's payment and queues its
+	// payout and the customer notification. Only succeeded payments can be
+	// refunded, and a zero Amount refunds everything that is left of them. It
+	// fails with a *models.RefundError when the amount is more than is left
+	// to refund, and with models.ErrPartialRefund when the payment's provider
+	// cannot return less than all of it. Refunds whose payout failed do not
+	// count against what is left.
+	Create(ctx context.Context, refund *models.Refund) error
+	GetByID(ctx context.Context, id int64) (*models.Refund, error)
+	ListByOrder(ctx context.Context, orderID int64) ([]models.Refund, error)
+	// MarkPaidOut records the provider's reference once the money has been returned
+	MarkPaidOut(ctx context.Context, id int64, reference string) error
+	// MarkPayoutRequested records the provider's reference for a payout it
+	// will report the result of later
+	MarkPayoutRequested(ctx context.Context, id int64, reference string) error
+	// CompletePayout records the result the provider reported for the payout
+	// with the given reference: paid out when failure is empty, failed
+	// otherwise. A successful payout queues the customer notification. It
+	// returns the refund, and false when the result had already been recorded.
+	CompletePayout(ctx context.Context, reference, failure string) (*models.Refund, bool, error)
+}
+
+type refundRepository struct {
+	db DBTX
+}
+
+func NewRefundRepository(db DBTX) RefundRepository {
+	return &refundRepository{db: db}
+}
+
+func (r *refundRepository) Create(ctx context.Context, refund *models.Refund) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Locking the order serialises refunds on it, so two concurrent partial
+	// refunds cannot together exceed what was paid
+	err = tx.QueryRow(ctx, `
+		SELECT currency FROM orders
+		WHERE id = $1 AND deleted_at IS NULL
+		FOR UPDATE
+	`, refund.OrderID).Scan(&refund.Currency)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("order with id %d: %w", refund.OrderID, models.ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock order: %w", err)
+	}
+
+	var paid decimal.Decimal
+	err = tx.QueryRow(ctx, "SELECT COALESCE(SUM(amount), 0) FROM payments WHERE order_id = $1 AND status = $2", refund.OrderID, models.PaymentSucceeded).Scan(&paid)
+	if err != nil {
+		return fmt.Errorf("failed to sum payments: %w", err)
+	}
+
+	var refunded decimal.Decimal
+	err = tx.QueryRow(ctx, "SELECT COALESCE(SUM(amount), 0) FROM refunds WHERE order_id = $1 AND payout_failure IS NULL", refund.OrderID).Scan(&refunded)
+	if err != nil {
+		return fmt.Errorf("failed to sum refunds: %w", err)
+	}
+
+	left := paid.Sub(refunded)
+	refundable := left
+
+	// The money goes back through a single payment, the one with the most
+	// left to refund on it, so a refund cannot be more than that payment has
+	var paymentID int64
+	var provider string
+	var paymentAmount, paymentLeft decimal.Decimal
+	err = tx.QueryRow(ctx, `
+		SELECT p.id, p.provider, p.amount, p.amount - COALESCE(SUM(r.amount), 0)
+		FROM payments p
+		LEFT JOIN refunds r ON r.payment_id = p.id AND r.payout_failure IS NULL
+		WHERE p.order_id = $1 AND p.status = $2
+		GROUP BY p.id
+		ORDER BY 4 DESC, p.id
+		LIMIT 1
+	`, refund.OrderID, models.PaymentSucceeded).Scan(&paymentID, &provider, &paymentAmount, &paymentLeft)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("failed to pick payment to refund: %w", err)
+	}
+	if err == nil {
+		refund.PaymentID = &paymentID
+		refundable = decimal.Min(refundable, paymentLeft)
+	}
+
+	if refund.Amount.IsZero() {
+		refund.Amount = refundable
+	}
+	if !refund.Amount.IsPositive() || refund.Amount.GreaterThan(refundable) {
+		return &models.RefundError{OrderID: refund.OrderID, Requested: refund.Amount, Refundable: refundable}
+	}
+	// Checked before the refund is recorded, as the provider would refuse the payout
+	if refund.PaymentID != nil && models.RefundsWholePayments(provider) && !refund.Amount.Equal(paymentAmount) {
+		return fmt.Errorf("cannot refund %s on order %d: %s %w (%s)", refund.Amount, refund.OrderID, provider, models.ErrPartialRefund, models.FormatMoney(paymentAmount, refund.Currency))
+	}
+	refund.Remaining = left.Sub(refund.Amount)
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO refunds (order_id, payment_id, amount, currency, reason, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		RETURNING id, created_at
+	`, refund.OrderID, refund.PaymentID, refund.Amount, refund.Currency, refund.Reason, refund.CreatedBy).Scan(&refund.ID, &refund.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create refund: %w", err)
+	}
+
+	payload := models.OrderRefundedPayload{
+		RefundID:  refund.ID,
+		Amount:    refund.Amount,
+		Currency:  refund.Currency,
+		Reason:    refund.Reason,
+		Remaining: refund.Remaining,
+	}
+	if err := insertOutbox(ctx, tx, models.TopicOrderRefunded, refund.OrderID, payload); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit refund: %w", err)
+	}
+
+	return nil
+}
+
+func (r *refundRepository) GetByID(ctx context.Context, id int64) (*models.Refund, error) {
+	var refund models.Refund
+	err := r.db.QueryRow(ctx, `
+		SELECT id, order_id, payment_id, amount, currency, reason, created_by, created_at, COALESCE(provider_reference, ''), paid_out_at, COALESCE(payout_failure, '')
+		FROM refunds
+		WHERE id = $1
+	`, id).Scan(
+		&refund.ID,
+		&refund.OrderID,
+		&refund.PaymentID,
+		&refund.Amount,
+		&refund.Currency,
+		&refund.Reason,
+		&refund.CreatedBy,
+		&refund.CreatedAt,
+		&refund.ProviderReference,
+		&refund.PaidOutAt,
+		&refund.PayoutFailure,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("refund with id %d: %w", id, models.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get refund: %w", err)
+	}
+
+	return &refund, nil
+}
+
+func (r *refundRepository) MarkPaidOut(ctx context.Context, id int64, reference string) error {
+	cmdTag, err := r.db.Exec(ctx, `
+		UPDATE refunds SET provider_reference = $2, paid_out_at = NOW()
+		WHERE id = $1
+	`, id, reference)
+	if err != nil {
+		return fmt.Errorf("failed to mark refund paid out: %w", err)
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return fmt.Errorf("refund with id %d: %w", id, models.ErrNotFound)
+	}
+	return nil
+}
+
+func (r *refundRepository) MarkPayoutRequested(ctx context.Context, id int64, reference string) error {
+	cmdTag, err := r.db.Exec(ctx, "UPDATE refunds SET provider_reference = $2 WHERE id = $1", id, reference)
+	if err != nil {
+		return fmt.Errorf("failed to mark refund payout requested: %w", err)
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return fmt.Errorf("refund with id %d: %w", id, models.ErrNotFound)
+	}
+	return nil
+}
+
+func (r *refundRepository) CompletePayout(ctx context.Context, reference, failure string) (*models.Refund, bool, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var refund models.Refund
+	err = tx.QueryRow(ctx, `
+		UPDATE refunds
+		SET paid_out_at = CASE WHEN $2 = '' THEN NOW() END,
+			payout_failure = NULLIF($2, '')
+		WHERE provider_reference = $1 AND paid_out_at IS NULL AND payout_failure IS NULL
+		RETURNING id, order_id, payment_id, amount, currency, reason, created_by, created_at, provider_reference, paid_out_at, COALESCE(payout_failure, '')
+	`, reference, failure).Scan(
+		&refund.ID,
+		&refund.OrderID,
+		&refund.PaymentID,
+		&refund.Amount,
+		&refund.Currency,
+		&refund.Reason,
+		&refund.CreatedBy,
+		&refund.CreatedAt,
+		&refund.ProviderReference,
+		&refund.PaidOutAt,
+		&refund.PayoutFailure,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Providers may report the same result more than once
+		var known bool
+		err = tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM refunds WHERE provider_reference = $1)", reference).Scan(&known)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to look up payout: %w", err)
+		}
+		if !known {
+			return nil, false, fmt.Errorf("payout with reference %s: %w", reference, models.ErrNotFound)
+		}
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to complete payout: %w", err)
+	}
+
+	if failure == "" {
+		// What was left to refund after this refund, as ListByOrder works it out
+		err = tx.QueryRow(ctx, `
+			SELECT (SELECT COALESCE(SUM(amount), 0) FROM payments WHERE order_id = $1 AND status = $2)
+				- (SELECT COALESCE(SUM(amount), 0) FROM refunds
+					WHERE order_id = $1 AND payout_failure IS NULL AND (created_at, id) <= ($3, $4))
+		`, refund.OrderID, models.PaymentSucceeded, refund.CreatedAt, refund.ID).Scan(&refund.Remaining)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to work out remaining refund: %w", err)
+		}
+
+		// Delivered again now the money has been returned, this time the
+		// dispatcher sends the customer notification
+		payload := models.OrderRefundedPayload{
+			RefundID:  refund.ID,
+			Amount:    refund.Amount,
+			Currency:  refund.Currency,
+			Reason:    refund.Reason,
+			Remaining: refund.Remaining,
+		}
+		if err := insertOutbox(ctx, tx, models.TopicOrderRefunded, refund.OrderID, payload); err != nil {
+			return nil, false, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, false, fmt.Errorf("failed to commit payout: %w", err)
+	}
+
+	return &refund, true, nil
+}
+
+func (r *refundRepository) ListByOrder(ctx context.Context, orderID int64) ([]models.Refund, error) {
+	refunds := []models.Refund{}
+	// remaining is what was left to refund after each refund, in order
+	query := `
+		SELECT r.id, r.order_id, r.payment_id, r.amount, r.currency, r.reason, r.created_by, r.created_at,
+			COALESCE(r.provider_reference, ''), r.paid_out_at, COALESCE(r.payout_failure, ''),
+			(SELECT COALESCE(SUM(p.amount), 0) FROM payments p WHERE p.order_id = r.order_id AND p.status = $2)
+				- SUM(CASE WHEN r.payout_failure IS NULL THEN r.amount ELSE 0 END) OVER (ORDER BY r.created_at, r.id)
+		FROM refunds r
+		WHERE r.order_id = $1
+		ORDER BY r.created_at, r.id
+	`
+
+	rows, err := r.db.Query(ctx, query, orderID, models.PaymentSucceeded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get refunds: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var refund models.Refund
+		err := rows.Scan(
+			&refund.ID,
+			&refund.OrderID,
+			&refund.PaymentID,
+			&refund.Amount,
+			&refund.Currency,
+			&refund.Reason,
+			&refund.CreatedBy,
+			&refund.CreatedAt,
+			&refund.ProviderReference,
+			&refund.PaidOutAt,
+			&refund.PayoutFailure,
+			&refund.Remaining,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan refund: %w", err)
+		}
+		refunds = append(refunds, refund)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating refunds: %w", err)
+	}
+
+	return refunds, nil
+}
@@ -0,0 +1,192 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/chesireabel/Technical-Interview/internal/segments"
+)
+
+type SegmentRepository interface {
+	Create(ctx context.Context, segment *models.Segment) (int64, error)
+	GetByID(ctx context.Context, id int64) (*models.Segment, error)
+	List(ctx context.Context) ([]models.Segment, error)
+	// Update changes the segment's name, description and rule. Its members
+	// stay as they were until the next refresh.
+	Update(ctx context.Context, segment *models.Segment) error
+	Delete(ctx context.Context, id int64) error
+	// Refresh recomputes the segment's members from its rule and returns how
+	// many there are. Customers who still match keep their added_at.
+	Refresh(ctx context.Context, id int64, rule *segments.Rule) (int, error)
+	// ListMembers returns the customers in the segment as of its last refresh
+	ListMembers(ctx context.Context, id int64) ([]models.Customer, error)
+}
+
+type segmentRepository struct {
+	db DBTX
+}
+
+func NewSegmentRepository(db DBTX) SegmentRepository {
+	return &segmentRepository{db: db}
+}
+
+const segmentColumns = `id, name, COALESCE(description, ''), rule, member_count, refreshed_at, created_at, updated_at`
+
+func scanSegment(row pgx.Row, s *models.Segment) error {
+	return row.Scan(
+		&s.ID,
+		&s.Name,
+		&s.Description,
+		&s.Rule,
+		&s.MemberCount,
+		&s.RefreshedAt,
+		&s.CreatedAt,
+		&s.UpdatedAt,
+	)
+}
+
+func (r *segmentRepository) Create(ctx context.Context, segment *models.Segment) (int64, error) {
+	err := r.db.QueryRow(ctx, `
+		INSERT INTO segments (name, description, rule, created_at, updated_at)
+		VALUES ($1, NULLIF($2, ''), $3, NOW(), NOW())
+		RETURNING id, created_at, updated_at
+	`, segment.Name, segment.Description, segment.Rule).Scan(&segment.ID, &segment.CreatedAt, &segment.UpdatedAt)
+	if conflict := asConflict(err); conflict != nil {
+		return 0, conflict
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to create segment: %w", err)
+	}
+	return segment.ID, nil
+}
+
+func (r *segmentRepository) GetByID(ctx context.Context, id int64) (*models.Segment, error) {
+	var s models.Segment
+	err := scanSegment(r.db.QueryRow(ctx, "SELECT "+segmentColumns+" FROM segments WHERE id = $1", id), &s)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("segment with id %d: %w", id, models.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get segment: %w", err)
+	}
+	return &s, nil
+}
+
+func (r *segmentRepository) List(ctx context.Context) ([]models.Segment, error) {
+	list := []models.Segment{}
+
+	rows, err := r.db.Query(ctx, "SELECT "+segmentColumns+" FROM segments ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get segments: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var s models.Segment
+		if err := scanSegment(rows, &s); err != nil {
+			return nil, fmt.Errorf("failed to scan segment: %w", err)
+		}
+		list = append(list, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating segments: %w", err)
+	}
+
+	return list, nil
+}
+
+func (r *segmentRepository) Update(ctx context.Context, segment *models.Segment) error {
+	err := r.db.QueryRow(ctx, `
+		UPDATE segments
+		SET name = $1, description = NULLIF($2, ''), rule = $3, updated_at = NOW()
+		WHERE id = $4
+		RETURNING member_count, refreshed_at, created_at, updated_at
+	`, segment.Name, segment.Description, segment.Rule, segment.ID).
+		Scan(&segment.MemberCount, &segment.RefreshedAt, &segment.CreatedAt, &segment.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("segment with id %d: %w", segment.ID, models.ErrNotFound)
+	}
+	if conflict := asConflict(err); conflict != nil {
+		return conflict
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update segment: %w", err)
+	}
+	return nil
+}
+
+func (r *segmentRepository) Delete(ctx context.Context, id int64) error {
+	result, err := r.db.Exec(ctx, "DELETE FROM segments WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete segment: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("segment with id %d: %w", id, models.ErrNotFound)
+	}
+	return nil
+}
+
+func (r *segmentRepository) Refresh(ctx context.Context, id int64, rule *segments.Rule) (int, error) {
+	where, args := rule.SQL(id)
+
+	// One statement, so the members and member_count come from the same snapshot
+	query := `
+		WITH matching AS (
+			SELECT c.id FROM customers c WHERE c.deleted_at IS NULL AND ` + where + `
+		), removed AS (
+			DELETE FROM segment_members m
+			WHERE m.segment_id = $1 AND NOT EXISTS (SELECT 1 FROM matching WHERE matching.id = m.customer_id)
+		), added AS (
+			INSERT INTO segment_members (segment_id, customer_id)
+			SELECT $1, id FROM matching
+			ON CONFLICT (segment_id, customer_id) DO NOTHING
+		)
+		UPDATE segments SET member_count = (SELECT COUNT(*) FROM matching), refreshed_at = NOW()
+		WHERE id = $1
+		RETURNING member_count
+	`
+
+	var count int
+	err := r.db.QueryRow(ctx, query, args...).Scan(&count)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, fmt.Errorf("segment with id %d: %w", id, models.ErrNotFound)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to refresh segment: %w", err)
+	}
+	return count, nil
+}
+
+func (r *segmentRepository) ListMembers(ctx context.Context, id int64) ([]models.Customer, error) {
+	customers := []models.Customer{}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT c.id, c.customer_name, c.email, COALESCE(c.phone, ''), COALESCE(c.country_code, ''), c.code, c.created_at
+		FROM segment_members m
+		JOIN customers c ON c.id = m.customer_id AND c.deleted_at IS NULL
+		WHERE m.segment_id = $1
+		ORDER BY m.added_at DESC, c.id DESC
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get segment members: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c models.Customer
+		if err := rows.Scan(&c.ID, &c.Customer_name, &c.Email, &c.Phone, &c.CountryCode, &c.Code, &c.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan segment member: %w", err)
+		}
+		customers = append(customers, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating segment members: %w", err)
+	}
+
+	return customers, nil
+}
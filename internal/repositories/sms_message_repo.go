@@ -0,0 +1,33 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+)
+
+// SMSMessageRepository records the SMS messages sent to customers
+type SMSMessageRepository interface {
+	Create(ctx context.Context, message *models.SMSMessage) error
+}
+
+type smsMessageRepository struct {
+	db DBTX
+}
+
+func NewSMSMessageRepository(db DBTX) SMSMessageRepository {
+	return &smsMessageRepository{db: db}
+}
+
+func (r *smsMessageRepository) Create(ctx context.Context, message *models.SMSMessage) error {
+	err := r.db.QueryRow(ctx, `
+		INSERT INTO sms_messages (customer_id, order_id, kind, phone, body, sent_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		RETURNING id, sent_at
+	`, message.CustomerID, message.OrderID, message.Kind, message.Phone, message.Body).Scan(&message.ID, &message.SentAt)
+	if err != nil {
+		return fmt.Errorf("failed to record sms message: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,369 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/chesireabel/Technical-Interview/internal/models"
+)
+
+type SubscriptionRepository interface {
+	Create(ctx context.Context, subscription *models.Subscription) (int64, error)
+	GetByID(ctx context.Context, id int64) (*models.Subscription, error)
+	// List returns every subscription, or only the customer's when customerID is not 0
+	List(ctx context.Context, customerID int64) ([]models.Subscription, error)
+	// SetStatus pauses, resumes or cancels a subscription. Cancelled
+	// subscriptions cannot be changed and return ErrSubscriptionCancelled.
+	SetStatus(ctx context.Context, id int64, status models.SubscriptionStatus, nextRunAt *time.Time) error
+	// LockDue returns active subscriptions whose next run is at or before now
+	// and locks them until the transaction ends. Subscriptions locked by
+	// another transaction are skipped.
+	LockDue(ctx context.Context, now time.Time, limit int) ([]models.Subscription, error)
+	// StartRun records the run scheduled for the subscription's next_run_at and
+	// moves next_run_at on, so the run is not picked up again
+	StartRun(ctx context.Context, subscription *models.Subscription, nextRunAt time.Time) (*models.SubscriptionRun, error)
+	// FinishRun records the order a run placed, or the error it failed with
+	FinishRun(ctx context.Context, run *models.SubscriptionRun) error
+	ListRuns(ctx context.Context, subscriptionID int64) ([]models.SubscriptionRun, error)
+}
+
+type subscriptionRepository struct {
+	db DBTX
+}
+
+func NewSubscriptionRepository(db DBTX) SubscriptionRepository {
+	return &subscriptionRepository{db: db}
+}
+
+const subscriptionColumns = `id, customer_id, currency, schedule, timezone, status, next_run_at, last_run_at,
+	last_order_id, COALESCE(last_error, ''), created_at, updated_at`
+
+func scanSubscription(row pgx.Row, s *models.Subscription) error {
+	return row.Scan(
+		&s.ID,
+		&s.CustomerID,
+		&s.Currency,
+		&s.Schedule,
+		&s.Timezone,
+		&s.Status,
+		&s.NextRunAt,
+		&s.LastRunAt,
+		&s.LastOrderID,
+		&s.LastError,
+		&s.CreatedAt,
+		&s.UpdatedAt,
+	)
+}
+
+const subscriptionRunColumns = `id, subscription_id, scheduled_for, order_id, COALESCE(error, ''), created_at, finished_at`
+
+func scanSubscriptionRun(row pgx.Row, run *models.SubscriptionRun) error {
+	return row.Scan(
+		&run.ID,
+		&run.SubscriptionID,
+		&run.ScheduledFor,
+		&run.OrderID,
+		&run.Error,
+		&run.CreatedAt,
+		&run.FinishedAt,
+	)
+}
+
+func (r *subscriptionRepository) Create(ctx context.Context, subscription *models.Subscription) (int64, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO subscriptions (customer_id, currency, schedule, timezone, status, next_run_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())
+		RETURNING id, created_at, updated_at
+	`,
+		subscription.CustomerID,
+		subscription.Currency,
+		subscription.Schedule,
+		subscription.Timezone,
+		subscription.Status,
+		subscription.NextRunAt,
+	).Scan(&subscription.ID, &subscription.CreatedAt, &subscription.UpdatedAt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create subscription: %w", err)
+	}
+
+	query := `
+		INSERT INTO subscription_items (subscription_id, product_id, product_ref, description, quantity, unit_price)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), $5, $6)
+		RETURNING id
+	`
+	for i := range subscription.Items {
+		item := &subscription.Items[i]
+		var unitPrice any
+		if item.ProductID == nil {
+			unitPrice = item.UnitPrice
+		}
+		err := tx.QueryRow(ctx, query,
+			subscription.ID,
+			item.ProductID,
+			item.ProductRef,
+			item.Description,
+			item.Quantity,
+			unitPrice,
+		).Scan(&item.ID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to create subscription item: %w", err)
+		}
+		item.SubscriptionID = subscription.ID
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return subscription.ID, nil
+}
+
+func (r *subscriptionRepository) GetByID(ctx context.Context, id int64) (*models.Subscription, error) {
+	var s models.Subscription
+	query := "SELECT " + subscriptionColumns + " FROM subscriptions WHERE id = $1"
+
+	err := scanSubscription(r.db.QueryRow(ctx, query, id), &s)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("subscription with id %d: %w", id, models.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subscription: %w", err)
+	}
+
+	subscriptions := []models.Subscription{s}
+	if err := r.attachItems(ctx, subscriptions); err != nil {
+		return nil, err
+	}
+
+	return &subscriptions[0], nil
+}
+
+func (r *subscriptionRepository) List(ctx context.Context, customerID int64) ([]models.Subscription, error) {
+	query := "SELECT " + subscriptionColumns + " FROM subscriptions WHERE ($1 = 0 OR customer_id = $1) ORDER BY id"
+	return r.query(ctx, "subscriptions", query, customerID)
+}
+
+func (r *subscriptionRepository) SetStatus(ctx context.Context, id int64, status models.SubscriptionStatus, nextRunAt *time.Time) error {
+	cmdTag, err := r.db.Exec(ctx, `
+		UPDATE subscriptions SET status = $1, next_run_at = $2, updated_at = NOW()
+		WHERE id = $3 AND status <> 'cancelled'
+	`, status, nextRunAt, id)
+	if err != nil {
+		return fmt.Errorf("failed to update subscription status: %w", err)
+	}
+
+	if cmdTag.RowsAffected() == 0 {
+		var exists bool
+		if err := r.db.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM subscriptions WHERE id = $1)", id).Scan(&exists); err != nil {
+			return fmt.Errorf("failed to get subscription: %w", err)
+		}
+		if exists {
+			return models.ErrSubscriptionCancelled
+		}
+		return fmt.Errorf("subscription with id %d: %w", id, models.ErrNotFound)
+	}
+
+	return nil
+}
+
+func (r *subscriptionRepository) LockDue(ctx context.Context, now time.Time, limit int) ([]models.Subscription, error) {
+	query := `
+		SELECT ` + subscriptionColumns + `
+		FROM subscriptions
+		WHERE status = 'active' AND next_run_at <= $1
+		ORDER BY next_run_at, id
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED
+	`
+	return r.query(ctx, "due subscriptions", query, now, limit)
+}
+
+func (r *subscriptionRepository) StartRun(ctx context.Context, subscription *models.Subscription, nextRunAt time.Time) (*models.SubscriptionRun, error) {
+	if subscription.NextRunAt == nil {
+		return nil, fmt.Errorf("subscription %d has no scheduled run", subscription.ID)
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var run models.SubscriptionRun
+	err = scanSubscriptionRun(tx.QueryRow(ctx, `
+		INSERT INTO subscription_runs (subscription_id, scheduled_for, created_at)
+		VALUES ($1, $2, NOW())
+		RETURNING `+subscriptionRunColumns,
+		subscription.ID, *subscription.NextRunAt,
+	), &run)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start subscription run: %w", err)
+	}
+
+	cmdTag, err := tx.Exec(ctx, `
+		UPDATE subscriptions SET next_run_at = $1, last_run_at = $2, updated_at = NOW()
+		WHERE id = $3
+	`, nextRunAt, run.ScheduledFor, subscription.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to schedule next subscription run: %w", err)
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return nil, fmt.Errorf("subscription with id %d: %w", subscription.ID, models.ErrNotFound)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return &run, nil
+}
+
+func (r *subscriptionRepository) FinishRun(ctx context.Context, run *models.SubscriptionRun) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `
+		UPDATE subscription_runs SET order_id = $1, error = NULLIF($2, ''), finished_at = NOW()
+		WHERE id = $3
+		RETURNING finished_at
+	`, run.OrderID, run.Error, run.ID).Scan(&run.FinishedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("subscription run with id %d: %w", run.ID, models.ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to finish subscription run: %w", err)
+	}
+
+	// A failed run keeps pointing at the last order that was placed
+	_, err = tx.Exec(ctx, `
+		UPDATE subscriptions
+		SET last_order_id = COALESCE($1, last_order_id), last_error = NULLIF($2, ''), updated_at = NOW()
+		WHERE id = $3
+	`, run.OrderID, run.Error, run.SubscriptionID)
+	if err != nil {
+		return fmt.Errorf("failed to update subscription: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (r *subscriptionRepository) ListRuns(ctx context.Context, subscriptionID int64) ([]models.SubscriptionRun, error) {
+	runs := []models.SubscriptionRun{}
+
+	rows, err := r.db.Query(ctx,
+		"SELECT "+subscriptionRunColumns+" FROM subscription_runs WHERE subscription_id = $1 ORDER BY scheduled_for DESC, id DESC",
+		subscriptionID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subscription runs: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var run models.SubscriptionRun
+		if err := scanSubscriptionRun(rows, &run); err != nil {
+			return nil, fmt.Errorf("failed to scan subscription run: %w", err)
+		}
+		runs = append(runs, run)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating subscription runs: %w", err)
+	}
+
+	return runs, nil
+}
+
+// query runs a subscriptions query and attaches the items of every row
+func (r *subscriptionRepository) query(ctx context.Context, what, query string, args ...any) ([]models.Subscription, error) {
+	subscriptions := []models.Subscription{}
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s: %w", what, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var s models.Subscription
+		if err := scanSubscription(rows, &s); err != nil {
+			return nil, fmt.Errorf("failed to scan subscription: %w", err)
+		}
+		subscriptions = append(subscriptions, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating %s: %w", what, err)
+	}
+
+	if err := r.attachItems(ctx, subscriptions); err != nil {
+		return nil, err
+	}
+	return subscriptions, nil
+}
+
+// attachItems fills in the items of every subscription in one query
+func (r *subscriptionRepository) attachItems(ctx context.Context, subscriptions []models.Subscription) error {
+	if len(subscriptions) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(subscriptions))
+	for i, s := range subscriptions {
+		ids[i] = s.ID
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT id, subscription_id, product_id, COALESCE(product_ref, ''), COALESCE(description, ''), quantity,
+		       COALESCE(unit_price, 0)
+		FROM subscription_items
+		WHERE subscription_id = ANY($1)
+		ORDER BY subscription_id, id
+	`, ids)
+	if err != nil {
+		return fmt.Errorf("failed to get subscription items: %w", err)
+	}
+	defer rows.Close()
+
+	items := make(map[int64][]models.SubscriptionItem, len(ids))
+	for rows.Next() {
+		var item models.SubscriptionItem
+		err := rows.Scan(
+			&item.ID,
+			&item.SubscriptionID,
+			&item.ProductID,
+			&item.ProductRef,
+			&item.Description,
+			&item.Quantity,
+			&item.UnitPrice,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to scan subscription item: %w", err)
+		}
+		items[item.SubscriptionID] = append(items[item.SubscriptionID], item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("error iterating subscription items: %w", err)
+	}
+
+	for i := range subscriptions {
+		subscriptions[i].Items = items[subscriptions[i].ID]
+	}
+	return nil
+}
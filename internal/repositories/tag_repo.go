@@ -0,0 +1,83 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+)
+
+// TagRepository stores the free-form tags on customers. Tags are expected
+// already normalised, see segments.NormalizeTag.
+type TagRepository interface {
+	// List returns the customer's tags in alphabetical order
+	List(ctx context.Context, customerID int64) ([]string, error)
+	// Add tags the customer, ignoring tags they already have
+	Add(ctx context.Context, customerID int64, tags []string) error
+	// Remove untags the customer and reports whether they had the tag
+	Remove(ctx context.Context, customerID int64, tag string) (bool, error)
+}
+
+type tagRepository struct {
+	db DBTX
+}
+
+func NewTagRepository(db DBTX) TagRepository {
+	return &tagRepository{db: db}
+}
+
+func (r *tagRepository) List(ctx context.Context, customerID int64) ([]string, error) {
+	tags := []string{}
+
+	rows, err := r.db.Query(ctx, "SELECT tag FROM customer_tags WHERE customer_id = $1 ORDER BY tag", customerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tags: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tags: %w", err)
+	}
+
+	return tags, nil
+}
+
+func (r *tagRepository) Add(ctx context.Context, customerID int64, tags []string) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockCustomer(ctx, tx, customerID); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO customer_tags (customer_id, tag)
+		SELECT $1, UNNEST($2::text[])
+		ON CONFLICT (customer_id, tag) DO NOTHING
+	`, customerID, tags)
+	if err != nil {
+		return fmt.Errorf("failed to add tags: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (r *tagRepository) Remove(ctx context.Context, customerID int64, tag string) (bool, error) {
+	result, err := r.db.Exec(ctx, "DELETE FROM customer_tags WHERE customer_id = $1 AND tag = $2", customerID, tag)
+	if err != nil {
+		return false, fmt.Errorf("failed to remove tag: %w", err)
+	}
+	return result.RowsAffected() > 0, nil
+}
@@ -0,0 +1,136 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/chesireabel/Technical-Interview/internal/models"
+)
+
+type TaxRateRepository interface {
+	Create(ctx context.Context, rate *models.TaxRate) (int64, error)
+	// List returns the rates for a region, or every rate when region is empty
+	List(ctx context.Context, region string) ([]models.TaxRate, error)
+	Update(ctx context.Context, rate *models.TaxRate) error
+	Delete(ctx context.Context, id int64) error
+}
+
+type taxRateRepository struct {
+	db DBTX
+}
+
+func NewTaxRateRepository(db DBTX) TaxRateRepository {
+	return &taxRateRepository{db: db}
+}
+
+const taxRateColumns = `id, name, region, COALESCE(category, ''), rate, inclusive, created_at, updated_at`
+
+func scanTaxRate(row pgx.Row, r *models.TaxRate) error {
+	return row.Scan(
+		&r.ID,
+		&r.Name,
+		&r.Region,
+		&r.Category,
+		&r.Rate,
+		&r.Inclusive,
+		&r.CreatedAt,
+		&r.UpdatedAt,
+	)
+}
+
+func (r *taxRateRepository) Create(ctx context.Context, rate *models.TaxRate) (int64, error) {
+	query := `
+		INSERT INTO tax_rates (name, region, category, rate, inclusive, created_at, updated_at)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, NOW(), NOW())
+		RETURNING id
+	`
+
+	var id int64
+	err := r.db.QueryRow(ctx, query,
+		rate.Name,
+		rate.Region,
+		rate.Category,
+		rate.Rate,
+		rate.Inclusive,
+	).Scan(&id)
+
+	if conflict := asConflict(err); conflict != nil {
+		return 0, conflict
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to create tax rate: %w", err)
+	}
+	return id, nil
+}
+
+func (r *taxRateRepository) List(ctx context.Context, region string) ([]models.TaxRate, error) {
+	rates := []models.TaxRate{}
+	query := "SELECT " + taxRateColumns + ` FROM tax_rates
+		WHERE ($1 = '' OR region = $1)
+		ORDER BY region, category NULLS FIRST, id
+	`
+
+	rows, err := r.db.Query(ctx, query, region)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tax rates: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var rate models.TaxRate
+		if err := scanTaxRate(rows, &rate); err != nil {
+			return nil, fmt.Errorf("failed to scan tax rate: %w", err)
+		}
+		rates = append(rates, rate)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tax rates: %w", err)
+	}
+
+	return rates, nil
+}
+
+// Update changes a rate. Orders already placed keep the rate they were charged.
+func (r *taxRateRepository) Update(ctx context.Context, rate *models.TaxRate) error {
+	query := `
+		UPDATE tax_rates
+		SET name = $1, region = $2, category = NULLIF($3, ''), rate = $4, inclusive = $5, updated_at = NOW()
+		WHERE id = $6
+	`
+
+	cmdTag, err := r.db.Exec(ctx, query,
+		rate.Name,
+		rate.Region,
+		rate.Category,
+		rate.Rate,
+		rate.Inclusive,
+		rate.ID,
+	)
+	if conflict := asConflict(err); conflict != nil {
+		return conflict
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update tax rate: %w", err)
+	}
+
+	if cmdTag.RowsAffected() == 0 {
+		return fmt.Errorf("tax rate with id %d: %w", rate.ID, models.ErrNotFound)
+	}
+
+	return nil
+}
+
+func (r *taxRateRepository) Delete(ctx context.Context, id int64) error {
+	cmdTag, err := r.db.Exec(ctx, "DELETE FROM tax_rates WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete tax rate: %w", err)
+	}
+
+	if cmdTag.RowsAffected() == 0 {
+		return fmt.Errorf("tax rate with id %d: %w", id, models.ErrNotFound)
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/chesireabel/Technical-Interview/internal/models"
+)
+
+// TimelineRepository reads a customer's notes, orders, order status changes
+// and SMS messages as a single feed
+type TimelineRepository interface {
+	// List returns up to limit events, newest first, starting after before
+	// when it is set. Deleted orders and their status changes are left out.
+	List(ctx context.Context, customerID int64, before *models.TimelineCursor, limit int) ([]models.TimelineEvent, error)
+}
+
+type timelineRepository struct {
+	db DBTX
+}
+
+func NewTimelineRepository(db DBTX) TimelineRepository {
+	return &timelineRepository{db: db}
+}
+
+// timelineQuery gives every source the same columns so they can be merged.
+// Events are ordered by (occurred_at, kind, id), which is unique, so the
+// cursor comparison neither skips nor repeats events that share a timestamp.
+const timelineQuery = `
+	SELECT e.kind, e.id, e.occurred_at, e.order_id, e.actor, e.body, e.pinned, e.status,
+		e.from_status, e.to_status, e.amount, e.currency, e.phone, e.sms_kind
+	FROM (
+		SELECT 'note'::text AS kind, n.id::bigint AS id, n.created_at AS occurred_at, NULL::bigint AS order_id,
+			n.author::text AS actor, n.body AS body, n.pinned AS pinned, ''::text AS status,
+			''::text AS from_status, ''::text AS to_status, NULL::numeric AS amount, ''::text AS currency,
+			''::text AS phone, ''::text AS sms_kind
+		FROM customer_notes n
+		WHERE n.customer_id = $1
+
+		UNION ALL
+
+		SELECT 'order', o.id, o.created_at, o.id,
+			'', o.item, false, o.status,
+			'', '', o.amount, o.currency,
+			'', ''
+		FROM orders o
+		WHERE o.customer_id = $1 AND o.deleted_at IS NULL
+
+		UNION ALL
+
+		SELECT 'status_change', h.id, h.changed_at, h.order_id,
+			h.changed_by, COALESCE(h.reason, ''), false, '',
+			h.from_status, h.to_status, NULL, '',
+			'', ''
+		FROM order_status_history h
+		JOIN orders o ON o.id = h.order_id
+		WHERE o.customer_id = $1 AND o.deleted_at IS NULL
+
+		UNION ALL
+
+		SELECT 'sms', s.id, s.sent_at, s.order_id,
+			'', s.body, false, '',
+			'', '', NULL, '',
+			s.phone, s.kind
+		FROM sms_messages s
+		WHERE s.customer_id = $1
+	) e
+	WHERE $2::timestamptz IS NULL OR (e.occurred_at, e.kind, e.id) < ($2::timestamptz, $3::text, $4::bigint)
+	ORDER BY e.occurred_at DESC, e.kind DESC, e.id DESC
+	LIMIT $5
+`
+
+func (r *timelineRepository) List(ctx context.Context, customerID int64, before *models.TimelineCursor, limit int) ([]models.TimelineEvent, error) {
+	events := []models.TimelineEvent{}
+
+	var (
+		beforeAt   *time.Time
+		beforeKind string
+		beforeID   int64
+	)
+	if before != nil {
+		beforeAt = &before.OccurredAt
+		beforeKind = string(before.Kind)
+		beforeID = before.ID
+	}
+
+	rows, err := r.db.Query(ctx, timelineQuery, customerID, beforeAt, beforeKind, beforeID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get timeline: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			e      models.TimelineEvent
+			amount decimal.NullDecimal
+		)
+		err := rows.Scan(
+			&e.Kind,
+			&e.ID,
+			&e.OccurredAt,
+			&e.OrderID,
+			&e.Actor,
+			&e.Body,
+			&e.Pinned,
+			&e.Status,
+			&e.FromStatus,
+			&e.ToStatus,
+			&amount,
+			&e.Currency,
+			&e.Phone,
+			&e.SMSKind,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan timeline event: %w", err)
+		}
+		if amount.Valid {
+			e.Amount = &amount.Decimal
+		}
+		events = append(events, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating timeline: %w", err)
+	}
+
+	return events, nil
+}
@@ -0,0 +1,134 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	serializationFailureCode = "40001"
+	deadlockDetectedCode     = "40P01"
+	// maxSerializableAttempts bounds how often a serializable transaction is
+	// re-run after PostgreSQL aborts it because of a concurrent transaction
+	maxSerializableAttempts = 3
+)
+
+// Repositories are the repositories bound to one transaction
+type Repositories struct {
+	Customers     CustomerRepository
+	Orders        OrderRepository
+	Outbox        OutboxRepository
+	Products      ProductRepository
+	Inventory     InventoryRepository
+	Promotions    PromotionRepository
+	TaxRates      TaxRateRepository
+	Refunds       RefundRepository
+	Payments      PaymentRepository
+	Subscriptions SubscriptionRepository
+	Addresses     AddressRepository
+}
+
+// TxFunc is the work done inside a transaction. ctx carries the transaction's
+// deadline; repos must be used instead of the service's own repositories for
+// the work to be part of the transaction.
+type TxFunc func(ctx context.Context, repos Repositories) error
+
+// TxManager runs a unit of work spanning several repositories in one database
+// transaction. The transaction commits when fn returns nil and rolls back when
+// it returns an error or panics.
+type TxManager interface {
+	WithinTx(ctx context.Context, fn TxFunc) error
+	// WithinSerializableTx runs fn at SERIALIZABLE isolation and re-runs it when
+	// the transaction fails with a serialization failure or deadlock, so fn
+	// must be safe to call more than once
+	WithinSerializableTx(ctx context.Context, fn TxFunc) error
+}
+
+type txManager struct {
+	db *pgxpool.Pool
+}
+
+func NewTxManager(db *pgxpool.Pool) TxManager {
+	return &txManager{db: db}
+}
+
+// bindRepositories builds the repositories on top of tx
+func bindRepositories(tx pgx.Tx) Repositories {
+	return Repositories{
+		Customers:     NewCustomerRepository(tx),
+		Orders:        NewOrderRepository(tx),
+		Outbox:        NewOutboxRepository(tx),
+		Products:      NewProductRepository(tx),
+		Inventory:     NewInventoryRepository(tx),
+		Promotions:    NewPromotionRepository(tx),
+		TaxRates:      NewTaxRateRepository(tx),
+		Refunds:       NewRefundRepository(tx),
+		Payments:      NewPaymentRepository(tx),
+		Subscriptions: NewSubscriptionRepository(tx),
+		Addresses:     NewAddressRepository(tx),
+	}
+}
+
+func (m *txManager) WithinTx(ctx context.Context, fn TxFunc) error {
+	return m.run(ctx, pgx.TxOptions{}, fn)
+}
+
+func (m *txManager) WithinSerializableTx(ctx context.Context, fn TxFunc) error {
+	var err error
+	for attempt := 1; attempt <= maxSerializableAttempts; attempt++ {
+		err = m.run(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable}, fn)
+		if !isRetryableTxError(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(time.Duration(attempt*10) * time.Millisecond):
+		}
+	}
+
+	return fmt.Errorf("transaction still conflicting after %d attempts: %w", maxSerializableAttempts, err)
+}
+
+func (m *txManager) run(ctx context.Context, opts pgx.TxOptions, fn TxFunc) (err error) {
+	tx, err := m.db.BeginTx(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback(context.WithoutCancel(ctx))
+			panic(p)
+		}
+		if err != nil {
+			_ = tx.Rollback(context.WithoutCancel(ctx))
+		}
+	}()
+
+	if err = fn(ctx, bindRepositories(tx)); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// isRetryableTxError reports whether err means the transaction lost a race and
+// running it again may succeed
+func isRetryableTxError(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return pgErr.Code == serializationFailureCode || pgErr.Code == deadlockDetectedCode
+}
@@ -3,12 +3,13 @@ package routes
 import (
 	"github.com/chesireabel/Technical-Interview/internal/handlers"
 	"github.com/chesireabel/Technical-Interview/internal/middleware"
+	"github.com/chesireabel/Technical-Interview/internal/services"
 
 
 	"github.com/gin-gonic/gin"
 )
 
-func RegisterRoutes(r *gin.Engine, customerHandler *handlers.CustomerHandler, orderHandler *handlers.OrderHandler,oidc *middleware.OIDC,returnToURL string) {
+func RegisterRoutes(r *gin.Engine, customerHandler *handlers.CustomerHandler, addressHandler *handlers.AddressHandler, tagHandler *handlers.TagHandler, noteHandler *handlers.NoteHandler, mergeHandler *handlers.MergeHandler, orderHandler *handlers.OrderHandler, invoiceHandler *handlers.InvoiceHandler, refundHandler *handlers.RefundHandler, paymentHandler *handlers.PaymentHandler, subscriptionHandler *handlers.SubscriptionHandler, segmentHandler *handlers.SegmentHandler, productHandler *handlers.ProductHandler, promotionHandler *handlers.PromotionHandler, taxRateHandler *handlers.TaxRateHandler, idempotency services.IdempotencyService, oidc *middleware.OIDC,returnToURL string) {
 	//Health check
 	r.GET("/", func(c *gin.Context) {
 		c.JSON(200, gin.H{
@@ -31,19 +32,115 @@ func RegisterRoutes(r *gin.Engine, customerHandler *handlers.CustomerHandler, or
 	{
 		customers.POST("", customerHandler.CreateCustomer)
 		customers.GET("", customerHandler.GetAllCustomers)
+		customers.GET("/export", customerHandler.ExportCustomers)
+		customers.GET("/duplicates", mergeHandler.FindDuplicates)
+		customers.POST("/import", customerHandler.ImportCustomers)
+		customers.GET("/imports/:import_id", customerHandler.GetImportReport)
 		customers.GET("/:id", customerHandler.GetCustomer)
 		customers.PUT("/:id", customerHandler.UpdateCustomer)
 		customers.DELETE("/:id", customerHandler.DeleteCustomer)
+		customers.POST("/:id/restore", customerHandler.RestoreCustomer)
+		customers.GET("/:id/addresses", addressHandler.ListAddresses)
+		customers.POST("/:id/addresses", addressHandler.CreateAddress)
+		customers.GET("/:id/addresses/:address_id", addressHandler.GetAddress)
+		customers.PUT("/:id/addresses/:address_id", addressHandler.UpdateAddress)
+		customers.DELETE("/:id/addresses/:address_id", addressHandler.DeleteAddress)
+		customers.GET("/:id/tags", tagHandler.ListTags)
+		customers.POST("/:id/tags", tagHandler.AddTags)
+		customers.DELETE("/:id/tags/:tag", tagHandler.RemoveTag)
+		customers.GET("/:id/notes", noteHandler.ListNotes)
+		customers.POST("/:id/notes", noteHandler.CreateNote)
+		customers.GET("/:id/notes/:note_id", noteHandler.GetNote)
+		customers.PUT("/:id/notes/:note_id", noteHandler.UpdateNote)
+		customers.DELETE("/:id/notes/:note_id", noteHandler.DeleteNote)
+		customers.GET("/:id/timeline", noteHandler.GetTimeline)
+		customers.POST("/:id/merge", mergeHandler.MergeCustomer)
+		customers.GET("/:id/merges", mergeHandler.ListMerges)
 	}
 
 	//Orders routes
 	orders := r.Group("/orders")
 	{
-		orders.POST("", orderHandler.CreateOrder)
+		orders.POST("", handlers.Idempotent(idempotency), orderHandler.CreateOrder)
 		orders.GET("", orderHandler.GetAllOrders)
+		orders.GET("/export", orderHandler.ExportOrders)
 		orders.GET("/:id", orderHandler.GetOrder)
 		orders.PUT("/:id", orderHandler.UpdateOrder)
 		orders.DELETE("/:id", orderHandler.DeleteOrder)
+		orders.POST("/:id/restore", orderHandler.RestoreOrder)
+		orders.POST("/:id/transitions", orderHandler.TransitionOrder)
+		orders.GET("/:id/transitions", orderHandler.GetOrderStatusHistory)
+		orders.POST("/:id/cancel", orderHandler.CancelOrder)
+		orders.POST("/:id/reschedule", orderHandler.RescheduleOrder)
+		orders.POST("/:id/refunds", refundHandler.RefundOrder)
+		orders.GET("/:id/refunds", refundHandler.ListRefunds)
+		orders.POST("/:id/payments", paymentHandler.StartPayment)
+		orders.GET("/:id/payments", paymentHandler.ListPayments)
+		orders.GET("/:id/invoice.pdf", invoiceHandler.GetInvoicePDF)
+	}
+
+	//Payments routes
+	payments := r.Group("/payments")
+	{
+		// Provider webhooks, e.g. /payments/mpesa/callback
+		payments.POST("/:provider/callback", paymentHandler.Callback)
+		payments.POST("/:provider/refund-result", refundHandler.PayoutResult)
+		payments.GET("/mismatches", paymentHandler.ListMismatches)
+		payments.POST("/mismatches/:id/resolve", paymentHandler.ResolveMismatch)
+	}
+
+	//Subscriptions routes
+	subscriptions := r.Group("/subscriptions")
+	{
+		subscriptions.POST("", subscriptionHandler.CreateSubscription)
+		subscriptions.GET("", subscriptionHandler.ListSubscriptions)
+		subscriptions.GET("/:id", subscriptionHandler.GetSubscription)
+		subscriptions.POST("/:id/pause", subscriptionHandler.PauseSubscription)
+		subscriptions.POST("/:id/resume", subscriptionHandler.ResumeSubscription)
+		subscriptions.POST("/:id/cancel", subscriptionHandler.CancelSubscription)
+		subscriptions.GET("/:id/runs", subscriptionHandler.ListRuns)
+	}
+
+	//Segments routes
+	segments := r.Group("/segments")
+	{
+		segments.POST("", segmentHandler.CreateSegment)
+		segments.GET("", segmentHandler.ListSegments)
+		segments.GET("/:id", segmentHandler.GetSegment)
+		segments.PUT("/:id", segmentHandler.UpdateSegment)
+		segments.DELETE("/:id", segmentHandler.DeleteSegment)
+		segments.POST("/:id/refresh", segmentHandler.RefreshSegment)
+		segments.GET("/:id/customers", segmentHandler.ListMembers)
+	}
+
+	//Products routes
+	products := r.Group("/products")
+	{
+		products.POST("", productHandler.CreateProduct)
+		products.GET("", productHandler.ListProducts)
+		products.GET("/:id", productHandler.GetProduct)
+		products.PUT("/:id", productHandler.UpdateProduct)
+		products.DELETE("/:id", productHandler.DeleteProduct)
+		products.GET("/:id/stock", productHandler.GetStock)
+		products.POST("/:id/stock", productHandler.AdjustStock)
+	}
+
+	//Promotions routes
+	promotions := r.Group("/promotions")
+	{
+		promotions.POST("", promotionHandler.CreatePromotion)
+		promotions.GET("", promotionHandler.ListPromotions)
+		promotions.GET("/:id", promotionHandler.GetPromotion)
+		promotions.DELETE("/:id", promotionHandler.DeletePromotion)
+	}
+
+	//Tax rates routes
+	taxRates := r.Group("/tax-rates")
+	{
+		taxRates.POST("", taxRateHandler.CreateTaxRate)
+		taxRates.GET("", taxRateHandler.ListTaxRates)
+		taxRates.PUT("/:id", taxRateHandler.UpdateTaxRate)
+		taxRates.DELETE("/:id", taxRateHandler.DeleteTaxRate)
 	}
 
 	//Get orders made by customer
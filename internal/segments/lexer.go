@@ -0,0 +1,119 @@
+package segments
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	// tokenDuration is a number with a unit, e.g. 30d
+	tokenDuration
+	tokenOperator
+	tokenLParen
+	tokenRParen
+	tokenComma
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	// pos is the 1-based character offset of the token in the rule, for error messages
+	pos int
+}
+
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of rule"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// lex splits a rule into tokens
+func lex(rule string) ([]token, error) {
+	var tokens []token
+	runes := []rune(rule)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		start := i
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case r == '(':
+			tokens = append(tokens, token{tokenLParen, "(", start + 1})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{tokenRParen, ")", start + 1})
+			i++
+		case r == ',':
+			tokens = append(tokens, token{tokenComma, ",", start + 1})
+			i++
+
+		case strings.ContainsRune("=!<>", r):
+			i++
+			if i < len(runes) && runes[i] == '=' {
+				i++
+			}
+			op := string(runes[start:i])
+			if op == "!" {
+				return nil, syntaxError(start+1, `unexpected "!", did you mean "!="?`)
+			}
+			tokens = append(tokens, token{tokenOperator, op, start + 1})
+
+		case r == '"' || r == '\'':
+			var b strings.Builder
+			i++
+			for {
+				if i >= len(runes) {
+					return nil, syntaxError(start+1, "unterminated string")
+				}
+				if runes[i] == '\\' && i+1 < len(runes) {
+					b.WriteRune(runes[i+1])
+					i += 2
+					continue
+				}
+				if runes[i] == r {
+					i++
+					break
+				}
+				b.WriteRune(runes[i])
+				i++
+			}
+			tokens = append(tokens, token{tokenString, b.String(), start + 1})
+
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			i++
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			kind := tokenNumber
+			if i < len(runes) && unicode.IsLetter(runes[i]) {
+				kind = tokenDuration
+				for i < len(runes) && unicode.IsLetter(runes[i]) {
+					i++
+				}
+			}
+			tokens = append(tokens, token{kind, string(runes[start:i]), start + 1})
+
+		case unicode.IsLetter(r) || r == '_':
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, token{tokenIdent, string(runes[start:i]), start + 1})
+
+		default:
+			return nil, syntaxError(start+1, fmt.Sprintf("unexpected character %q", r))
+		}
+	}
+
+	return append(tokens, token{tokenEOF, "", len(runes) + 1}), nil
+}
@@ -0,0 +1,373 @@
+// Package segments parses the rules that pick a segment's customers and
+// compiles them to SQL.
+//
+// A rule compares customer fields and order aggregates, combined with AND,
+// OR, NOT and parentheses:
+//
+//	town = "Nairobi" AND order_total(30d) > 5000
+//	tag = "vip" OR (order_count(90d) >= 3 AND NOT county = "Mombasa")
+//
+// Text fields (name, email, code, country_code, town, county) are compared
+// case-insensitively with = and !=; town and county come from the customer's
+// default address. tag = "x" matches customers tagged x and tag != "x" those
+// who are not. Numeric fields take =, !=, <, <=, > and >=:
+//
+//	order_count(window)           orders placed, excluding scheduled and cancelled ones
+//	order_total(window, currency) the sum of those orders in one currency, DEFAULT_CURRENCY if omitted
+//	days_since_last_order         never matches customers without orders
+//	days_since_signup
+//
+// The window is optional, e.g. 12h, 30d or 8w; without it all orders count.
+package segments
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// MaxRuleLength keeps rules, and the SQL they compile to, a reasonable size
+	MaxRuleLength = 2000
+	maxConditions = 30
+	maxWindow     = 5 * 365 * 24 * time.Hour
+)
+
+// ErrInvalidRule wraps every error Parse returns
+var ErrInvalidRule = errors.New("invalid segment rule")
+
+// SyntaxError reports where in a rule parsing failed
+type SyntaxError struct {
+	// Pos is the 1-based character offset of the problem
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("invalid segment rule at position %d: %s", e.Pos, e.Msg)
+}
+
+func (e *SyntaxError) Is(target error) bool {
+	return target == ErrInvalidRule
+}
+
+func syntaxError(pos int, msg string) error {
+	return &SyntaxError{Pos: pos, Msg: msg}
+}
+
+type fieldKind int
+
+const (
+	textField fieldKind = iota
+	tagField
+	numberField
+)
+
+type fieldSpec struct {
+	kind fieldKind
+	// window and currency report which arguments an order aggregate takes
+	window   bool
+	currency bool
+}
+
+var fields = map[string]fieldSpec{
+	"name":                  {kind: textField},
+	"email":                 {kind: textField},
+	"code":                  {kind: textField},
+	"country_code":          {kind: textField},
+	"town":                  {kind: textField},
+	"county":                {kind: textField},
+	"tag":                   {kind: tagField},
+	"order_count":           {kind: numberField, window: true},
+	"order_total":           {kind: numberField, window: true, currency: true},
+	"days_since_last_order": {kind: numberField},
+	"days_since_signup":     {kind: numberField},
+}
+
+var validOperators = map[string]bool{"=": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true}
+
+// node is one part of a parsed rule
+type node interface {
+	compile(b *builder) string
+}
+
+type logical struct {
+	op          string // AND or OR
+	left, right node
+}
+
+type negation struct {
+	operand node
+}
+
+type comparison struct {
+	field    string
+	window   time.Duration // zero means all time
+	currency string
+	op       string
+	text     string
+	number   decimal.Decimal
+}
+
+// Rule is a parsed segment rule
+type Rule struct {
+	source string
+	root   node
+}
+
+// String returns the rule as it was written
+func (r *Rule) String() string {
+	return r.source
+}
+
+// Parse checks a rule and returns it ready to compile
+func Parse(source string) (*Rule, error) {
+	source = strings.TrimSpace(source)
+	if source == "" {
+		return nil, fmt.Errorf("%w: rule is required", ErrInvalidRule)
+	}
+	if len([]rune(source)) > MaxRuleLength {
+		return nil, fmt.Errorf("%w: rule must be at most %d characters", ErrInvalidRule, MaxRuleLength)
+	}
+
+	tokens, err := lex(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, syntaxError(t.pos, fmt.Sprintf("unexpected %s, expected AND, OR or end of rule", t))
+	}
+
+	return &Rule{source: source, root: root}, nil
+}
+
+type parser struct {
+	tokens     []token
+	pos        int
+	conditions int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// keyword reports whether the next token is the given keyword, consuming it if so
+func (p *parser) keyword(word string) bool {
+	t := p.peek()
+	if t.kind == tokenIdent && strings.EqualFold(t.text, word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(kind tokenKind, what string) (token, error) {
+	t := p.next()
+	if t.kind != kind {
+		return t, syntaxError(t.pos, fmt.Sprintf("expected %s, found %s", what, t))
+	}
+	return t, nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("OR") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logical{op: "OR", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("AND") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &logical{op: "AND", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.keyword("NOT") {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &negation{operand: operand}, nil
+	}
+
+	if p.peek().kind == tokenLParen {
+		p.next()
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokenRParen, `")"`); err != nil {
+			return nil, err
+		}
+		return inner, nil
+	}
+
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	name, err := p.expect(tokenIdent, "a field")
+	if err != nil {
+		return nil, err
+	}
+	field := strings.ToLower(name.text)
+	spec, ok := fields[field]
+	if !ok {
+		return nil, syntaxError(name.pos, fmt.Sprintf("unknown field %q", name.text))
+	}
+
+	p.conditions++
+	if p.conditions > maxConditions {
+		return nil, syntaxError(name.pos, fmt.Sprintf("a rule can have at most %d conditions", maxConditions))
+	}
+
+	c := &comparison{field: field}
+	if spec.currency {
+		c.currency = models.DefaultCurrency().Code
+	}
+	if p.peek().kind == tokenLParen {
+		if !spec.window {
+			return nil, syntaxError(p.peek().pos, fmt.Sprintf("%s does not take arguments", field))
+		}
+		if err := p.parseArguments(c, spec); err != nil {
+			return nil, err
+		}
+	}
+
+	op, err := p.expect(tokenOperator, "a comparison such as = or >")
+	if err != nil {
+		return nil, err
+	}
+	if !validOperators[op.text] {
+		return nil, syntaxError(op.pos, fmt.Sprintf("unknown comparison %q", op.text))
+	}
+	c.op = op.text
+
+	value := p.next()
+	switch spec.kind {
+	case textField, tagField:
+		if c.op != "=" && c.op != "!=" {
+			return nil, syntaxError(op.pos, fmt.Sprintf("%s can only be compared with = or !=", field))
+		}
+		if value.kind != tokenString {
+			return nil, syntaxError(value.pos, fmt.Sprintf("expected a quoted value for %s, found %s", field, value))
+		}
+		c.text = value.text
+		if spec.kind == tagField {
+			c.text = NormalizeTag(c.text)
+		}
+	case numberField:
+		if value.kind != tokenNumber {
+			return nil, syntaxError(value.pos, fmt.Sprintf("expected a number for %s, found %s", field, value))
+		}
+		number, err := decimal.NewFromString(value.text)
+		if err != nil {
+			return nil, syntaxError(value.pos, fmt.Sprintf("invalid number %q", value.text))
+		}
+		c.number = number
+	}
+
+	return c, nil
+}
+
+// parseArguments reads an aggregate's optional window and currency, e.g. (30d, USD)
+func (p *parser) parseArguments(c *comparison, spec fieldSpec) error {
+	p.next() // (
+
+	if p.peek().kind == tokenDuration {
+		t := p.next()
+		window, err := parseWindow(t.text)
+		if err != nil {
+			return syntaxError(t.pos, err.Error())
+		}
+		c.window = window
+
+		if p.peek().kind == tokenComma {
+			p.next()
+		}
+	}
+
+	if p.peek().kind == tokenIdent {
+		t := p.next()
+		if !spec.currency {
+			return syntaxError(t.pos, fmt.Sprintf("%s does not take a currency", c.field))
+		}
+		currency, err := models.LookupCurrency(t.text)
+		if err != nil {
+			return syntaxError(t.pos, err.Error())
+		}
+		c.currency = currency.Code
+	}
+
+	_, err := p.expect(tokenRParen, `a window such as 30d or ")"`)
+	return err
+}
+
+// parseWindow reads a window such as 12h, 30d or 8w
+func parseWindow(text string) (time.Duration, error) {
+	i := strings.IndexFunc(text, func(r rune) bool { return r < '0' || r > '9' })
+	n, err := strconv.Atoi(text[:i])
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid window %q", text)
+	}
+
+	var unit time.Duration
+	switch strings.ToLower(text[i:]) {
+	case "h":
+		unit = time.Hour
+	case "d":
+		unit = 24 * time.Hour
+	case "w":
+		unit = 7 * 24 * time.Hour
+	default:
+		return 0, fmt.Errorf("invalid window %q, use h, d or w, e.g. 30d", text)
+	}
+
+	window := time.Duration(n) * unit
+	if window > maxWindow || window/unit != time.Duration(n) {
+		return 0, fmt.Errorf("window %q is longer than five years", text)
+	}
+	return window, nil
+}
+
+// NormalizeTag is the form tags are stored and matched in
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.Join(strings.Fields(tag), " "))
+}
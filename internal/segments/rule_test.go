@@ -0,0 +1,94 @@
+package segments
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuleSQL(t *testing.T) {
+	t.Setenv("DEFAULT_CURRENCY", "KES")
+
+	rule, err := Parse(`town = "Nairobi" AND order_total(30d) > 5000`)
+	assert.NoError(t, err)
+
+	where, args := rule.SQL(int64(7))
+
+	assert.Equal(t, "(LOWER((SELECT a.town FROM customer_addresses a WHERE a.customer_id = c.id AND a.is_default)) = LOWER($2::text)"+
+		" AND (SELECT COALESCE(SUM(o.amount), 0) FROM orders o WHERE "+countedOrders+
+		" AND o.created_at >= NOW() - make_interval(hours => $3::int) AND o.currency = $4) > $5::text::numeric)", where)
+	assert.Equal(t, []any{int64(7), "Nairobi", int64(720), "KES", "5000"}, args)
+}
+
+func TestRuleSQL_TagsNotAndPrecedence(t *testing.T) {
+	rule, err := Parse(`tag = " VIP " or NOT (tag != "wholesale" AND order_count >= 3)`)
+	assert.NoError(t, err)
+
+	where, args := rule.SQL()
+
+	assert.Equal(t, "(EXISTS (SELECT 1 FROM customer_tags t WHERE t.customer_id = c.id AND t.tag = $1)"+
+		" OR NOT COALESCE((NOT EXISTS (SELECT 1 FROM customer_tags t WHERE t.customer_id = c.id AND t.tag = $2)"+
+		" AND (SELECT COUNT(*) FROM orders o WHERE "+countedOrders+") >= $3::text::numeric), false))", where)
+	assert.Equal(t, []any{"vip", "wholesale", "3"}, args)
+}
+
+func TestRuleSQL_ValuesAreParameters(t *testing.T) {
+	rule, err := Parse(`name = "x'); DROP TABLE customers; --"`)
+	assert.NoError(t, err)
+
+	where, args := rule.SQL()
+
+	assert.NotContains(t, where, "DROP")
+	assert.Equal(t, []any{"x'); DROP TABLE customers; --"}, args)
+}
+
+func TestParse_OrderTotalCurrency(t *testing.T) {
+	rule, err := Parse(`order_total(8w, usd) <= 100.50`)
+	assert.NoError(t, err)
+
+	_, args := rule.SQL()
+
+	assert.Equal(t, []any{int64(1344), "USD", "100.5"}, args)
+}
+
+func TestParse_Invalid(t *testing.T) {
+	tests := []struct {
+		rule    string
+		wantErr string
+	}{
+		{"", "invalid segment rule: rule is required"},
+		{`city = "Nairobi"`, `invalid segment rule at position 1: unknown field "city"`},
+		{`town > "Nairobi"`, "invalid segment rule at position 6: town can only be compared with = or !="},
+		{`town = Nairobi`, `invalid segment rule at position 8: expected a quoted value for town, found "Nairobi"`},
+		{`order_count > "5"`, `invalid segment rule at position 15: expected a number for order_count, found "5"`},
+		{`order_total(30y) > 1`, `invalid segment rule at position 13: invalid window "30y", use h, d or w, e.g. 30d`},
+		{`order_total(6000d) > 1`, `invalid segment rule at position 13: window "6000d" is longer than five years`},
+		{`order_count(30d, USD) > 1`, "invalid segment rule at position 18: order_count does not take a currency"},
+		{`order_total(XYZ) > 1`, `invalid segment rule at position 13: unsupported currency "XYZ"`},
+		{`days_since_signup(30d) > 1`, "invalid segment rule at position 18: days_since_signup does not take arguments"},
+		{`order_count == 1`, `invalid segment rule at position 13: unknown comparison "=="`},
+		{`(tag = "vip"`, `invalid segment rule at position 13: expected ")", found end of rule`},
+		{`tag = "vip" tag = "new"`, `invalid segment rule at position 13: unexpected "tag", expected AND, OR or end of rule`},
+		{`tag = "vip`, "invalid segment rule at position 7: unterminated string"},
+		{`tag ! "vip"`, `invalid segment rule at position 5: unexpected "!", did you mean "!="?`},
+		{`tag = "vip" AND`, "invalid segment rule at position 16: expected a field, found end of rule"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			_, err := Parse(tt.rule)
+
+			assert.ErrorIs(t, err, ErrInvalidRule)
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestParse_TooManyConditions(t *testing.T) {
+	rule := strings.TrimSuffix(strings.Repeat(`tag = "a" OR `, maxConditions+1), " OR ")
+
+	_, err := Parse(rule)
+
+	assert.ErrorContains(t, err, "a rule can have at most 30 conditions")
+}
@@ -0,0 +1,92 @@
+package segments
+
+import "strconv"
+
+// countedOrders are the orders aggregates look at: placed for the customer
+// aliased c, not deleted, and neither waiting for their scheduled time nor cancelled
+const countedOrders = `o.customer_id = c.id AND o.deleted_at IS NULL AND o.status NOT IN ('scheduled', 'cancelled')`
+
+// textColumns are the SQL for each text field, over customers aliased c
+var textColumns = map[string]string{
+	"name":         "c.customer_name",
+	"email":        "c.email",
+	"code":         "c.code",
+	"country_code": "c.country_code",
+	"town":         "(SELECT a.town FROM customer_addresses a WHERE a.customer_id = c.id AND a.is_default)",
+	"county":       "(SELECT a.county FROM customer_addresses a WHERE a.customer_id = c.id AND a.is_default)",
+}
+
+// builder collects the parameters of the SQL being built
+type builder struct {
+	args []any
+}
+
+func (b *builder) param(v any) string {
+	b.args = append(b.args, v)
+	return "$" + strconv.Itoa(len(b.args))
+}
+
+// SQL compiles the rule to a condition over the customers table aliased c.
+// Values from the rule are passed as parameters numbered after args, which
+// the caller's own parameters should be; the returned args hold both.
+func (r *Rule) SQL(args ...any) (string, []any) {
+	b := &builder{args: append([]any(nil), args...)}
+	return r.root.compile(b), b.args
+}
+
+func (l *logical) compile(b *builder) string {
+	return "(" + l.left.compile(b) + " " + l.op + " " + l.right.compile(b) + ")"
+}
+
+func (n *negation) compile(b *builder) string {
+	return "NOT COALESCE(" + n.operand.compile(b) + ", false)"
+}
+
+func (c *comparison) compile(b *builder) string {
+	switch fields[c.field].kind {
+	case tagField:
+		exists := "EXISTS (SELECT 1 FROM customer_tags t WHERE t.customer_id = c.id AND t.tag = " + b.param(c.text) + ")"
+		if c.op == "!=" {
+			return "NOT " + exists
+		}
+		return exists
+
+	case textField:
+		column := "LOWER(" + textColumns[c.field] + ")"
+		value := "LOWER(" + b.param(c.text) + "::text)"
+		if c.op == "!=" {
+			return column + " IS DISTINCT FROM " + value
+		}
+		return column + " = " + value
+	}
+
+	// The number is sent as text so it keeps its exact decimal value
+	return c.numberExpr(b) + " " + sqlOperator(c.op) + " " + b.param(c.number.String()) + "::text::numeric"
+}
+
+// numberExpr is the SQL for a numeric field
+func (c *comparison) numberExpr(b *builder) string {
+	switch c.field {
+	case "days_since_signup":
+		return "(EXTRACT(EPOCH FROM NOW() - c.created_at) / 86400)"
+	case "days_since_last_order":
+		return "(SELECT EXTRACT(EPOCH FROM NOW() - MAX(o.created_at)) / 86400 FROM orders o WHERE " + countedOrders + ")"
+	}
+
+	where := countedOrders
+	if c.window > 0 {
+		where += " AND o.created_at >= NOW() - make_interval(hours => " + b.param(int64(c.window.Hours())) + "::int)"
+	}
+	if c.field == "order_total" {
+		where += " AND o.currency = " + b.param(c.currency)
+		return "(SELECT COALESCE(SUM(o.amount), 0) FROM orders o WHERE " + where + ")"
+	}
+	return "(SELECT COUNT(*) FROM orders o WHERE " + where + ")"
+}
+
+func sqlOperator(op string) string {
+	if op == "!=" {
+		return "<>"
+	}
+	return op
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/chesireabel/Technical-Interview/internal/repositories"
+)
+
+const (
+	// maxAddressLabelLength matches the customer_addresses.label column
+	maxAddressLabelLength         = 50
+	maxDeliveryInstructionsLength = 500
+)
+
+// AddressService manages customers' address books. Orders copy an address
+// when they are placed, so editing or deleting one leaves past orders as they were.
+type AddressService interface {
+	CreateAddress(ctx context.Context, address *models.CustomerAddress) error
+	GetAddress(ctx context.Context, customerID, id int64) (*models.CustomerAddress, error)
+	ListAddresses(ctx context.Context, customerID int64) ([]models.CustomerAddress, error)
+	UpdateAddress(ctx context.Context, address *models.CustomerAddress) error
+	DeleteAddress(ctx context.Context, customerID, id int64) error
+}
+
+type addressService struct {
+	repo        repositories.AddressRepository
+	phoneRegion string
+}
+
+func NewAddressService(repo repositories.AddressRepository) AddressService {
+	return &addressService{repo: repo, phoneRegion: phoneDefaultRegion()}
+}
+
+// validateDeliveryAddress trims the address, requires a town, normalises the
+// recipient's phone to E.164 and checks the coordinates are a real point
+func validateDeliveryAddress(address *models.DeliveryAddress, phoneRegion string) error {
+	address.Recipient = strings.TrimSpace(address.Recipient)
+	address.Line1 = strings.TrimSpace(address.Line1)
+	address.Line2 = strings.TrimSpace(address.Line2)
+	address.Town = strings.TrimSpace(address.Town)
+	address.County = strings.TrimSpace(address.County)
+	address.PostalCode = strings.TrimSpace(address.PostalCode)
+
+	if address.Town == "" {
+		return errors.New("town is required")
+	}
+
+	if phone := strings.TrimSpace(address.Phone); phone != "" {
+		number, err := ParsePhoneNumber(phone, phoneRegion)
+		if err != nil {
+			return fmt.Errorf("invalid phone: %w", err)
+		}
+		address.Phone = number.E164
+	}
+
+	if (address.Latitude == nil) != (address.Longitude == nil) {
+		return errors.New("latitude and longitude must be given together")
+	}
+	if address.Latitude != nil {
+		if *address.Latitude < -90 || *address.Latitude > 90 {
+			return errors.New("latitude must be between -90 and 90")
+		}
+		if *address.Longitude < -180 || *address.Longitude > 180 {
+			return errors.New("longitude must be between -180 and 180")
+		}
+	}
+
+	return nil
+}
+
+func (s *addressService) validate(address *models.CustomerAddress) error {
+	if address.CustomerID == 0 {
+		return errors.New("customer_id is required")
+	}
+	address.Label = strings.TrimSpace(address.Label)
+	if len([]rune(address.Label)) > maxAddressLabelLength {
+		return fmt.Errorf("label must be at most %d characters", maxAddressLabelLength)
+	}
+	return validateDeliveryAddress(&address.DeliveryAddress, s.phoneRegion)
+}
+
+func (s *addressService) CreateAddress(ctx context.Context, address *models.CustomerAddress) error {
+	if err := s.validate(address); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return s.repo.Create(ctx, address)
+}
+
+func (s *addressService) GetAddress(ctx context.Context, customerID, id int64) (*models.CustomerAddress, error) {
+	if customerID == 0 || id == 0 {
+		return nil, errors.New("id is required")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return s.repo.GetByID(ctx, customerID, id)
+}
+
+func (s *addressService) ListAddresses(ctx context.Context, customerID int64) ([]models.CustomerAddress, error) {
+	if customerID == 0 {
+		return nil, errors.New("customer_id is required")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	return s.repo.ListByCustomer(ctx, customerID)
+}
+
+func (s *addressService) UpdateAddress(ctx context.Context, address *models.CustomerAddress) error {
+	if address.ID == 0 {
+		return errors.New("id is required for update")
+	}
+	if err := s.validate(address); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return s.repo.Update(ctx, address)
+}
+
+func (s *addressService) DeleteAddress(ctx context.Context, customerID, id int64) error {
+	if customerID == 0 || id == 0 {
+		return errors.New("id is required for delete")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return s.repo.Delete(ctx, customerID, id)
+}
+
+// prepareDelivery fills in the order's delivery address. An address_id is
+// copied from the customer's address book; an address given inline is
+// validated and stored with the order only.
+func prepareDelivery(ctx context.Context, addresses repositories.AddressRepository, order *models.Order) error {
+	order.DeliveryInstructions = strings.TrimSpace(order.DeliveryInstructions)
+	if len([]rune(order.DeliveryInstructions)) > maxDeliveryInstructionsLength {
+		return fmt.Errorf("delivery_instructions must be at most %d characters", maxDeliveryInstructionsLength)
+	}
+
+	if order.AddressID == nil {
+		if order.DeliveryAddress == nil {
+			return nil
+		}
+		return validateDeliveryAddress(order.DeliveryAddress, phoneDefaultRegion())
+	}
+	if order.DeliveryAddress != nil {
+		return errors.New("give either address_id or delivery_address, not both")
+	}
+
+	customerID, err := strconv.ParseInt(order.CustomerID, 10, 64)
+	if err != nil {
+		return errors.New("invalid customer_id format")
+	}
+	address, err := addresses.GetByID(ctx, customerID, *order.AddressID)
+	if errors.Is(err, models.ErrNotFound) {
+		return fmt.Errorf("address %d is not one of the customer's addresses", *order.AddressID)
+	}
+	if err != nil {
+		return err
+	}
+
+	snapshot := address.DeliveryAddress
+	order.DeliveryAddress = &snapshot
+	return nil
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/chesireabel/Technical-Interview/internal/repositories"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCreateAddress(t *testing.T) {
+	mockAddressRepo := new(MockAddressRepo)
+	service := NewAddressService(mockAddressRepo)
+
+	lat, lng := -1.2921, 36.8219
+	address := &models.CustomerAddress{
+		CustomerID: 4,
+		Label:      " Home ",
+		DeliveryAddress: models.DeliveryAddress{
+			Phone:     "0712 345 678",
+			Line1:     " Kindaruma Rd ",
+			Town:      " Nairobi ",
+			County:    "Nairobi",
+			Latitude:  &lat,
+			Longitude: &lng,
+		},
+	}
+
+	mockAddressRepo.On("Create", mock.Anything, address).Return(nil)
+
+	err := service.CreateAddress(context.Background(), address)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Home", address.Label)
+	assert.Equal(t, "Kindaruma Rd", address.Line1)
+	assert.Equal(t, "Nairobi", address.Town)
+	assert.Equal(t, "+254712345678", address.Phone)
+	mockAddressRepo.AssertExpectations(t)
+}
+
+func TestCreateAddress_Invalid(t *testing.T) {
+	lat, lng, far := -1.2921, 36.8219, 181.0
+
+	tests := []struct {
+		name    string
+		address models.CustomerAddress
+		wantErr string
+	}{
+		{"missing town", models.CustomerAddress{CustomerID: 4, DeliveryAddress: models.DeliveryAddress{Town: "  "}}, "town is required"},
+		{"latitude without longitude", models.CustomerAddress{CustomerID: 4, DeliveryAddress: models.DeliveryAddress{Town: "Nakuru", Latitude: &lat}}, "latitude and longitude must be given together"},
+		{"longitude out of range", models.CustomerAddress{CustomerID: 4, DeliveryAddress: models.DeliveryAddress{Town: "Nakuru", Latitude: &lat, Longitude: &far}}, "longitude must be between -180 and 180"},
+		{"latitude out of range", models.CustomerAddress{CustomerID: 4, DeliveryAddress: models.DeliveryAddress{Town: "Nakuru", Latitude: &far, Longitude: &lng}}, "latitude must be between -90 and 90"},
+		{"missing customer", models.CustomerAddress{DeliveryAddress: models.DeliveryAddress{Town: "Nakuru"}}, "customer_id is required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := NewAddressService(new(MockAddressRepo))
+
+			err := service.CreateAddress(context.Background(), &tt.address)
+
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestCreateAddress_InvalidPhone(t *testing.T) {
+	service := NewAddressService(new(MockAddressRepo))
+
+	err := service.CreateAddress(context.Background(), &models.CustomerAddress{
+		CustomerID:      4,
+		DeliveryAddress: models.DeliveryAddress{Town: "Kisumu", Phone: "12345"},
+	})
+
+	assert.ErrorContains(t, err, "invalid phone")
+}
+
+func TestCreateOrder_CopiesSavedAddress(t *testing.T) {
+	mockOrderRepo := new(MockOrderRepo)
+	mockCustomerRepo := new(MockCustomerRepo)
+	mockAddressRepo := new(MockAddressRepo)
+	tx := &FakeTxManager{Repos: repositories.Repositories{Orders: mockOrderRepo, Addresses: mockAddressRepo, TaxRates: noTaxRates()}}
+	service := NewOrderService(mockOrderRepo, mockCustomerRepo, nil, tx)
+
+	saved := &models.CustomerAddress{
+		ID:              9,
+		CustomerID:      1,
+		Label:           "Office",
+		DeliveryAddress: models.DeliveryAddress{Line1: "Moi Avenue", Town: "Mombasa"},
+	}
+	addressID := int64(9)
+	order := &models.Order{
+		CustomerID:           "1",
+		Item:                 "Laptop",
+		Amount:               decimal.NewFromInt(1000),
+		AddressID:            &addressID,
+		DeliveryInstructions: "  Leave with the guard  ",
+	}
+
+	mockCustomerRepo.On("GetByID", mock.Anything, int64(1)).Return(&models.Customer{ID: 1, Phone: "+254712345678"}, nil)
+	mockAddressRepo.On("GetByID", mock.Anything, int64(1), int64(9)).Return(saved, nil)
+	mockOrderRepo.On("Create", mock.Anything, order).Return(int64(3), nil)
+
+	_, err := service.CreateOrder(context.Background(), order)
+
+	assert.NoError(t, err)
+	assert.Equal(t, &models.DeliveryAddress{Line1: "Moi Avenue", Town: "Mombasa"}, order.DeliveryAddress)
+	assert.Equal(t, "Leave with the guard", order.DeliveryInstructions)
+
+	// Editing the saved address afterwards leaves the order's copy alone
+	saved.Town = "Malindi"
+	assert.Equal(t, "Mombasa", order.DeliveryAddress.Town)
+	mockOrderRepo.AssertExpectations(t)
+}
+
+func TestCreateOrder_UnknownAddress(t *testing.T) {
+	mockOrderRepo := new(MockOrderRepo)
+	mockCustomerRepo := new(MockCustomerRepo)
+	mockAddressRepo := new(MockAddressRepo)
+	tx := &FakeTxManager{Repos: repositories.Repositories{Orders: mockOrderRepo, Addresses: mockAddressRepo, TaxRates: noTaxRates()}}
+	service := NewOrderService(mockOrderRepo, mockCustomerRepo, nil, tx)
+
+	addressID := int64(12)
+	order := &models.Order{CustomerID: "1", Item: "Laptop", Amount: decimal.NewFromInt(1000), AddressID: &addressID}
+
+	mockCustomerRepo.On("GetByID", mock.Anything, int64(1)).Return(&models.Customer{ID: 1, Phone: "+254712345678"}, nil)
+	mockAddressRepo.On("GetByID", mock.Anything, int64(1), int64(12)).
+		Return(nil, fmt.Errorf("address with id 12: %w", models.ErrNotFound))
+
+	_, err := service.CreateOrder(context.Background(), order)
+
+	assert.EqualError(t, err, "address 12 is not one of the customer's addresses")
+	mockOrderRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestCreateOrder_AddressAndInlineAddress(t *testing.T) {
+	mockOrderRepo := new(MockOrderRepo)
+	mockCustomerRepo := new(MockCustomerRepo)
+	tx := &FakeTxManager{Repos: repositories.Repositories{Orders: mockOrderRepo, Addresses: new(MockAddressRepo), TaxRates: noTaxRates()}}
+	service := NewOrderService(mockOrderRepo, mockCustomerRepo, nil, tx)
+
+	addressID := int64(9)
+	order := &models.Order{
+		CustomerID:      "1",
+		Item:            "Laptop",
+		Amount:          decimal.NewFromInt(1000),
+		AddressID:       &addressID,
+		DeliveryAddress: &models.DeliveryAddress{Town: "Thika"},
+	}
+
+	mockCustomerRepo.On("GetByID", mock.Anything, int64(1)).Return(&models.Customer{ID: 1, Phone: "+254712345678"}, nil)
+
+	_, err := service.CreateOrder(context.Background(), order)
+
+	assert.EqualError(t, err, "give either address_id or delivery_address, not both")
+}
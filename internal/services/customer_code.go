@@ -0,0 +1,72 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const defaultCustomerCodePrefix = "CUS"
+
+// customerCodeGenerator builds human-friendly customer codes such as CUS-000042-2
+// from a database sequence value, optionally followed by a Luhn check digit so
+// that mistyped codes can be spotted before they hit the database.
+type customerCodeGenerator struct {
+	prefix     string
+	checkDigit bool
+}
+
+// newCustomerCodeGenerator reads CUSTOMER_CODE_PREFIX (default "CUS") and
+// CUSTOMER_CODE_CHECK_DIGIT (default true) from the environment
+func newCustomerCodeGenerator() customerCodeGenerator {
+	prefix, ok := os.LookupEnv("CUSTOMER_CODE_PREFIX")
+	if !ok {
+		prefix = defaultCustomerCodePrefix
+	}
+
+	checkDigit := true
+	if v := os.Getenv("CUSTOMER_CODE_CHECK_DIGIT"); v != "" {
+		if parsed, err := strconv.ParseBool(v); err == nil {
+			checkDigit = parsed
+		}
+	}
+
+	return customerCodeGenerator{
+		prefix:     strings.ToUpper(strings.TrimSpace(prefix)),
+		checkDigit: checkDigit,
+	}
+}
+
+func (g customerCodeGenerator) Format(seq int64) string {
+	digits := fmt.Sprintf("%06d", seq)
+
+	parts := make([]string, 0, 3)
+	if g.prefix != "" {
+		parts = append(parts, g.prefix)
+	}
+	parts = append(parts, digits)
+	if g.checkDigit {
+		parts = append(parts, strconv.Itoa(luhnCheckDigit(digits)))
+	}
+
+	return strings.Join(parts, "-")
+}
+
+// luhnCheckDigit returns the digit that makes digits+check pass the Luhn algorithm
+func luhnCheckDigit(digits string) int {
+	sum := 0
+	double := true
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return (10 - sum%10) % 10
+}
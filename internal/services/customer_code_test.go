@@ -0,0 +1,44 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLuhnCheckDigit(t *testing.T) {
+	tests := []struct {
+		digits string
+		want   int
+	}{
+		{"7992739871", 3},
+		{"000000", 0},
+		{"000042", 2},
+		{"000008", 3},
+		{"123456", 6},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, luhnCheckDigit(tt.digits), tt.digits)
+	}
+}
+
+func TestCustomerCodeGeneratorFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		gen  customerCodeGenerator
+		seq  int64
+		want string
+	}{
+		{"prefix and check digit", customerCodeGenerator{prefix: "CUS", checkDigit: true}, 42, "CUS-000042-2"},
+		{"no check digit", customerCodeGenerator{prefix: "NBO", checkDigit: false}, 42, "NBO-000042"},
+		{"no prefix", customerCodeGenerator{checkDigit: true}, 123456, "123456-6"},
+		{"wider than padding", customerCodeGenerator{prefix: "CUS"}, 12345678, "CUS-12345678"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.gen.Format(tt.seq))
+		})
+	}
+}
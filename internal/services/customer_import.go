@@ -0,0 +1,371 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+)
+
+const defaultImportChunkSize = 500
+
+// importFields are the customer fields a CSV column can be mapped to
+var importFields = []string{"customer_name", "email", "password", "phone", "code"}
+
+// importFieldAliases lets common header spellings map without an explicit mapping
+var importFieldAliases = map[string]string{
+	"name":          "customer_name",
+	"customer_name": "customer_name",
+	"email":         "email",
+	"password":      "password",
+	"phone":         "phone",
+	"code":          "code",
+}
+
+// ImportOptions controls a bulk customer import
+type ImportOptions struct {
+	// Mapping maps customer fields to CSV header names, e.g. customer_name -> "Full Name".
+	// Fields without an entry are matched against headers by name.
+	Mapping map[string]string
+	// DryRun validates every row without writing anything
+	DryRun bool
+	// ChunkSize is the number of rows inserted per transaction
+	ChunkSize int
+}
+
+// ImportReport summarises a bulk customer import. It is stored under ID so
+// GetImportReport can return it again later.
+type ImportReport struct {
+	ID        int64             `json:"id,omitempty"`
+	DryRun    bool              `json:"dry_run"`
+	TotalRows int               `json:"total_rows"`
+	Valid     int               `json:"valid"`
+	Imported  int64             `json:"imported"`
+	Rejected  []ImportRejection `json:"rejected"`
+
+	header []string
+}
+
+// ImportRejection is a CSV row that was not imported
+type ImportRejection struct {
+	Row    int      `json:"row"`
+	Record []string `json:"record"`
+	Reason string   `json:"reason"`
+}
+
+// WriteRejectedCSV writes the rejected rows in their original layout plus an error column
+func (r *ImportReport) WriteRejectedCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+
+	header := append([]string{"row"}, r.header...)
+	if err := writer.Write(escapeCSVFormulas(append(header, "error"))); err != nil {
+		return err
+	}
+	for _, rej := range r.Rejected {
+		record := append([]string{fmt.Sprint(rej.Row)}, rej.Record...)
+		if err := writer.Write(escapeCSVFormulas(append(record, rej.Reason))); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// ParseColumnMapping parses "customer_name=Full Name,phone=Mobile" into a field to header map
+func ParseColumnMapping(raw string) (map[string]string, error) {
+	mapping := make(map[string]string)
+	if strings.TrimSpace(raw) == "" {
+		return mapping, nil
+	}
+
+	for _, pair := range strings.Split(raw, ",") {
+		field, header, ok := strings.Cut(pair, "=")
+		field = strings.ToLower(strings.TrimSpace(field))
+		header = strings.TrimSpace(header)
+		if !ok || field == "" || header == "" {
+			return nil, fmt.Errorf("invalid column mapping %q, expected field=Header", pair)
+		}
+		if _, known := importFieldAliases[field]; !known {
+			return nil, fmt.Errorf("unknown customer field %q in column mapping", field)
+		}
+		mapping[importFieldAliases[field]] = header
+	}
+
+	return mapping, nil
+}
+
+// resolveImportColumns returns the CSV column index for each mapped customer field
+func resolveImportColumns(header []string, mapping map[string]string) (map[string]int, error) {
+	byName := make(map[string]int, len(header))
+	for i, h := range header {
+		byName[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+
+	columns := make(map[string]int)
+	for field, h := range mapping {
+		idx, ok := byName[strings.ToLower(h)]
+		if !ok {
+			return nil, fmt.Errorf("column %q mapped to %s not found in CSV header", h, field)
+		}
+		columns[field] = idx
+	}
+
+	for name, idx := range byName {
+		field, ok := importFieldAliases[name]
+		if !ok {
+			continue
+		}
+		if _, mapped := columns[field]; !mapped {
+			columns[field] = idx
+		}
+	}
+
+	var missing []string
+	for _, field := range importFields {
+		if _, ok := columns[field]; !ok && field != "code" {
+			missing = append(missing, field)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("CSV is missing required columns: %s", strings.Join(missing, ", "))
+	}
+
+	return columns, nil
+}
+
+type importRow struct {
+	row      int
+	record   []string
+	customer models.Customer
+}
+
+// ImportCustomers validates every CSV row with the same rules as CreateCustomer
+// and, unless DryRun is set, inserts the valid rows in chunks. Each chunk is
+// atomic: if the database rejects it, all of its rows are reported as rejected.
+func (s *customerService) ImportCustomers(ctx context.Context, r io.Reader, opts ImportOptions) (*ImportReport, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("CSV file is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+
+	columns, err := resolveImportColumns(header, opts.Mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ImportReport{DryRun: opts.DryRun, Rejected: []ImportRejection{}, header: header}
+	// Rejected rows are echoed back in the report, so never include the password
+	reject := func(row int, record []string, reason string) {
+		record = append([]string(nil), record...)
+		if idx, ok := columns["password"]; ok && idx < len(record) {
+			record[idx] = ""
+		}
+		report.Rejected = append(report.Rejected, ImportRejection{Row: row, Record: record, Reason: reason})
+	}
+
+	var pending []importRow
+	seenEmails := make(map[string]int)
+	seenCodes := make(map[string]int)
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			report.TotalRows++
+			reject(parseErr.StartLine, record, parseErr.Err.Error())
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV: %w", err)
+		}
+		report.TotalRows++
+		row, _ := reader.FieldPos(0)
+
+		value := func(field string) string {
+			idx, ok := columns[field]
+			if !ok || idx >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[idx])
+		}
+		customer := models.Customer{
+			Customer_name: value("customer_name"),
+			Email:         value("email"),
+			Password:      value("password"),
+			Phone:         value("phone"),
+			Code:          value("code"),
+		}
+
+		if err := s.validateNewCustomer(&customer); err != nil {
+			reject(row, record, err.Error())
+			continue
+		}
+
+		email := strings.ToLower(customer.Email)
+		if first, dup := seenEmails[email]; dup {
+			reject(row, record, fmt.Sprintf("duplicate email, first seen on row %d", first))
+			continue
+		}
+		if first, dup := seenCodes[customer.Code]; dup && customer.Code != "" {
+			reject(row, record, fmt.Sprintf("duplicate code, first seen on row %d", first))
+			continue
+		}
+		seenEmails[email] = row
+		if customer.Code != "" {
+			seenCodes[customer.Code] = row
+		}
+
+		pending = append(pending, importRow{row: row, record: record, customer: customer})
+	}
+
+	pending, err = s.rejectExisting(ctx, pending, reject)
+	if err != nil {
+		return nil, err
+	}
+	report.Valid = len(pending)
+
+	if opts.DryRun || len(pending) == 0 {
+		s.saveImport(ctx, report)
+		return report, nil
+	}
+
+	chunkSize := opts.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultImportChunkSize
+	}
+
+	for start := 0; start < len(pending); start += chunkSize {
+		chunk := pending[start:min(start+chunkSize, len(pending))]
+
+		imported, err := s.importChunk(ctx, chunk)
+		if err != nil {
+			for _, p := range chunk {
+				reject(p.row, p.record, fmt.Sprintf("batch rejected by database: %v", err))
+			}
+			continue
+		}
+		report.Imported += imported
+	}
+
+	s.saveImport(ctx, report)
+	return report, nil
+}
+
+// saveImport stores the report and sets its ID. The import itself has already
+// happened, so a failure only means the report cannot be fetched again later.
+func (s *customerService) saveImport(ctx context.Context, report *ImportReport) {
+	body, err := json.Marshal(report)
+	if err != nil {
+		log.Printf("⚠️ Failed to encode customer import report: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	stored := &models.CustomerImport{Header: report.header, Report: body}
+	if err := s.repo.SaveImport(ctx, stored); err != nil {
+		log.Printf("⚠️ Failed to store customer import report: %v", err)
+		return
+	}
+	report.ID = stored.ID
+}
+
+// GetImportReport returns a report stored by an earlier ImportCustomers call
+func (s *customerService) GetImportReport(ctx context.Context, id int64) (*ImportReport, error) {
+	if id <= 0 {
+		return nil, errors.New("invalid import ID")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	stored, err := s.repo.GetImport(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var report ImportReport
+	if err := json.Unmarshal(stored.Report, &report); err != nil {
+		return nil, fmt.Errorf("failed to decode customer import %d: %w", id, err)
+	}
+	report.ID = stored.ID
+	report.header = stored.Header
+	return &report, nil
+}
+
+// rejectExisting drops rows whose email or code already belongs to a stored customer
+func (s *customerService) rejectExisting(ctx context.Context, rows []importRow, reject func(int, []string, string)) ([]importRow, error) {
+	if len(rows) == 0 {
+		return rows, nil
+	}
+
+	emails := make([]string, 0, len(rows))
+	codes := make([]string, 0, len(rows))
+	for _, p := range rows {
+		emails = append(emails, p.customer.Email)
+		if p.customer.Code != "" {
+			codes = append(codes, p.customer.Code)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	existingEmails, existingCodes, err := s.repo.FindExisting(ctx, emails, codes)
+	if err != nil {
+		return nil, err
+	}
+
+	kept := rows[:0]
+	for _, p := range rows {
+		switch {
+		case existingEmails[strings.ToLower(p.customer.Email)]:
+			reject(p.row, p.record, "email already exists")
+		case p.customer.Code != "" && existingCodes[p.customer.Code]:
+			reject(p.row, p.record, "code already exists")
+		default:
+			kept = append(kept, p)
+		}
+	}
+
+	return kept, nil
+}
+
+// importChunk assigns codes to rows that lack one and inserts the chunk in one transaction
+func (s *customerService) importChunk(ctx context.Context, chunk []importRow) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	customers := make([]models.Customer, len(chunk))
+	for i, p := range chunk {
+		customers[i] = p.customer
+		if customers[i].Code == "" {
+			seq, err := s.repo.NextCodeSequence(ctx)
+			if err != nil {
+				return 0, err
+			}
+			customers[i].Code = s.codes.Format(seq)
+		}
+	}
+
+	return s.repo.BulkCreate(ctx, customers)
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const importCSV = `Full Name,E-mail,password,Mobile,code
+Jane Wanjiru,jane@example.com,secret,0712345678,
+Peter Otieno,,secret,0722000000,
+Mary Atieno,JANE@example.com,secret,0733000000,
+Ali Hassan,ali@example.com,secret,12345,
+Grace Njeri,grace@example.com,secret,+254744000000,GR-1
+Existing Person,taken@example.com,secret,0755000000,
+`
+
+var importMapping = map[string]string{
+	"customer_name": "Full Name",
+	"email":         "E-mail",
+	"phone":         "Mobile",
+}
+
+func TestImportCustomers_DryRun(t *testing.T) {
+	mockRepo := new(MockCustomerRepo)
+	service := NewCustomerService(mockRepo, nil)
+
+	mockRepo.On("FindExisting", mock.Anything, mock.Anything, mock.Anything).
+		Return(map[string]bool{"taken@example.com": true}, map[string]bool{}, nil)
+	mockRepo.On("SaveImport", mock.Anything, mock.Anything).Return(nil)
+
+	report, err := service.ImportCustomers(context.Background(), strings.NewReader(importCSV), ImportOptions{
+		Mapping: importMapping,
+		DryRun:  true,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 6, report.TotalRows)
+	assert.Equal(t, 2, report.Valid)
+	assert.Equal(t, int64(0), report.Imported)
+
+	reasons := map[int]string{}
+	for _, rej := range report.Rejected {
+		reasons[rej.Row] = rej.Reason
+	}
+	assert.Equal(t, "all fields  are required", reasons[3])
+	assert.Equal(t, "duplicate email, first seen on row 2", reasons[4])
+	assert.Contains(t, reasons[5], "invalid phone")
+	assert.Equal(t, "email already exists", reasons[7])
+
+	mockRepo.AssertNotCalled(t, "BulkCreate", mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "NextCodeSequence", mock.Anything)
+}
+
+func TestImportCustomers_Chunks(t *testing.T) {
+	mockRepo := new(MockCustomerRepo)
+	service := NewCustomerService(mockRepo, nil)
+
+	mockRepo.On("FindExisting", mock.Anything, mock.Anything, mock.Anything).
+		Return(map[string]bool{}, map[string]bool{}, nil)
+	mockRepo.On("NextCodeSequence", mock.Anything).Return(int64(10), nil).Once()
+	mockRepo.On("NextCodeSequence", mock.Anything).Return(int64(11), nil).Once()
+	mockRepo.On("BulkCreate", mock.Anything, mock.MatchedBy(func(cs []models.Customer) bool {
+		return len(cs) == 1 && cs[0].Email == "jane@example.com"
+	})).Return(int64(1), nil)
+	mockRepo.On("BulkCreate", mock.Anything, mock.MatchedBy(func(cs []models.Customer) bool {
+		return len(cs) == 1 && cs[0].Email == "grace@example.com"
+	})).Return(int64(0), errors.New("connection reset"))
+	mockRepo.On("BulkCreate", mock.Anything, mock.MatchedBy(func(cs []models.Customer) bool {
+		return len(cs) == 1 && cs[0].Email == "taken@example.com"
+	})).Return(int64(1), nil)
+	mockRepo.On("SaveImport", mock.Anything, mock.Anything).Return(nil)
+
+	report, err := service.ImportCustomers(context.Background(), strings.NewReader(importCSV), ImportOptions{
+		Mapping:   importMapping,
+		ChunkSize: 1,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, report.Valid)
+	assert.Equal(t, int64(2), report.Imported)
+	assert.Len(t, report.Rejected, 4)
+	assert.Equal(t, 6, report.Rejected[3].Row)
+	assert.Contains(t, report.Rejected[3].Reason, "batch rejected by database")
+	mockRepo.AssertExpectations(t)
+
+	var out bytes.Buffer
+	assert.NoError(t, report.WriteRejectedCSV(&out))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Equal(t, "row,Full Name,E-mail,password,Mobile,code,error", lines[0])
+	assert.Len(t, lines, 5)
+	assert.NotContains(t, out.String(), "secret")
+	for _, rejection := range report.Rejected {
+		assert.Equal(t, "", rejection.Record[2])
+	}
+}
+
+func TestGetImportReport(t *testing.T) {
+	mockRepo := new(MockCustomerRepo)
+	service := NewCustomerService(mockRepo, nil)
+
+	var stored *models.CustomerImport
+	mockRepo.On("FindExisting", mock.Anything, mock.Anything, mock.Anything).
+		Return(map[string]bool{"taken@example.com": true}, map[string]bool{}, nil)
+	mockRepo.On("SaveImport", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		stored = args.Get(1).(*models.CustomerImport)
+		stored.ID = 7
+	}).Return(nil)
+
+	report, err := service.ImportCustomers(context.Background(), strings.NewReader(importCSV), ImportOptions{
+		Mapping: importMapping,
+		DryRun:  true,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), report.ID)
+
+	mockRepo.On("GetImport", mock.Anything, int64(7)).Return(stored, nil)
+	mockRepo.On("GetImport", mock.Anything, int64(8)).Return(nil, models.ErrNotFound)
+
+	again, err := service.GetImportReport(context.Background(), 7)
+	assert.NoError(t, err)
+	assert.Equal(t, report, again)
+
+	var first, second bytes.Buffer
+	assert.NoError(t, report.WriteRejectedCSV(&first))
+	assert.NoError(t, again.WriteRejectedCSV(&second))
+	assert.Equal(t, first.String(), second.String())
+
+	_, err = service.GetImportReport(context.Background(), 8)
+	assert.ErrorIs(t, err, models.ErrNotFound)
+}
+
+func TestImportCustomers_MissingColumns(t *testing.T) {
+	service := NewCustomerService(new(MockCustomerRepo), nil)
+
+	_, err := service.ImportCustomers(context.Background(), strings.NewReader("name,email\nJane,jane@example.com\n"), ImportOptions{})
+
+	assert.Error(t, err)
+	assert.Equal(t, "CSV is missing required columns: password, phone", err.Error())
+}
+
+func TestParseColumnMapping(t *testing.T) {
+	mapping, err := ParseColumnMapping("name=Full Name, phone = Mobile")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"customer_name": "Full Name", "phone": "Mobile"}, mapping)
+
+	_, err = ParseColumnMapping("address=Street")
+	assert.Error(t, err)
+
+	_, err = ParseColumnMapping("phone")
+	assert.Error(t, err)
+}
@@ -3,6 +3,10 @@ package services
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/chesireabel/Technical-Interview/internal/models"
@@ -11,29 +15,164 @@ import (
 
 type CustomerService interface {
 	CreateCustomer(ctx context.Context, customer *models.Customer) (int64, error)
+	CreateCustomerWithOrder(ctx context.Context, customer *models.Customer, order *models.Order) (customerID int64, orderID int64, err error)
 	GetCustomer(ctx context.Context, id int64) (*models.Customer, error)
-	GetAllCustomers(ctx context.Context) ([]models.Customer, error)
+	GetAllCustomers(ctx context.Context, includeDeleted bool) ([]models.Customer, error)
+	// UpdateCustomer leaves the phone unchanged when it is blank
 	UpdateCustomer(ctx context.Context, customer *models.Customer) error
 	DeleteCustomer(ctx context.Context, id int64) error
+	RestoreCustomer(ctx context.Context, id int64) error
+	BackfillPhones(ctx context.Context) (*PhoneBackfillReport, error)
+	ImportCustomers(ctx context.Context, r io.Reader, opts ImportOptions) (*ImportReport, error)
+	GetImportReport(ctx context.Context, id int64) (*ImportReport, error)
+	ExportCustomers(ctx context.Context, w io.Writer, format ExportFormat, includeDeleted bool) error
 }
 
+// PhoneBackfillReport summarises a run of BackfillPhones
+type PhoneBackfillReport struct {
+	Scanned   int                 `json:"scanned"`
+	Updated   int                 `json:"updated"`
+	Unchanged int                 `json:"unchanged"`
+	Failed    []PhoneBackfillFail `json:"failed"`
+}
+
+// PhoneBackfillFail is a stored phone number that could not be normalised
+type PhoneBackfillFail struct {
+	CustomerID int64  `json:"customer_id"`
+	Phone      string `json:"phone"`
+	Reason     string `json:"reason"`
+}
+
+// maxCodeAttempts bounds retries when a generated code collides with one a client chose earlier
+const maxCodeAttempts = 3
+
 type customerService struct {
-	repo repositories.CustomerRepository
+	repo          repositories.CustomerRepository
+	tx            repositories.TxManager
+	codes         customerCodeGenerator
+	phoneRegion   string
+	stockLocation string
 }
 
-func NewCustomerService(repo repositories.CustomerRepository) CustomerService {
-	return &customerService{repo: repo}
+// NewCustomerService creates the customer service. tx is used for operations
+// that also write orders and may be nil where those are not needed.
+func NewCustomerService(repo repositories.CustomerRepository, tx repositories.TxManager) CustomerService {
+	return &customerService{
+		repo:          repo,
+		tx:            tx,
+		codes:         newCustomerCodeGenerator(),
+		phoneRegion:   phoneDefaultRegion(),
+		stockLocation: stockDefaultLocation(),
+	}
 }
 
-func (s *customerService) CreateCustomer(ctx context.Context, customer *models.Customer) (int64, error) {
+// normalisePhone rewrites the customer's phone to E.164 and records its country
+func (s *customerService) normalisePhone(customer *models.Customer) error {
+	number, err := ParsePhoneNumber(customer.Phone, s.phoneRegion)
+	if err != nil {
+		return fmt.Errorf("invalid phone: %w", err)
+	}
+
+	customer.Phone = number.E164
+	customer.CountryCode = number.CountryCode
+	return nil
+}
+
+// validateNewCustomer applies the create rules shared by CreateCustomer and ImportCustomers
+func (s *customerService) validateNewCustomer(customer *models.Customer) error {
+	customer.Email = strings.TrimSpace(customer.Email)
+	customer.Code = strings.TrimSpace(customer.Code)
+
 	if customer.Customer_name == "" || customer.Email == ""  || customer.Password == "" || customer.Phone == ""{
-		return 0, errors.New("all fields  are required")
+		return errors.New("all fields  are required")
+	}
+
+	return s.normalisePhone(customer)
+}
+
+func (s *customerService) CreateCustomer(ctx context.Context, customer *models.Customer) (int64, error) {
+	if err := s.validateNewCustomer(customer); err != nil {
+		return 0, err
 	}
 
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	return s.repo.Create(ctx, customer)
+	return s.withCustomerCode(ctx, customer, func() (int64, error) {
+		return s.repo.Create(ctx, customer)
+	})
+}
+
+// withCustomerCode runs create after assigning a generated code to customers
+// that do not bring their own, retrying with a fresh code on a collision
+func (s *customerService) withCustomerCode(ctx context.Context, customer *models.Customer, create func() (int64, error)) (int64, error) {
+	if customer.Code != "" {
+		return create()
+	}
+
+	for attempt := 0; attempt < maxCodeAttempts; attempt++ {
+		seq, err := s.repo.NextCodeSequence(ctx)
+		if err != nil {
+			return 0, err
+		}
+		customer.Code = s.codes.Format(seq)
+
+		id, err := create()
+		var conflict *models.ConflictError
+		if errors.As(err, &conflict) && conflict.Field == "code" {
+			continue
+		}
+		return id, err
+	}
+
+	customer.Code = ""
+	return 0, errors.New("could not generate a unique customer code")
+}
+
+// CreateCustomerWithOrder creates a customer and their first order atomically:
+// if the order cannot be stored, e.g. because a product is out of stock, the
+// customer is not created either
+func (s *customerService) CreateCustomerWithOrder(ctx context.Context, customer *models.Customer, order *models.Order) (int64, int64, error) {
+	if s.tx == nil {
+		return 0, 0, errors.New("transactions are not available")
+	}
+	if err := s.validateNewCustomer(customer); err != nil {
+		return 0, 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	var orderID int64
+	customerID, err := s.withCustomerCode(ctx, customer, func() (int64, error) {
+		var customerID int64
+		err := s.tx.WithinTx(ctx, func(ctx context.Context, repos repositories.Repositories) error {
+			if err := prepareOrder(ctx, repos.Products, order); err != nil {
+				return err
+			}
+			if err := setInitialStatus(order, time.Now()); err != nil {
+				return err
+			}
+
+			var err error
+			if customerID, err = repos.Customers.Create(ctx, customer); err != nil {
+				return err
+			}
+
+			order.CustomerID = strconv.FormatInt(customerID, 10)
+			order.TaxRegion = taxRegion(customer)
+			orderID, err = placeOrder(ctx, repos, order, s.stockLocation)
+			return err
+		})
+		return customerID, err
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	customer.ID = customerID
+	order.ID = orderID
+	return customerID, orderID, nil
 }
 
 func (s *customerService) GetCustomer(ctx context.Context, id int64) (*models.Customer, error) {
@@ -47,14 +186,17 @@ func (s *customerService) GetCustomer(ctx context.Context, id int64) (*models.Cu
 	return s.repo.GetByID(ctx, id)
 }
 
-func (s *customerService) GetAllCustomers(ctx context.Context) ([]models.Customer, error) {
+func (s *customerService) GetAllCustomers(ctx context.Context, includeDeleted bool) ([]models.Customer, error) {
 	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
-	return s.repo.GetAll(ctx)
+	return s.repo.GetAll(ctx, includeDeleted)
 }
 
 func (s *customerService) UpdateCustomer(ctx context.Context, customer *models.Customer) error {
+	customer.Email = strings.TrimSpace(customer.Email)
+	customer.Code = strings.TrimSpace(customer.Code)
+
 	if customer.ID == 0 {
 		return errors.New("id is required for update")
 	}
@@ -63,6 +205,15 @@ func (s *customerService) UpdateCustomer(ctx context.Context, customer *models.C
 		return errors.New("name and email are required")
 	}
 
+	// A blank phone leaves the stored number as it is
+	customer.Phone = strings.TrimSpace(customer.Phone)
+	customer.CountryCode = ""
+	if customer.Phone != "" {
+		if err := s.normalisePhone(customer); err != nil {
+			return err
+		}
+	}
+
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
@@ -73,9 +224,102 @@ func (s *customerService) DeleteCustomer(ctx context.Context, id int64) error {
 	if id == 0 {
 		return errors.New("id is required for delete")
 	}
+	if s.tx == nil {
+		return errors.New("transactions are not available")
+	}
 
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	return s.repo.Delete(ctx, id)
-}
\ No newline at end of file
+	// The customer's orders are deleted with it, so their stock goes back too
+	return s.tx.WithinTx(ctx, func(ctx context.Context, repos repositories.Repositories) error {
+		orders, err := repos.Orders.GetByCustomerID(ctx, id, false)
+		if err != nil {
+			return err
+		}
+		if err := repos.Customers.Delete(ctx, id); err != nil {
+			return err
+		}
+
+		for _, order := range orders {
+			if err := repos.Inventory.Release(ctx, order.ID, fmt.Sprintf("order:%d", order.ID)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *customerService) RestoreCustomer(ctx context.Context, id int64) error {
+	if id == 0 {
+		return errors.New("id is required for restore")
+	}
+	if s.tx == nil {
+		return errors.New("transactions are not available")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	// Restoring brings back the orders deleted with the customer, which
+	// reserve their stock again
+	return s.tx.WithinTx(ctx, func(ctx context.Context, repos repositories.Repositories) error {
+		if err := repos.Customers.Restore(ctx, id); err != nil {
+			return err
+		}
+
+		orders, err := repos.Orders.GetByCustomerID(ctx, id, false)
+		if err != nil {
+			return err
+		}
+		for i := range orders {
+			if !holdsStock(orders[i].Status) {
+				continue
+			}
+			if err := reserveStock(ctx, repos.Inventory, &orders[i], s.stockLocation); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// BackfillPhones normalises the phone numbers of every stored customer,
+// including soft deleted ones, and reports the rows it could not fix
+func (s *customerService) BackfillPhones(ctx context.Context) (*PhoneBackfillReport, error) {
+	customers, err := s.repo.GetAll(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &PhoneBackfillReport{Failed: []PhoneBackfillFail{}}
+	for _, c := range customers {
+		report.Scanned++
+
+		if strings.TrimSpace(c.Phone) == "" {
+			report.Failed = append(report.Failed, PhoneBackfillFail{CustomerID: c.ID, Phone: c.Phone, Reason: "phone number is missing"})
+			continue
+		}
+
+		number, err := ParsePhoneNumber(c.Phone, s.phoneRegion)
+		if err != nil {
+			report.Failed = append(report.Failed, PhoneBackfillFail{CustomerID: c.ID, Phone: c.Phone, Reason: err.Error()})
+			continue
+		}
+
+		if number.E164 == c.Phone && number.CountryCode == c.CountryCode {
+			report.Unchanged++
+			continue
+		}
+
+		updateCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		err = s.repo.UpdatePhone(updateCtx, c.ID, number.E164, number.CountryCode)
+		cancel()
+		if err != nil {
+			return report, err
+		}
+		report.Updated++
+	}
+
+	return report, nil
+}
@@ -2,16 +2,19 @@ package services
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/chesireabel/Technical-Interview/internal/repositories"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
 func TestCreateCustomer(t *testing.T) {
 	mockRepo := new(MockCustomerRepo)
-	service := NewCustomerService(mockRepo)
+	service := NewCustomerService(mockRepo, nil)
 
 	// Success case
 	customer := &models.Customer{
@@ -20,12 +23,14 @@ func TestCreateCustomer(t *testing.T) {
 		Password:      "12345",
 		Phone:         "+254712345678",
 	}
+	mockRepo.On("NextCodeSequence", mock.Anything).Return(int64(42), nil)
 mockRepo.On("Create", mock.Anything, customer).Return(int64(1), nil)
 
 	id, err := service.CreateCustomer(context.Background(), customer)
 
 	assert.NoError(t, err)
 	assert.Equal(t, int64(1), id)
+	assert.Equal(t, "CUS-000042-2", customer.Code)
 	mockRepo.AssertExpectations(t)
 
 	// Failure: missing fields
@@ -35,9 +40,148 @@ mockRepo.On("Create", mock.Anything, customer).Return(int64(1), nil)
 	assert.Equal(t, "all fields  are required", err.Error())
 }
 
+func TestCreateCustomer_ProvidedCode(t *testing.T) {
+	mockRepo := new(MockCustomerRepo)
+	service := NewCustomerService(mockRepo, nil)
+
+	customer := &models.Customer{
+		Customer_name: "Wanjiku",
+		Email:         " wanjiku@example.com ",
+		Password:      "12345",
+		Phone:         "+254712345678",
+		Code:          "VIP-1",
+	}
+	mockRepo.On("Create", mock.Anything, customer).Return(int64(2), nil)
+
+	id, err := service.CreateCustomer(context.Background(), customer)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), id)
+	assert.Equal(t, "wanjiku@example.com", customer.Email)
+	assert.Equal(t, "VIP-1", customer.Code)
+	mockRepo.AssertNotCalled(t, "NextCodeSequence", mock.Anything)
+}
+
+func TestCreateCustomer_GeneratedCodeCollision(t *testing.T) {
+	mockRepo := new(MockCustomerRepo)
+	service := NewCustomerService(mockRepo, nil)
+
+	customer := &models.Customer{
+		Customer_name: "Otieno",
+		Email:         "otieno@example.com",
+		Password:      "12345",
+		Phone:         "+254712345678",
+	}
+	mockRepo.On("NextCodeSequence", mock.Anything).Return(int64(7), nil).Once()
+	mockRepo.On("NextCodeSequence", mock.Anything).Return(int64(8), nil).Once()
+	mockRepo.On("Create", mock.Anything, customer).Return(int64(0), &models.ConflictError{Field: "code"}).Once()
+	mockRepo.On("Create", mock.Anything, customer).Return(int64(3), nil).Once()
+
+	id, err := service.CreateCustomer(context.Background(), customer)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), id)
+	assert.Equal(t, "CUS-000008-3", customer.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateCustomer_EmailConflict(t *testing.T) {
+	mockRepo := new(MockCustomerRepo)
+	service := NewCustomerService(mockRepo, nil)
+
+	customer := &models.Customer{
+		Customer_name: "Achieng",
+		Email:         "achieng@example.com",
+		Password:      "12345",
+		Phone:         "+254712345678",
+		Code:          "ACH-1",
+	}
+	mockRepo.On("Create", mock.Anything, customer).Return(int64(0), &models.ConflictError{Field: "email"})
+
+	_, err := service.CreateCustomer(context.Background(), customer)
+
+	assert.ErrorIs(t, err, models.ErrConflict)
+	assert.Equal(t, "email already exists", err.Error())
+}
+
+func TestCreateCustomer_NormalisesPhone(t *testing.T) {
+	mockRepo := new(MockCustomerRepo)
+	service := NewCustomerService(mockRepo, nil)
+
+	customer := &models.Customer{
+		Customer_name: "Kamau",
+		Email:         "kamau@example.com",
+		Password:      "12345",
+		Phone:         "0712 345 678",
+		Code:          "KAM-1",
+	}
+	mockRepo.On("Create", mock.Anything, customer).Return(int64(4), nil)
+
+	_, err := service.CreateCustomer(context.Background(), customer)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "+254712345678", customer.Phone)
+	assert.Equal(t, "KE", customer.CountryCode)
+
+	// Failure: invalid phone never reaches the repository
+	bad := &models.Customer{
+		Customer_name: "Kamau",
+		Email:         "kamau2@example.com",
+		Password:      "12345",
+		Phone:         "12345",
+	}
+	_, err = service.CreateCustomer(context.Background(), bad)
+	assert.Error(t, err)
+	mockRepo.AssertNumberOfCalls(t, "Create", 1)
+}
+
+func TestBackfillPhones(t *testing.T) {
+	mockRepo := new(MockCustomerRepo)
+	service := NewCustomerService(mockRepo, nil)
+
+	mockRepo.On("GetAll", mock.Anything, true).Return([]models.Customer{
+		{ID: 1, Phone: "0712345678"},
+		{ID: 2, Phone: "+254722000000", CountryCode: "KE"},
+		{ID: 3, Phone: "not a phone"},
+		{ID: 4, Phone: ""},
+	}, nil)
+	mockRepo.On("UpdatePhone", mock.Anything, int64(1), "+254712345678", "KE").Return(nil)
+
+	report, err := service.BackfillPhones(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 4, report.Scanned)
+	assert.Equal(t, 1, report.Updated)
+	assert.Equal(t, 1, report.Unchanged)
+	assert.Len(t, report.Failed, 2)
+	assert.Equal(t, int64(3), report.Failed[0].CustomerID)
+	assert.Equal(t, int64(4), report.Failed[1].CustomerID)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUpdateCustomer_Phone(t *testing.T) {
+	mockRepo := new(MockCustomerRepo)
+	service := NewCustomerService(mockRepo, nil)
+
+	// A blank phone is passed on empty so the stored number is kept
+	mockRepo.On("Update", mock.Anything, mock.MatchedBy(func(c *models.Customer) bool {
+		return c.ID == 1 && c.Phone == "" && c.CountryCode == ""
+	})).Return(nil).Once()
+	err := service.UpdateCustomer(context.Background(), &models.Customer{ID: 1, Customer_name: "Jane", Email: "jane@example.com", Phone: "  "})
+	assert.NoError(t, err)
+
+	mockRepo.On("Update", mock.Anything, mock.MatchedBy(func(c *models.Customer) bool {
+		return c.ID == 1 && c.Phone == "+254712345678" && c.CountryCode == "KE"
+	})).Return(nil).Once()
+	err = service.UpdateCustomer(context.Background(), &models.Customer{ID: 1, Customer_name: "Jane", Email: "jane@example.com", Phone: "0712 345 678"})
+	assert.NoError(t, err)
+
+	mockRepo.AssertExpectations(t)
+}
+
 func TestGetCustomer(t *testing.T) {
 	mockRepo := new(MockCustomerRepo)
-	service := NewCustomerService(mockRepo)
+	service := NewCustomerService(mockRepo, nil)
 
 	// Success case
 	expected := &models.Customer{
@@ -57,3 +201,114 @@ func TestGetCustomer(t *testing.T) {
 	assert.Error(t, err)
 	assert.Equal(t, "id is required", err.Error())
 }
+
+func TestRestoreCustomer(t *testing.T) {
+	mockRepo := new(MockCustomerRepo)
+	mockOrderRepo := new(MockOrderRepo)
+	mockInventoryRepo := new(MockInventoryRepo)
+	tx := &FakeTxManager{Repos: repositories.Repositories{Customers: mockRepo, Orders: mockOrderRepo, Inventory: mockInventoryRepo}}
+	service := NewCustomerService(mockRepo, tx)
+
+	shoesID := int64(3)
+	mockRepo.On("Restore", mock.Anything, int64(1)).Return(nil)
+	mockOrderRepo.On("GetByCustomerID", mock.Anything, int64(1), false).Return([]models.Order{
+		{ID: 7, Status: models.OrderStatusPending, Items: []models.OrderItem{{ProductID: &shoesID, Quantity: 2}}},
+		{ID: 8, Status: models.OrderStatusDelivered, Items: []models.OrderItem{{ProductID: &shoesID, Quantity: 1}}},
+	}, nil)
+	// Only the order still waiting to ship takes its stock back
+	mockInventoryRepo.On("Reserve", mock.Anything, int64(7), map[int64]int{3: 2}, "MAIN", "order:7").Return(nil)
+
+	err := service.RestoreCustomer(context.Background(), 1)
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+	mockInventoryRepo.AssertExpectations(t)
+
+	// Failure: invalid ID
+	err = service.RestoreCustomer(context.Background(), 0)
+	assert.Error(t, err)
+	assert.Equal(t, "id is required for restore", err.Error())
+	// Failure: built without transactions, as the CLI does
+	err = NewCustomerService(mockRepo, nil).RestoreCustomer(context.Background(), 1)
+	assert.EqualError(t, err, "transactions are not available")
+}
+
+func TestDeleteCustomer_ReleasesOrderStock(t *testing.T) {
+	mockRepo := new(MockCustomerRepo)
+	mockOrderRepo := new(MockOrderRepo)
+	mockInventoryRepo := new(MockInventoryRepo)
+	tx := &FakeTxManager{Repos: repositories.Repositories{Customers: mockRepo, Orders: mockOrderRepo, Inventory: mockInventoryRepo}}
+	service := NewCustomerService(mockRepo, tx)
+
+	mockOrderRepo.On("GetByCustomerID", mock.Anything, int64(1), false).Return([]models.Order{{ID: 7}, {ID: 8}}, nil)
+	mockRepo.On("Delete", mock.Anything, int64(1)).Return(nil)
+	mockInventoryRepo.On("Release", mock.Anything, int64(7), "order:7").Return(nil)
+	mockInventoryRepo.On("Release", mock.Anything, int64(8), "order:8").Return(nil)
+
+	err := service.DeleteCustomer(context.Background(), 1)
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+	mockInventoryRepo.AssertExpectations(t)
+}
+
+func TestCreateCustomerWithOrder(t *testing.T) {
+	mockRepo := new(MockCustomerRepo)
+	mockOrderRepo := new(MockOrderRepo)
+	tx := &FakeTxManager{Repos: repositories.Repositories{Customers: mockRepo, Orders: mockOrderRepo, TaxRates: noTaxRates()}}
+	service := NewCustomerService(mockRepo, tx)
+
+	customer := &models.Customer{Customer_name: "Akinyi", Email: "akinyi@example.com", Password: "12345", Phone: "0712345678"}
+	order := &models.Order{Item: "Laptop", Amount: decimal.NewFromInt(1000)}
+
+	mockRepo.On("NextCodeSequence", mock.Anything).Return(int64(7), nil).Once()
+	mockRepo.On("NextCodeSequence", mock.Anything).Return(int64(8), nil).Once()
+	// The first generated code collides, the whole unit of work is retried
+	mockRepo.On("Create", mock.Anything, customer).Return(int64(0), &models.ConflictError{Field: "code"}).Once()
+	mockRepo.On("Create", mock.Anything, customer).Return(int64(11), nil).Once()
+	mockOrderRepo.On("Create", mock.Anything, order).Return(int64(21), nil).Once()
+
+	customerID, orderID, err := service.CreateCustomerWithOrder(context.Background(), customer, order)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(11), customerID)
+	assert.Equal(t, int64(21), orderID)
+	assert.Equal(t, 2, tx.Calls)
+	assert.Equal(t, "CUS-000008-3", customer.Code)
+	assert.Equal(t, "11", order.CustomerID)
+	assert.Equal(t, models.OrderStatusPending, order.Status)
+	assert.Equal(t, "KES", order.Currency)
+	mockRepo.AssertExpectations(t)
+	mockOrderRepo.AssertExpectations(t)
+}
+
+func TestCreateCustomerWithOrder_OrderFails(t *testing.T) {
+	mockRepo := new(MockCustomerRepo)
+	mockOrderRepo := new(MockOrderRepo)
+	tx := &FakeTxManager{Repos: repositories.Repositories{Customers: mockRepo, Orders: mockOrderRepo, TaxRates: noTaxRates()}}
+	service := NewCustomerService(mockRepo, tx)
+
+	customer := &models.Customer{Customer_name: "Akinyi", Email: "akinyi@example.com", Password: "12345", Phone: "0712345678", Code: "VIP-2"}
+	order := &models.Order{Item: "Laptop", Amount: decimal.NewFromInt(1000)}
+
+	mockRepo.On("Create", mock.Anything, customer).Return(int64(11), nil)
+	mockOrderRepo.On("Create", mock.Anything, order).Return(int64(0), errors.New("failed to create order: connection reset"))
+
+	_, _, err := service.CreateCustomerWithOrder(context.Background(), customer, order)
+
+	assert.EqualError(t, err, "failed to create order: connection reset")
+	assert.Equal(t, int64(0), customer.ID)
+}
+
+func TestCreateCustomerWithOrder_InvalidOrder(t *testing.T) {
+	mockRepo := new(MockCustomerRepo)
+	tx := &FakeTxManager{Repos: repositories.Repositories{Customers: mockRepo}}
+	service := NewCustomerService(mockRepo, tx)
+
+	customer := &models.Customer{Customer_name: "Akinyi", Email: "akinyi@example.com", Password: "12345", Phone: "0712345678", Code: "VIP-3"}
+
+	_, _, err := service.CreateCustomerWithOrder(context.Background(), customer, &models.Order{Item: "Laptop"})
+
+	assert.EqualError(t, err, "amount must be greater than 0")
+	mockRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
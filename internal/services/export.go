@@ -0,0 +1,197 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+)
+
+// exportTimeout bounds a single export; exports stream so they may run much longer than list calls
+const exportTimeout = 5 * time.Minute
+
+// exportFlushEvery controls how many rows are buffered before flushing to the client
+const exportFlushEvery = 100
+
+// ExportFormat is the encoding used by the export endpoints
+type ExportFormat string
+
+const (
+	ExportCSV    ExportFormat = "csv"
+	ExportNDJSON ExportFormat = "ndjson"
+)
+
+// ParseExportFormat accepts csv (the default), ndjson or jsonl
+func ParseExportFormat(raw string) (ExportFormat, error) {
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case "", "csv":
+		return ExportCSV, nil
+	case "ndjson", "jsonl":
+		return ExportNDJSON, nil
+	default:
+		return "", fmt.Errorf("unsupported export format %q, use csv or ndjson", raw)
+	}
+}
+
+func (f ExportFormat) ContentType() string {
+	if f == ExportNDJSON {
+		return "application/x-ndjson"
+	}
+	return "text/csv"
+}
+
+func (f ExportFormat) Extension() string {
+	if f == ExportNDJSON {
+		return "ndjson"
+	}
+	return "csv"
+}
+
+var customerExportHeader = []string{"id", "customer_name", "email", "phone", "country_code", "code", "created_at", "deleted_at"}
+
+var orderExportHeader = []string{"id", "customer_id", "item", "amount", "currency", "status", "ordered_at", "created_at", "deleted_at", "subtotal", "discount", "promo_code", "tax", "scheduled_for"}
+
+// exportWriter encodes rows one at a time and periodically flushes them so
+// large exports reach the client while the database cursor is still open
+type exportWriter struct {
+	w      io.Writer
+	format ExportFormat
+	csv    *csv.Writer
+	json   *json.Encoder
+	rows   int
+}
+
+func newExportWriter(w io.Writer, format ExportFormat, header []string) (*exportWriter, error) {
+	ew := &exportWriter{w: w, format: format}
+	if format == ExportNDJSON {
+		ew.json = json.NewEncoder(w)
+		return ew, nil
+	}
+
+	ew.csv = csv.NewWriter(w)
+	if err := ew.csv.Write(header); err != nil {
+		return nil, err
+	}
+	return ew, nil
+}
+
+// write emits record for CSV exports and value for NDJSON exports
+func (ew *exportWriter) write(record []string, value any) error {
+	var err error
+	if ew.format == ExportNDJSON {
+		err = ew.json.Encode(value)
+	} else {
+		err = ew.csv.Write(escapeCSVFormulas(record))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write export row: %w", err)
+	}
+
+	ew.rows++
+	if ew.rows%exportFlushEvery == 0 {
+		return ew.flush()
+	}
+	return nil
+}
+
+func (ew *exportWriter) flush() error {
+	if ew.csv != nil {
+		ew.csv.Flush()
+		if err := ew.csv.Error(); err != nil {
+			return err
+		}
+	}
+	if f, ok := ew.w.(interface{ Flush() }); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// escapeCSVFormulas prefixes cells that a spreadsheet would run as a formula
+// with a quote, so customer-supplied text cannot inject one into an export
+func escapeCSVFormulas(record []string) []string {
+	escaped := make([]string, len(record))
+	for i, cell := range record {
+		if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+			cell = "'" + cell
+		}
+		escaped[i] = cell
+	}
+	return escaped
+}
+
+func formatExportTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func (s *customerService) ExportCustomers(ctx context.Context, w io.Writer, format ExportFormat, includeDeleted bool) error {
+	ew, err := newExportWriter(w, format, customerExportHeader)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, exportTimeout)
+	defer cancel()
+
+	err = s.repo.Stream(ctx, includeDeleted, func(c *models.Customer) error {
+		c.Password = ""
+		return ew.write([]string{
+			strconv.FormatInt(c.ID, 10),
+			c.Customer_name,
+			c.Email,
+			c.Phone,
+			c.CountryCode,
+			c.Code,
+			formatExportTime(&c.CreatedAt),
+			formatExportTime(c.DeletedAt),
+		}, c)
+	})
+	if err != nil {
+		return err
+	}
+
+	return ew.flush()
+}
+
+func (s *orderService) ExportOrders(ctx context.Context, w io.Writer, format ExportFormat, customerID int64, includeDeleted bool) error {
+	ew, err := newExportWriter(w, format, orderExportHeader)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, exportTimeout)
+	defer cancel()
+
+	err = s.repo.Stream(ctx, customerID, includeDeleted, func(o *models.Order) error {
+		return ew.write([]string{
+			strconv.FormatInt(o.ID, 10),
+			o.CustomerID,
+			o.Item,
+			o.Amount.String(),
+			o.Currency,
+			string(o.Status),
+			formatExportTime(&o.OrderedAt),
+			formatExportTime(&o.CreatedAt),
+			formatExportTime(o.DeletedAt),
+			o.Subtotal.String(),
+			o.Discount.String(),
+			o.PromoCode,
+			o.Tax.String(),
+			formatExportTime(o.ScheduledFor),
+		}, o)
+	})
+	if err != nil {
+		return err
+	}
+
+	return ew.flush()
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestParseExportFormat(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    ExportFormat
+		wantErr bool
+	}{
+		{"", ExportCSV, false},
+		{"CSV", ExportCSV, false},
+		{"ndjson", ExportNDJSON, false},
+		{"jsonl", ExportNDJSON, false},
+		{"xlsx", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseExportFormat(tt.raw)
+		if tt.wantErr {
+			assert.Error(t, err, tt.raw)
+			continue
+		}
+		assert.NoError(t, err, tt.raw)
+		assert.Equal(t, tt.want, got, tt.raw)
+	}
+}
+
+func TestExportCustomers_CSV(t *testing.T) {
+	mockRepo := new(MockCustomerRepo)
+	service := NewCustomerService(mockRepo, nil)
+
+	created := time.Date(2025, 9, 1, 8, 30, 0, 0, time.UTC)
+	mockRepo.On("Stream", mock.Anything, false, mock.Anything).Run(func(args mock.Arguments) {
+		fn := args.Get(2).(func(*models.Customer) error)
+		fn(&models.Customer{ID: 1, Customer_name: "Jane, W.", Email: "jane@example.com", Password: "secret", Phone: "+254712345678", CountryCode: "KE", Code: "CUS-000001-8", CreatedAt: created})
+	}).Return(nil)
+
+	var out bytes.Buffer
+	err := service.ExportCustomers(context.Background(), &out, ExportCSV, false)
+
+	assert.NoError(t, err)
+	assert.Equal(t,
+		"id,customer_name,email,phone,country_code,code,created_at,deleted_at\n"+
+			"1,\"Jane, W.\",jane@example.com,'+254712345678,KE,CUS-000001-8,2025-09-01T08:30:00Z,\n",
+		out.String())
+	assert.NotContains(t, out.String(), "secret")
+}
+
+func TestExportCustomers_EscapesFormulas(t *testing.T) {
+	mockRepo := new(MockCustomerRepo)
+	service := NewCustomerService(mockRepo, nil)
+
+	mockRepo.On("Stream", mock.Anything, false, mock.Anything).Run(func(args mock.Arguments) {
+		fn := args.Get(2).(func(*models.Customer) error)
+		fn(&models.Customer{ID: 1, Customer_name: "=HYPERLINK(\"http://evil\")", Email: "@sum@example.com", Code: "-1+2"})
+	}).Return(nil)
+
+	var out bytes.Buffer
+	err := service.ExportCustomers(context.Background(), &out, ExportCSV, false)
+
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Equal(t, `1,"'=HYPERLINK(""http://evil"")",'@sum@example.com,,,'-1+2,0001-01-01T00:00:00Z,`, lines[1])
+}
+
+func TestExportOrders_NDJSON(t *testing.T) {
+	mockOrderRepo := new(MockOrderRepo)
+	service := NewOrderService(mockOrderRepo, nil, nil, nil)
+
+	mockOrderRepo.On("Stream", mock.Anything, int64(7), true, mock.Anything).Run(func(args mock.Arguments) {
+		fn := args.Get(3).(func(*models.Order) error)
+		for i := int64(1); i <= 3; i++ {
+			fn(&models.Order{ID: i, CustomerID: "7", Item: "Book", Amount: decimal.NewFromInt(250), Currency: "KES",
+				Items: []models.OrderItem{{ID: i, OrderID: i, Description: "Book", Quantity: 1, UnitPrice: decimal.NewFromInt(250)}}})
+		}
+	}).Return(nil)
+
+	var out bytes.Buffer
+	err := service.ExportOrders(context.Background(), &out, ExportNDJSON, 7, true)
+
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 3)
+	assert.True(t, strings.HasPrefix(lines[0], `{"id":1,"customer_id":"7","item":"Book","amount":"250","currency":"KES"`))
+	assert.Contains(t, lines[0], `"items":[{"id":1,"order_id":1,"description":"Book","quantity":1,"unit_price":"250"`)
+	mockOrderRepo.AssertExpectations(t)
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/chesireabel/Technical-Interview/internal/repositories"
+)
+
+const (
+	defaultIdempotencyTTL = 24 * time.Hour
+	// defaultIdempotencyLease comfortably outlasts the slowest request, so a
+	// key still in progress after it belongs to a request that died
+	defaultIdempotencyLease = 2 * time.Minute
+	maxIdempotencyKeyLen  = 255
+)
+
+// IdempotencyService lets clients safely retry non-idempotent requests by
+// sending the same Idempotency-Key header. The first request with a key is
+// executed and its response stored; identical retries get that response back.
+type IdempotencyService interface {
+	// Begin reserves key for a new request. It returns the stored record when
+	// the request has already completed and its response should be replayed.
+	Begin(ctx context.Context, scope, key string, request []byte) (*models.IdempotencyRecord, error)
+	Complete(ctx context.Context, scope, key string, statusCode int, body []byte) error
+	Release(ctx context.Context, scope, key string) error
+	Run(ctx context.Context)
+}
+
+type idempotencyService struct {
+	repo  repositories.IdempotencyRepository
+	ttl   time.Duration
+	lease time.Duration
+	now   func() time.Time
+}
+
+// NewIdempotencyService reads IDEMPOTENCY_KEY_TTL (a Go duration, default 24h),
+// the window during which a key can be replayed, and IDEMPOTENCY_KEY_LEASE
+// (default 2m), after which a request that never finished no longer holds its key
+func NewIdempotencyService(repo repositories.IdempotencyRepository) (IdempotencyService, error) {
+	ttl := defaultIdempotencyTTL
+	if v := os.Getenv("IDEMPOTENCY_KEY_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("IDEMPOTENCY_KEY_TTL must be a positive duration, got %q", v)
+		}
+		ttl = d
+	}
+	lease, err := positiveDurationEnv("IDEMPOTENCY_KEY_LEASE", defaultIdempotencyLease)
+	if err != nil {
+		return nil, err
+	}
+
+	return &idempotencyService{repo: repo, ttl: ttl, lease: lease, now: time.Now}, nil
+}
+
+// hashRequest fingerprints a request so a reused key with a different body can be detected
+func hashRequest(request []byte) string {
+	sum := sha256.Sum256(request)
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *idempotencyService) Begin(ctx context.Context, scope, key string, request []byte) (*models.IdempotencyRecord, error) {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return nil, errors.New("idempotency key is required")
+	}
+	if len(key) > maxIdempotencyKeyLen {
+		return nil, fmt.Errorf("idempotency key must be at most %d characters", maxIdempotencyKeyLen)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	record := &models.IdempotencyRecord{
+		Scope:       scope,
+		Key:         key,
+		RequestHash: hashRequest(request),
+		ExpiresAt:   s.now().Add(s.ttl),
+	}
+
+	reserved, err := s.repo.Reserve(ctx, record, s.now().Add(-s.lease))
+	if err != nil {
+		return nil, err
+	}
+	if reserved {
+		return nil, nil
+	}
+
+	existing, err := s.repo.Get(ctx, scope, key)
+	if errors.Is(err, models.ErrNotFound) {
+		// The holder released the key between our insert and read
+		return nil, models.ErrIdempotencyInProgress
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if existing.RequestHash != record.RequestHash {
+		return nil, models.ErrIdempotencyKeyReused
+	}
+	if !existing.Completed() {
+		return nil, models.ErrIdempotencyInProgress
+	}
+
+	return existing, nil
+}
+
+func (s *idempotencyService) Complete(ctx context.Context, scope, key string, statusCode int, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return s.repo.Complete(ctx, scope, strings.TrimSpace(key), statusCode, body)
+}
+
+func (s *idempotencyService) Release(ctx context.Context, scope, key string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return s.repo.Release(ctx, scope, strings.TrimSpace(key))
+}
+
+// Run deletes expired keys every hour until ctx is cancelled. Expired keys are
+// already ignored by Begin; this only keeps the table small.
+func (s *idempotencyService) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		deleteCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		if n, err := s.repo.DeleteExpired(deleteCtx, s.now()); err != nil {
+			log.Printf("⚠️ Cleanup of expired idempotency keys failed: %v", err)
+		} else if n > 0 {
+			log.Printf("🧹 Removed %d expired idempotency key(s)", n)
+		}
+		cancel()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const testScope = "POST /orders jane@example.com"
+
+func newTestIdempotencyService(repo *MockIdempotencyRepo, now time.Time) *idempotencyService {
+	return &idempotencyService{repo: repo, ttl: time.Hour, lease: time.Minute, now: func() time.Time { return now }}
+}
+
+func TestIdempotencyBegin_NewKey(t *testing.T) {
+	mockRepo := new(MockIdempotencyRepo)
+	now := time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)
+	svc := newTestIdempotencyService(mockRepo, now)
+
+	mockRepo.On("Reserve", mock.Anything, mock.MatchedBy(func(r *models.IdempotencyRecord) bool {
+		return r.Scope == testScope && r.Key == "abc" &&
+			r.RequestHash == hashRequest([]byte(`{"item":"Book"}`)) &&
+			r.ExpiresAt.Equal(now.Add(time.Hour))
+	}), now.Add(-time.Minute)).Return(true, nil)
+
+	stored, err := svc.Begin(context.Background(), testScope, " abc ", []byte(`{"item":"Book"}`))
+
+	assert.NoError(t, err)
+	assert.Nil(t, stored)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestIdempotencyBegin_ReplaysCompletedRequest(t *testing.T) {
+	mockRepo := new(MockIdempotencyRepo)
+	svc := newTestIdempotencyService(mockRepo, time.Now())
+	body := []byte(`{"item":"Book"}`)
+
+	original := &models.IdempotencyRecord{
+		Scope:        testScope,
+		Key:          "abc",
+		RequestHash:  hashRequest(body),
+		StatusCode:   201,
+		ResponseBody: []byte(`{"id":7}`),
+	}
+	mockRepo.On("Reserve", mock.Anything, mock.Anything, mock.Anything).Return(false, nil)
+	mockRepo.On("Get", mock.Anything, testScope, "abc").Return(original, nil)
+
+	stored, err := svc.Begin(context.Background(), testScope, "abc", body)
+
+	assert.NoError(t, err)
+	assert.Equal(t, original, stored)
+}
+
+func TestIdempotencyBegin_DifferentBody(t *testing.T) {
+	mockRepo := new(MockIdempotencyRepo)
+	svc := newTestIdempotencyService(mockRepo, time.Now())
+
+	mockRepo.On("Reserve", mock.Anything, mock.Anything, mock.Anything).Return(false, nil)
+	mockRepo.On("Get", mock.Anything, testScope, "abc").Return(&models.IdempotencyRecord{
+		RequestHash: hashRequest([]byte(`{"item":"Book"}`)),
+		StatusCode:  201,
+	}, nil)
+
+	stored, err := svc.Begin(context.Background(), testScope, "abc", []byte(`{"item":"Pen"}`))
+
+	assert.ErrorIs(t, err, models.ErrIdempotencyKeyReused)
+	assert.Nil(t, stored)
+}
+
+func TestIdempotencyBegin_InProgress(t *testing.T) {
+	mockRepo := new(MockIdempotencyRepo)
+	svc := newTestIdempotencyService(mockRepo, time.Now())
+	body := []byte(`{"item":"Book"}`)
+
+	mockRepo.On("Reserve", mock.Anything, mock.Anything, mock.Anything).Return(false, nil)
+	mockRepo.On("Get", mock.Anything, testScope, "abc").Return(&models.IdempotencyRecord{RequestHash: hashRequest(body)}, nil)
+
+	_, err := svc.Begin(context.Background(), testScope, "abc", body)
+
+	assert.ErrorIs(t, err, models.ErrIdempotencyInProgress)
+}
+
+func TestIdempotencyBegin_InvalidKey(t *testing.T) {
+	svc := newTestIdempotencyService(new(MockIdempotencyRepo), time.Now())
+
+	_, err := svc.Begin(context.Background(), testScope, "  ", nil)
+	assert.EqualError(t, err, "idempotency key is required")
+
+	_, err = svc.Begin(context.Background(), testScope, strings.Repeat("k", 256), nil)
+	assert.EqualError(t, err, "idempotency key must be at most 255 characters")
+}
+
+func TestNewIdempotencyService_TTL(t *testing.T) {
+	t.Setenv("IDEMPOTENCY_KEY_TTL", "2h")
+	svc, err := NewIdempotencyService(new(MockIdempotencyRepo))
+	assert.NoError(t, err)
+	assert.Equal(t, 2*time.Hour, svc.(*idempotencyService).ttl)
+
+	t.Setenv("IDEMPOTENCY_KEY_TTL", "soon")
+	_, err = NewIdempotencyService(new(MockIdempotencyRepo))
+	assert.Error(t, err)
+}
+
+func TestNewIdempotencyService_Lease(t *testing.T) {
+	svc, err := NewIdempotencyService(new(MockIdempotencyRepo))
+	assert.NoError(t, err)
+	assert.Equal(t, defaultIdempotencyLease, svc.(*idempotencyService).lease)
+
+	t.Setenv("IDEMPOTENCY_KEY_LEASE", "30s")
+	svc, err = NewIdempotencyService(new(MockIdempotencyRepo))
+	assert.NoError(t, err)
+	assert.Equal(t, 30*time.Second, svc.(*idempotencyService).lease)
+}
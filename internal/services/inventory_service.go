@@ -0,0 +1,164 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/chesireabel/Technical-Interview/internal/repositories"
+)
+
+const (
+	defaultStockLocation = "MAIN"
+	// stockMovementsShown is how much of the ledger GetStock returns
+	stockMovementsShown = 50
+)
+
+// InventoryService manages stock levels for the warehouse team. Reservations
+// are made by the order service inside the order's transaction.
+type InventoryService interface {
+	GetStock(ctx context.Context, productID int64) (*models.ProductStock, error)
+	AdjustStock(ctx context.Context, movement *models.StockMovement) error
+}
+
+type inventoryService struct {
+	repo     repositories.InventoryRepository
+	products repositories.ProductRepository
+	location string
+}
+
+func NewInventoryService(repo repositories.InventoryRepository, products repositories.ProductRepository) InventoryService {
+	return &inventoryService{
+		repo:     repo,
+		products: products,
+		location: stockDefaultLocation(),
+	}
+}
+
+// stockDefaultLocation reads STOCK_DEFAULT_LOCATION, the location orders reserve from first (default MAIN)
+func stockDefaultLocation() string {
+	location := normaliseLocation(os.Getenv("STOCK_DEFAULT_LOCATION"))
+	if location == "" {
+		return defaultStockLocation
+	}
+	return location
+}
+
+func normaliseLocation(location string) string {
+	return strings.ToUpper(strings.TrimSpace(location))
+}
+
+// stockQuantities totals the quantity ordered per catalogue product. Lines
+// without a product are not stocked.
+func stockQuantities(order *models.Order) map[int64]int {
+	quantities := make(map[int64]int)
+	for _, item := range order.Items {
+		if item.ProductID != nil {
+			quantities[*item.ProductID] += item.Quantity
+		}
+	}
+	return quantities
+}
+
+// holdsStock reports whether an order in this status has stock reserved for it
+func holdsStock(status models.OrderStatus) bool {
+	switch status {
+	case models.OrderStatusScheduled, models.OrderStatusPending, models.OrderStatusPaid, models.OrderStatusConfirmed:
+		return true
+	}
+	return false
+}
+
+// reserveStock reserves the catalogue products on a stored order
+func reserveStock(ctx context.Context, inventory repositories.InventoryRepository, order *models.Order, location string) error {
+	quantities := stockQuantities(order)
+	if len(quantities) == 0 {
+		return nil
+	}
+	return inventory.Reserve(ctx, order.ID, quantities, location, fmt.Sprintf("order:%d", order.ID))
+}
+
+func (s *inventoryService) GetStock(ctx context.Context, productID int64) (*models.ProductStock, error) {
+	if productID == 0 {
+		return nil, errors.New("product id is required")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if _, err := s.products.GetByID(ctx, productID); err != nil {
+		return nil, err
+	}
+
+	levels, err := s.repo.GetLevels(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	movements, err := s.repo.GetMovements(ctx, productID, stockMovementsShown)
+	if err != nil {
+		return nil, err
+	}
+
+	stock := &models.ProductStock{
+		ProductID: productID,
+		Tracked:   len(levels) > 0,
+		Levels:    levels,
+		Movements: movements,
+	}
+	for _, l := range levels {
+		stock.OnHand += l.OnHand
+		stock.Reserved += l.Reserved
+	}
+	stock.Available = stock.OnHand - stock.Reserved
+
+	return stock, nil
+}
+
+// AdjustStock records a goods receipt (positive quantity) or a manual
+// correction such as a stock count or breakage (any non-zero quantity)
+func (s *inventoryService) AdjustStock(ctx context.Context, movement *models.StockMovement) error {
+	if movement.ProductID == 0 {
+		return errors.New("product id is required")
+	}
+	if movement.CreatedBy == "" {
+		return errors.New("actor is required")
+	}
+
+	movement.Location = normaliseLocation(movement.Location)
+	if movement.Location == "" {
+		movement.Location = s.location
+	}
+	movement.Note = strings.TrimSpace(movement.Note)
+	movement.OrderID = nil
+
+	switch movement.Kind {
+	case "", models.StockReceipt:
+		movement.Kind = models.StockReceipt
+		if movement.Quantity <= 0 {
+			return errors.New("quantity must be greater than 0 for a receipt")
+		}
+	case models.StockAdjustment:
+		if movement.Quantity == 0 {
+			return errors.New("quantity must not be 0")
+		}
+		if movement.Note == "" {
+			return errors.New("note is required for an adjustment")
+		}
+	default:
+		return fmt.Errorf("kind must be %s or %s", models.StockReceipt, models.StockAdjustment)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if _, err := s.products.GetByID(ctx, movement.ProductID); err != nil {
+		return err
+	}
+
+	return s.repo.Adjust(ctx, movement)
+}
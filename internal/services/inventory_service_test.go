@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/chesireabel/Technical-Interview/internal/repositories"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGetStock(t *testing.T) {
+	mockRepo := new(MockInventoryRepo)
+	mockProductRepo := new(MockProductRepo)
+	service := NewInventoryService(mockRepo, mockProductRepo)
+
+	mockProductRepo.On("GetByID", mock.Anything, int64(3)).Return(&models.Product{ID: 3}, nil)
+	mockRepo.On("GetLevels", mock.Anything, int64(3)).Return([]models.StockLevel{
+		{ProductID: 3, Location: "MAIN", OnHand: 10, Reserved: 4},
+		{ProductID: 3, Location: "MOMBASA", OnHand: 5, Reserved: 0},
+	}, nil)
+	mockRepo.On("GetMovements", mock.Anything, int64(3), stockMovementsShown).Return([]models.StockMovement{}, nil)
+
+	stock, err := service.GetStock(context.Background(), 3)
+
+	assert.NoError(t, err)
+	assert.True(t, stock.Tracked)
+	assert.Equal(t, 15, stock.OnHand)
+	assert.Equal(t, 4, stock.Reserved)
+	assert.Equal(t, 11, stock.Available)
+}
+
+func TestAdjustStock(t *testing.T) {
+	mockRepo := new(MockInventoryRepo)
+	mockProductRepo := new(MockProductRepo)
+	service := NewInventoryService(mockRepo, mockProductRepo)
+
+	movement := &models.StockMovement{ProductID: 3, Quantity: 20, Location: " mombasa ", CreatedBy: "warehouse@example.com"}
+	mockProductRepo.On("GetByID", mock.Anything, int64(3)).Return(&models.Product{ID: 3}, nil)
+	mockRepo.On("Adjust", mock.Anything, movement).Return(nil)
+
+	err := service.AdjustStock(context.Background(), movement)
+
+	assert.NoError(t, err)
+	assert.Equal(t, models.StockReceipt, movement.Kind)
+	assert.Equal(t, "MOMBASA", movement.Location)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAdjustStock_Invalid(t *testing.T) {
+	service := NewInventoryService(new(MockInventoryRepo), new(MockProductRepo))
+
+	tests := []struct {
+		name     string
+		movement models.StockMovement
+		want     string
+	}{
+		{"negative receipt", models.StockMovement{Quantity: -1}, "quantity must be greater than 0 for a receipt"},
+		{"adjustment without note", models.StockMovement{Kind: models.StockAdjustment, Quantity: -2}, "note is required for an adjustment"},
+		{"reservation by hand", models.StockMovement{Kind: models.StockReservation, Quantity: 1}, "kind must be receipt or adjustment"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.movement.ProductID = 3
+			tt.movement.CreatedBy = "warehouse@example.com"
+			assert.EqualError(t, service.AdjustStock(context.Background(), &tt.movement), tt.want)
+		})
+	}
+}
+
+func TestCreateOrder_OutOfStock(t *testing.T) {
+	mockOrderRepo := new(MockOrderRepo)
+	mockCustomerRepo := new(MockCustomerRepo)
+	mockProductRepo := new(MockProductRepo)
+	mockInventoryRepo := new(MockInventoryRepo)
+	tx := &FakeTxManager{Repos: repositories.Repositories{Orders: mockOrderRepo, Inventory: mockInventoryRepo, TaxRates: noTaxRates()}}
+	service := NewOrderService(mockOrderRepo, mockCustomerRepo, mockProductRepo, tx)
+
+	productID := int64(3)
+	order := &models.Order{CustomerID: "1", Items: []models.OrderItem{{ProductID: &productID, Quantity: 5}}}
+
+	mockProductRepo.On("GetByIDs", mock.Anything, []int64{3}).Return(map[int64]*models.Product{
+		3: {ID: 3, SKU: "SHOE-42", Name: "Running Shoes", Price: decimal.NewFromInt(2500), Currency: "KES"},
+	}, nil)
+	mockCustomerRepo.On("GetByID", mock.Anything, int64(1)).Return(&models.Customer{ID: 1}, nil)
+	mockOrderRepo.On("Create", mock.Anything, order).Return(int64(9), nil)
+	mockInventoryRepo.On("Reserve", mock.Anything, int64(9), map[int64]int{3: 5}, "MAIN", "order:9").
+		Return(&models.StockError{ProductID: 3, Requested: 5, Available: 2})
+
+	id, err := service.CreateOrder(context.Background(), order)
+
+	assert.ErrorIs(t, err, models.ErrOutOfStock)
+	assert.Equal(t, int64(0), id)
+	assert.Equal(t, int64(0), order.ID)
+}
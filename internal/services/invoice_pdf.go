@@ -0,0 +1,213 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/chesireabel/Technical-Interview/config"
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/jung-kurt/gofpdf"
+)
+
+// invoiceDocument is everything printed on an invoice
+type invoiceDocument struct {
+	Company   config.Company
+	Reference string
+	Invoice   *models.Invoice
+	Order     *models.Order
+	Customer  *models.Customer
+}
+
+// Layout of the A4 page in millimetres
+const (
+	invoiceMargin     = 15.0
+	invoiceWidth      = 180.0
+	invoiceLineHeight = 6.0
+)
+
+// invoiceColumns are the line item table columns; the widths add up to invoiceWidth
+var invoiceColumns = []struct {
+	title string
+	width float64
+	align string
+}{
+	{"Description", 72, "L"},
+	{"Qty", 14, "R"},
+	{"Unit price", 26, "R"},
+	{"Discount", 22, "R"},
+	{"Tax", 20, "R"},
+	{"Amount", 26, "R"},
+}
+
+// renderInvoice writes the invoice as a PDF. The document dates come from
+// the invoice, so the same invoice always renders to the same bytes.
+func renderInvoice(w io.Writer, doc invoiceDocument) error {
+	currency, err := models.LookupCurrency(doc.Order.Currency)
+	if err != nil {
+		return err
+	}
+
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(invoiceMargin, invoiceMargin, invoiceMargin)
+	pdf.SetAutoPageBreak(true, invoiceMargin+10)
+	pdf.SetCreationDate(doc.Invoice.IssuedAt)
+	pdf.SetModificationDate(doc.Invoice.IssuedAt)
+	pdf.SetCatalogSort(true)
+	pdf.SetTitle("Invoice "+doc.Reference, true)
+	pdf.SetAuthor(doc.Company.Name, true)
+	pdf.AliasNbPages("")
+
+	// The core fonts are cp1252, so names like "Zoë" need translating
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-invoiceMargin - 5)
+		pdf.SetFont("Helvetica", "I", 8)
+		pdf.SetTextColor(110, 110, 110)
+		pdf.CellFormat(invoiceWidth/2, 5, tr(fmt.Sprintf("Invoice %s", doc.Reference)), "", 0, "L", false, 0, "")
+		pdf.CellFormat(invoiceWidth/2, 5, fmt.Sprintf("Page %d of {nb}", pdf.PageNo()), "", 0, "R", false, 0, "")
+	})
+	pdf.AddPage()
+
+	// Seller on the left, invoice details on the right
+	top := pdf.GetY()
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.CellFormat(110, 8, tr(doc.Company.Name), "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 9)
+	for _, line := range companyLines(doc.Company) {
+		pdf.CellFormat(110, 4.5, tr(line), "", 1, "L", false, 0, "")
+	}
+	bottom := pdf.GetY()
+
+	pdf.SetXY(invoiceMargin+110, top)
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.CellFormat(70, 8, "INVOICE", "", 2, "R", false, 0, "")
+	pdf.SetFont("Helvetica", "", 9)
+	for _, line := range []string{
+		"Invoice no: " + doc.Reference,
+		"Date: " + doc.Invoice.IssuedAt.Format("02 Jan 2006"),
+		"Order: #" + strconv.FormatInt(doc.Order.ID, 10),
+		"Order date: " + doc.Order.OrderedAt.Format("02 Jan 2006"),
+	} {
+		pdf.CellFormat(70, 4.5, tr(line), "", 2, "R", false, 0, "")
+	}
+	if pdf.GetY() > bottom {
+		bottom = pdf.GetY()
+	}
+
+	// Buyer
+	pdf.SetXY(invoiceMargin, bottom+8)
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.CellFormat(invoiceWidth, 5, "Bill to", "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 9)
+	for _, line := range customerLines(doc.Customer) {
+		pdf.CellFormat(invoiceWidth, 4.5, tr(line), "", 1, "L", false, 0, "")
+	}
+	pdf.Ln(6)
+
+	// Line items
+	pdf.SetFont("Helvetica", "B", 9)
+	pdf.SetFillColor(235, 235, 235)
+	for _, col := range invoiceColumns {
+		pdf.CellFormat(col.width, invoiceLineHeight+1, col.title, "B", 0, col.align, true, 0, "")
+	}
+	pdf.Ln(-1)
+
+	pdf.SetFont("Helvetica", "", 9)
+	for _, item := range doc.Order.Items {
+		cells := []string{
+			truncateToWidth(pdf, tr, item.Description, invoiceColumns[0].width-2),
+			strconv.Itoa(item.Quantity),
+			currency.FormatAmount(item.UnitPrice),
+			currency.FormatAmount(item.Discount),
+			currency.FormatAmount(item.Tax),
+			currency.FormatAmount(item.LineTotal),
+		}
+		for i, col := range invoiceColumns {
+			pdf.CellFormat(col.width, invoiceLineHeight, cells[i], "B", 0, col.align, false, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+	pdf.Ln(4)
+
+	// Totals
+	total := func(label, amount string, bold bool) {
+		style := ""
+		if bold {
+			style = "B"
+		}
+		pdf.SetFont("Helvetica", style, 9)
+		pdf.SetX(invoiceMargin + invoiceWidth - 90)
+		pdf.CellFormat(60, invoiceLineHeight, tr(label), "", 0, "R", false, 0, "")
+		pdf.CellFormat(30, invoiceLineHeight, amount, "", 1, "R", false, 0, "")
+	}
+
+	order := doc.Order
+	total("Subtotal", currency.FormatAmount(order.Subtotal), false)
+	if order.Discount.IsPositive() {
+		label := "Discount"
+		if order.PromoCode != "" {
+			label = fmt.Sprintf("Discount (%s)", order.PromoCode)
+		}
+		total(label, "-"+currency.FormatAmount(order.Discount), false)
+	}
+	for _, line := range models.TaxBreakdown(order.Items) {
+		if line.Name == "" {
+			continue
+		}
+		label := fmt.Sprintf("%s %s%% on %s", line.Name, line.Rate.String(), currency.FormatAmount(line.Net))
+		if line.Inclusive {
+			label += " (included)"
+		}
+		total(label, currency.FormatAmount(line.Tax), false)
+	}
+	total("Total "+currency.Code, currency.FormatAmount(order.Amount), true)
+
+	pdf.Ln(10)
+	pdf.SetFont("Helvetica", "", 9)
+	pdf.CellFormat(invoiceWidth, 5, "Thank you for your business.", "", 1, "L", false, 0, "")
+
+	return pdf.Output(w)
+}
+
+func companyLines(company config.Company) []string {
+	lines := append([]string{}, company.Address...)
+	if company.Phone != "" {
+		lines = append(lines, "Tel: "+company.Phone)
+	}
+	if company.Email != "" {
+		lines = append(lines, company.Email)
+	}
+	if company.TaxPIN != "" {
+		lines = append(lines, "PIN: "+company.TaxPIN)
+	}
+	return lines
+}
+
+func customerLines(customer *models.Customer) []string {
+	lines := []string{customer.Customer_name}
+	if customer.Code != "" {
+		lines = append(lines, "Customer no: "+customer.Code)
+	}
+	if customer.Email != "" {
+		lines = append(lines, customer.Email)
+	}
+	if customer.Phone != "" {
+		lines = append(lines, customer.Phone)
+	}
+	return lines
+}
+
+// truncateToWidth translates text for the PDF and shortens it when it would
+// overflow a table cell
+func truncateToWidth(pdf *gofpdf.Fpdf, tr func(string) string, text string, width float64) string {
+	if pdf.GetStringWidth(tr(text)) <= width {
+		return tr(text)
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && pdf.GetStringWidth(tr(string(runes)+"...")) > width {
+		runes = runes[:len(runes)-1]
+	}
+	return tr(string(runes) + "...")
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/chesireabel/Technical-Interview/config"
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/chesireabel/Technical-Interview/internal/repositories"
+)
+
+type InvoiceService interface {
+	// InvoicePDF renders the order's invoice, issuing it with the next invoice
+	// number the first time it is requested. It returns the invoice reference
+	// for the file name.
+	InvoicePDF(ctx context.Context, orderID int64) (string, []byte, error)
+}
+
+type invoiceService struct {
+	repo         repositories.InvoiceRepository
+	orderRepo    repositories.OrderRepository
+	customerRepo repositories.CustomerRepository
+	company      config.Company
+}
+
+func NewInvoiceService(repo repositories.InvoiceRepository, orderRepo repositories.OrderRepository, customerRepo repositories.CustomerRepository, company config.Company) InvoiceService {
+	return &invoiceService{
+		repo:         repo,
+		orderRepo:    orderRepo,
+		customerRepo: customerRepo,
+		company:      company,
+	}
+}
+
+// invoiceReference formats an invoice number for people, e.g. INV-000042
+func invoiceReference(prefix string, number int64) string {
+	return fmt.Sprintf("%s%06d", prefix, number)
+}
+
+func (s *invoiceService) InvoicePDF(ctx context.Context, orderID int64) (string, []byte, error) {
+	if orderID == 0 {
+		return "", nil, errors.New("order id is required")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	// An issued invoice is rendered from its own snapshot, so it needs
+	// neither the order nor the customer to still exist
+	invoice, err := s.repo.GetByOrderID(ctx, orderID)
+	if errors.Is(err, models.ErrNotFound) {
+		invoice, err = s.issue(ctx, orderID)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	doc := invoiceDocument{
+		Company:   s.company,
+		Reference: invoiceReference(s.company.InvoicePrefix, invoice.Number),
+		Invoice:   invoice,
+		Order:     &invoice.Order,
+		Customer:  &invoice.Customer,
+	}
+
+	var buf bytes.Buffer
+	if err := renderInvoice(&buf, doc); err != nil {
+		return "", nil, fmt.Errorf("failed to render invoice: %w", err)
+	}
+
+	return doc.Reference, buf.Bytes(), nil
+}
+
+// issue gives the order its invoice. An order cancelled after it was
+// invoiced keeps its invoice, but a cancelled order is never given a new number.
+func (s *invoiceService) issue(ctx context.Context, orderID int64) (*models.Invoice, error) {
+	order, err := s.orderRepo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if order.Status == models.OrderStatusCancelled {
+		return nil, models.ErrNotInvoiceable
+	}
+
+	customerID, err := strconv.ParseInt(order.CustomerID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("order %d has an invalid customer_id", orderID)
+	}
+	customer, err := s.customerRepo.GetByID(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only what the invoice prints is kept
+	return s.repo.Issue(ctx, order, &models.Customer{
+		ID:            customer.ID,
+		Customer_name: customer.Customer_name,
+		Email:         customer.Email,
+		Phone:         customer.Phone,
+		Code:          customer.Code,
+	})
+}
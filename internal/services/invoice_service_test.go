@@ -0,0 +1,162 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/chesireabel/Technical-Interview/config"
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Run `go test ./internal/services -run TestRenderInvoice -update` after an
+// intentional change to the invoice layout and check the new PDFs by eye
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+var testCompany = config.Company{
+	Name:          "Duka Supplies Ltd",
+	Address:       []string{"Moi Avenue 12", "P.O. Box 100-00100, Nairobi"},
+	Phone:         "+254 20 123 4567",
+	Email:         "billing@duka.example",
+	TaxPIN:        "P051234567Z",
+	InvoicePrefix: "INV-",
+}
+
+func TestRenderInvoice(t *testing.T) {
+	issued := time.Date(2026, 10, 20, 9, 30, 0, 0, time.UTC)
+	productID := int64(3)
+
+	tests := []struct {
+		name     string
+		golden   string
+		order    *models.Order
+		customer *models.Customer
+	}{
+		{
+			name:   "discount and inclusive VAT",
+			golden: "invoice_kes.golden.pdf",
+			order: &models.Order{
+				ID:         42,
+				CustomerID: "7",
+				Currency:   "KES",
+				Subtotal:   decimal.RequireFromString("5800"),
+				Discount:   decimal.RequireFromString("580"),
+				PromoCode:  "JAMHURI",
+				Tax:        decimal.RequireFromString("720"),
+				Amount:     decimal.RequireFromString("5220"),
+				OrderedAt:  time.Date(2026, 10, 19, 15, 4, 0, 0, time.UTC),
+				Items: []models.OrderItem{
+					{ProductID: &productID, ProductRef: "SHOE-42", Description: "Running Shoes", Quantity: 2, UnitPrice: decimal.RequireFromString("2320"), LineTotal: decimal.RequireFromString("4640"), Discount: decimal.RequireFromString("464"), TaxName: "VAT", TaxRate: decimal.RequireFromString("16"), TaxInclusive: true, Tax: decimal.RequireFromString("576")},
+					{Description: "Sports socks, cotton, assorted colours, pack of three pairs (large)", Quantity: 4, UnitPrice: decimal.RequireFromString("290"), LineTotal: decimal.RequireFromString("1160"), Discount: decimal.RequireFromString("116"), TaxName: "VAT", TaxRate: decimal.RequireFromString("16"), TaxInclusive: true, Tax: decimal.RequireFromString("144")},
+				},
+			},
+			customer: &models.Customer{ID: 7, Customer_name: "Zoë Wanjiru", Code: "CUS-000007-3", Email: "zoe@example.com", Phone: "+254712345678"},
+		},
+		{
+			name:   "exclusive tax without discount",
+			golden: "invoice_ugx.golden.pdf",
+			order: &models.Order{
+				ID:         43,
+				CustomerID: "8",
+				Currency:   "UGX",
+				Subtotal:   decimal.RequireFromString("150000"),
+				Tax:        decimal.RequireFromString("27000"),
+				Amount:     decimal.RequireFromString("177000"),
+				OrderedAt:  time.Date(2026, 10, 20, 8, 0, 0, 0, time.UTC),
+				Items: []models.OrderItem{
+					{Description: "Sugar 50kg", Quantity: 1, UnitPrice: decimal.RequireFromString("150000"), LineTotal: decimal.RequireFromString("150000"), TaxName: "VAT", TaxRate: decimal.RequireFromString("18"), Tax: decimal.RequireFromString("27000")},
+				},
+			},
+			customer: &models.Customer{ID: 8, Customer_name: "Okello Traders"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := renderInvoice(&out, invoiceDocument{
+				Company:   testCompany,
+				Reference: invoiceReference(testCompany.InvoicePrefix, tt.order.ID),
+				Invoice:   &models.Invoice{ID: 1, Number: tt.order.ID, OrderID: tt.order.ID, IssuedAt: issued},
+				Order:     tt.order,
+				Customer:  tt.customer,
+			})
+			assert.NoError(t, err)
+
+			path := filepath.Join("testdata", tt.golden)
+			if *updateGolden {
+				assert.NoError(t, os.WriteFile(path, out.Bytes(), 0o644))
+			}
+
+			golden, err := os.ReadFile(path)
+			assert.NoError(t, err)
+			assert.True(t, bytes.Equal(golden, out.Bytes()), "rendered invoice differs from %s", path)
+		})
+	}
+}
+
+func TestInvoicePDF_IssuesOnFirstRequest(t *testing.T) {
+	mockInvoiceRepo := new(MockInvoiceRepo)
+	mockOrderRepo := new(MockOrderRepo)
+	mockCustomerRepo := new(MockCustomerRepo)
+	service := NewInvoiceService(mockInvoiceRepo, mockOrderRepo, mockCustomerRepo, testCompany)
+
+	order := &models.Order{ID: 5, CustomerID: "1", Currency: "KES", Status: models.OrderStatusPending, Subtotal: decimal.NewFromInt(100), Amount: decimal.NewFromInt(100)}
+	mockOrderRepo.On("GetByID", mock.Anything, int64(5)).Return(order, nil)
+	mockInvoiceRepo.On("GetByOrderID", mock.Anything, int64(5)).Return(nil, models.ErrNotFound)
+	mockCustomerRepo.On("GetByID", mock.Anything, int64(1)).Return(&models.Customer{ID: 1, Customer_name: "Jane", Password: "secret", Phone: "+254712345678"}, nil)
+	snapshot := &models.Customer{ID: 1, Customer_name: "Jane", Phone: "+254712345678"}
+	mockInvoiceRepo.On("Issue", mock.Anything, order, snapshot).
+		Return(&models.Invoice{ID: 1, Number: 17, OrderID: 5, IssuedAt: time.Now(), Order: *order, Customer: *snapshot}, nil)
+
+	reference, pdf, err := service.InvoicePDF(context.Background(), 5)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "INV-000017", reference)
+	assert.True(t, bytes.HasPrefix(pdf, []byte("%PDF-")))
+	mockInvoiceRepo.AssertExpectations(t)
+}
+
+func TestInvoicePDF_RendersIssuedSnapshot(t *testing.T) {
+	mockInvoiceRepo := new(MockInvoiceRepo)
+	mockOrderRepo := new(MockOrderRepo)
+	mockCustomerRepo := new(MockCustomerRepo)
+	service := NewInvoiceService(mockInvoiceRepo, mockOrderRepo, mockCustomerRepo, testCompany)
+
+	// The order and customer are gone; the invoice still renders
+	issued := time.Date(2026, 10, 20, 9, 30, 0, 0, time.UTC)
+	mockInvoiceRepo.On("GetByOrderID", mock.Anything, int64(5)).Return(&models.Invoice{
+		ID: 1, Number: 17, OrderID: 5, IssuedAt: issued,
+		Order:    models.Order{ID: 5, CustomerID: "1", Currency: "KES", Subtotal: decimal.NewFromInt(100), Amount: decimal.NewFromInt(100)},
+		Customer: models.Customer{ID: 1, Customer_name: "Jane"},
+	}, nil)
+
+	reference, pdf, err := service.InvoicePDF(context.Background(), 5)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "INV-000017", reference)
+	assert.True(t, bytes.HasPrefix(pdf, []byte("%PDF-")))
+	mockOrderRepo.AssertNotCalled(t, "GetByID", mock.Anything, mock.Anything)
+	mockCustomerRepo.AssertNotCalled(t, "GetByID", mock.Anything, mock.Anything)
+}
+
+func TestInvoicePDF_CancelledOrder(t *testing.T) {
+	mockInvoiceRepo := new(MockInvoiceRepo)
+	mockOrderRepo := new(MockOrderRepo)
+	service := NewInvoiceService(mockInvoiceRepo, mockOrderRepo, nil, testCompany)
+
+	mockOrderRepo.On("GetByID", mock.Anything, int64(5)).Return(&models.Order{ID: 5, CustomerID: "1", Status: models.OrderStatusCancelled}, nil)
+	mockInvoiceRepo.On("GetByOrderID", mock.Anything, int64(5)).Return(nil, models.ErrNotFound)
+
+	_, _, err := service.InvoicePDF(context.Background(), 5)
+
+	assert.ErrorIs(t, err, models.ErrNotInvoiceable)
+	mockInvoiceRepo.AssertNotCalled(t, "Issue", mock.Anything, mock.Anything, mock.Anything)
+}
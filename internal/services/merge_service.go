@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/chesireabel/Technical-Interview/internal/dedupe"
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/chesireabel/Technical-Interview/internal/repositories"
+)
+
+// findDuplicatesTimeout allows for comparing every customer
+const findDuplicatesTimeout = 30 * time.Second
+
+// MergeService finds customers entered more than once, e.g. with a slightly
+// different name and the same phone, and merges them
+type MergeService interface {
+	// FindDuplicates returns pairs of customers scoring at least minScore,
+	// best first. See package dedupe for the scoring.
+	FindDuplicates(ctx context.Context, minScore float64) ([]models.DuplicatePair, error)
+	// MergeCustomers merges the loser into the winner, recording mergedBy
+	// as who did it for the audit trail
+	MergeCustomers(ctx context.Context, winnerID, loserID int64, mergedBy string) (*models.CustomerMerge, error)
+	// ListMerges returns the merges the customer took part in, newest first
+	ListMerges(ctx context.Context, customerID int64) ([]models.CustomerMerge, error)
+}
+
+type mergeService struct {
+	repo         repositories.MergeRepository
+	customerRepo repositories.CustomerRepository
+	phoneRegion  string
+}
+
+func NewMergeService(repo repositories.MergeRepository, customerRepo repositories.CustomerRepository) MergeService {
+	return &mergeService{repo: repo, customerRepo: customerRepo, phoneRegion: phoneDefaultRegion()}
+}
+
+// dedupeRecord describes the customer for package dedupe, normalising their
+// phone so "0712 345 678" and "+254712345678" match. A phone that does not
+// parse is compared as written.
+func (s *mergeService) dedupeRecord(c *models.Customer) dedupe.Record {
+	phone := c.Phone
+	if phone != "" {
+		region := c.CountryCode
+		if region == "" {
+			region = s.phoneRegion
+		}
+		if number, err := ParsePhoneNumber(phone, region); err == nil {
+			phone = number.E164
+		}
+	}
+	return dedupe.Record{ID: c.ID, Name: c.Customer_name, Email: c.Email, Phone: phone}
+}
+
+func (s *mergeService) FindDuplicates(ctx context.Context, minScore float64) ([]models.DuplicatePair, error) {
+	if minScore == 0 {
+		minScore = dedupe.DefaultMinScore
+	}
+	if minScore < 0 || minScore > 1 {
+		return nil, errors.New("min_score must be between 0 and 1")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, findDuplicatesTimeout)
+	defer cancel()
+
+	customers := make(map[int64]models.Customer)
+	records := []dedupe.Record{}
+	err := s.customerRepo.Stream(ctx, false, func(c *models.Customer) error {
+		customers[c.ID] = *c
+		records = append(records, s.dedupeRecord(c))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	matches := dedupe.Find(records, minScore)
+	pairs := make([]models.DuplicatePair, 0, len(matches))
+	for _, m := range matches {
+		pairs = append(pairs, models.DuplicatePair{
+			Score:     m.Score,
+			Reasons:   m.Reasons,
+			Customers: []models.Customer{customers[m.A], customers[m.B]},
+		})
+	}
+	return pairs, nil
+}
+
+func (s *mergeService) MergeCustomers(ctx context.Context, winnerID, loserID int64, mergedBy string) (*models.CustomerMerge, error) {
+	if winnerID == 0 || loserID == 0 {
+		return nil, errors.New("id is required")
+	}
+	if winnerID == loserID {
+		return nil, errors.New("a customer cannot be merged into itself")
+	}
+	mergedBy = strings.TrimSpace(mergedBy)
+	if mergedBy == "" {
+		return nil, errors.New("merged_by is required")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	merge := &models.CustomerMerge{WinnerID: winnerID, LoserID: loserID, MergedBy: mergedBy}
+	if err := s.repo.Merge(ctx, merge); err != nil {
+		return nil, err
+	}
+	return merge, nil
+}
+
+func (s *mergeService) ListMerges(ctx context.Context, customerID int64) ([]models.CustomerMerge, error) {
+	if customerID == 0 {
+		return nil, errors.New("customer_id is required")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	return s.repo.ListByCustomer(ctx, customerID)
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestFindDuplicates_NormalisesPhones(t *testing.T) {
+	t.Setenv("PHONE_DEFAULT_REGION", "KE")
+	mockMergeRepo := new(MockMergeRepo)
+	mockCustomerRepo := new(MockCustomerRepo)
+	service := NewMergeService(mockMergeRepo, mockCustomerRepo)
+
+	mockCustomerRepo.On("Stream", mock.Anything, false, mock.Anything).Run(func(args mock.Arguments) {
+		fn := args.Get(2).(func(*models.Customer) error)
+		fn(&models.Customer{ID: 1, Customer_name: "Jane Wanjiru", Email: "jane@example.com", Phone: "+254712345678", CountryCode: "KE"})
+		fn(&models.Customer{ID: 2, Customer_name: "Peter Otieno", Email: "peter@example.com", Phone: "+254722000000", CountryCode: "KE"})
+		fn(&models.Customer{ID: 3, Customer_name: "Jane Wanjru", Email: "jw@example.com", Phone: "0712 345 678"})
+	}).Return(nil)
+
+	pairs, err := service.FindDuplicates(context.Background(), 0)
+
+	assert.NoError(t, err)
+	assert.Len(t, pairs, 1)
+	assert.Equal(t, int64(1), pairs[0].Customers[0].ID)
+	assert.Equal(t, int64(3), pairs[0].Customers[1].ID)
+	assert.Equal(t, "0712 345 678", pairs[0].Customers[1].Phone)
+	assert.Contains(t, pairs[0].Reasons, "same phone")
+	assert.GreaterOrEqual(t, pairs[0].Score, 0.6)
+}
+
+func TestMergeCustomers(t *testing.T) {
+	mockMergeRepo := new(MockMergeRepo)
+	service := NewMergeService(mockMergeRepo, new(MockCustomerRepo))
+
+	mockMergeRepo.On("Merge", mock.Anything, &models.CustomerMerge{WinnerID: 4, LoserID: 9, MergedBy: "agent@example.com"}).Run(func(args mock.Arguments) {
+		merge := args.Get(1).(*models.CustomerMerge)
+		merge.ID = 1
+		merge.OrdersMoved = 3
+		merge.OrderIDs = []int64{11, 12, 15}
+		merge.InvoicedOrderIDs = []int64{12}
+	}).Return(nil)
+
+	merge, err := service.MergeCustomers(context.Background(), 4, 9, "agent@example.com")
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), merge.ID)
+	assert.Equal(t, 3, merge.OrdersMoved)
+	assert.Equal(t, []int64{12}, merge.InvoicedOrderIDs)
+	mockMergeRepo.AssertExpectations(t)
+}
+
+func TestMergeCustomers_Invalid(t *testing.T) {
+	mockMergeRepo := new(MockMergeRepo)
+	service := NewMergeService(mockMergeRepo, new(MockCustomerRepo))
+
+	_, err := service.MergeCustomers(context.Background(), 4, 4, "agent@example.com")
+	assert.EqualError(t, err, "a customer cannot be merged into itself")
+
+	_, err = service.MergeCustomers(context.Background(), 4, 0, "agent@example.com")
+	assert.EqualError(t, err, "id is required")
+
+	mockMergeRepo.AssertNotCalled(t, "Merge", mock.Anything, mock.Anything)
+}
+
+func TestMergeCustomers_UnknownCustomer(t *testing.T) {
+	mockMergeRepo := new(MockMergeRepo)
+	service := NewMergeService(mockMergeRepo, new(MockCustomerRepo))
+
+	mockMergeRepo.On("Merge", mock.Anything, mock.Anything).Return(fmt.Errorf("customer with id 9: %w", models.ErrNotFound))
+
+	_, err := service.MergeCustomers(context.Background(), 4, 9, "agent@example.com")
+
+	assert.ErrorIs(t, err, models.ErrNotFound)
+}
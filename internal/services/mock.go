@@ -2,8 +2,11 @@ package services
 
 import (
 	"context"
+	"time"
 
 	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/chesireabel/Technical-Interview/internal/repositories"
+	"github.com/chesireabel/Technical-Interview/internal/segments"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -24,8 +27,8 @@ func (m *MockCustomerRepo) GetByID(ctx context.Context, id int64) (*models.Custo
 	return nil, args.Error(1)
 }
 
-func (m *MockCustomerRepo) GetAll(ctx context.Context) ([]models.Customer, error) {
-	args := m.Called(ctx)
+func (m *MockCustomerRepo) GetAll(ctx context.Context, includeDeleted bool) ([]models.Customer, error) {
+	args := m.Called(ctx, includeDeleted)
 	return args.Get(0).([]models.Customer), args.Error(1)
 }
 
@@ -39,6 +42,54 @@ func (m *MockCustomerRepo) Delete(ctx context.Context, id int64) error {
 	return args.Error(0)
 }
 
+func (m *MockCustomerRepo) Restore(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockCustomerRepo) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	args := m.Called(ctx, deletedBefore)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockCustomerRepo) NextCodeSequence(ctx context.Context) (int64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockCustomerRepo) UpdatePhone(ctx context.Context, id int64, phone, countryCode string) error {
+	args := m.Called(ctx, id, phone, countryCode)
+	return args.Error(0)
+}
+
+func (m *MockCustomerRepo) FindExisting(ctx context.Context, emails, codes []string) (map[string]bool, map[string]bool, error) {
+	args := m.Called(ctx, emails, codes)
+	return args.Get(0).(map[string]bool), args.Get(1).(map[string]bool), args.Error(2)
+}
+
+func (m *MockCustomerRepo) BulkCreate(ctx context.Context, customers []models.Customer) (int64, error) {
+	args := m.Called(ctx, customers)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockCustomerRepo) SaveImport(ctx context.Context, report *models.CustomerImport) error {
+	args := m.Called(ctx, report)
+	return args.Error(0)
+}
+
+func (m *MockCustomerRepo) GetImport(ctx context.Context, id int64) (*models.CustomerImport, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) != nil {
+		return args.Get(0).(*models.CustomerImport), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockCustomerRepo) Stream(ctx context.Context, includeDeleted bool, fn func(*models.Customer) error) error {
+	args := m.Called(ctx, includeDeleted, fn)
+	return args.Error(0)
+}
+
 type MockOrderRepo struct {
 	mock.Mock
 }
@@ -56,13 +107,13 @@ func (m *MockOrderRepo) GetByID(ctx context.Context, id int64) (*models.Order, e
 	return nil, args.Error(1)
 }
 
-func (m *MockOrderRepo) GetByCustomerID(ctx context.Context, customerID int64) ([]models.Order, error) {
-	args := m.Called(ctx, customerID)
+func (m *MockOrderRepo) GetByCustomerID(ctx context.Context, customerID int64, includeDeleted bool) ([]models.Order, error) {
+	args := m.Called(ctx, customerID, includeDeleted)
 	return args.Get(0).([]models.Order), args.Error(1)
 }
 
-func (m *MockOrderRepo) GetAll(ctx context.Context) ([]models.Order, error) {
-	args := m.Called(ctx)
+func (m *MockOrderRepo) GetAll(ctx context.Context, includeDeleted bool) ([]models.Order, error) {
+	args := m.Called(ctx, includeDeleted)
 	return args.Get(0).([]models.Order), args.Error(1)
 }
 
@@ -76,6 +127,44 @@ func (m *MockOrderRepo) Delete(ctx context.Context, id int64) error {
 	return args.Error(0)
 }
 
+func (m *MockOrderRepo) Restore(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockOrderRepo) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	args := m.Called(ctx, deletedBefore)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockOrderRepo) Stream(ctx context.Context, customerID int64, includeDeleted bool, fn func(*models.Order) error) error {
+	args := m.Called(ctx, customerID, includeDeleted, fn)
+	return args.Error(0)
+}
+
+func (m *MockOrderRepo) Transition(ctx context.Context, change *models.OrderStatusChange) error {
+	args := m.Called(ctx, change)
+	return args.Error(0)
+}
+
+func (m *MockOrderRepo) GetStatusHistory(ctx context.Context, orderID int64) ([]models.OrderStatusChange, error) {
+	args := m.Called(ctx, orderID)
+	return args.Get(0).([]models.OrderStatusChange), args.Error(1)
+}
+
+func (m *MockOrderRepo) Reschedule(ctx context.Context, id int64, scheduledFor time.Time) error {
+	args := m.Called(ctx, id, scheduledFor)
+	return args.Error(0)
+}
+
+func (m *MockOrderRepo) ListDueScheduled(ctx context.Context, now time.Time, limit int) ([]int64, error) {
+	args := m.Called(ctx, now, limit)
+	if args.Get(0) != nil {
+		return args.Get(0).([]int64), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
 type MockSMSService struct {
 	mock.Mock
 }
@@ -89,3 +178,620 @@ func (m *MockSMSService) SendOrderUpdate(ctx context.Context, order *models.Orde
 	args := m.Called(ctx, order, phoneNumber, status)
 	return args.Error(0)
 }
+
+func (m *MockSMSService) SendRefundNotice(ctx context.Context, order *models.Order, refund *models.Refund, phoneNumber string) error {
+	args := m.Called(ctx, order, refund, phoneNumber)
+	return args.Error(0)
+}
+
+type MockIdempotencyRepo struct {
+	mock.Mock
+}
+
+func (m *MockIdempotencyRepo) Reserve(ctx context.Context, record *models.IdempotencyRecord, staleBefore time.Time) (bool, error) {
+	args := m.Called(ctx, record, staleBefore)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockIdempotencyRepo) Get(ctx context.Context, scope, key string) (*models.IdempotencyRecord, error) {
+	args := m.Called(ctx, scope, key)
+	if args.Get(0) != nil {
+		return args.Get(0).(*models.IdempotencyRecord), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockIdempotencyRepo) Complete(ctx context.Context, scope, key string, statusCode int, body []byte) error {
+	args := m.Called(ctx, scope, key, statusCode, body)
+	return args.Error(0)
+}
+
+func (m *MockIdempotencyRepo) Release(ctx context.Context, scope, key string) error {
+	args := m.Called(ctx, scope, key)
+	return args.Error(0)
+}
+
+func (m *MockIdempotencyRepo) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	args := m.Called(ctx, before)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+type MockOutboxRepo struct {
+	mock.Mock
+}
+
+func (m *MockOutboxRepo) Claim(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxMessage, error) {
+	args := m.Called(ctx, limit, lease)
+	return args.Get(0).([]models.OutboxMessage), args.Error(1)
+}
+
+func (m *MockOutboxRepo) MarkSent(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockOutboxRepo) Retry(ctx context.Context, id int64, lastError string, availableAt time.Time) error {
+	args := m.Called(ctx, id, lastError, availableAt)
+	return args.Error(0)
+}
+
+func (m *MockOutboxRepo) MarkDead(ctx context.Context, id int64, lastError string) error {
+	args := m.Called(ctx, id, lastError)
+	return args.Error(0)
+}
+
+// FakeTxManager runs the unit of work directly against the given repositories
+type FakeTxManager struct {
+	Repos repositories.Repositories
+	Calls int
+}
+
+func (f *FakeTxManager) WithinTx(ctx context.Context, fn repositories.TxFunc) error {
+	f.Calls++
+	return fn(ctx, f.Repos)
+}
+
+func (f *FakeTxManager) WithinSerializableTx(ctx context.Context, fn repositories.TxFunc) error {
+	return f.WithinTx(ctx, fn)
+}
+
+type MockProductRepo struct {
+	mock.Mock
+}
+
+func (m *MockProductRepo) Create(ctx context.Context, product *models.Product) (int64, error) {
+	args := m.Called(ctx, product)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockProductRepo) GetByID(ctx context.Context, id int64) (*models.Product, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) != nil {
+		return args.Get(0).(*models.Product), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockProductRepo) GetByIDs(ctx context.Context, ids []int64) (map[int64]*models.Product, error) {
+	args := m.Called(ctx, ids)
+	return args.Get(0).(map[int64]*models.Product), args.Error(1)
+}
+
+func (m *MockProductRepo) List(ctx context.Context, filter models.ProductFilter) ([]models.Product, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]models.Product), args.Error(1)
+}
+
+func (m *MockProductRepo) Update(ctx context.Context, product *models.Product) error {
+	args := m.Called(ctx, product)
+	return args.Error(0)
+}
+
+func (m *MockProductRepo) SetActive(ctx context.Context, id int64, active bool) error {
+	args := m.Called(ctx, id, active)
+	return args.Error(0)
+}
+
+type MockInventoryRepo struct {
+	mock.Mock
+}
+
+func (m *MockInventoryRepo) Reserve(ctx context.Context, orderID int64, quantities map[int64]int, preferredLocation, actor string) error {
+	args := m.Called(ctx, orderID, quantities, preferredLocation, actor)
+	return args.Error(0)
+}
+
+func (m *MockInventoryRepo) Release(ctx context.Context, orderID int64, actor string) error {
+	args := m.Called(ctx, orderID, actor)
+	return args.Error(0)
+}
+
+func (m *MockInventoryRepo) Fulfil(ctx context.Context, orderID int64, actor string) error {
+	args := m.Called(ctx, orderID, actor)
+	return args.Error(0)
+}
+
+func (m *MockInventoryRepo) Adjust(ctx context.Context, movement *models.StockMovement) error {
+	args := m.Called(ctx, movement)
+	return args.Error(0)
+}
+
+func (m *MockInventoryRepo) GetLevels(ctx context.Context, productID int64) ([]models.StockLevel, error) {
+	args := m.Called(ctx, productID)
+	return args.Get(0).([]models.StockLevel), args.Error(1)
+}
+
+func (m *MockInventoryRepo) GetMovements(ctx context.Context, productID int64, limit int) ([]models.StockMovement, error) {
+	args := m.Called(ctx, productID, limit)
+	return args.Get(0).([]models.StockMovement), args.Error(1)
+}
+
+type MockPromotionRepo struct {
+	mock.Mock
+}
+
+func (m *MockPromotionRepo) Create(ctx context.Context, promotion *models.Promotion) (int64, error) {
+	args := m.Called(ctx, promotion)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockPromotionRepo) GetByID(ctx context.Context, id int64) (*models.Promotion, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) != nil {
+		return args.Get(0).(*models.Promotion), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockPromotionRepo) GetByCode(ctx context.Context, code string) (*models.Promotion, error) {
+	args := m.Called(ctx, code)
+	if args.Get(0) != nil {
+		return args.Get(0).(*models.Promotion), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockPromotionRepo) LockByCode(ctx context.Context, code string) (*models.Promotion, error) {
+	args := m.Called(ctx, code)
+	if args.Get(0) != nil {
+		return args.Get(0).(*models.Promotion), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockPromotionRepo) List(ctx context.Context, includeInactive bool) ([]models.Promotion, error) {
+	args := m.Called(ctx, includeInactive)
+	return args.Get(0).([]models.Promotion), args.Error(1)
+}
+
+func (m *MockPromotionRepo) SetActive(ctx context.Context, id int64, active bool) error {
+	args := m.Called(ctx, id, active)
+	return args.Error(0)
+}
+
+func (m *MockPromotionRepo) CountRedemptions(ctx context.Context, promotionID, customerID int64) (int, error) {
+	args := m.Called(ctx, promotionID, customerID)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockPromotionRepo) Redeem(ctx context.Context, redemption *models.PromotionRedemption) error {
+	args := m.Called(ctx, redemption)
+	return args.Error(0)
+}
+
+func (m *MockPromotionRepo) Release(ctx context.Context, orderID int64) error {
+	args := m.Called(ctx, orderID)
+	return args.Error(0)
+}
+
+type MockTaxRateRepo struct {
+	mock.Mock
+}
+
+// noTaxRates is a tax rate repository for tests that do not care about tax
+func noTaxRates() *MockTaxRateRepo {
+	m := new(MockTaxRateRepo)
+	m.On("List", mock.Anything, mock.Anything).Return([]models.TaxRate{}, nil)
+	return m
+}
+
+func (m *MockTaxRateRepo) Create(ctx context.Context, rate *models.TaxRate) (int64, error) {
+	args := m.Called(ctx, rate)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockTaxRateRepo) List(ctx context.Context, region string) ([]models.TaxRate, error) {
+	args := m.Called(ctx, region)
+	return args.Get(0).([]models.TaxRate), args.Error(1)
+}
+
+func (m *MockTaxRateRepo) Update(ctx context.Context, rate *models.TaxRate) error {
+	args := m.Called(ctx, rate)
+	return args.Error(0)
+}
+
+func (m *MockTaxRateRepo) Delete(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+type MockInvoiceRepo struct {
+	mock.Mock
+}
+
+func (m *MockInvoiceRepo) GetByOrderID(ctx context.Context, orderID int64) (*models.Invoice, error) {
+	args := m.Called(ctx, orderID)
+	if args.Get(0) != nil {
+		return args.Get(0).(*models.Invoice), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockInvoiceRepo) Issue(ctx context.Context, order *models.Order, customer *models.Customer) (*models.Invoice, error) {
+	args := m.Called(ctx, order, customer)
+	if args.Get(0) != nil {
+		return args.Get(0).(*models.Invoice), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+type MockRefundRepo struct {
+	mock.Mock
+}
+
+func (m *MockRefundRepo) Create(ctx context.Context, refund *models.Refund) error {
+	args := m.Called(ctx, refund)
+	return args.Error(0)
+}
+
+func (m *MockRefundRepo) GetByID(ctx context.Context, id int64) (*models.Refund, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) != nil {
+		return args.Get(0).(*models.Refund), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRefundRepo) ListByOrder(ctx context.Context, orderID int64) ([]models.Refund, error) {
+	args := m.Called(ctx, orderID)
+	if args.Get(0) != nil {
+		return args.Get(0).([]models.Refund), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRefundRepo) MarkPaidOut(ctx context.Context, id int64, reference string) error {
+	args := m.Called(ctx, id, reference)
+	return args.Error(0)
+}
+
+func (m *MockRefundRepo) MarkPayoutRequested(ctx context.Context, id int64, reference string) error {
+	args := m.Called(ctx, id, reference)
+	return args.Error(0)
+}
+
+func (m *MockRefundRepo) CompletePayout(ctx context.Context, reference, failure string) (*models.Refund, bool, error) {
+	args := m.Called(ctx, reference, failure)
+	if args.Get(0) != nil {
+		return args.Get(0).(*models.Refund), args.Bool(1), args.Error(2)
+	}
+	return nil, args.Bool(1), args.Error(2)
+}
+
+type MockPaymentRepo struct {
+	mock.Mock
+}
+
+func (m *MockPaymentRepo) Create(ctx context.Context, payment *models.Payment, activeSince time.Time) error {
+	args := m.Called(ctx, payment, activeSince)
+	return args.Error(0)
+}
+
+func (m *MockPaymentRepo) HasPending(ctx context.Context, orderID int64, activeSince time.Time) (bool, error) {
+	args := m.Called(ctx, orderID, activeSince)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockPaymentRepo) SetReference(ctx context.Context, id int64, reference string) error {
+	args := m.Called(ctx, id, reference)
+	return args.Error(0)
+}
+
+func (m *MockPaymentRepo) MarkFailed(ctx context.Context, id int64, reason string) error {
+	args := m.Called(ctx, id, reason)
+	return args.Error(0)
+}
+
+func (m *MockPaymentRepo) Complete(ctx context.Context, result *models.PaymentResult) (*models.Payment, bool, error) {
+	args := m.Called(ctx, result)
+	if args.Get(0) != nil {
+		return args.Get(0).(*models.Payment), args.Bool(1), args.Error(2)
+	}
+	return nil, args.Bool(1), args.Error(2)
+}
+
+func (m *MockPaymentRepo) ListByOrder(ctx context.Context, orderID int64) ([]models.Payment, error) {
+	args := m.Called(ctx, orderID)
+	if args.Get(0) != nil {
+		return args.Get(0).([]models.Payment), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockPaymentRepo) ListForReconciliation(ctx context.Context, from, to time.Time) ([]models.Payment, error) {
+	args := m.Called(ctx, from, to)
+	if args.Get(0) != nil {
+		return args.Get(0).([]models.Payment), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockPaymentRepo) FlagMismatch(ctx context.Context, mismatch *models.PaymentMismatch) (bool, error) {
+	args := m.Called(ctx, mismatch)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockPaymentRepo) ListMismatches(ctx context.Context, includeResolved bool) ([]models.PaymentMismatch, error) {
+	args := m.Called(ctx, includeResolved)
+	if args.Get(0) != nil {
+		return args.Get(0).([]models.PaymentMismatch), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockPaymentRepo) ResolveMismatch(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+type MockSubscriptionRepo struct {
+	mock.Mock
+}
+
+func (m *MockSubscriptionRepo) Create(ctx context.Context, subscription *models.Subscription) (int64, error) {
+	args := m.Called(ctx, subscription)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockSubscriptionRepo) GetByID(ctx context.Context, id int64) (*models.Subscription, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) != nil {
+		return args.Get(0).(*models.Subscription), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockSubscriptionRepo) List(ctx context.Context, customerID int64) ([]models.Subscription, error) {
+	args := m.Called(ctx, customerID)
+	if args.Get(0) != nil {
+		return args.Get(0).([]models.Subscription), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockSubscriptionRepo) SetStatus(ctx context.Context, id int64, status models.SubscriptionStatus, nextRunAt *time.Time) error {
+	args := m.Called(ctx, id, status, nextRunAt)
+	return args.Error(0)
+}
+
+func (m *MockSubscriptionRepo) LockDue(ctx context.Context, now time.Time, limit int) ([]models.Subscription, error) {
+	args := m.Called(ctx, now, limit)
+	if args.Get(0) != nil {
+		return args.Get(0).([]models.Subscription), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockSubscriptionRepo) StartRun(ctx context.Context, subscription *models.Subscription, nextRunAt time.Time) (*models.SubscriptionRun, error) {
+	args := m.Called(ctx, subscription, nextRunAt)
+	if args.Get(0) != nil {
+		return args.Get(0).(*models.SubscriptionRun), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockSubscriptionRepo) FinishRun(ctx context.Context, run *models.SubscriptionRun) error {
+	args := m.Called(ctx, run)
+	return args.Error(0)
+}
+
+func (m *MockSubscriptionRepo) ListRuns(ctx context.Context, subscriptionID int64) ([]models.SubscriptionRun, error) {
+	args := m.Called(ctx, subscriptionID)
+	if args.Get(0) != nil {
+		return args.Get(0).([]models.SubscriptionRun), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+type MockAddressRepo struct {
+	mock.Mock
+}
+
+func (m *MockAddressRepo) Create(ctx context.Context, address *models.CustomerAddress) error {
+	args := m.Called(ctx, address)
+	return args.Error(0)
+}
+
+func (m *MockAddressRepo) GetByID(ctx context.Context, customerID, id int64) (*models.CustomerAddress, error) {
+	args := m.Called(ctx, customerID, id)
+	if args.Get(0) != nil {
+		return args.Get(0).(*models.CustomerAddress), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockAddressRepo) ListByCustomer(ctx context.Context, customerID int64) ([]models.CustomerAddress, error) {
+	args := m.Called(ctx, customerID)
+	if args.Get(0) != nil {
+		return args.Get(0).([]models.CustomerAddress), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockAddressRepo) Update(ctx context.Context, address *models.CustomerAddress) error {
+	args := m.Called(ctx, address)
+	return args.Error(0)
+}
+
+func (m *MockAddressRepo) Delete(ctx context.Context, customerID, id int64) error {
+	args := m.Called(ctx, customerID, id)
+	return args.Error(0)
+}
+
+type MockTagRepo struct {
+	mock.Mock
+}
+
+func (m *MockTagRepo) List(ctx context.Context, customerID int64) ([]string, error) {
+	args := m.Called(ctx, customerID)
+	if args.Get(0) != nil {
+		return args.Get(0).([]string), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockTagRepo) Add(ctx context.Context, customerID int64, tags []string) error {
+	args := m.Called(ctx, customerID, tags)
+	return args.Error(0)
+}
+
+func (m *MockTagRepo) Remove(ctx context.Context, customerID int64, tag string) (bool, error) {
+	args := m.Called(ctx, customerID, tag)
+	return args.Bool(0), args.Error(1)
+}
+
+type MockSegmentRepo struct {
+	mock.Mock
+}
+
+func (m *MockSegmentRepo) Create(ctx context.Context, segment *models.Segment) (int64, error) {
+	args := m.Called(ctx, segment)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockSegmentRepo) GetByID(ctx context.Context, id int64) (*models.Segment, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) != nil {
+		return args.Get(0).(*models.Segment), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockSegmentRepo) List(ctx context.Context) ([]models.Segment, error) {
+	args := m.Called(ctx)
+	if args.Get(0) != nil {
+		return args.Get(0).([]models.Segment), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockSegmentRepo) Update(ctx context.Context, segment *models.Segment) error {
+	args := m.Called(ctx, segment)
+	return args.Error(0)
+}
+
+func (m *MockSegmentRepo) Delete(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockSegmentRepo) Refresh(ctx context.Context, id int64, rule *segments.Rule) (int, error) {
+	args := m.Called(ctx, id, rule)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockSegmentRepo) ListMembers(ctx context.Context, id int64) ([]models.Customer, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) != nil {
+		return args.Get(0).([]models.Customer), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+// FakeLeaderLock is a leader lock whose outcome the test decides
+type MockNoteRepo struct {
+	mock.Mock
+}
+
+func (m *MockNoteRepo) Create(ctx context.Context, note *models.CustomerNote) error {
+	args := m.Called(ctx, note)
+	return args.Error(0)
+}
+
+func (m *MockNoteRepo) GetByID(ctx context.Context, customerID, id int64) (*models.CustomerNote, error) {
+	args := m.Called(ctx, customerID, id)
+	if args.Get(0) != nil {
+		return args.Get(0).(*models.CustomerNote), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockNoteRepo) List(ctx context.Context, customerID int64) ([]models.CustomerNote, error) {
+	args := m.Called(ctx, customerID)
+	if args.Get(0) != nil {
+		return args.Get(0).([]models.CustomerNote), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockNoteRepo) Update(ctx context.Context, note *models.CustomerNote) error {
+	args := m.Called(ctx, note)
+	return args.Error(0)
+}
+
+func (m *MockNoteRepo) Delete(ctx context.Context, customerID, id int64) error {
+	args := m.Called(ctx, customerID, id)
+	return args.Error(0)
+}
+
+type MockTimelineRepo struct {
+	mock.Mock
+}
+
+func (m *MockTimelineRepo) List(ctx context.Context, customerID int64, before *models.TimelineCursor, limit int) ([]models.TimelineEvent, error) {
+	args := m.Called(ctx, customerID, before, limit)
+	if args.Get(0) != nil {
+		return args.Get(0).([]models.TimelineEvent), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+type MockSMSMessageRepo struct {
+	mock.Mock
+}
+
+func (m *MockSMSMessageRepo) Create(ctx context.Context, message *models.SMSMessage) error {
+	args := m.Called(ctx, message)
+	return args.Error(0)
+}
+
+type MockMergeRepo struct {
+	mock.Mock
+}
+
+func (m *MockMergeRepo) Merge(ctx context.Context, merge *models.CustomerMerge) error {
+	args := m.Called(ctx, merge)
+	return args.Error(0)
+}
+
+func (m *MockMergeRepo) ListByCustomer(ctx context.Context, customerID int64) ([]models.CustomerMerge, error) {
+	args := m.Called(ctx, customerID)
+	if args.Get(0) != nil {
+		return args.Get(0).([]models.CustomerMerge), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+type FakeLeaderLock struct {
+	Leader   bool
+	Released bool
+}
+
+func (f *FakeLeaderLock) Acquire(ctx context.Context) (bool, error) {
+	return f.Leader, nil
+}
+
+func (f *FakeLeaderLock) Release(ctx context.Context) error {
+	f.Released = true
+	return nil
+}
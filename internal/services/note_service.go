@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/chesireabel/Technical-Interview/internal/repositories"
+)
+
+const maxNoteLength = 5000
+
+// NoteService manages the notes support staff record against customers,
+// e.g. what was agreed on a call. A note's author is whoever created it and
+// does not change when the note is edited.
+type NoteService interface {
+	CreateNote(ctx context.Context, note *models.CustomerNote) error
+	GetNote(ctx context.Context, customerID, id int64) (*models.CustomerNote, error)
+	// ListNotes returns pinned notes first, then the newest
+	ListNotes(ctx context.Context, customerID int64) ([]models.CustomerNote, error)
+	// UpdateNote changes the note's body and pinned flag
+	UpdateNote(ctx context.Context, note *models.CustomerNote) error
+	DeleteNote(ctx context.Context, customerID, id int64) error
+}
+
+type noteService struct {
+	repo repositories.NoteRepository
+}
+
+func NewNoteService(repo repositories.NoteRepository) NoteService {
+	return &noteService{repo: repo}
+}
+
+func validateNote(note *models.CustomerNote) error {
+	if note.CustomerID == 0 {
+		return errors.New("customer_id is required")
+	}
+	note.Body = strings.TrimSpace(note.Body)
+	if note.Body == "" {
+		return errors.New("body is required")
+	}
+	if len([]rune(note.Body)) > maxNoteLength {
+		return fmt.Errorf("body must be at most %d characters", maxNoteLength)
+	}
+	return nil
+}
+
+func (s *noteService) CreateNote(ctx context.Context, note *models.CustomerNote) error {
+	if err := validateNote(note); err != nil {
+		return err
+	}
+	note.Author = strings.TrimSpace(note.Author)
+	if note.Author == "" {
+		return errors.New("author is required")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return s.repo.Create(ctx, note)
+}
+
+func (s *noteService) GetNote(ctx context.Context, customerID, id int64) (*models.CustomerNote, error) {
+	if customerID == 0 || id == 0 {
+		return nil, errors.New("id is required")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return s.repo.GetByID(ctx, customerID, id)
+}
+
+func (s *noteService) ListNotes(ctx context.Context, customerID int64) ([]models.CustomerNote, error) {
+	if customerID == 0 {
+		return nil, errors.New("customer_id is required")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	return s.repo.List(ctx, customerID)
+}
+
+func (s *noteService) UpdateNote(ctx context.Context, note *models.CustomerNote) error {
+	if note.ID == 0 {
+		return errors.New("id is required for update")
+	}
+	if err := validateNote(note); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return s.repo.Update(ctx, note)
+}
+
+func (s *noteService) DeleteNote(ctx context.Context, customerID, id int64) error {
+	if customerID == 0 || id == 0 {
+		return errors.New("id is required for delete")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return s.repo.Delete(ctx, customerID, id)
+}
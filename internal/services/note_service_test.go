@@ -0,0 +1,64 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCreateNote(t *testing.T) {
+	mockNoteRepo := new(MockNoteRepo)
+	service := NewNoteService(mockNoteRepo)
+
+	note := &models.CustomerNote{CustomerID: 4, Author: "agent@example.com", Body: "  Called about a late delivery \n", Pinned: true}
+
+	mockNoteRepo.On("Create", mock.Anything, note).Return(nil)
+
+	err := service.CreateNote(context.Background(), note)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Called about a late delivery", note.Body)
+	mockNoteRepo.AssertExpectations(t)
+}
+
+func TestCreateNote_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		note    models.CustomerNote
+		wantErr string
+	}{
+		{"missing customer", models.CustomerNote{Author: "agent", Body: "hi"}, "customer_id is required"},
+		{"blank body", models.CustomerNote{CustomerID: 4, Author: "agent", Body: "   "}, "body is required"},
+		{"long body", models.CustomerNote{CustomerID: 4, Author: "agent", Body: strings.Repeat("a", maxNoteLength+1)}, "body must be at most 5000 characters"},
+		{"missing author", models.CustomerNote{CustomerID: 4, Body: "hi"}, "author is required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockNoteRepo := new(MockNoteRepo)
+			service := NewNoteService(mockNoteRepo)
+
+			err := service.CreateNote(context.Background(), &tt.note)
+
+			assert.EqualError(t, err, tt.wantErr)
+			mockNoteRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestUpdateNote_NotFound(t *testing.T) {
+	mockNoteRepo := new(MockNoteRepo)
+	service := NewNoteService(mockNoteRepo)
+
+	note := &models.CustomerNote{ID: 7, CustomerID: 4, Body: "Resolved"}
+	mockNoteRepo.On("Update", mock.Anything, note).Return(fmt.Errorf("note with id 7: %w", models.ErrNotFound))
+
+	err := service.UpdateNote(context.Background(), note)
+
+	assert.ErrorIs(t, err, models.ErrNotFound)
+}
@@ -5,6 +5,8 @@ import (
 	"testing"
 
 	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/chesireabel/Technical-Interview/internal/repositories"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -12,10 +14,8 @@ import (
 func TestCreateOrder(t *testing.T) {
 	mockOrderRepo := new(MockOrderRepo)
 	mockCustomerRepo := new(MockCustomerRepo)
-	mockSMS := new(MockSMSService)
-	
 
-	service := NewOrderService(mockOrderRepo, mockCustomerRepo, mockSMS)
+	service := NewOrderService(mockOrderRepo, mockCustomerRepo, nil, &FakeTxManager{Repos: repositories.Repositories{Orders: mockOrderRepo, TaxRates: noTaxRates()}})
 
 	customer := &models.Customer{
 		ID:            1,
@@ -26,18 +26,12 @@ func TestCreateOrder(t *testing.T) {
 	order := &models.Order{
 		CustomerID: "1",
 		Item:       "Laptop",
-		Amount:     1000,
+		Amount: decimal.NewFromInt(1000),
 	}
 
 	// Setup mock responses
 	mockCustomerRepo.On("GetByID", mock.Anything, int64(1)).Return(customer, nil)
     mockOrderRepo.On("Create", mock.Anything, order).Return(int64(1), nil)
-mockSMS.On(
-    "SendOrderConfirmation",
-    mock.Anything, // context.Context
-    mock.AnythingOfType("*models.Order"),
-    mock.AnythingOfType("*models.Customer"),
-).Return(nil)
 	orderID, err := service.CreateOrder(context.Background(), order)
 
 	assert.NoError(t, err)
@@ -45,16 +39,15 @@ mockSMS.On(
 
 	mockCustomerRepo.AssertExpectations(t)
 	mockOrderRepo.AssertExpectations(t)
-	mockSMS.AssertExpectations(t)
 }
 
 func TestCreateOrder_InvalidCustomerID(t *testing.T) {
-	service := NewOrderService(nil, nil, nil)
+	service := NewOrderService(nil, nil, nil, nil)
 
 	order := &models.Order{
 		CustomerID: "",
 		Item:       "Laptop",
-		Amount:     1000,
+		Amount: decimal.NewFromInt(1000),
 	}
 
 	_, err := service.CreateOrder(context.Background(), order)
@@ -63,11 +56,76 @@ func TestCreateOrder_InvalidCustomerID(t *testing.T) {
 	assert.Equal(t, "customer_id is required", err.Error())
 }
 
+func TestCreateOrder_MultipleItems(t *testing.T) {
+	mockOrderRepo := new(MockOrderRepo)
+	mockCustomerRepo := new(MockCustomerRepo)
+
+	service := NewOrderService(mockOrderRepo, mockCustomerRepo, nil, &FakeTxManager{Repos: repositories.Repositories{Orders: mockOrderRepo, TaxRates: noTaxRates()}})
+
+	customer := &models.Customer{ID: 1, Customer_name: "Jane", Phone: "+254712345678"}
+	order := &models.Order{
+		CustomerID: "1",
+		Amount:     decimal.NewFromInt(1), // ignored, totals are computed server-side
+		Items: []models.OrderItem{
+			{Description: " Shoes ", Quantity: 2, UnitPrice: decimal.RequireFromString("1499.99")},
+			{Description: "Socks", ProductRef: "SKU-9", Quantity: 3, UnitPrice: decimal.RequireFromString("0.10")},
+		},
+	}
+
+	mockCustomerRepo.On("GetByID", mock.Anything, int64(1)).Return(customer, nil)
+	mockOrderRepo.On("Create", mock.Anything, order).Return(int64(5), nil)
+
+	orderID, err := service.CreateOrder(context.Background(), order)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), orderID)
+	assert.Equal(t, "Shoes", order.Items[0].Description)
+	assert.Equal(t, "2999.98", order.Items[0].LineTotal.String())
+	assert.Equal(t, "0.3", order.Items[1].LineTotal.String())
+	assert.Equal(t, "3000.28", order.Amount.String())
+	assert.Equal(t, "KES", order.Currency)
+	assert.Equal(t, "Shoes +1 more", order.Item)
+}
+
+func TestCreateOrder_InvalidItems(t *testing.T) {
+	service := NewOrderService(nil, nil, nil, nil)
+
+	tests := []struct {
+		name  string
+		items []models.OrderItem
+		want  string
+	}{
+		{"missing description", []models.OrderItem{{Quantity: 1, UnitPrice: decimal.NewFromInt(10)}}, "items[0].description is required"},
+		{"zero quantity", []models.OrderItem{{Description: "Pen", UnitPrice: decimal.NewFromInt(10)}}, "items[0].quantity must be greater than 0"},
+		{"negative price", []models.OrderItem{{Description: "Pen", Quantity: 1, UnitPrice: decimal.NewFromInt(5)}, {Description: "Ink", Quantity: 1, UnitPrice: decimal.NewFromInt(-1)}}, "items[1].unit_price must be greater than 0"},
+		{"sub-cent price", []models.OrderItem{{Description: "Pen", Quantity: 1, UnitPrice: decimal.RequireFromString("9.995")}}, "items[0].unit_price has more decimal places than KES allows"},
+		{"no items or legacy item", nil, "item is required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.CreateOrder(context.Background(), &models.Order{CustomerID: "1", Items: tt.items})
+			assert.EqualError(t, err, tt.want)
+		})
+	}
+
+	// Currency specific rules
+	_, err := service.CreateOrder(context.Background(), &models.Order{
+		CustomerID: "1",
+		Currency:   "UGX",
+		Items:      []models.OrderItem{{Description: "Sugar", Quantity: 1, UnitPrice: decimal.RequireFromString("3500.50")}},
+	})
+	assert.EqualError(t, err, "items[0].unit_price has more decimal places than UGX allows")
+
+	_, err = service.CreateOrder(context.Background(), &models.Order{CustomerID: "1", Currency: "XYZ", Item: "Pen", Amount: decimal.NewFromInt(1)})
+	assert.EqualError(t, err, `unsupported currency "XYZ"`)
+}
+
 func TestGetOrder(t *testing.T) {
 	mockOrderRepo := new(MockOrderRepo)
-	service := NewOrderService(mockOrderRepo, nil, nil)
+	service := NewOrderService(mockOrderRepo, nil, nil, nil)
 
-	expected := &models.Order{ID: 1, Item: "Laptop", Amount: 1000}
+	expected := &models.Order{ID: 1, Item: "Laptop", Amount: decimal.NewFromInt(1000)}
 	mockOrderRepo.On("GetByID", mock.Anything, int64(1)).Return(expected, nil)
 
 	order, err := service.GetOrder(context.Background(), 1)
@@ -79,3 +137,113 @@ func TestGetOrder(t *testing.T) {
 	assert.Error(t, err)
 	assert.Equal(t, "id is required", err.Error())
 }
+
+func TestUpdateOrder_ReservesChangedLines(t *testing.T) {
+	mockOrderRepo := new(MockOrderRepo)
+	mockProductRepo := new(MockProductRepo)
+	mockInventoryRepo := new(MockInventoryRepo)
+	mockPaymentRepo := new(MockPaymentRepo)
+	tx := &FakeTxManager{Repos: repositories.Repositories{Orders: mockOrderRepo, Products: mockProductRepo, Inventory: mockInventoryRepo, Payments: mockPaymentRepo, TaxRates: noTaxRates()}}
+	service := NewOrderService(mockOrderRepo, new(MockCustomerRepo), mockProductRepo, tx)
+
+	shoesID := int64(3)
+	mockPaymentRepo.On("HasPending", mock.Anything, int64(9), mock.Anything).Return(false, nil)
+	mockOrderRepo.On("GetByID", mock.Anything, int64(9)).Return(&models.Order{
+		ID:       9,
+		Status:   models.OrderStatusPending,
+		Currency: "KES",
+		Items:    []models.OrderItem{{ProductID: &shoesID, Description: "Shoes", Quantity: 1, UnitPrice: decimal.NewFromInt(100)}},
+	}, nil)
+	mockProductRepo.On("GetByIDs", mock.Anything, []int64{3}).Return(map[int64]*models.Product{}, nil)
+	mockOrderRepo.On("Update", mock.Anything, mock.Anything).Return(nil)
+	mockInventoryRepo.On("Release", mock.Anything, int64(9), "order:9").Return(nil)
+	mockInventoryRepo.On("Reserve", mock.Anything, int64(9), map[int64]int{3: 3}, "MAIN", "order:9").Return(nil)
+
+	err := service.UpdateOrder(context.Background(), &models.Order{
+		ID:         9,
+		CustomerID: "1",
+		Items:      []models.OrderItem{{ProductID: &shoesID, Quantity: 3}},
+	})
+
+	assert.NoError(t, err)
+	mockOrderRepo.AssertExpectations(t)
+	mockInventoryRepo.AssertExpectations(t)
+}
+
+func TestUpdateOrder_NotEditable(t *testing.T) {
+	for _, status := range []models.OrderStatus{models.OrderStatusPaid, models.OrderStatusShipped, models.OrderStatusDelivered, models.OrderStatusCancelled} {
+		t.Run(string(status), func(t *testing.T) {
+			mockOrderRepo := new(MockOrderRepo)
+			tx := &FakeTxManager{Repos: repositories.Repositories{Orders: mockOrderRepo}}
+			service := NewOrderService(mockOrderRepo, new(MockCustomerRepo), nil, tx)
+
+			mockOrderRepo.On("GetByID", mock.Anything, int64(9)).Return(&models.Order{ID: 9, Status: status}, nil)
+
+			err := service.UpdateOrder(context.Background(), &models.Order{
+				ID:         9,
+				CustomerID: "1",
+				Items:      []models.OrderItem{{Description: "Shoes", Quantity: 1, UnitPrice: decimal.NewFromInt(100)}},
+			})
+
+			assert.ErrorIs(t, err, models.ErrNotEditable)
+			mockOrderRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestUpdateOrder_PaymentInProgress(t *testing.T) {
+	mockOrderRepo := new(MockOrderRepo)
+	mockPaymentRepo := new(MockPaymentRepo)
+	tx := &FakeTxManager{Repos: repositories.Repositories{Orders: mockOrderRepo, Payments: mockPaymentRepo}}
+	service := NewOrderService(mockOrderRepo, new(MockCustomerRepo), nil, tx)
+
+	mockOrderRepo.On("GetByID", mock.Anything, int64(9)).Return(&models.Order{ID: 9, Status: models.OrderStatusPending}, nil)
+	mockPaymentRepo.On("HasPending", mock.Anything, int64(9), mock.Anything).Return(true, nil)
+
+	err := service.UpdateOrder(context.Background(), &models.Order{
+		ID:         9,
+		CustomerID: "1",
+		Items:      []models.OrderItem{{Description: "Shoes", Quantity: 1, UnitPrice: decimal.NewFromInt(100)}},
+	})
+
+	assert.ErrorIs(t, err, models.ErrPaymentInProgress)
+	mockOrderRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+}
+
+func TestDeleteOrder_ReleasesStock(t *testing.T) {
+	mockOrderRepo := new(MockOrderRepo)
+	mockInventoryRepo := new(MockInventoryRepo)
+	tx := &FakeTxManager{Repos: repositories.Repositories{Orders: mockOrderRepo, Inventory: mockInventoryRepo}}
+	service := NewOrderService(mockOrderRepo, new(MockCustomerRepo), nil, tx)
+
+	mockOrderRepo.On("Delete", mock.Anything, int64(9)).Return(nil)
+	mockInventoryRepo.On("Release", mock.Anything, int64(9), "order:9").Return(nil)
+
+	err := service.DeleteOrder(context.Background(), 9)
+
+	assert.NoError(t, err)
+	mockOrderRepo.AssertExpectations(t)
+	mockInventoryRepo.AssertExpectations(t)
+}
+
+func TestRestoreOrder_ReservesStock(t *testing.T) {
+	mockOrderRepo := new(MockOrderRepo)
+	mockInventoryRepo := new(MockInventoryRepo)
+	tx := &FakeTxManager{Repos: repositories.Repositories{Orders: mockOrderRepo, Inventory: mockInventoryRepo}}
+	service := NewOrderService(mockOrderRepo, new(MockCustomerRepo), nil, tx)
+
+	shoesID := int64(3)
+	mockOrderRepo.On("Restore", mock.Anything, int64(9)).Return(nil)
+	mockOrderRepo.On("GetByID", mock.Anything, int64(9)).Return(&models.Order{
+		ID:     9,
+		Status: models.OrderStatusConfirmed,
+		Items:  []models.OrderItem{{ProductID: &shoesID, Quantity: 2}},
+	}, nil)
+	mockInventoryRepo.On("Reserve", mock.Anything, int64(9), map[int64]int{3: 2}, "MAIN", "order:9").Return(&models.StockError{ProductID: 3, Requested: 2})
+
+	err := service.RestoreOrder(context.Background(), 9)
+
+	var stockErr *models.StockError
+	assert.ErrorAs(t, err, &stockErr)
+	mockInventoryRepo.AssertExpectations(t)
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/chesireabel/Technical-Interview/internal/repositories"
+)
+
+// orderTransitions is the order lifecycle state machine: each status maps to
+// the statuses it may move to next. Delivered and cancelled are terminal.
+// Orders move to paid when a payment succeeds; orders paid on delivery go
+// straight from pending to confirmed. Scheduled orders are confirmed when
+// their time comes and, like orders paid on delivery, are settled then.
+var orderTransitions = map[models.OrderStatus][]models.OrderStatus{
+	models.OrderStatusScheduled: {models.OrderStatusConfirmed, models.OrderStatusCancelled},
+	models.OrderStatusPending:   {models.OrderStatusPaid, models.OrderStatusConfirmed, models.OrderStatusCancelled},
+	models.OrderStatusPaid:      {models.OrderStatusConfirmed, models.OrderStatusCancelled},
+	models.OrderStatusConfirmed: {models.OrderStatusShipped, models.OrderStatusCancelled},
+	models.OrderStatusShipped:   {models.OrderStatusDelivered},
+	models.OrderStatusDelivered: {},
+	models.OrderStatusCancelled: {},
+}
+
+// customerVisibleStatuses are the statuses the outbox dispatcher tells the customer about by SMS
+var customerVisibleStatuses = map[models.OrderStatus]bool{
+	models.OrderStatusPaid:      true,
+	models.OrderStatusConfirmed: true,
+	models.OrderStatusShipped:   true,
+	models.OrderStatusDelivered: true,
+	models.OrderStatusCancelled: true,
+}
+
+// CanTransition reports whether an order may move from one status to another
+func CanTransition(from, to models.OrderStatus) bool {
+	for _, next := range orderTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// TransitionOrder moves an order to a new status and records who made the
+// change. The repository queues the customer notification in the outbox.
+// Shipping an order takes the reserved stock out of the warehouse in the same
+// transaction. Orders only become paid when their payment succeeds, and are
+// cancelled through CancelOrder so a reason is given and refunds are not missed.
+func (s *orderService) TransitionOrder(ctx context.Context, id int64, to models.OrderStatus, actor, reason string) (*models.Order, error) {
+	switch models.OrderStatus(strings.ToLower(strings.TrimSpace(string(to)))) {
+	case models.OrderStatusPaid:
+		return nil, fmt.Errorf("%w: orders become paid when their payment succeeds", models.ErrStatusNotSettable)
+	case models.OrderStatusCancelled:
+		return nil, fmt.Errorf("%w: cancel the order with a reason instead", models.ErrStatusNotSettable)
+	}
+	return s.transition(ctx, id, to, actor, reason, nil)
+}
+
+// CancelOrder cancels an order for the given reason. With refund set it also
+// refunds whatever has not been refunded yet, in the same transaction, so an
+// order is never left cancelled with the refund missing.
+func (s *orderService) CancelOrder(ctx context.Context, id int64, actor, reason string, refund bool) (*models.Order, *models.Refund, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, nil, errors.New("reason is required")
+	}
+
+	var issued *models.Refund
+	order, err := s.transition(ctx, id, models.OrderStatusCancelled, actor, reason, func(ctx context.Context, repos repositories.Repositories, order *models.Order) error {
+		if !refund {
+			return nil
+		}
+
+		r := &models.Refund{OrderID: order.ID, Reason: reason, CreatedBy: actor}
+		err := repos.Refunds.Create(ctx, r)
+		var refundErr *models.RefundError
+		if errors.As(err, &refundErr) && !refundErr.Refundable.IsPositive() {
+			// Nothing was paid, or it was already refunded in full before it
+			// was cancelled
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		issued = r
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return order, issued, nil
+}
+
+// transition runs a status change, calling then (when set) inside the same
+// transaction after the order has moved
+func (s *orderService) transition(ctx context.Context, id int64, to models.OrderStatus, actor, reason string, then func(context.Context, repositories.Repositories, *models.Order) error) (*models.Order, error) {
+	if id == 0 {
+		return nil, errors.New("id is required")
+	}
+
+	to = models.OrderStatus(strings.ToLower(strings.TrimSpace(string(to))))
+	if _, known := orderTransitions[to]; !known {
+		return nil, fmt.Errorf("unknown order status %q", to)
+	}
+	if actor == "" {
+		return nil, errors.New("actor is required")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	order, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !CanTransition(order.Status, to) {
+		return nil, &models.TransitionError{
+			From:    order.Status,
+			To:      to,
+			Allowed: orderTransitions[order.Status],
+		}
+	}
+
+	change := &models.OrderStatusChange{
+		OrderID:    order.ID,
+		FromStatus: order.Status,
+		ToStatus:   to,
+		ChangedBy:  actor,
+		Reason:     strings.TrimSpace(reason),
+	}
+	err = s.tx.WithinTx(ctx, func(ctx context.Context, repos repositories.Repositories) error {
+		if err := repos.Orders.Transition(ctx, change); err != nil {
+			return err
+		}
+
+		switch to {
+		case models.OrderStatusCancelled:
+			if order.PromoCode != "" {
+				if err := repos.Promotions.Release(ctx, order.ID); err != nil {
+					return err
+				}
+			}
+			if err := repos.Inventory.Release(ctx, order.ID, actor); err != nil {
+				return err
+			}
+		case models.OrderStatusShipped:
+			if err := repos.Inventory.Fulfil(ctx, order.ID, actor); err != nil {
+				return err
+			}
+		}
+
+		if then != nil {
+			return then(ctx, repos, order)
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			// The order changed status between our read and the update
+			return nil, &models.TransitionError{From: order.Status, To: to}
+		}
+		return nil, err
+	}
+	order.Status = to
+
+	return order, nil
+}
+
+func (s *orderService) GetOrderStatusHistory(ctx context.Context, id int64) ([]models.OrderStatusChange, error) {
+	if id == 0 {
+		return nil, errors.New("id is required")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if _, err := s.repo.GetByID(ctx, id); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetStatusHistory(ctx, id)
+}
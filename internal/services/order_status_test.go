@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/chesireabel/Technical-Interview/internal/repositories"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to models.OrderStatus
+		want     bool
+	}{
+		{models.OrderStatusPending, models.OrderStatusConfirmed, true},
+		{models.OrderStatusPending, models.OrderStatusCancelled, true},
+		{models.OrderStatusPending, models.OrderStatusShipped, false},
+		{models.OrderStatusPending, models.OrderStatusPaid, true},
+		{models.OrderStatusPaid, models.OrderStatusConfirmed, true},
+		{models.OrderStatusPaid, models.OrderStatusPending, false},
+		{models.OrderStatusScheduled, models.OrderStatusPending, false},
+		{models.OrderStatusScheduled, models.OrderStatusConfirmed, true},
+		{models.OrderStatusScheduled, models.OrderStatusCancelled, true},
+		{models.OrderStatusScheduled, models.OrderStatusShipped, false},
+		{models.OrderStatusPending, models.OrderStatusScheduled, false},
+		{models.OrderStatusConfirmed, models.OrderStatusShipped, true},
+		{models.OrderStatusShipped, models.OrderStatusDelivered, true},
+		{models.OrderStatusShipped, models.OrderStatusCancelled, false},
+		{models.OrderStatusDelivered, models.OrderStatusPending, false},
+		{models.OrderStatusCancelled, models.OrderStatusConfirmed, false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, CanTransition(tt.from, tt.to), "%s -> %s", tt.from, tt.to)
+	}
+}
+
+func TestTransitionOrder(t *testing.T) {
+	mockOrderRepo := new(MockOrderRepo)
+	mockInventoryRepo := new(MockInventoryRepo)
+	tx := &FakeTxManager{Repos: repositories.Repositories{Orders: mockOrderRepo, Inventory: mockInventoryRepo}}
+	service := NewOrderService(mockOrderRepo, nil, nil, tx)
+
+	order := &models.Order{ID: 3, CustomerID: "1", Item: "Laptop", Amount: decimal.NewFromInt(1000), Status: models.OrderStatusConfirmed}
+
+	mockOrderRepo.On("GetByID", mock.Anything, int64(3)).Return(order, nil)
+	mockOrderRepo.On("Transition", mock.Anything, mock.MatchedBy(func(c *models.OrderStatusChange) bool {
+		return c.OrderID == 3 &&
+			c.FromStatus == models.OrderStatusConfirmed &&
+			c.ToStatus == models.OrderStatusShipped &&
+			c.ChangedBy == "agent@example.com" &&
+			c.Reason == "picked up by rider"
+	})).Return(nil)
+	mockInventoryRepo.On("Fulfil", mock.Anything, int64(3), "agent@example.com").Return(nil)
+
+	updated, err := service.TransitionOrder(context.Background(), 3, "Shipped", "agent@example.com", " picked up by rider ")
+
+	assert.NoError(t, err)
+	assert.Equal(t, models.OrderStatusShipped, updated.Status)
+	mockOrderRepo.AssertExpectations(t)
+	mockInventoryRepo.AssertExpectations(t)
+}
+
+func TestCancelOrder_ReleasesStock(t *testing.T) {
+	mockOrderRepo := new(MockOrderRepo)
+	mockInventoryRepo := new(MockInventoryRepo)
+	tx := &FakeTxManager{Repos: repositories.Repositories{Orders: mockOrderRepo, Inventory: mockInventoryRepo}}
+	service := NewOrderService(mockOrderRepo, nil, nil, tx)
+
+	order := &models.Order{ID: 3, CustomerID: "1", Status: models.OrderStatusPending}
+	mockOrderRepo.On("GetByID", mock.Anything, int64(3)).Return(order, nil)
+	mockOrderRepo.On("Transition", mock.Anything, mock.Anything).Return(nil)
+	mockInventoryRepo.On("Release", mock.Anything, int64(3), "agent@example.com").Return(errors.New("failed to release stock: timeout"))
+
+	_, _, err := service.CancelOrder(context.Background(), 3, "agent@example.com", "customer changed their mind", false)
+
+	// The transaction is rolled back, so the order keeps its status
+	assert.EqualError(t, err, "failed to release stock: timeout")
+	assert.Equal(t, models.OrderStatusPending, order.Status)
+	mockInventoryRepo.AssertExpectations(t)
+}
+
+func TestCancelOrder_ReleasesPromoCode(t *testing.T) {
+	mockOrderRepo := new(MockOrderRepo)
+	mockInventoryRepo := new(MockInventoryRepo)
+	mockPromotionRepo := new(MockPromotionRepo)
+	tx := &FakeTxManager{Repos: repositories.Repositories{Orders: mockOrderRepo, Inventory: mockInventoryRepo, Promotions: mockPromotionRepo}}
+	service := NewOrderService(mockOrderRepo, nil, nil, tx)
+
+	order := &models.Order{ID: 3, CustomerID: "1", Status: models.OrderStatusPending, PromoCode: "JAMHURI"}
+	mockOrderRepo.On("GetByID", mock.Anything, int64(3)).Return(order, nil)
+	mockOrderRepo.On("Transition", mock.Anything, mock.Anything).Return(nil)
+	mockPromotionRepo.On("Release", mock.Anything, int64(3)).Return(nil)
+	mockInventoryRepo.On("Release", mock.Anything, int64(3), "agent@example.com").Return(nil)
+
+	_, _, err := service.CancelOrder(context.Background(), 3, "agent@example.com", "customer changed their mind", false)
+
+	assert.NoError(t, err)
+	mockPromotionRepo.AssertExpectations(t)
+}
+
+func TestTransitionOrder_Illegal(t *testing.T) {
+	mockOrderRepo := new(MockOrderRepo)
+	service := NewOrderService(mockOrderRepo, nil, nil, nil)
+
+	order := &models.Order{ID: 3, CustomerID: "1", Status: models.OrderStatusDelivered}
+	mockOrderRepo.On("GetByID", mock.Anything, int64(3)).Return(order, nil)
+
+	_, err := service.TransitionOrder(context.Background(), 3, models.OrderStatusConfirmed, "agent@example.com", "")
+
+	assert.ErrorIs(t, err, models.ErrInvalidTransition)
+	assert.Equal(t, "cannot change order status from delivered to confirmed", err.Error())
+	mockOrderRepo.AssertNotCalled(t, "Transition", mock.Anything, mock.Anything)
+}
+
+func TestTransitionOrder_PaidAndCancelledHaveTheirOwnFlows(t *testing.T) {
+	mockOrderRepo := new(MockOrderRepo)
+	service := NewOrderService(mockOrderRepo, nil, nil, nil)
+
+	for _, to := range []models.OrderStatus{models.OrderStatusPaid, "Cancelled"} {
+		_, err := service.TransitionOrder(context.Background(), 3, to, "agent@example.com", "")
+
+		assert.ErrorIs(t, err, models.ErrStatusNotSettable, to)
+	}
+	mockOrderRepo.AssertNotCalled(t, "GetByID", mock.Anything, mock.Anything)
+}
+
+func TestTransitionOrder_ConcurrentChange(t *testing.T) {
+	mockOrderRepo := new(MockOrderRepo)
+	service := NewOrderService(mockOrderRepo, nil, nil, &FakeTxManager{Repos: repositories.Repositories{Orders: mockOrderRepo}})
+
+	order := &models.Order{ID: 3, Status: models.OrderStatusPending}
+	mockOrderRepo.On("GetByID", mock.Anything, int64(3)).Return(order, nil)
+	mockOrderRepo.On("Transition", mock.Anything, mock.Anything).Return(fmt.Errorf("order with id 3 in status pending: %w", models.ErrNotFound))
+
+	_, err := service.TransitionOrder(context.Background(), 3, models.OrderStatusConfirmed, "agent@example.com", "")
+
+	assert.ErrorIs(t, err, models.ErrInvalidTransition)
+}
+
+func TestTransitionOrder_UnknownStatus(t *testing.T) {
+	service := NewOrderService(new(MockOrderRepo), nil, nil, nil)
+
+	_, err := service.TransitionOrder(context.Background(), 3, "lost", "agent@example.com", "")
+
+	assert.EqualError(t, err, `unknown order status "lost"`)
+}
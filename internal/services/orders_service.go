@@ -4,53 +4,203 @@ package services
 import (
 	"context"
 	"errors"
-	"log"
+	"fmt"
+	"io"
+	"maps"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/chesireabel/Technical-Interview/internal/models"
 	"github.com/chesireabel/Technical-Interview/internal/repositories"
+	"github.com/shopspring/decimal"
 )
 
 type OrderService interface {
 	CreateOrder(ctx context.Context, order *models.Order) (int64, error)
 	GetOrder(ctx context.Context, id int64) (*models.Order, error)
-	GetOrdersByCustomer(ctx context.Context, customerID int64) ([]models.Order, error)
-	GetAllOrders(ctx context.Context) ([]models.Order, error)
+	GetOrdersByCustomer(ctx context.Context, customerID int64, includeDeleted bool) ([]models.Order, error)
+	GetAllOrders(ctx context.Context, includeDeleted bool) ([]models.Order, error)
 	UpdateOrder(ctx context.Context, order *models.Order) error
 	DeleteOrder(ctx context.Context, id int64) error
+	RestoreOrder(ctx context.Context, id int64) error
+	ExportOrders(ctx context.Context, w io.Writer, format ExportFormat, customerID int64, includeDeleted bool) error
+	TransitionOrder(ctx context.Context, id int64, to models.OrderStatus, actor, reason string) (*models.Order, error)
+	CancelOrder(ctx context.Context, id int64, actor, reason string, refund bool) (*models.Order, *models.Refund, error)
+	// RescheduleOrder moves a scheduled order that has not fired yet to a new time
+	RescheduleOrder(ctx context.Context, id int64, scheduledFor time.Time) (*models.Order, error)
+	GetOrderStatusHistory(ctx context.Context, id int64) ([]models.OrderStatusChange, error)
 }
 
 type orderService struct {
-	repo            repositories.OrderRepository
-	customerRepo    repositories.CustomerRepository
-	smsService      SMSService
+	repo          repositories.OrderRepository
+	customerRepo  repositories.CustomerRepository
+	productRepo   repositories.ProductRepository
+	tx            repositories.TxManager
+	stockLocation string
 }
 
-func NewOrderService(repo repositories.OrderRepository, customerRepo repositories.CustomerRepository, smsService SMSService) OrderService {
+// NewOrderService creates the order service. Customer notifications are not
+// sent from here: the repository writes them to the outbox in the same
+// transaction as the order and the OutboxDispatcher delivers them. tx is used
+// to reserve and release stock atomically with order changes.
+func NewOrderService(repo repositories.OrderRepository, customerRepo repositories.CustomerRepository, productRepo repositories.ProductRepository, tx repositories.TxManager) OrderService {
 	return &orderService{
-		repo:         repo,
-		customerRepo: customerRepo,
-		smsService:   smsService,
+		repo:          repo,
+		customerRepo:  customerRepo,
+		productRepo:   productRepo,
+		tx:            tx,
+		stockLocation: stockDefaultLocation(),
 	}
 }
 
+// maxItemSummaryLength matches the orders.item column
+const maxItemSummaryLength = 100
+
+// prepareOrder snapshots catalogue products into the order lines, then
+// validates the order and computes its totals
+func prepareOrder(ctx context.Context, products repositories.ProductRepository, order *models.Order) error {
+	if err := snapshotProducts(ctx, products, order, nil); err != nil {
+		return err
+	}
+	return prepareOrderItems(order)
+}
+
+// prepareOrderItems validates the currency and line items and computes line
+// totals, the order amount and the item summary server-side. Prices must be
+// exact in the order's currency (no fractions of a shilling for UGX); totals are
+// exact sums so no rounding happens here. Orders sent in the older single
+// item/amount shape become an order with one line.
+func prepareOrderItems(order *models.Order) error {
+	currency := models.DefaultCurrency()
+	if order.Currency != "" {
+		c, err := models.LookupCurrency(order.Currency)
+		if err != nil {
+			return err
+		}
+		currency = c
+	}
+	order.Currency = currency.Code
+
+	if len(order.Items) == 0 {
+		if order.Item == "" {
+			return errors.New("item is required")
+		}
+		if !order.Amount.IsPositive() {
+			return errors.New("amount must be greater than 0")
+		}
+		order.Items = []models.OrderItem{{
+			Description: order.Item,
+			Quantity:    1,
+			UnitPrice:   order.Amount,
+		}}
+	}
+
+	total := decimal.Zero
+	for i := range order.Items {
+		item := &order.Items[i]
+		item.Description = strings.TrimSpace(item.Description)
+		item.ProductRef = strings.TrimSpace(item.ProductRef)
+
+		if item.Description == "" {
+			return fmt.Errorf("items[%d].description is required", i)
+		}
+		if item.Quantity <= 0 {
+			return fmt.Errorf("items[%d].quantity must be greater than 0", i)
+		}
+		if !item.UnitPrice.IsPositive() {
+			return fmt.Errorf("items[%d].unit_price must be greater than 0", i)
+		}
+		if !currency.Exact(item.UnitPrice) {
+			return fmt.Errorf("items[%d].unit_price has more decimal places than %s allows", i, currency.Code)
+		}
+
+		item.LineTotal = item.UnitPrice.Mul(decimal.NewFromInt(int64(item.Quantity)))
+		total = total.Add(item.LineTotal)
+	}
+
+	order.Subtotal = total
+	order.Discount = decimal.Zero
+	order.Amount = total
+	order.Item = summariseItems(order.Items)
+	return nil
+}
+
+// placeOrder stores a prepared order with its delivery address, redeems its
+// promo code, charges tax and reserves stock for its catalogue products. It must run inside a transaction so an
+// invalid code or an out of stock product also undoes the order.
+func placeOrder(ctx context.Context, repos repositories.Repositories, order *models.Order, location string) (int64, error) {
+	if err := prepareDelivery(ctx, repos.Addresses, order); err != nil {
+		return 0, err
+	}
+
+	var promotion *models.Promotion
+	if order.PromoCode = normalisePromoCode(order.PromoCode); order.PromoCode != "" {
+		var err error
+		if promotion, err = applyPromotion(ctx, repos.Promotions, order, time.Now()); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := applyTax(ctx, repos.TaxRates, order); err != nil {
+		return 0, err
+	}
+
+	orderID, err := repos.Orders.Create(ctx, order)
+	if err != nil {
+		return 0, err
+	}
+
+	if promotion != nil {
+		customerID, _ := strconv.ParseInt(order.CustomerID, 10, 64)
+		err := repos.Promotions.Redeem(ctx, &models.PromotionRedemption{
+			PromotionID: promotion.ID,
+			OrderID:     orderID,
+			CustomerID:  customerID,
+			Discount:    order.Discount,
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if quantities := stockQuantities(order); len(quantities) > 0 {
+		if err := repos.Inventory.Reserve(ctx, orderID, quantities, location, fmt.Sprintf("order:%d", orderID)); err != nil {
+			return 0, err
+		}
+	}
+
+	return orderID, nil
+}
+
+// summariseItems builds the short description stored in orders.item, e.g. "Shoes +2 more"
+func summariseItems(items []models.OrderItem) string {
+	summary := items[0].Description
+	if len(items) > 1 {
+		summary = fmt.Sprintf("%s +%d more", summary, len(items)-1)
+	}
+	if runes := []rune(summary); len(runes) > maxItemSummaryLength {
+		summary = string(runes[:maxItemSummaryLength])
+	}
+	return summary
+}
+
 func (s *orderService) CreateOrder(ctx context.Context, order *models.Order) (int64, error) {
 	// Validation
 	if order.CustomerID == "" {
 		return 0, errors.New("customer_id is required")
 	}
-	if order.Item == "" {
-		return 0, errors.New("item is required")
-	}
-	if order.Amount <= 0 {
-		return 0, errors.New("amount must be greater than 0")
-	}
 
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	// Get customer details to fetch phone number
+	if err := prepareOrder(ctx, s.productRepo, order); err != nil {
+		return 0, err
+	}
+	if err := setInitialStatus(order, time.Now()); err != nil {
+		return 0, err
+	}
+
 	customerIDInt, err := strconv.ParseInt(order.CustomerID, 10, 64)
 	if err != nil {
 		return 0, errors.New("invalid customer_id format")
@@ -60,33 +210,21 @@ func (s *orderService) CreateOrder(ctx context.Context, order *models.Order) (in
 	if err != nil {
 		return 0, errors.New("customer not found")
 	}
+	order.TaxRegion = taxRegion(customer)
 
-	// Create order in database
-	orderID, err := s.repo.Create(ctx, order)
+	// The order.created outbox message is written with the order, the
+	// confirmation SMS goes out from the dispatcher
+	var orderID int64
+	err = s.tx.WithinTx(ctx, func(ctx context.Context, repos repositories.Repositories) error {
+		var err error
+		orderID, err = placeOrder(ctx, repos, order, s.stockLocation)
+		return err
+	})
 	if err != nil {
 		return 0, err
 	}
-
-	// Set the order ID for SMS notification
 	order.ID = orderID
 
-	// Send SMS notification asynchronously to avoid blocking
-	if s.smsService != nil && customer.Phone != "" {
-			// Create a new context for the async operation with timeout
-			smsCtx, smsCancel := context.WithTimeout(context.Background(), 30*time.Second)
-			defer smsCancel()
-
-			if err := s.smsService.SendOrderConfirmation(smsCtx, order, customer); err != nil {
-				// Log error but don't fail the order creation
-				log.Printf("Failed to send SMS for order %d to %s: %v", orderID, customer.Phone, err)
-			} else {
-				log.Printf("SMS sent successfully for order %d to %s (%s)", orderID, customer.Customer_name, customer.Phone)
-			}
-		
-	} else {
-		log.Printf("SMS service not available or customer phone missing for order %d", orderID)
-	}
-
 	return orderID, nil
 }
 
@@ -98,10 +236,16 @@ func (s *orderService) GetOrder(ctx context.Context, id int64) (*models.Order, e
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	return s.repo.GetByID(ctx, id)
+	order, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	order.TaxBreakdown = models.TaxBreakdown(order.Items)
+
+	return order, nil
 }
 
-func (s *orderService) GetOrdersByCustomer(ctx context.Context, customerID int64) ([]models.Order, error) {
+func (s *orderService) GetOrdersByCustomer(ctx context.Context, customerID int64, includeDeleted bool) ([]models.Order, error) {
 	if customerID == 0 {
 		return nil, errors.New("customer_id is required")
 	}
@@ -109,14 +253,14 @@ func (s *orderService) GetOrdersByCustomer(ctx context.Context, customerID int64
 	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
-	return s.repo.GetByCustomerID(ctx, customerID)
+	return s.repo.GetByCustomerID(ctx, customerID, includeDeleted)
 }
 
-func (s *orderService) GetAllOrders(ctx context.Context) ([]models.Order, error) {
+func (s *orderService) GetAllOrders(ctx context.Context, includeDeleted bool) ([]models.Order, error) {
 	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
-	return s.repo.GetAll(ctx)
+	return s.repo.GetAll(ctx, includeDeleted)
 }
 
 func (s *orderService) UpdateOrder(ctx context.Context, order *models.Order) error {
@@ -126,17 +270,74 @@ func (s *orderService) UpdateOrder(ctx context.Context, order *models.Order) err
 	if order.CustomerID == "" {
 		return errors.New("customer_id is required")
 	}
-	if order.Item == "" {
-		return errors.New("item is required")
-	}
-	if order.Amount <= 0 {
-		return errors.New("amount must be greater than 0")
-	}
 
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	return s.repo.Update(ctx, order)
+	// An order keeps its currency, the prices its catalogue lines were placed
+	// at, and the promo code and tax region it was placed with; the discount
+	// and tax follow the new lines but the code's limits were checked when it
+	// was redeemed
+	return s.tx.WithinTx(ctx, func(ctx context.Context, repos repositories.Repositories) error {
+		existing, err := repos.Orders.GetByID(ctx, order.ID)
+		if err != nil {
+			return err
+		}
+		if existing.Status != models.OrderStatusPending && existing.Status != models.OrderStatusScheduled {
+			return models.ErrNotEditable
+		}
+		// The customer may be approving the current amount right now
+		paying, err := repos.Payments.HasPending(ctx, order.ID, time.Now().Add(-paymentAttemptTimeout))
+		if err != nil {
+			return err
+		}
+		if paying {
+			return models.ErrPaymentInProgress
+		}
+
+		if order.Currency == "" {
+			order.Currency = existing.Currency
+		}
+		if !strings.EqualFold(order.Currency, existing.Currency) {
+			return errors.New("currency cannot be changed once the order is placed")
+		}
+		if err := snapshotProducts(ctx, repos.Products, order, existing.Items); err != nil {
+			return err
+		}
+		if err := prepareOrderItems(order); err != nil {
+			return err
+		}
+
+		order.PromoCode = existing.PromoCode
+		if order.PromoCode != "" {
+			promotion, err := repos.Promotions.GetByCode(ctx, order.PromoCode)
+			if err != nil {
+				return err
+			}
+			if order.Discount, err = promotionDiscount(promotion, order); err != nil {
+				return err
+			}
+		}
+
+		order.TaxRegion = existing.TaxRegion
+		if err := applyTax(ctx, repos.TaxRates, order); err != nil {
+			return err
+		}
+
+		if err := repos.Orders.Update(ctx, order); err != nil {
+			return err
+		}
+
+		// Swap the old reservation for one matching the new lines, so a
+		// product that is now out of stock undoes the edit
+		if maps.Equal(stockQuantities(existing), stockQuantities(order)) {
+			return nil
+		}
+		if err := repos.Inventory.Release(ctx, order.ID, fmt.Sprintf("order:%d", order.ID)); err != nil {
+			return err
+		}
+		return reserveStock(ctx, repos.Inventory, order, s.stockLocation)
+	})
 }
 
 func (s *orderService) DeleteOrder(ctx context.Context, id int64) error {
@@ -147,5 +348,35 @@ func (s *orderService) DeleteOrder(ctx context.Context, id int64) error {
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	return s.repo.Delete(ctx, id)
-}
\ No newline at end of file
+	// A deleted order gives back whatever stock it still has reserved
+	return s.tx.WithinTx(ctx, func(ctx context.Context, repos repositories.Repositories) error {
+		if err := repos.Orders.Delete(ctx, id); err != nil {
+			return err
+		}
+		return repos.Inventory.Release(ctx, id, fmt.Sprintf("order:%d", id))
+	})
+}
+
+func (s *orderService) RestoreOrder(ctx context.Context, id int64) error {
+	if id == 0 {
+		return errors.New("id is required for restore")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return s.tx.WithinTx(ctx, func(ctx context.Context, repos repositories.Repositories) error {
+		if err := repos.Orders.Restore(ctx, id); err != nil {
+			return err
+		}
+
+		order, err := repos.Orders.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !holdsStock(order.Status) {
+			return nil
+		}
+		return reserveStock(ctx, repos.Inventory, order, s.stockLocation)
+	})
+}
@@ -0,0 +1,333 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/chesireabel/Technical-Interview/internal/payments"
+	"github.com/chesireabel/Technical-Interview/internal/repositories"
+)
+
+const (
+	defaultOutboxPollInterval = time.Second
+	defaultOutboxBatchSize    = 20
+	defaultOutboxMaxAttempts  = 8
+	outboxBaseBackoff         = 30 * time.Second
+	outboxMaxBackoff          = time.Hour
+	// outboxLease must comfortably exceed the time a batch takes to deliver,
+	// otherwise another replica may claim the same messages again
+	outboxLease = 5 * time.Minute
+)
+
+// OutboxDispatcher delivers messages written to the outbox by the repositories.
+// Several replicas can run it at once; each claims a disjoint batch.
+type OutboxDispatcher interface {
+	DispatchBatch(ctx context.Context) (int, error)
+	Run(ctx context.Context)
+}
+
+// outboxHandler delivers one message. Returning a permanent error dead-letters
+// the message straight away, any other error schedules a retry.
+type outboxHandler func(ctx context.Context, msg *models.OutboxMessage) error
+
+type outboxDispatcher struct {
+	repo         repositories.OutboxRepository
+	orderRepo    repositories.OrderRepository
+	customerRepo repositories.CustomerRepository
+	smsService   SMSService
+	refundPayer  RefundPayer
+	handlers     map[string]outboxHandler
+	batchSize    int
+	maxAttempts  int
+	interval     time.Duration
+	now          func() time.Time
+}
+
+// permanentError marks a delivery failure that retrying cannot fix
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+func permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// NewOutboxDispatcher reads OUTBOX_POLL_INTERVAL (a Go duration, default 1s),
+// OUTBOX_BATCH_SIZE (default 20) and OUTBOX_MAX_ATTEMPTS (default 8) from the
+// environment. smsService may be nil, in which case notifications are skipped.
+// refundPayer returns the money of each refund before its notice is sent.
+func NewOutboxDispatcher(repo repositories.OutboxRepository, orderRepo repositories.OrderRepository, customerRepo repositories.CustomerRepository, smsService SMSService, refundPayer RefundPayer) (OutboxDispatcher, error) {
+	interval := defaultOutboxPollInterval
+	if v := os.Getenv("OUTBOX_POLL_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("OUTBOX_POLL_INTERVAL must be a positive duration, got %q", v)
+		}
+		interval = d
+	}
+
+	batchSize, err := positiveIntEnv("OUTBOX_BATCH_SIZE", defaultOutboxBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	maxAttempts, err := positiveIntEnv("OUTBOX_MAX_ATTEMPTS", defaultOutboxMaxAttempts)
+	if err != nil {
+		return nil, err
+	}
+
+	return newOutboxDispatcher(repo, orderRepo, customerRepo, smsService, refundPayer, batchSize, maxAttempts, interval), nil
+}
+
+func newOutboxDispatcher(repo repositories.OutboxRepository, orderRepo repositories.OrderRepository, customerRepo repositories.CustomerRepository, smsService SMSService, refundPayer RefundPayer, batchSize, maxAttempts int, interval time.Duration) *outboxDispatcher {
+	d := &outboxDispatcher{
+		repo:         repo,
+		orderRepo:    orderRepo,
+		customerRepo: customerRepo,
+		smsService:   smsService,
+		refundPayer:  refundPayer,
+		batchSize:    batchSize,
+		maxAttempts:  maxAttempts,
+		interval:     interval,
+		now:          time.Now,
+	}
+	d.handlers = map[string]outboxHandler{
+		models.TopicOrderCreated:       d.sendOrderConfirmation,
+		models.TopicOrderStatusChanged: d.sendOrderUpdate,
+		models.TopicOrderRefunded:      d.payOutRefund,
+	}
+	return d
+}
+
+func positiveIntEnv(name string, fallback int) (int, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("%s must be a positive integer, got %q", name, v)
+	}
+	return n, nil
+}
+
+// backoff is the delay before the next attempt: 30s doubling per attempt, capped at an hour
+func backoff(attempts int) time.Duration {
+	delay := outboxBaseBackoff
+	for i := 1; i < attempts && delay < outboxMaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, outboxMaxBackoff)
+}
+
+// DispatchBatch claims and delivers one batch of due messages and returns how many were claimed
+func (d *outboxDispatcher) DispatchBatch(ctx context.Context) (int, error) {
+	claimCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	messages, err := d.repo.Claim(claimCtx, d.batchSize, outboxLease)
+	cancel()
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range messages {
+		d.deliver(ctx, &messages[i])
+	}
+
+	return len(messages), nil
+}
+
+func (d *outboxDispatcher) deliver(ctx context.Context, msg *models.OutboxMessage) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	var err error
+	if handler, ok := d.handlers[msg.Topic]; ok {
+		err = handler(ctx, msg)
+	} else {
+		err = permanent(fmt.Errorf("no handler for topic %s", msg.Topic))
+	}
+
+	var perm *permanentError
+	switch {
+	case err == nil:
+		err = d.repo.MarkSent(ctx, msg.ID)
+	case errors.As(err, &perm) || msg.Attempts >= d.maxAttempts:
+		log.Printf("☠️ Outbox message %d (%s) dead-lettered after %d attempt(s): %v", msg.ID, msg.Topic, msg.Attempts, err)
+		err = d.repo.MarkDead(ctx, msg.ID, err.Error())
+	default:
+		next := d.now().Add(backoff(msg.Attempts))
+		log.Printf("⚠️ Outbox message %d (%s) attempt %d failed, retrying at %s: %v", msg.ID, msg.Topic, msg.Attempts, next.Format(time.RFC3339), err)
+		err = d.repo.Retry(ctx, msg.ID, err.Error(), next)
+	}
+
+	if err != nil {
+		// The lease runs out and the message is claimed again
+		log.Printf("⚠️ Failed to update outbox message %d: %v", msg.ID, err)
+	}
+}
+
+// Run dispatches until ctx is cancelled, draining full batches back to back and
+// polling every interval once the outbox is empty
+func (d *outboxDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := d.DispatchBatch(ctx)
+		if err != nil {
+			log.Printf("⚠️ Outbox dispatch failed: %v", err)
+		}
+		if err == nil && n == d.batchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// loadRecipient fetches the order and the customer to notify. A missing order
+// or customer is permanent: the record was deleted after the message was written.
+func (d *outboxDispatcher) loadRecipient(ctx context.Context, orderID int64) (*models.Order, *models.Customer, error) {
+	order, err := d.orderRepo.GetByID(ctx, orderID)
+	if errors.Is(err, models.ErrNotFound) {
+		return nil, nil, permanent(err)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	customerID, err := strconv.ParseInt(order.CustomerID, 10, 64)
+	if err != nil {
+		return nil, nil, permanent(fmt.Errorf("invalid customer_id %q on order %d", order.CustomerID, order.ID))
+	}
+
+	customer, err := d.customerRepo.GetByID(ctx, customerID)
+	if errors.Is(err, models.ErrNotFound) {
+		return nil, nil, permanent(err)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return order, customer, nil
+}
+
+func (d *outboxDispatcher) sendOrderConfirmation(ctx context.Context, msg *models.OutboxMessage) error {
+	if d.smsService == nil {
+		log.Printf("SMS service not available, skipping confirmation for order %d", msg.AggregateID)
+		return nil
+	}
+
+	order, customer, err := d.loadRecipient(ctx, msg.AggregateID)
+	if err != nil {
+		return err
+	}
+	if customer.Phone == "" {
+		log.Printf("Customer phone missing for order %d", order.ID)
+		return nil
+	}
+
+	if err := d.smsService.SendOrderConfirmation(ctx, order, customer); err != nil {
+		return err
+	}
+	log.Printf("SMS sent successfully for order %d to %s (%s)", order.ID, customer.Customer_name, customer.Phone)
+	return nil
+}
+
+func (d *outboxDispatcher) sendOrderUpdate(ctx context.Context, msg *models.OutboxMessage) error {
+	var payload models.OrderStatusChangedPayload
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		return permanent(fmt.Errorf("invalid %s payload: %w", msg.Topic, err))
+	}
+	if payload.From == models.OrderStatusScheduled {
+		// The customer hears about a scheduled order only once it is confirmed
+		if payload.To != models.OrderStatusConfirmed {
+			return nil
+		}
+		return d.sendOrderConfirmation(ctx, msg)
+	}
+	if !customerVisibleStatuses[payload.To] {
+		return nil
+	}
+	if d.smsService == nil {
+		log.Printf("SMS service not available for status update of order %d", msg.AggregateID)
+		return nil
+	}
+
+	order, customer, err := d.loadRecipient(ctx, msg.AggregateID)
+	if err != nil {
+		return err
+	}
+	if customer.Phone == "" {
+		log.Printf("Customer phone missing for status update of order %d", order.ID)
+		return nil
+	}
+
+	// The order may have moved on since; the message describes this change
+	return d.smsService.SendOrderUpdate(ctx, order, customer.Phone, string(payload.To))
+}
+
+// payOutRefund returns the refund's money through the payment provider and
+// then tells the customer. The refund is marked once paid out, so a retry
+// after a failed SMS does not pay it twice. A payout the provider refuses
+// outright is not retried. Providers such as M-Pesa only accept the payout
+// here; the customer is told once their result callback queues the message
+// again.
+func (d *outboxDispatcher) payOutRefund(ctx context.Context, msg *models.OutboxMessage) error {
+	var payload models.OrderRefundedPayload
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		return permanent(fmt.Errorf("invalid %s payload: %w", msg.Topic, err))
+	}
+
+	paidOut, err := d.refundPayer.PayOut(ctx, payload.RefundID)
+	if errors.Is(err, payments.ErrInvalidRequest) || errors.Is(err, payments.ErrUnknownProvider) || errors.Is(err, models.ErrNotFound) {
+		return permanent(err)
+	}
+	if err != nil {
+		return err
+	}
+	if !paidOut {
+		return nil
+	}
+
+	return d.sendRefundNotice(ctx, msg, payload)
+}
+
+func (d *outboxDispatcher) sendRefundNotice(ctx context.Context, msg *models.OutboxMessage, payload models.OrderRefundedPayload) error {
+	if d.smsService == nil {
+		log.Printf("SMS service not available for refund %d of order %d", payload.RefundID, msg.AggregateID)
+		return nil
+	}
+
+	order, customer, err := d.loadRecipient(ctx, msg.AggregateID)
+	if err != nil {
+		return err
+	}
+	if customer.Phone == "" {
+		log.Printf("Customer phone missing for refund %d of order %d", payload.RefundID, order.ID)
+		return nil
+	}
+
+	refund := &models.Refund{
+		ID:        payload.RefundID,
+		OrderID:   order.ID,
+		Amount:    payload.Amount,
+		Currency:  payload.Currency,
+		Reason:    payload.Reason,
+		Remaining: payload.Remaining,
+	}
+	return d.smsService.SendRefundNotice(ctx, order, refund, customer.Phone)
+}
@@ -0,0 +1,309 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/chesireabel/Technical-Interview/internal/payments"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type dispatcherMocks struct {
+	outbox    *MockOutboxRepo
+	orders    *MockOrderRepo
+	customers *MockCustomerRepo
+	sms       *MockSMSService
+	refunds   *MockRefundRepo
+	payments  *MockPaymentRepo
+	provider  *payments.FakeProvider
+}
+
+func newTestDispatcher(now time.Time) (*outboxDispatcher, *dispatcherMocks) {
+	m := &dispatcherMocks{
+		outbox:    new(MockOutboxRepo),
+		orders:    new(MockOrderRepo),
+		customers: new(MockCustomerRepo),
+		sms:       new(MockSMSService),
+		refunds:   new(MockRefundRepo),
+		payments:  new(MockPaymentRepo),
+		provider:  payments.NewFakeProvider("card", "secret"),
+	}
+	payer := NewRefundPayer(m.refunds, m.payments, payments.NewRegistry(m.provider))
+	d := newOutboxDispatcher(m.outbox, m.orders, m.customers, m.sms, payer, 10, 3, time.Second)
+	d.now = func() time.Time { return now }
+	return d, m
+}
+
+func statusPayload(t *testing.T, from, to models.OrderStatus) json.RawMessage {
+	body, err := json.Marshal(models.OrderStatusChangedPayload{From: from, To: to})
+	assert.NoError(t, err)
+	return body
+}
+
+func TestDispatchBatch_SendsOrderConfirmation(t *testing.T) {
+	d, m := newTestDispatcher(time.Now())
+
+	order := &models.Order{ID: 5, CustomerID: "1", Item: "Laptop", Amount: decimal.NewFromInt(1000), Currency: "KES"}
+	customer := &models.Customer{ID: 1, Customer_name: "Jane", Phone: "+254712345678"}
+
+	m.outbox.On("Claim", mock.Anything, 10, outboxLease).Return([]models.OutboxMessage{
+		{ID: 1, Topic: models.TopicOrderCreated, AggregateID: 5, Payload: json.RawMessage(`{}`), Attempts: 1},
+	}, nil)
+	m.orders.On("GetByID", mock.Anything, int64(5)).Return(order, nil)
+	m.customers.On("GetByID", mock.Anything, int64(1)).Return(customer, nil)
+	m.sms.On("SendOrderConfirmation", mock.Anything, order, customer).Return(nil)
+	m.outbox.On("MarkSent", mock.Anything, int64(1)).Return(nil)
+
+	n, err := d.DispatchBatch(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	m.sms.AssertExpectations(t)
+	m.outbox.AssertExpectations(t)
+}
+
+func TestDispatchBatch_StatusChange(t *testing.T) {
+	d, m := newTestDispatcher(time.Now())
+
+	order := &models.Order{ID: 3, CustomerID: "1", Status: models.OrderStatusDelivered}
+	customer := &models.Customer{ID: 1, Phone: "+254712345678"}
+
+	m.outbox.On("Claim", mock.Anything, 10, outboxLease).Return([]models.OutboxMessage{
+		{ID: 1, Topic: models.TopicOrderStatusChanged, AggregateID: 3, Payload: statusPayload(t, models.OrderStatusConfirmed, models.OrderStatusShipped), Attempts: 1},
+		{ID: 2, Topic: models.TopicOrderStatusChanged, AggregateID: 3, Payload: statusPayload(t, models.OrderStatusPending, models.OrderStatusPending), Attempts: 1},
+	}, nil)
+	m.orders.On("GetByID", mock.Anything, int64(3)).Return(order, nil).Once()
+	m.customers.On("GetByID", mock.Anything, int64(1)).Return(customer, nil).Once()
+	// The SMS describes the change in the message, not the order's current status
+	m.sms.On("SendOrderUpdate", mock.Anything, order, "+254712345678", "shipped").Return(nil).Once()
+	m.outbox.On("MarkSent", mock.Anything, int64(1)).Return(nil)
+	m.outbox.On("MarkSent", mock.Anything, int64(2)).Return(nil)
+
+	_, err := d.DispatchBatch(context.Background())
+
+	assert.NoError(t, err)
+	m.sms.AssertExpectations(t)
+	m.outbox.AssertExpectations(t)
+}
+
+func TestDispatchBatch_ScheduledOrderFires(t *testing.T) {
+	d, m := newTestDispatcher(time.Now())
+
+	order := &models.Order{ID: 3, CustomerID: "1", Status: models.OrderStatusConfirmed}
+	customer := &models.Customer{ID: 1, Phone: "+254712345678"}
+
+	m.outbox.On("Claim", mock.Anything, 10, outboxLease).Return([]models.OutboxMessage{
+		{ID: 1, Topic: models.TopicOrderStatusChanged, AggregateID: 3, Payload: statusPayload(t, models.OrderStatusScheduled, models.OrderStatusConfirmed), Attempts: 1},
+		{ID: 2, Topic: models.TopicOrderStatusChanged, AggregateID: 4, Payload: statusPayload(t, models.OrderStatusScheduled, models.OrderStatusCancelled), Attempts: 1},
+	}, nil)
+	m.orders.On("GetByID", mock.Anything, int64(3)).Return(order, nil).Once()
+	m.customers.On("GetByID", mock.Anything, int64(1)).Return(customer, nil).Once()
+	// The customer gets the confirmation, not a status update, when the order fires
+	m.sms.On("SendOrderConfirmation", mock.Anything, order, customer).Return(nil).Once()
+	m.outbox.On("MarkSent", mock.Anything, int64(1)).Return(nil)
+	m.outbox.On("MarkSent", mock.Anything, int64(2)).Return(nil)
+
+	_, err := d.DispatchBatch(context.Background())
+
+	assert.NoError(t, err)
+	m.sms.AssertExpectations(t)
+	m.sms.AssertNotCalled(t, "SendOrderUpdate", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	m.outbox.AssertExpectations(t)
+}
+
+func TestDispatchBatch_RefundNotice(t *testing.T) {
+	d, m := newTestDispatcher(time.Now())
+
+	order := &models.Order{ID: 3, CustomerID: "1", Item: "Laptop", Currency: "KES"}
+	customer := &models.Customer{ID: 1, Phone: "+254712345678"}
+	payload, err := json.Marshal(models.OrderRefundedPayload{
+		RefundID: 9,
+		Amount:   decimal.NewFromInt(400),
+		Currency: "KES",
+		Reason:   "damaged",
+	})
+	assert.NoError(t, err)
+
+	initiation, err := m.provider.Initiate(context.Background(), payments.InitiateRequest{OrderID: 3, Amount: decimal.NewFromInt(400)})
+	assert.NoError(t, err)
+	_, err = m.provider.SetState(initiation.Reference, models.PaymentSucceeded, "RCPT-1")
+	assert.NoError(t, err)
+
+	paymentID := int64(4)
+	m.outbox.On("Claim", mock.Anything, 10, outboxLease).Return([]models.OutboxMessage{
+		{ID: 1, Topic: models.TopicOrderRefunded, AggregateID: 3, Payload: payload, Attempts: 1},
+	}, nil)
+	m.refunds.On("GetByID", mock.Anything, int64(9)).Return(&models.Refund{ID: 9, OrderID: 3, PaymentID: &paymentID, Amount: decimal.NewFromInt(400), Currency: "KES"}, nil)
+	m.payments.On("ListByOrder", mock.Anything, int64(3)).Return([]models.Payment{
+		{ID: 4, OrderID: 3, Provider: "card", Reference: initiation.Reference, Receipt: "RCPT-1", Amount: decimal.NewFromInt(400), Status: models.PaymentSucceeded},
+	}, nil)
+	m.refunds.On("MarkPaidOut", mock.Anything, int64(9), "card_refund_1").Return(nil)
+	m.orders.On("GetByID", mock.Anything, int64(3)).Return(order, nil)
+	m.customers.On("GetByID", mock.Anything, int64(1)).Return(customer, nil)
+	m.sms.On("SendRefundNotice", mock.Anything, order, mock.MatchedBy(func(r *models.Refund) bool {
+		return r.ID == 9 && r.Amount.Equal(decimal.NewFromInt(400)) && r.Reason == "damaged" && r.Full()
+	}), "+254712345678").Return(nil)
+	m.outbox.On("MarkSent", mock.Anything, int64(1)).Return(nil)
+
+	_, err = d.DispatchBatch(context.Background())
+
+	assert.NoError(t, err)
+	if assert.Len(t, m.provider.Refunds(), 1) {
+		assert.Equal(t, "RCPT-1", m.provider.Refunds()[0].Receipt)
+	}
+	m.refunds.AssertExpectations(t)
+	m.sms.AssertExpectations(t)
+	m.outbox.AssertExpectations(t)
+}
+
+func TestDispatchBatch_RefundAlreadyPaidOut(t *testing.T) {
+	d, m := newTestDispatcher(time.Now())
+
+	customer := &models.Customer{ID: 1, Phone: "+254712345678"}
+	payload, err := json.Marshal(models.OrderRefundedPayload{RefundID: 9, Amount: decimal.NewFromInt(400), Currency: "KES"})
+	assert.NoError(t, err)
+
+	// The payout went through on an earlier attempt whose SMS failed
+	paidOut := time.Now()
+	m.outbox.On("Claim", mock.Anything, 10, outboxLease).Return([]models.OutboxMessage{
+		{ID: 1, Topic: models.TopicOrderRefunded, AggregateID: 3, Payload: payload, Attempts: 2},
+	}, nil)
+	m.refunds.On("GetByID", mock.Anything, int64(9)).Return(&models.Refund{ID: 9, OrderID: 3, PaidOutAt: &paidOut}, nil)
+	m.orders.On("GetByID", mock.Anything, int64(3)).Return(&models.Order{ID: 3, CustomerID: "1"}, nil)
+	m.customers.On("GetByID", mock.Anything, int64(1)).Return(customer, nil)
+	m.sms.On("SendRefundNotice", mock.Anything, mock.Anything, mock.Anything, "+254712345678").Return(nil)
+	m.outbox.On("MarkSent", mock.Anything, int64(1)).Return(nil)
+
+	_, err = d.DispatchBatch(context.Background())
+
+	assert.NoError(t, err)
+	assert.Empty(t, m.provider.Refunds())
+	m.sms.AssertExpectations(t)
+}
+
+func TestDispatchBatch_RefundAwaitingPayoutResult(t *testing.T) {
+	d, m := newTestDispatcher(time.Now())
+
+	payload, err := json.Marshal(models.OrderRefundedPayload{RefundID: 9, Amount: decimal.NewFromInt(400), Currency: "KES"})
+	assert.NoError(t, err)
+
+	// The provider accepted the payout and has not reported its result yet
+	paymentID := int64(4)
+	m.outbox.On("Claim", mock.Anything, 10, outboxLease).Return([]models.OutboxMessage{
+		{ID: 1, Topic: models.TopicOrderRefunded, AggregateID: 3, Payload: payload, Attempts: 1},
+	}, nil)
+	m.refunds.On("GetByID", mock.Anything, int64(9)).Return(&models.Refund{ID: 9, OrderID: 3, PaymentID: &paymentID, ProviderReference: "AG_1"}, nil)
+	m.outbox.On("MarkSent", mock.Anything, int64(1)).Return(nil)
+
+	_, err = d.DispatchBatch(context.Background())
+
+	assert.NoError(t, err)
+	assert.Empty(t, m.provider.Refunds())
+	m.outbox.AssertExpectations(t)
+	m.sms.AssertNotCalled(t, "SendRefundNotice", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDispatchBatch_RefundPayoutRefused(t *testing.T) {
+	d, m := newTestDispatcher(time.Now())
+
+	payload, err := json.Marshal(models.OrderRefundedPayload{RefundID: 9, Amount: decimal.NewFromInt(100), Currency: "KES"})
+	assert.NoError(t, err)
+
+	// The fake provider never collected this payment
+	paymentID := int64(4)
+	m.outbox.On("Claim", mock.Anything, 10, outboxLease).Return([]models.OutboxMessage{
+		{ID: 1, Topic: models.TopicOrderRefunded, AggregateID: 3, Payload: payload, Attempts: 1},
+	}, nil)
+	m.refunds.On("GetByID", mock.Anything, int64(9)).Return(&models.Refund{ID: 9, OrderID: 3, PaymentID: &paymentID, Amount: decimal.NewFromInt(100)}, nil)
+	m.payments.On("ListByOrder", mock.Anything, int64(3)).Return([]models.Payment{
+		{ID: 4, OrderID: 3, Provider: "card", Reference: "card_404", Amount: decimal.NewFromInt(400), Status: models.PaymentSucceeded},
+	}, nil)
+	m.outbox.On("MarkDead", mock.Anything, int64(1), mock.Anything).Return(nil)
+
+	_, err = d.DispatchBatch(context.Background())
+
+	assert.NoError(t, err)
+	m.outbox.AssertExpectations(t)
+	m.sms.AssertNotCalled(t, "SendRefundNotice", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	m.refunds.AssertNotCalled(t, "MarkPaidOut", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDispatchBatch_RetriesWithBackoff(t *testing.T) {
+	now := time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)
+	d, m := newTestDispatcher(now)
+
+	order := &models.Order{ID: 5, CustomerID: "1"}
+	customer := &models.Customer{ID: 1, Phone: "+254712345678"}
+
+	m.outbox.On("Claim", mock.Anything, 10, outboxLease).Return([]models.OutboxMessage{
+		{ID: 1, Topic: models.TopicOrderCreated, AggregateID: 5, Attempts: 2},
+	}, nil)
+	m.orders.On("GetByID", mock.Anything, int64(5)).Return(order, nil)
+	m.customers.On("GetByID", mock.Anything, int64(1)).Return(customer, nil)
+	m.sms.On("SendOrderConfirmation", mock.Anything, order, customer).Return(errors.New("gateway timeout"))
+	m.outbox.On("Retry", mock.Anything, int64(1), "gateway timeout", now.Add(time.Minute)).Return(nil)
+
+	_, err := d.DispatchBatch(context.Background())
+
+	assert.NoError(t, err)
+	m.outbox.AssertExpectations(t)
+	m.outbox.AssertNotCalled(t, "MarkSent", mock.Anything, mock.Anything)
+}
+
+func TestDispatchBatch_DeadLetters(t *testing.T) {
+	d, m := newTestDispatcher(time.Now())
+
+	order := &models.Order{ID: 5, CustomerID: "1"}
+	customer := &models.Customer{ID: 1, Phone: "+254712345678"}
+
+	m.outbox.On("Claim", mock.Anything, 10, outboxLease).Return([]models.OutboxMessage{
+		// Out of attempts
+		{ID: 1, Topic: models.TopicOrderCreated, AggregateID: 5, Attempts: 3},
+		// Order deleted since the message was written
+		{ID: 2, Topic: models.TopicOrderCreated, AggregateID: 6, Attempts: 1},
+		{ID: 3, Topic: "order.teleported", AggregateID: 5, Attempts: 1},
+	}, nil)
+	m.orders.On("GetByID", mock.Anything, int64(5)).Return(order, nil)
+	m.orders.On("GetByID", mock.Anything, int64(6)).Return(nil, fmt.Errorf("order with id 6: %w", models.ErrNotFound))
+	m.customers.On("GetByID", mock.Anything, int64(1)).Return(customer, nil)
+	m.sms.On("SendOrderConfirmation", mock.Anything, order, customer).Return(errors.New("gateway timeout"))
+	m.outbox.On("MarkDead", mock.Anything, int64(1), "gateway timeout").Return(nil)
+	m.outbox.On("MarkDead", mock.Anything, int64(2), "order with id 6: not found").Return(nil)
+	m.outbox.On("MarkDead", mock.Anything, int64(3), "no handler for topic order.teleported").Return(nil)
+
+	_, err := d.DispatchBatch(context.Background())
+
+	assert.NoError(t, err)
+	m.outbox.AssertExpectations(t)
+	m.outbox.AssertNotCalled(t, "Retry", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDispatchBatch_WithoutSMSService(t *testing.T) {
+	mockOutbox := new(MockOutboxRepo)
+	d := newOutboxDispatcher(mockOutbox, nil, nil, nil, nil, 10, 3, time.Second)
+
+	mockOutbox.On("Claim", mock.Anything, 10, outboxLease).Return([]models.OutboxMessage{
+		{ID: 1, Topic: models.TopicOrderCreated, AggregateID: 5, Attempts: 1},
+	}, nil)
+	mockOutbox.On("MarkSent", mock.Anything, int64(1)).Return(nil)
+
+	_, err := d.DispatchBatch(context.Background())
+
+	assert.NoError(t, err)
+	mockOutbox.AssertExpectations(t)
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, 30*time.Second, backoff(1))
+	assert.Equal(t, time.Minute, backoff(2))
+	assert.Equal(t, 4*time.Minute, backoff(4))
+	assert.Equal(t, time.Hour, backoff(8))
+	assert.Equal(t, time.Hour, backoff(50))
+}
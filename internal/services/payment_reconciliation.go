@@ -0,0 +1,192 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/chesireabel/Technical-Interview/internal/payments"
+	"github.com/chesireabel/Technical-Interview/internal/repositories"
+)
+
+const (
+	defaultReconcileInterval = 15 * time.Minute
+	defaultReconcileLookback = 72 * time.Hour
+	// reconcileGrace leaves recent payments alone while their webhook may still be on its way
+	reconcileGrace = 10 * time.Minute
+)
+
+// ReconciliationService compares recent payments with what their provider
+// reports and flags the ones that disagree, e.g. because a webhook was lost.
+// It only flags mismatches; fixing them is left to a person.
+type ReconciliationService interface {
+	Reconcile(ctx context.Context) (checked int, flagged int, err error)
+	Run(ctx context.Context)
+}
+
+type reconciliationService struct {
+	repo      repositories.PaymentRepository
+	providers *payments.Registry
+	lock      repositories.LeaderLock
+	interval  time.Duration
+	lookback  time.Duration
+	now       func() time.Time
+}
+
+// NewReconciliationService reads PAYMENT_RECONCILE_INTERVAL (default 15m) and
+// PAYMENT_RECONCILE_LOOKBACK (default 72h), both Go durations
+func NewReconciliationService(repo repositories.PaymentRepository, providers *payments.Registry, lock repositories.LeaderLock) (ReconciliationService, error) {
+	interval, err := positiveDurationEnv("PAYMENT_RECONCILE_INTERVAL", defaultReconcileInterval)
+	if err != nil {
+		return nil, err
+	}
+	lookback, err := positiveDurationEnv("PAYMENT_RECONCILE_LOOKBACK", defaultReconcileLookback)
+	if err != nil {
+		return nil, err
+	}
+
+	return &reconciliationService{
+		repo:      repo,
+		providers: providers,
+		lock:      lock,
+		interval:  interval,
+		lookback:  lookback,
+		now:       time.Now,
+	}, nil
+}
+
+func positiveDurationEnv(name string, fallback time.Duration) (time.Duration, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("%s must be a positive duration, got %q", name, v)
+	}
+	return d, nil
+}
+
+// Reconcile checks the payments started within the lookback window. A
+// provider that cannot be reached is logged and skipped so one outage does
+// not stop the others being checked.
+func (s *reconciliationService) Reconcile(ctx context.Context) (int, int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+
+	now := s.now()
+	list, err := s.repo.ListForReconciliation(ctx, now.Add(-s.lookback), now.Add(-reconcileGrace))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	checked, flagged := 0, 0
+	for i := range list {
+		payment := &list[i]
+
+		provider, err := s.providers.Get(payment.Provider)
+		if err != nil {
+			log.Printf("⚠️ Cannot reconcile payment %d: %v", payment.ID, err)
+			continue
+		}
+
+		result, err := provider.Query(ctx, payment.Reference)
+		if err != nil && !errors.Is(err, payments.ErrUnknownPayment) {
+			log.Printf("⚠️ Cannot reconcile payment %d with %s: %v", payment.ID, provider.Name(), err)
+			continue
+		}
+		checked++
+
+		for _, mismatch := range comparePayment(payment, result) {
+			recorded, err := s.repo.FlagMismatch(ctx, mismatch)
+			if err != nil {
+				return checked, flagged, err
+			}
+			if recorded {
+				flagged++
+				log.Printf("🚩 Payment %d for order %d: %s", payment.ID, payment.OrderID, mismatch.Detail)
+			}
+		}
+	}
+
+	return checked, flagged, nil
+}
+
+// comparePayment lists how our record of a payment differs from the
+// provider's. A nil result means the provider does not know the payment.
+// Providers that do not report an amount or receipt are not checked for them.
+func comparePayment(payment *models.Payment, result *models.PaymentResult) []*models.PaymentMismatch {
+	flag := func(kind string, providerStatus models.PaymentStatus, format string, args ...any) *models.PaymentMismatch {
+		return &models.PaymentMismatch{
+			PaymentID:      payment.ID,
+			OrderID:        payment.OrderID,
+			Provider:       payment.Provider,
+			Kind:           kind,
+			LocalStatus:    payment.Status,
+			ProviderStatus: providerStatus,
+			Detail:         fmt.Sprintf(format, args...),
+		}
+	}
+
+	if result == nil {
+		return []*models.PaymentMismatch{
+			flag(models.MismatchUnknown, "", "%s has no payment %s", payment.Provider, payment.Reference),
+		}
+	}
+
+	if result.Status != payment.Status {
+		return []*models.PaymentMismatch{
+			flag(models.MismatchStatus, result.Status, "payment is %s here but %s at %s", payment.Status, result.Status, payment.Provider),
+		}
+	}
+	if payment.Status != models.PaymentSucceeded {
+		return nil
+	}
+
+	var mismatches []*models.PaymentMismatch
+	if !result.Amount.IsZero() && !result.Amount.Equal(payment.Amount) {
+		mismatches = append(mismatches, flag(models.MismatchAmount, result.Status, "%s collected %s but the payment was for %s",
+			payment.Provider, models.FormatMoney(result.Amount, payment.Currency), models.FormatMoney(payment.Amount, payment.Currency)))
+	}
+	if result.Receipt != "" && result.Receipt != payment.Receipt {
+		mismatches = append(mismatches, flag(models.MismatchReceipt, result.Status, "%s receipt is %s but we recorded %q",
+			payment.Provider, result.Receipt, payment.Receipt))
+	}
+	return mismatches
+}
+
+// Run reconciles immediately and then on every interval until ctx is
+// cancelled, doing the work only while it holds the leader lock
+func (s *reconciliationService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		leader, err := s.lock.Acquire(ctx)
+		if err != nil {
+			log.Printf("⚠️ Payment reconciliation leader election failed: %v", err)
+		}
+
+		if leader {
+			checked, flagged, err := s.Reconcile(ctx)
+			if err != nil {
+				log.Printf("⚠️ Payment reconciliation failed: %v", err)
+			} else if flagged > 0 {
+				log.Printf("🚩 Payment reconciliation checked %d payment(s) and flagged %d mismatch(es)", checked, flagged)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			if err := s.lock.Release(context.WithoutCancel(ctx)); err != nil {
+				log.Printf("⚠️ %v", err)
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
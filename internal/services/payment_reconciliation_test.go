@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/chesireabel/Technical-Interview/internal/payments"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestReconcile(t *testing.T) {
+	fake := payments.NewFakeProvider("card", "secret")
+	mockPaymentRepo := new(MockPaymentRepo)
+
+	now := time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)
+	svc := &reconciliationService{
+		repo:      mockPaymentRepo,
+		providers: payments.NewRegistry(fake),
+		lookback:  72 * time.Hour,
+		now:       func() time.Time { return now },
+	}
+
+	for i := 0; i < 4; i++ {
+		fake.Initiate(context.Background(), payments.InitiateRequest{Amount: decimal.NewFromInt(100)})
+	}
+	fake.SetState("card_1", models.PaymentSucceeded, "RCPT-1") // agrees with us
+	fake.SetState("card_2", models.PaymentSucceeded, "RCPT-2") // webhook lost
+	fake.SetState("card_3", models.PaymentSucceeded, "RCPT-X") // different receipt
+
+	mockPaymentRepo.On("ListForReconciliation", mock.Anything, now.Add(-72*time.Hour), now.Add(-reconcileGrace)).Return([]models.Payment{
+		{ID: 1, OrderID: 11, Provider: "card", Reference: "card_1", Amount: decimal.NewFromInt(100), Status: models.PaymentSucceeded, Receipt: "RCPT-1"},
+		{ID: 2, OrderID: 12, Provider: "card", Reference: "card_2", Amount: decimal.NewFromInt(100), Status: models.PaymentPending},
+		{ID: 3, OrderID: 13, Provider: "card", Reference: "card_3", Amount: decimal.NewFromInt(100), Status: models.PaymentSucceeded, Receipt: "RCPT-3"},
+		{ID: 4, OrderID: 14, Provider: "card", Reference: "card_9", Amount: decimal.NewFromInt(100), Status: models.PaymentFailed},
+		{ID: 5, OrderID: 15, Provider: "card", Reference: "card_4", Amount: decimal.NewFromInt(100), Status: models.PaymentPending},
+		{ID: 6, OrderID: 16, Provider: "retired", Reference: "x", Status: models.PaymentPending},
+	}, nil)
+
+	var flagged []models.PaymentMismatch
+	mockPaymentRepo.On("FlagMismatch", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		flagged = append(flagged, *args.Get(1).(*models.PaymentMismatch))
+	}).Return(true, nil)
+
+	checked, n, err := svc.Reconcile(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 5, checked)
+	assert.Equal(t, 3, n)
+	if assert.Len(t, flagged, 3) {
+		assert.Equal(t, int64(2), flagged[0].PaymentID)
+		assert.Equal(t, models.MismatchStatus, flagged[0].Kind)
+		assert.Equal(t, "payment is pending here but succeeded at card", flagged[0].Detail)
+		assert.Equal(t, models.MismatchReceipt, flagged[1].Kind)
+		assert.Equal(t, `card receipt is RCPT-X but we recorded "RCPT-3"`, flagged[1].Detail)
+		assert.Equal(t, models.MismatchUnknown, flagged[2].Kind)
+		assert.Equal(t, "card has no payment card_9", flagged[2].Detail)
+	}
+}
+
+func TestComparePayment_Amount(t *testing.T) {
+	payment := &models.Payment{ID: 1, Provider: "card", Amount: decimal.NewFromInt(100), Currency: "KES", Status: models.PaymentSucceeded, Receipt: "R"}
+
+	mismatches := comparePayment(payment, &models.PaymentResult{Status: models.PaymentSucceeded, Amount: decimal.NewFromInt(90), Receipt: "R"})
+	if assert.Len(t, mismatches, 1) {
+		assert.Equal(t, "card collected KES 90.00 but the payment was for KES 100.00", mismatches[0].Detail)
+	}
+
+	// M-Pesa queries report neither amount nor receipt
+	assert.Empty(t, comparePayment(payment, &models.PaymentResult{Status: models.PaymentSucceeded}))
+}
+
+func TestReconciliationService_RunOnlyReconcilesAsLeader(t *testing.T) {
+	mockPaymentRepo := new(MockPaymentRepo)
+	lock := &FakeLeaderLock{Leader: false}
+	svc := &reconciliationService{repo: mockPaymentRepo, providers: payments.NewRegistry(), lock: lock, interval: time.Hour, now: time.Now}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	svc.Run(ctx)
+
+	mockPaymentRepo.AssertNotCalled(t, "ListForReconciliation", mock.Anything, mock.Anything, mock.Anything)
+	assert.True(t, lock.Released)
+}
@@ -0,0 +1,223 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/chesireabel/Technical-Interview/internal/payments"
+	"github.com/chesireabel/Technical-Interview/internal/repositories"
+)
+
+// paymentAttemptTimeout is how long a pending attempt blocks a new one and
+// edits to its order. The customer has long stopped being able to approve an
+// attempt by then, e.g. an M-Pesa prompt expires within a couple of minutes,
+// so an attempt still pending is taken as abandoned.
+const paymentAttemptTimeout = 10 * time.Minute
+
+type PaymentService interface {
+	// StartPayment asks the provider (M-Pesa when empty) to collect the
+	// order's amount. phone defaults to the customer's. The payment stays
+	// pending until the provider's webhook arrives, and until then no other
+	// attempt can be started for the order.
+	StartPayment(ctx context.Context, orderID int64, provider, phone string) (*models.Payment, error)
+	// HandleWebhook records the result a provider reported and moves the
+	// order to paid when the customer paid the order's amount
+	HandleWebhook(ctx context.Context, provider string, hook payments.Webhook) error
+	ListPayments(ctx context.Context, orderID int64) ([]models.Payment, error)
+	ListMismatches(ctx context.Context, includeResolved bool) ([]models.PaymentMismatch, error)
+	ResolveMismatch(ctx context.Context, id int64) error
+}
+
+type paymentService struct {
+	repo         repositories.PaymentRepository
+	orderRepo    repositories.OrderRepository
+	customerRepo repositories.CustomerRepository
+	tx           repositories.TxManager
+	providers    *payments.Registry
+}
+
+// NewPaymentService creates the payment service. Only the providers in the
+// registry can be used; an empty registry disables payments.
+func NewPaymentService(repo repositories.PaymentRepository, orderRepo repositories.OrderRepository, customerRepo repositories.CustomerRepository, tx repositories.TxManager, providers *payments.Registry) PaymentService {
+	return &paymentService{
+		repo:         repo,
+		orderRepo:    orderRepo,
+		customerRepo: customerRepo,
+		tx:           tx,
+		providers:    providers,
+	}
+}
+
+func (s *paymentService) StartPayment(ctx context.Context, orderID int64, providerName, phone string) (*models.Payment, error) {
+	if orderID == 0 {
+		return nil, errors.New("order id is required")
+	}
+	if providerName == "" {
+		providerName = models.PaymentProviderMpesa
+	}
+	provider, err := s.providers.Get(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	order, err := s.orderRepo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if order.Status != models.OrderStatusPending {
+		return nil, models.ErrNotPayable
+	}
+
+	if phone == "" {
+		customerID, err := strconv.ParseInt(order.CustomerID, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid customer_id %q on order %d", order.CustomerID, order.ID)
+		}
+		customer, err := s.customerRepo.GetByID(ctx, customerID)
+		if err != nil {
+			return nil, err
+		}
+		phone = customer.Phone
+	}
+	if phone != "" {
+		number, err := ParsePhoneNumber(phone, phoneDefaultRegion())
+		if err != nil {
+			return nil, err
+		}
+		phone = number.E164
+	}
+
+	payment := &models.Payment{
+		OrderID:  order.ID,
+		Provider: provider.Name(),
+		Phone:    phone,
+		Amount:   order.Amount,
+		Currency: order.Currency,
+	}
+	initiate := payments.InitiateRequest{
+		OrderID:     order.ID,
+		Amount:      order.Amount,
+		Currency:    order.Currency,
+		Phone:       phone,
+		Description: fmt.Sprintf("Payment for order %d", order.ID),
+	}
+
+	// Record the attempt before calling the provider so that its webhook always has a row to land on
+	if err := s.repo.Create(ctx, payment, time.Now().Add(-paymentAttemptTimeout)); err != nil {
+		return nil, err
+	}
+
+	initiation, err := provider.Initiate(ctx, initiate)
+	if err != nil {
+		if markErr := s.repo.MarkFailed(ctx, payment.ID, err.Error()); markErr != nil {
+			log.Printf("Failed to mark payment %d failed: %v", payment.ID, markErr)
+		}
+		if errors.Is(err, payments.ErrInvalidRequest) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", models.ErrPaymentProvider, err)
+	}
+
+	if err := s.repo.SetReference(ctx, payment.ID, initiation.Reference); err != nil {
+		return nil, err
+	}
+	payment.Reference = initiation.Reference
+
+	return payment, nil
+}
+
+func (s *paymentService) HandleWebhook(ctx context.Context, providerName string, hook payments.Webhook) error {
+	provider, err := s.providers.Get(providerName)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	result, err := provider.VerifyWebhook(ctx, hook)
+	if err != nil {
+		return err
+	}
+	if result.Status == models.PaymentPending {
+		return nil
+	}
+
+	return s.tx.WithinTx(ctx, func(ctx context.Context, repos repositories.Repositories) error {
+		payment, completed, err := repos.Payments.Complete(ctx, result)
+		if err != nil {
+			return err
+		}
+		if !completed || payment.Status != models.PaymentSucceeded {
+			return nil
+		}
+
+		order, err := repos.Orders.GetByID(ctx, payment.OrderID)
+		if err != nil {
+			return err
+		}
+		// The payment holds what was collected; the order may have changed
+		// since the attempt was started
+		if !payment.Amount.Equal(order.Amount) {
+			log.Printf("%s receipt %s paid %s but order %d is for %s, order left unpaid",
+				provider.Name(), result.Receipt, payment.Amount, order.ID, order.Amount)
+			return nil
+		}
+		if !CanTransition(order.Status, models.OrderStatusPaid) {
+			// e.g. cancelled while the customer was approving the payment;
+			// the receipt is kept so the money can be refunded
+			log.Printf("%s receipt %s received for order %d in status %s, order not marked paid",
+				provider.Name(), result.Receipt, order.ID, order.Status)
+			return nil
+		}
+
+		return repos.Orders.Transition(ctx, &models.OrderStatusChange{
+			OrderID:    order.ID,
+			FromStatus: order.Status,
+			ToStatus:   models.OrderStatusPaid,
+			ChangedBy:  provider.Name(),
+			Reason:     fmt.Sprintf("%s receipt %s", provider.Name(), result.Receipt),
+		})
+	})
+}
+
+func (s *paymentService) ListPayments(ctx context.Context, orderID int64) ([]models.Payment, error) {
+	if orderID == 0 {
+		return nil, errors.New("order id is required")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if _, err := s.orderRepo.GetByID(ctx, orderID); err != nil {
+		return nil, err
+	}
+
+	return s.repo.ListByOrder(ctx, orderID)
+}
+
+func (s *paymentService) ListMismatches(ctx context.Context, includeResolved bool) ([]models.PaymentMismatch, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	return s.repo.ListMismatches(ctx, includeResolved)
+}
+
+func (s *paymentService) ResolveMismatch(ctx context.Context, id int64) error {
+	if id == 0 {
+		return errors.New("id is required")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return s.repo.ResolveMismatch(ctx, id)
+}
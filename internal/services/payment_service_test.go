@@ -0,0 +1,235 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/chesireabel/Technical-Interview/internal/payments"
+	"github.com/chesireabel/Technical-Interview/internal/repositories"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func pendingOrder(id int64, amount int64) *models.Order {
+	return &models.Order{ID: id, CustomerID: "1", Amount: decimal.NewFromInt(amount), Currency: "KES", Status: models.OrderStatusPending}
+}
+
+func TestStartPayment(t *testing.T) {
+	fake := payments.NewFakeProvider("card", "secret")
+	mockPaymentRepo := new(MockPaymentRepo)
+	mockOrderRepo := new(MockOrderRepo)
+	mockCustomerRepo := new(MockCustomerRepo)
+	service := NewPaymentService(mockPaymentRepo, mockOrderRepo, mockCustomerRepo, nil, payments.NewRegistry(fake))
+
+	mockOrderRepo.On("GetByID", mock.Anything, int64(7)).Return(pendingOrder(7, 1500), nil)
+	mockCustomerRepo.On("GetByID", mock.Anything, int64(1)).Return(&models.Customer{ID: 1, Phone: "0712345678"}, nil)
+	mockPaymentRepo.On("Create", mock.Anything, mock.MatchedBy(func(p *models.Payment) bool {
+		return p.OrderID == 7 && p.Provider == "card" && p.Phone == "+254712345678" && p.Amount.Equal(decimal.NewFromInt(1500))
+	}), mock.Anything).Run(func(args mock.Arguments) {
+		args.Get(1).(*models.Payment).ID = 3
+	}).Return(nil)
+	mockPaymentRepo.On("SetReference", mock.Anything, int64(3), "card_1").Return(nil)
+
+	payment, err := service.StartPayment(context.Background(), 7, "card", "")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "card_1", payment.Reference)
+	mockPaymentRepo.AssertExpectations(t)
+}
+
+func TestStartPayment_Rejected(t *testing.T) {
+	fake := payments.NewFakeProvider("card", "secret")
+	mockPaymentRepo := new(MockPaymentRepo)
+	mockOrderRepo := new(MockOrderRepo)
+	service := NewPaymentService(mockPaymentRepo, mockOrderRepo, nil, nil, payments.NewRegistry(fake))
+
+	confirmed := pendingOrder(1, 10)
+	confirmed.Status = models.OrderStatusConfirmed
+	mockOrderRepo.On("GetByID", mock.Anything, int64(1)).Return(confirmed, nil)
+	mockOrderRepo.On("GetByID", mock.Anything, int64(2)).Return(pendingOrder(2, 10), nil)
+	mockPaymentRepo.On("Create", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		args.Get(1).(*models.Payment).ID = 5
+	}).Return(nil)
+	mockPaymentRepo.On("MarkFailed", mock.Anything, int64(5), "gateway timeout").Return(nil)
+
+	_, err := service.StartPayment(context.Background(), 1, "card", "0712345678")
+	assert.ErrorIs(t, err, models.ErrNotPayable)
+
+	_, err = service.StartPayment(context.Background(), 2, "paypal", "0712345678")
+	assert.ErrorIs(t, err, payments.ErrUnknownProvider)
+
+	// Provider failures are recorded on the attempt
+	fake.InitiateErr = errors.New("gateway timeout")
+	_, err = service.StartPayment(context.Background(), 2, "card", "0712345678")
+	assert.ErrorIs(t, err, models.ErrPaymentProvider)
+	mockPaymentRepo.AssertExpectations(t)
+}
+
+func TestHandleWebhook_MarksOrderPaid(t *testing.T) {
+	fake := payments.NewFakeProvider("card", "secret")
+	mockPaymentRepo := new(MockPaymentRepo)
+	mockOrderRepo := new(MockOrderRepo)
+	tx := &FakeTxManager{Repos: repositories.Repositories{Orders: mockOrderRepo, Payments: mockPaymentRepo}}
+	service := NewPaymentService(mockPaymentRepo, mockOrderRepo, nil, tx, payments.NewRegistry(fake))
+
+	initiation, _ := fake.Initiate(context.Background(), payments.InitiateRequest{OrderID: 7, Amount: decimal.NewFromInt(1500), Currency: "KES"})
+	hook, err := fake.Settle(initiation.Reference, models.PaymentSucceeded, "RCPT-1")
+	assert.NoError(t, err)
+
+	payment := &models.Payment{ID: 3, OrderID: 7, Amount: decimal.NewFromInt(1500), Status: models.PaymentSucceeded}
+	mockPaymentRepo.On("Complete", mock.Anything, mock.MatchedBy(func(r *models.PaymentResult) bool {
+		return r.Reference == initiation.Reference && r.Status == models.PaymentSucceeded && r.Receipt == "RCPT-1" && !r.PaidAt.IsZero()
+	})).Return(payment, true, nil)
+	mockOrderRepo.On("GetByID", mock.Anything, int64(7)).Return(pendingOrder(7, 1500), nil)
+	mockOrderRepo.On("Transition", mock.Anything, mock.MatchedBy(func(c *models.OrderStatusChange) bool {
+		return c.OrderID == 7 && c.FromStatus == models.OrderStatusPending && c.ToStatus == models.OrderStatusPaid &&
+			c.ChangedBy == "card" && c.Reason == "card receipt RCPT-1"
+	})).Return(nil)
+
+	err = service.HandleWebhook(context.Background(), "card", hook)
+
+	assert.NoError(t, err)
+	mockOrderRepo.AssertExpectations(t)
+}
+
+func TestHandleWebhook_NoStatusChange(t *testing.T) {
+	fake := payments.NewFakeProvider("card", "secret")
+	mockPaymentRepo := new(MockPaymentRepo)
+	mockOrderRepo := new(MockOrderRepo)
+	tx := &FakeTxManager{Repos: repositories.Repositories{Orders: mockOrderRepo, Payments: mockPaymentRepo}}
+	service := NewPaymentService(mockPaymentRepo, mockOrderRepo, nil, tx, payments.NewRegistry(fake))
+
+	for i := 0; i < 3; i++ {
+		fake.Initiate(context.Background(), payments.InitiateRequest{Amount: decimal.NewFromInt(100)})
+	}
+	repeated, _ := fake.Settle("card_1", models.PaymentSucceeded, "RCPT-1")
+	declined, _ := fake.Settle("card_2", models.PaymentFailed, "")
+	late, _ := fake.Settle("card_3", models.PaymentSucceeded, "RCPT-3")
+
+	// A repeated webhook is acknowledged without touching the order
+	mockPaymentRepo.On("Complete", mock.Anything, mock.MatchedBy(func(r *models.PaymentResult) bool { return r.Reference == "card_1" })).
+		Return(&models.Payment{ID: 1, OrderID: 7, Amount: decimal.NewFromInt(100), Status: models.PaymentSucceeded}, false, nil)
+	mockPaymentRepo.On("Complete", mock.Anything, mock.MatchedBy(func(r *models.PaymentResult) bool { return r.Reference == "card_2" })).
+		Return(&models.Payment{ID: 2, OrderID: 8, Status: models.PaymentFailed}, true, nil)
+	// The order was cancelled while the customer approved the payment
+	mockPaymentRepo.On("Complete", mock.Anything, mock.MatchedBy(func(r *models.PaymentResult) bool { return r.Reference == "card_3" })).
+		Return(&models.Payment{ID: 3, OrderID: 9, Amount: decimal.NewFromInt(100), Status: models.PaymentSucceeded}, true, nil)
+	mockOrderRepo.On("GetByID", mock.Anything, int64(9)).Return(&models.Order{ID: 9, Amount: decimal.NewFromInt(100), Status: models.OrderStatusCancelled}, nil)
+
+	assert.NoError(t, service.HandleWebhook(context.Background(), "card", repeated))
+	assert.NoError(t, service.HandleWebhook(context.Background(), "card", declined))
+	assert.NoError(t, service.HandleWebhook(context.Background(), "card", late))
+
+	mockOrderRepo.AssertNotCalled(t, "Transition", mock.Anything, mock.Anything)
+}
+
+func TestStartPayment_InProgress(t *testing.T) {
+	fake := payments.NewFakeProvider("card", "secret")
+	mockPaymentRepo := new(MockPaymentRepo)
+	mockOrderRepo := new(MockOrderRepo)
+	service := NewPaymentService(mockPaymentRepo, mockOrderRepo, nil, nil, payments.NewRegistry(fake))
+
+	mockOrderRepo.On("GetByID", mock.Anything, int64(3)).Return(pendingOrder(3, 10), nil)
+	mockPaymentRepo.On("Create", mock.Anything, mock.Anything, mock.MatchedBy(func(since time.Time) bool {
+		return time.Since(since) >= paymentAttemptTimeout
+	})).Return(models.ErrPaymentInProgress)
+
+	_, err := service.StartPayment(context.Background(), 3, "card", "0712345678")
+
+	assert.ErrorIs(t, err, models.ErrPaymentInProgress)
+	// No second prompt reaches the customer
+	_, err = fake.Query(context.Background(), "card_1")
+	assert.ErrorIs(t, err, payments.ErrUnknownPayment)
+}
+
+func TestHandleWebhook_OrderAmountChanged(t *testing.T) {
+	fake := payments.NewFakeProvider("card", "secret")
+	mockPaymentRepo := new(MockPaymentRepo)
+	mockOrderRepo := new(MockOrderRepo)
+	tx := &FakeTxManager{Repos: repositories.Repositories{Orders: mockOrderRepo, Payments: mockPaymentRepo}}
+	service := NewPaymentService(mockPaymentRepo, mockOrderRepo, nil, tx, payments.NewRegistry(fake))
+
+	initiation, _ := fake.Initiate(context.Background(), payments.InitiateRequest{OrderID: 7, Amount: decimal.NewFromInt(1500), Currency: "KES"})
+	hook, _ := fake.Settle(initiation.Reference, models.PaymentSucceeded, "RCPT-1")
+
+	// The payment records the 1500 collected, but the order now asks for 1800
+	mockPaymentRepo.On("Complete", mock.Anything, mock.Anything).
+		Return(&models.Payment{ID: 3, OrderID: 7, Amount: decimal.NewFromInt(1500), Status: models.PaymentSucceeded}, true, nil)
+	mockOrderRepo.On("GetByID", mock.Anything, int64(7)).Return(pendingOrder(7, 1800), nil)
+
+	assert.NoError(t, service.HandleWebhook(context.Background(), "card", hook))
+	mockOrderRepo.AssertNotCalled(t, "Transition", mock.Anything, mock.Anything)
+}
+
+func TestHandleWebhook_InvalidSignature(t *testing.T) {
+	fake := payments.NewFakeProvider("card", "secret")
+	service := NewPaymentService(nil, nil, nil, nil, payments.NewRegistry(fake))
+
+	fake.Initiate(context.Background(), payments.InitiateRequest{Amount: decimal.NewFromInt(100)})
+	hook, _ := fake.Settle("card_1", models.PaymentSucceeded, "RCPT-1")
+	hook.Body = []byte(`{"reference":"card_1","status":"succeeded","amount":"1"}`)
+
+	err := service.HandleWebhook(context.Background(), "card", hook)
+
+	assert.ErrorIs(t, err, payments.ErrInvalidSignature)
+}
+
+// TestMpesaPayment_EndToEnd pays an order through the Daraja simulator: the
+// STK push is sent, the simulated customer approves it and the callback the
+// simulator posts moves the order to paid
+func TestMpesaPayment_EndToEnd(t *testing.T) {
+	mockPaymentRepo := new(MockPaymentRepo)
+	mockOrderRepo := new(MockOrderRepo)
+	tx := &FakeTxManager{Repos: repositories.Repositories{Orders: mockOrderRepo, Payments: mockPaymentRepo}}
+	registry := payments.NewRegistry()
+	service := NewPaymentService(mockPaymentRepo, mockOrderRepo, nil, tx, registry)
+
+	callbacks := make(chan payments.Webhook, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		callbacks <- payments.Webhook{Header: r.Header, Query: r.URL.Query(), Body: body}
+	}))
+	defer receiver.Close()
+
+	cfg := payments.SimulatorDefaults(payments.MpesaConfig{CallbackToken: "s3cret"}, receiver.URL+"/payments/mpesa/callback")
+	simulator := payments.NewSimulator(cfg)
+	simulator.Delay = 0
+	daraja := httptest.NewServer(simulator)
+	defer daraja.Close()
+	cfg.BaseURL = daraja.URL
+	registry.Register(payments.NewMpesa(cfg, daraja.Client()))
+
+	mockOrderRepo.On("GetByID", mock.Anything, int64(7)).Return(pendingOrder(7, 1500), nil)
+	mockPaymentRepo.On("Create", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		args.Get(1).(*models.Payment).ID = 3
+	}).Return(nil)
+	mockPaymentRepo.On("SetReference", mock.Anything, int64(3), mock.AnythingOfType("string")).Return(nil)
+
+	payment, err := service.StartPayment(context.Background(), 7, "", "0712345678")
+	assert.NoError(t, err)
+	assert.Equal(t, models.PaymentProviderMpesa, payment.Provider)
+
+	simulator.Wait()
+	hook := <-callbacks
+
+	mockPaymentRepo.On("Complete", mock.Anything, mock.MatchedBy(func(r *models.PaymentResult) bool {
+		return r.Reference == payment.Reference && r.Status == models.PaymentSucceeded && r.Amount.Equal(decimal.NewFromInt(1500))
+	})).Return(&models.Payment{ID: 3, OrderID: 7, Amount: decimal.NewFromInt(1500), Status: models.PaymentSucceeded}, true, nil)
+	mockOrderRepo.On("Transition", mock.Anything, mock.MatchedBy(func(c *models.OrderStatusChange) bool {
+		return c.ToStatus == models.OrderStatusPaid && c.ChangedBy == "mpesa"
+	})).Return(nil)
+
+	assert.NoError(t, service.HandleWebhook(context.Background(), "mpesa", hook))
+	mockOrderRepo.AssertExpectations(t)
+
+	// A callback without the token is refused
+	hook.Query.Del("token")
+	assert.ErrorIs(t, service.HandleWebhook(context.Background(), "mpesa", hook), payments.ErrInvalidSignature)
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/nyaruka/phonenumbers"
+)
+
+const defaultPhoneRegion = "KE"
+
+// PhoneNumber is a parsed, validated phone number
+type PhoneNumber struct {
+	E164        string // canonical form, e.g. +254712345678
+	CountryCode string // ISO 3166-1 alpha-2 region, e.g. KE
+}
+
+// phoneDefaultRegion reads PHONE_DEFAULT_REGION (default KE)
+func phoneDefaultRegion() string {
+	region := strings.ToUpper(strings.TrimSpace(os.Getenv("PHONE_DEFAULT_REGION")))
+	if region == "" {
+		return defaultPhoneRegion
+	}
+	return region
+}
+
+// ParsePhoneNumber normalises raw into E.164 using libphonenumber's numbering
+// plans. Numbers without an international prefix are interpreted in
+// defaultRegion, so "0712 345 678" with region KE becomes +254712345678.
+func ParsePhoneNumber(raw, defaultRegion string) (PhoneNumber, error) {
+	s := strings.TrimSpace(raw)
+	if s == "" {
+		return PhoneNumber{}, fmt.Errorf("phone number is required")
+	}
+	for _, ch := range s {
+		if !strings.ContainsRune("+0123456789 -.()", ch) {
+			return PhoneNumber{}, fmt.Errorf("%q contains invalid characters", raw)
+		}
+	}
+
+	// 00 is the international prefix in most of the world, though not in
+	// every region's numbering plan, e.g. Kenya dials 000
+	if strings.HasPrefix(s, "00") {
+		s = "+" + s[2:]
+	}
+
+	region := strings.ToUpper(strings.TrimSpace(defaultRegion))
+	if phonenumbers.GetCountryCodeForRegion(region) == 0 {
+		return PhoneNumber{}, fmt.Errorf("unsupported default phone region %q", defaultRegion)
+	}
+
+	number, err := phonenumbers.Parse(s, region)
+	if err != nil || !phonenumbers.IsValidNumber(number) {
+		return PhoneNumber{}, fmt.Errorf("%q is not a valid phone number", raw)
+	}
+
+	return PhoneNumber{
+		E164:        phonenumbers.Format(number, phonenumbers.E164),
+		CountryCode: phonenumbers.GetRegionCodeForNumber(number),
+	}, nil
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePhoneNumber(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		region  string
+		want    PhoneNumber
+		wantErr bool
+	}{
+		{"kenyan local mobile", "0712345678", "KE", PhoneNumber{"+254712345678", "KE"}, false},
+		{"kenyan local with spaces", "0712 345 678", "KE", PhoneNumber{"+254712345678", "KE"}, false},
+		{"kenyan new prefix", "0110 123456", "KE", PhoneNumber{"+254110123456", "KE"}, false},
+		{"already e164", "+254712345678", "KE", PhoneNumber{"+254712345678", "KE"}, false},
+		{"calling code without plus", "254712345678", "KE", PhoneNumber{"+254712345678", "KE"}, false},
+		{"00 international prefix", "00256 772 123456", "KE", PhoneNumber{"+256772123456", "UG"}, false},
+		{"foreign number keeps its country", "+255 754 123 456", "KE", PhoneNumber{"+255754123456", "TZ"}, false},
+		{"country outside our markets", "+33 6 12 34 56 78", "KE", PhoneNumber{"+33612345678", "FR"}, false},
+		{"any default region", "030 123456", "DE", PhoneNumber{"+4930123456", "DE"}, false},
+		{"ugandan default region", "0772-123-456", "UG", PhoneNumber{"+256772123456", "UG"}, false},
+		{"region is case insensitive", "0712345678", "ke", PhoneNumber{"+254712345678", "KE"}, false},
+		{"too short", "071234567", "KE", PhoneNumber{}, true},
+		{"too long", "07123456789", "KE", PhoneNumber{}, true},
+		{"invalid leading digit", "0812345678", "KE", PhoneNumber{}, true},
+		{"letters", "07123ABC78", "KE", PhoneNumber{}, true},
+		{"empty", "  ", "KE", PhoneNumber{}, true},
+		{"unsupported country", "+99912345678", "KE", PhoneNumber{}, true},
+		{"unsupported default region", "0712345678", "XX", PhoneNumber{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePhoneNumber(tt.raw, tt.region)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
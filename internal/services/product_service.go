@@ -0,0 +1,212 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/chesireabel/Technical-Interview/internal/repositories"
+)
+
+type ProductService interface {
+	CreateProduct(ctx context.Context, product *models.Product) (int64, error)
+	GetProduct(ctx context.Context, id int64) (*models.Product, error)
+	ListProducts(ctx context.Context, filter models.ProductFilter) ([]models.Product, error)
+	UpdateProduct(ctx context.Context, product *models.Product) error
+	ArchiveProduct(ctx context.Context, id int64) error
+}
+
+type productService struct {
+	repo repositories.ProductRepository
+}
+
+func NewProductService(repo repositories.ProductRepository) ProductService {
+	return &productService{repo: repo}
+}
+
+// normaliseCategory makes "Shoes " and "shoes" the same category
+func normaliseCategory(category string) string {
+	return strings.ToLower(strings.TrimSpace(category))
+}
+
+// validateProduct applies the rules shared by create and update: SKUs are
+// stored upper case, prices must be exact in the product's currency and
+// categories are normalised and de-duplicated
+func validateProduct(product *models.Product) error {
+	product.SKU = strings.ToUpper(strings.TrimSpace(product.SKU))
+	product.Name = strings.TrimSpace(product.Name)
+	product.Description = strings.TrimSpace(product.Description)
+
+	if product.SKU == "" || product.Name == "" {
+		return errors.New("sku and name are required")
+	}
+	if len(product.SKU) > 64 {
+		return errors.New("sku must be at most 64 characters")
+	}
+
+	currency := models.DefaultCurrency()
+	if product.Currency != "" {
+		c, err := models.LookupCurrency(product.Currency)
+		if err != nil {
+			return err
+		}
+		currency = c
+	}
+	product.Currency = currency.Code
+
+	// Order lines must be priced above zero, so a free product could not be ordered
+	if !product.Price.IsPositive() {
+		return errors.New("price must be greater than 0")
+	}
+	if !currency.Exact(product.Price) {
+		return fmt.Errorf("price has more decimal places than %s allows", currency.Code)
+	}
+
+	categories := make([]string, 0, len(product.Categories))
+	for _, c := range product.Categories {
+		if c = normaliseCategory(c); c != "" && !slices.Contains(categories, c) {
+			categories = append(categories, c)
+		}
+	}
+	product.Categories = categories
+
+	return nil
+}
+
+func (s *productService) CreateProduct(ctx context.Context, product *models.Product) (int64, error) {
+	if err := validateProduct(product); err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return s.repo.Create(ctx, product)
+}
+
+func (s *productService) GetProduct(ctx context.Context, id int64) (*models.Product, error) {
+	if id == 0 {
+		return nil, errors.New("id is required")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return s.repo.GetByID(ctx, id)
+}
+
+func (s *productService) ListProducts(ctx context.Context, filter models.ProductFilter) ([]models.Product, error) {
+	filter.Category = normaliseCategory(filter.Category)
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	return s.repo.List(ctx, filter)
+}
+
+func (s *productService) UpdateProduct(ctx context.Context, product *models.Product) error {
+	if product.ID == 0 {
+		return errors.New("id is required for update")
+	}
+	if err := validateProduct(product); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return s.repo.Update(ctx, product)
+}
+
+// ArchiveProduct hides a product from the catalogue and stops new orders for it.
+// Existing orders keep their snapshot of its name and price.
+func (s *productService) ArchiveProduct(ctx context.Context, id int64) error {
+	if id == 0 {
+		return errors.New("id is required for delete")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return s.repo.SetActive(ctx, id, false)
+}
+
+// snapshotProducts fills in order lines that reference a catalogue product with
+// the product's current SKU, name and price, so later price changes do not
+// alter the order. A client supplied unit price for such a line is ignored.
+// Products must be active and priced in the order's currency; an order without
+// a currency takes the currency of its first product.
+//
+// previous are the lines of the order being edited. A line for a product the
+// order already had keeps that line's snapshot, even if the product's price
+// has changed or it has been archived since; only new products are priced
+// from the catalogue.
+func snapshotProducts(ctx context.Context, repo repositories.ProductRepository, order *models.Order, previous []models.OrderItem) error {
+	var ids []int64
+	for _, item := range order.Items {
+		if item.ProductID != nil {
+			ids = append(ids, *item.ProductID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	if repo == nil {
+		return errors.New("product catalogue is not available")
+	}
+
+	products, err := repo.GetByIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	snapshots := make(map[int64][]models.OrderItem)
+	for _, item := range previous {
+		if item.ProductID != nil {
+			snapshots[*item.ProductID] = append(snapshots[*item.ProductID], item)
+		}
+	}
+
+	for i := range order.Items {
+		item := &order.Items[i]
+		if item.ProductID == nil {
+			continue
+		}
+
+		product, ok := products[*item.ProductID]
+		if kept := snapshots[*item.ProductID]; len(kept) > 0 {
+			snapshots[*item.ProductID] = kept[1:]
+			item.ProductRef = kept[0].ProductRef
+			item.Description = kept[0].Description
+			item.UnitPrice = kept[0].UnitPrice
+			if ok {
+				item.Categories = product.Categories
+			}
+			continue
+		}
+
+		if !ok {
+			return fmt.Errorf("items[%d].product_id %d does not exist", i, *item.ProductID)
+		}
+		if !product.IsActive() {
+			return fmt.Errorf("items[%d].product_id %d is no longer available", i, product.ID)
+		}
+		if order.Currency == "" {
+			order.Currency = product.Currency
+		}
+		if !strings.EqualFold(order.Currency, product.Currency) {
+			return fmt.Errorf("items[%d].product_id %d is priced in %s, not %s", i, product.ID, product.Currency, strings.ToUpper(order.Currency))
+		}
+
+		item.ProductRef = product.SKU
+		item.Description = product.Name
+		item.UnitPrice = product.Price
+		item.Categories = product.Categories
+	}
+
+	return nil
+}
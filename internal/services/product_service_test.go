@@ -0,0 +1,192 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/chesireabel/Technical-Interview/internal/repositories"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCreateProduct(t *testing.T) {
+	mockRepo := new(MockProductRepo)
+	service := NewProductService(mockRepo)
+
+	product := &models.Product{
+		SKU:        " shoe-42 ",
+		Name:       " Running Shoes ",
+		Price:      decimal.RequireFromString("2499.50"),
+		Categories: []string{"Shoes ", "shoes", " Sport", ""},
+	}
+	mockRepo.On("Create", mock.Anything, product).Return(int64(1), nil)
+
+	id, err := service.CreateProduct(context.Background(), product)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), id)
+	assert.Equal(t, "SHOE-42", product.SKU)
+	assert.Equal(t, "Running Shoes", product.Name)
+	assert.Equal(t, "KES", product.Currency)
+	assert.Equal(t, []string{"shoes", "sport"}, product.Categories)
+	assert.True(t, product.IsActive())
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateProduct_Invalid(t *testing.T) {
+	service := NewProductService(new(MockProductRepo))
+
+	tests := []struct {
+		name    string
+		product models.Product
+		want    string
+	}{
+		{"missing sku", models.Product{Name: "Shoes", Price: decimal.NewFromInt(1)}, "sku and name are required"},
+		{"negative price", models.Product{SKU: "A", Name: "Shoes", Price: decimal.NewFromInt(-1)}, "price must be greater than 0"},
+		{"free", models.Product{SKU: "A", Name: "Shoes"}, "price must be greater than 0"},
+		{"fractional UGX", models.Product{SKU: "A", Name: "Shoes", Currency: "ugx", Price: decimal.RequireFromString("10.5")}, "price has more decimal places than UGX allows"},
+		{"unknown currency", models.Product{SKU: "A", Name: "Shoes", Currency: "ZZZ", Price: decimal.NewFromInt(1)}, `unsupported currency "ZZZ"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.CreateProduct(context.Background(), &tt.product)
+			assert.EqualError(t, err, tt.want)
+		})
+	}
+}
+
+func TestCreateOrder_SnapshotsProducts(t *testing.T) {
+	mockOrderRepo := new(MockOrderRepo)
+	mockCustomerRepo := new(MockCustomerRepo)
+	mockProductRepo := new(MockProductRepo)
+	mockInventoryRepo := new(MockInventoryRepo)
+	tx := &FakeTxManager{Repos: repositories.Repositories{Orders: mockOrderRepo, Inventory: mockInventoryRepo, TaxRates: noTaxRates()}}
+	service := NewOrderService(mockOrderRepo, mockCustomerRepo, mockProductRepo, tx)
+
+	shoesID, socksID := int64(3), int64(4)
+	order := &models.Order{
+		CustomerID: "1",
+		Items: []models.OrderItem{
+			// The client's price is ignored for catalogue products
+			{ProductID: &shoesID, Quantity: 2, UnitPrice: decimal.NewFromInt(1)},
+			{ProductID: &socksID, Quantity: 1},
+			{Description: "Gift wrap", Quantity: 1, UnitPrice: decimal.NewFromInt(50)},
+		},
+	}
+
+	mockProductRepo.On("GetByIDs", mock.Anything, []int64{3, 4}).Return(map[int64]*models.Product{
+		3: {ID: 3, SKU: "SHOE-42", Name: "Running Shoes", Price: decimal.RequireFromString("2499.50"), Currency: "KES"},
+		4: {ID: 4, SKU: "SOCK-1", Name: "Socks", Price: decimal.NewFromInt(200), Currency: "KES"},
+	}, nil)
+	mockCustomerRepo.On("GetByID", mock.Anything, int64(1)).Return(&models.Customer{ID: 1}, nil)
+	mockOrderRepo.On("Create", mock.Anything, order).Return(int64(9), nil)
+	mockInventoryRepo.On("Reserve", mock.Anything, int64(9), map[int64]int{3: 2, 4: 1}, "MAIN", "order:9").Return(nil)
+
+	_, err := service.CreateOrder(context.Background(), order)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "SHOE-42", order.Items[0].ProductRef)
+	assert.Equal(t, "Running Shoes", order.Items[0].Description)
+	assert.Equal(t, "4999", order.Items[0].LineTotal.String())
+	assert.Equal(t, "Socks", order.Items[1].Description)
+	assert.Equal(t, "5249", order.Amount.String())
+	assert.Equal(t, "KES", order.Currency)
+	mockOrderRepo.AssertExpectations(t)
+	mockInventoryRepo.AssertExpectations(t)
+}
+
+func TestCreateOrder_ProductErrors(t *testing.T) {
+	inactive := false
+	id := int64(3)
+	products := map[int64]*models.Product{
+		3: {ID: 3, Name: "Shoes", Price: decimal.NewFromInt(10), Currency: "UGX", Active: &inactive},
+	}
+
+	tests := []struct {
+		name     string
+		currency string
+		active   bool
+		want     string
+	}{
+		{"inactive", "", false, "items[0].product_id 3 is no longer available"},
+		{"currency mismatch", "kes", true, "items[0].product_id 3 is priced in UGX, not KES"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockProductRepo := new(MockProductRepo)
+			service := NewOrderService(new(MockOrderRepo), new(MockCustomerRepo), mockProductRepo, nil)
+
+			*products[3].Active = tt.active
+			mockProductRepo.On("GetByIDs", mock.Anything, []int64{3}).Return(products, nil)
+
+			_, err := service.CreateOrder(context.Background(), &models.Order{
+				CustomerID: "1",
+				Currency:   tt.currency,
+				Items:      []models.OrderItem{{ProductID: &id, Quantity: 1}},
+			})
+			assert.EqualError(t, err, tt.want)
+		})
+	}
+
+	mockProductRepo := new(MockProductRepo)
+	service := NewOrderService(new(MockOrderRepo), new(MockCustomerRepo), mockProductRepo, nil)
+	mockProductRepo.On("GetByIDs", mock.Anything, []int64{3}).Return(map[int64]*models.Product{}, nil)
+
+	_, err := service.CreateOrder(context.Background(), &models.Order{CustomerID: "1", Items: []models.OrderItem{{ProductID: &id, Quantity: 1}}})
+	assert.EqualError(t, err, "items[0].product_id 3 does not exist")
+}
+
+func TestUpdateOrder_KeepsProductSnapshots(t *testing.T) {
+	mockOrderRepo := new(MockOrderRepo)
+	mockProductRepo := new(MockProductRepo)
+	mockInventoryRepo := new(MockInventoryRepo)
+	mockPaymentRepo := new(MockPaymentRepo)
+	tx := &FakeTxManager{Repos: repositories.Repositories{Orders: mockOrderRepo, Products: mockProductRepo, Inventory: mockInventoryRepo, Payments: mockPaymentRepo, TaxRates: noTaxRates()}}
+	service := NewOrderService(mockOrderRepo, new(MockCustomerRepo), mockProductRepo, tx)
+	mockPaymentRepo.On("HasPending", mock.Anything, int64(9), mock.Anything).Return(false, nil)
+
+	archived := false
+	shoesID, socksID := int64(3), int64(4)
+	mockOrderRepo.On("GetByID", mock.Anything, int64(9)).Return(&models.Order{
+		ID:         9,
+		CustomerID: "1",
+		Status:     models.OrderStatusPending,
+		Currency:   "KES",
+		Items: []models.OrderItem{
+			{ProductID: &shoesID, ProductRef: "SHOE-42", Description: "Running Shoes", Quantity: 1, UnitPrice: decimal.NewFromInt(2000)},
+		},
+	}, nil)
+	// The shoes have since gone up in price and been archived
+	mockProductRepo.On("GetByIDs", mock.Anything, []int64{3, 4}).Return(map[int64]*models.Product{
+		3: {ID: 3, SKU: "SHOE-42", Name: "Running Shoes v2", Price: decimal.RequireFromString("2499.50"), Currency: "KES", Active: &archived},
+		4: {ID: 4, SKU: "SOCK-1", Name: "Socks", Price: decimal.NewFromInt(200), Currency: "KES"},
+	}, nil)
+	mockOrderRepo.On("Update", mock.Anything, mock.Anything).Return(nil)
+	mockInventoryRepo.On("Release", mock.Anything, int64(9), "order:9").Return(nil)
+	mockInventoryRepo.On("Reserve", mock.Anything, int64(9), map[int64]int{3: 2, 4: 1}, "MAIN", "order:9").Return(nil)
+
+	order := &models.Order{
+		ID:         9,
+		CustomerID: "1",
+		Items: []models.OrderItem{
+			{ProductID: &shoesID, Quantity: 2},
+			{ProductID: &socksID, Quantity: 1},
+		},
+	}
+	err := service.UpdateOrder(context.Background(), order)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Running Shoes", order.Items[0].Description)
+	assert.Equal(t, "4000", order.Items[0].LineTotal.String())
+	assert.Equal(t, "Socks", order.Items[1].Description)
+	assert.Equal(t, "4200", order.Amount.String())
+	assert.Equal(t, "KES", order.Currency)
+	mockOrderRepo.AssertExpectations(t)
+
+	err = service.UpdateOrder(context.Background(), &models.Order{ID: 9, CustomerID: "1", Currency: "UGX", Items: order.Items})
+	assert.EqualError(t, err, "currency cannot be changed once the order is placed")
+}
@@ -0,0 +1,231 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/chesireabel/Technical-Interview/internal/repositories"
+	"github.com/shopspring/decimal"
+)
+
+// maxPromoCodeLength matches the promotions.code column
+const maxPromoCodeLength = 50
+
+// PromotionService manages the discount codes marketing hands out. Codes are
+// applied to orders by the order service inside the order's transaction.
+type PromotionService interface {
+	CreatePromotion(ctx context.Context, promotion *models.Promotion) (int64, error)
+	GetPromotion(ctx context.Context, id int64) (*models.Promotion, error)
+	ListPromotions(ctx context.Context, includeInactive bool) ([]models.Promotion, error)
+	DeactivatePromotion(ctx context.Context, id int64) error
+}
+
+type promotionService struct {
+	repo repositories.PromotionRepository
+}
+
+func NewPromotionService(repo repositories.PromotionRepository) PromotionService {
+	return &promotionService{repo: repo}
+}
+
+// normalisePromoCode makes " jamhuri" and "JAMHURI" the same code
+func normalisePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// validatePromotion checks a new promotion. Fixed discounts and minimum order
+// amounts are money, so they need a currency and default to DEFAULT_CURRENCY.
+func validatePromotion(promotion *models.Promotion, now time.Time) error {
+	promotion.Code = normalisePromoCode(promotion.Code)
+	promotion.Description = strings.TrimSpace(promotion.Description)
+
+	if promotion.Code == "" {
+		return errors.New("code is required")
+	}
+	if len(promotion.Code) > maxPromoCodeLength || strings.ContainsAny(promotion.Code, " \t\n") {
+		return fmt.Errorf("code must be at most %d characters without spaces", maxPromoCodeLength)
+	}
+
+	if !promotion.Value.IsPositive() {
+		return errors.New("value must be greater than 0")
+	}
+	if promotion.MinOrderAmount.IsNegative() {
+		return errors.New("min_order_amount must not be negative")
+	}
+
+	if promotion.Currency != "" || promotion.Kind == models.PromotionFixed || promotion.MinOrderAmount.IsPositive() {
+		currency := models.DefaultCurrency()
+		if promotion.Currency != "" {
+			c, err := models.LookupCurrency(promotion.Currency)
+			if err != nil {
+				return err
+			}
+			currency = c
+		}
+		promotion.Currency = currency.Code
+
+		if !currency.Exact(promotion.MinOrderAmount) {
+			return fmt.Errorf("min_order_amount has more decimal places than %s allows", currency.Code)
+		}
+		if promotion.Kind == models.PromotionFixed && !currency.Exact(promotion.Value) {
+			return fmt.Errorf("value has more decimal places than %s allows", currency.Code)
+		}
+	}
+
+	switch promotion.Kind {
+	case models.PromotionFixed:
+	case models.PromotionPercentage:
+		if promotion.Value.GreaterThan(decimal.NewFromInt(100)) {
+			return errors.New("a percentage discount must be at most 100")
+		}
+	default:
+		return fmt.Errorf("kind must be %s or %s", models.PromotionPercentage, models.PromotionFixed)
+	}
+
+	if promotion.StartsAt.IsZero() {
+		promotion.StartsAt = now
+	}
+	if promotion.EndsAt != nil && !promotion.EndsAt.After(promotion.StartsAt) {
+		return errors.New("ends_at must be after starts_at")
+	}
+	if promotion.MaxUses != nil && *promotion.MaxUses <= 0 {
+		return errors.New("max_uses must be greater than 0")
+	}
+	if promotion.MaxUsesPerCustomer != nil && *promotion.MaxUsesPerCustomer <= 0 {
+		return errors.New("max_uses_per_customer must be greater than 0")
+	}
+
+	return nil
+}
+
+// promotionDiscount works out the discount a promotion gives on an order's
+// subtotal. Percentage discounts are rounded to the order's currency and no
+// discount is larger than the subtotal.
+func promotionDiscount(promotion *models.Promotion, order *models.Order) (decimal.Decimal, error) {
+	if promotion.Currency != "" && promotion.Currency != order.Currency {
+		return decimal.Zero, &models.PromotionError{Code: promotion.Code, Reason: "is only valid on " + promotion.Currency + " orders"}
+	}
+	if order.Subtotal.LessThan(promotion.MinOrderAmount) {
+		return decimal.Zero, &models.PromotionError{
+			Code:   promotion.Code,
+			Reason: "needs an order of at least " + models.FormatMoney(promotion.MinOrderAmount, promotion.Currency),
+		}
+	}
+
+	discount := promotion.Value
+	if promotion.Kind == models.PromotionPercentage {
+		currency, err := models.LookupCurrency(order.Currency)
+		if err != nil {
+			return decimal.Zero, err
+		}
+		discount = currency.Round(order.Subtotal.Mul(promotion.Value).Div(decimal.NewFromInt(100)))
+	}
+
+	if discount.GreaterThan(order.Subtotal) {
+		discount = order.Subtotal
+	}
+	return discount, nil
+}
+
+// applyPromotion checks the order's promo code against the promotion's
+// validity window and usage limits and takes the discount off the order. It
+// locks the promotion, so it must run inside the order's transaction.
+func applyPromotion(ctx context.Context, repo repositories.PromotionRepository, order *models.Order, now time.Time) (*models.Promotion, error) {
+	code := normalisePromoCode(order.PromoCode)
+
+	promotion, err := repo.LockByCode(ctx, code)
+	if errors.Is(err, models.ErrNotFound) {
+		return nil, &models.PromotionError{Code: code, Reason: "does not exist"}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case !promotion.IsActive():
+		return nil, &models.PromotionError{Code: promotion.Code, Reason: "is no longer available"}
+	case now.Before(promotion.StartsAt):
+		return nil, &models.PromotionError{Code: promotion.Code, Reason: "is not valid yet"}
+	case promotion.EndsAt != nil && !now.Before(*promotion.EndsAt):
+		return nil, &models.PromotionError{Code: promotion.Code, Reason: "has expired"}
+	case promotion.MaxUses != nil && promotion.Uses >= *promotion.MaxUses:
+		return nil, &models.PromotionError{Code: promotion.Code, Reason: "has been fully redeemed"}
+	}
+
+	if promotion.MaxUsesPerCustomer != nil {
+		customerID, err := strconv.ParseInt(order.CustomerID, 10, 64)
+		if err != nil {
+			return nil, errors.New("invalid customer_id format")
+		}
+		used, err := repo.CountRedemptions(ctx, promotion.ID, customerID)
+		if err != nil {
+			return nil, err
+		}
+		if used >= *promotion.MaxUsesPerCustomer {
+			return nil, &models.PromotionError{Code: promotion.Code, Reason: "has already been used the maximum number of times"}
+		}
+	}
+
+	discount, err := promotionDiscount(promotion, order)
+	if err != nil {
+		return nil, err
+	}
+
+	order.PromoCode = promotion.Code
+	order.Discount = discount
+	order.Amount = order.Subtotal.Sub(discount)
+	return promotion, nil
+}
+
+func (s *promotionService) CreatePromotion(ctx context.Context, promotion *models.Promotion) (int64, error) {
+	if err := validatePromotion(promotion, time.Now()); err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	id, err := s.repo.Create(ctx, promotion)
+	if err != nil {
+		return 0, err
+	}
+	promotion.ID = id
+
+	return id, nil
+}
+
+func (s *promotionService) GetPromotion(ctx context.Context, id int64) (*models.Promotion, error) {
+	if id == 0 {
+		return nil, errors.New("id is required")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return s.repo.GetByID(ctx, id)
+}
+
+func (s *promotionService) ListPromotions(ctx context.Context, includeInactive bool) ([]models.Promotion, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	return s.repo.List(ctx, includeInactive)
+}
+
+// DeactivatePromotion stops a code from being redeemed. Promotions are never
+// deleted because orders keep the code they were placed with.
+func (s *promotionService) DeactivatePromotion(ctx context.Context, id int64) error {
+	if id == 0 {
+		return errors.New("id is required")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return s.repo.SetActive(ctx, id, false)
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/chesireabel/Technical-Interview/internal/repositories"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestValidatePromotion(t *testing.T) {
+	now := time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC)
+	before := now.Add(-time.Hour)
+	zero := 0
+
+	tests := []struct {
+		name      string
+		promotion models.Promotion
+		want      string
+	}{
+		{"missing code", models.Promotion{Kind: models.PromotionPercentage, Value: decimal.NewFromInt(10)}, "code is required"},
+		{"code with spaces", models.Promotion{Code: "JAMHURI DAY", Kind: models.PromotionPercentage, Value: decimal.NewFromInt(10)}, "code must be at most 50 characters without spaces"},
+		{"unknown kind", models.Promotion{Code: "X", Kind: "bogof", Value: decimal.NewFromInt(10)}, "kind must be percentage or fixed"},
+		{"zero value", models.Promotion{Code: "X", Kind: models.PromotionFixed}, "value must be greater than 0"},
+		{"over 100 percent", models.Promotion{Code: "X", Kind: models.PromotionPercentage, Value: decimal.NewFromInt(101)}, "a percentage discount must be at most 100"},
+		{"sub-unit fixed value", models.Promotion{Code: "X", Kind: models.PromotionFixed, Value: decimal.RequireFromString("50.5"), Currency: "UGX"}, "value has more decimal places than UGX allows"},
+		{"ends before it starts", models.Promotion{Code: "X", Kind: models.PromotionPercentage, Value: decimal.NewFromInt(10), EndsAt: &before}, "ends_at must be after starts_at"},
+		{"zero max uses", models.Promotion{Code: "X", Kind: models.PromotionPercentage, Value: decimal.NewFromInt(10), MaxUses: &zero}, "max_uses must be greater than 0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.EqualError(t, validatePromotion(&tt.promotion, now), tt.want)
+		})
+	}
+
+	promotion := models.Promotion{Code: " jamhuri ", Kind: models.PromotionFixed, Value: decimal.NewFromInt(200)}
+	assert.NoError(t, validatePromotion(&promotion, now))
+	assert.Equal(t, "JAMHURI", promotion.Code)
+	assert.Equal(t, "KES", promotion.Currency)
+	assert.Equal(t, now, promotion.StartsAt)
+}
+
+func TestPromotionDiscount(t *testing.T) {
+	percent := &models.Promotion{Code: "JAMHURI", Kind: models.PromotionPercentage, Value: decimal.NewFromInt(10)}
+	fixed := &models.Promotion{Code: "KARIBU", Kind: models.PromotionFixed, Value: decimal.NewFromInt(500), Currency: "KES", MinOrderAmount: decimal.NewFromInt(300)}
+
+	discount, err := promotionDiscount(percent, &models.Order{Subtotal: decimal.RequireFromString("1234.55"), Currency: "KES"})
+	assert.NoError(t, err)
+	assert.Equal(t, "123.46", discount.String())
+
+	// A fixed discount never takes the order below zero
+	discount, err = promotionDiscount(fixed, &models.Order{Subtotal: decimal.NewFromInt(400), Currency: "KES"})
+	assert.NoError(t, err)
+	assert.Equal(t, "400", discount.String())
+
+	_, err = promotionDiscount(fixed, &models.Order{Subtotal: decimal.NewFromInt(250), Currency: "KES"})
+	assert.EqualError(t, err, "promo code KARIBU needs an order of at least KES 300.00")
+
+	_, err = promotionDiscount(fixed, &models.Order{Subtotal: decimal.NewFromInt(4000), Currency: "UGX"})
+	assert.ErrorIs(t, err, models.ErrPromotionNotApplicable)
+}
+
+func TestCreateOrder_WithPromoCode(t *testing.T) {
+	mockOrderRepo := new(MockOrderRepo)
+	mockCustomerRepo := new(MockCustomerRepo)
+	mockPromotionRepo := new(MockPromotionRepo)
+	tx := &FakeTxManager{Repos: repositories.Repositories{Orders: mockOrderRepo, Promotions: mockPromotionRepo, TaxRates: noTaxRates()}}
+	service := NewOrderService(mockOrderRepo, mockCustomerRepo, nil, tx)
+
+	maxPerCustomer := 1
+	promotion := &models.Promotion{
+		ID:                 4,
+		Code:               "JAMHURI",
+		Kind:               models.PromotionPercentage,
+		Value:              decimal.NewFromInt(10),
+		StartsAt:           time.Now().Add(-time.Hour),
+		MaxUsesPerCustomer: &maxPerCustomer,
+	}
+	order := &models.Order{CustomerID: "1", Item: "Kikoi", Amount: decimal.NewFromInt(1500), PromoCode: " jamhuri"}
+
+	mockCustomerRepo.On("GetByID", mock.Anything, int64(1)).Return(&models.Customer{ID: 1}, nil)
+	mockPromotionRepo.On("LockByCode", mock.Anything, "JAMHURI").Return(promotion, nil)
+	mockPromotionRepo.On("CountRedemptions", mock.Anything, int64(4), int64(1)).Return(0, nil)
+	mockOrderRepo.On("Create", mock.Anything, order).Return(int64(12), nil)
+	mockPromotionRepo.On("Redeem", mock.Anything, mock.MatchedBy(func(r *models.PromotionRedemption) bool {
+		return r.PromotionID == 4 && r.OrderID == 12 && r.CustomerID == 1 && r.Discount.Equal(decimal.NewFromInt(150))
+	})).Return(nil)
+
+	id, err := service.CreateOrder(context.Background(), order)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(12), id)
+	assert.Equal(t, "JAMHURI", order.PromoCode)
+	assert.Equal(t, "1500", order.Subtotal.String())
+	assert.Equal(t, "150", order.Discount.String())
+	assert.Equal(t, "1350", order.Amount.String())
+	mockPromotionRepo.AssertExpectations(t)
+}
+
+func TestCreateOrder_RejectedPromoCode(t *testing.T) {
+	now := time.Now()
+	ended := now.Add(-time.Minute)
+	one := 1
+
+	tests := []struct {
+		name      string
+		promotion *models.Promotion
+		used      int
+		want      string
+	}{
+		{"unknown code", nil, 0, "promo code JAMHURI does not exist"},
+		{"not started", &models.Promotion{Code: "JAMHURI", StartsAt: now.Add(time.Hour)}, 0, "promo code JAMHURI is not valid yet"},
+		{"expired", &models.Promotion{Code: "JAMHURI", StartsAt: now.Add(-time.Hour), EndsAt: &ended}, 0, "promo code JAMHURI has expired"},
+		{"fully redeemed", &models.Promotion{Code: "JAMHURI", MaxUses: &one, Uses: 1}, 0, "promo code JAMHURI has been fully redeemed"},
+		{"used by customer", &models.Promotion{ID: 4, Code: "JAMHURI", MaxUsesPerCustomer: &one}, 1, "promo code JAMHURI has already been used the maximum number of times"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockOrderRepo := new(MockOrderRepo)
+			mockCustomerRepo := new(MockCustomerRepo)
+			mockPromotionRepo := new(MockPromotionRepo)
+			tx := &FakeTxManager{Repos: repositories.Repositories{Orders: mockOrderRepo, Promotions: mockPromotionRepo}}
+			service := NewOrderService(mockOrderRepo, mockCustomerRepo, nil, tx)
+
+			mockCustomerRepo.On("GetByID", mock.Anything, int64(1)).Return(&models.Customer{ID: 1}, nil)
+			if tt.promotion == nil {
+				mockPromotionRepo.On("LockByCode", mock.Anything, "JAMHURI").Return(nil, models.ErrNotFound)
+			} else {
+				tt.promotion.Kind = models.PromotionPercentage
+				tt.promotion.Value = decimal.NewFromInt(10)
+				mockPromotionRepo.On("LockByCode", mock.Anything, "JAMHURI").Return(tt.promotion, nil)
+				mockPromotionRepo.On("CountRedemptions", mock.Anything, int64(4), int64(1)).Return(tt.used, nil)
+			}
+
+			_, err := service.CreateOrder(context.Background(), &models.Order{CustomerID: "1", Item: "Kikoi", Amount: decimal.NewFromInt(1500), PromoCode: "jamhuri"})
+
+			assert.EqualError(t, err, tt.want)
+			assert.ErrorIs(t, err, models.ErrPromotionNotApplicable)
+			mockOrderRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+		})
+	}
+}
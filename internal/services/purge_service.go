@@ -0,0 +1,105 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/chesireabel/Technical-Interview/internal/repositories"
+)
+
+const (
+	defaultRetentionDays = 30
+	defaultPurgeInterval = 24 * time.Hour
+)
+
+// PurgeService permanently removes customers and orders whose soft delete is
+// older than the configured retention period. Invoices are kept; they carry
+// their own copy of the order and customer.
+type PurgeService interface {
+	PurgeExpired(ctx context.Context) (customers int64, orders int64, err error)
+	Run(ctx context.Context)
+}
+
+type purgeService struct {
+	customerRepo repositories.CustomerRepository
+	orderRepo    repositories.OrderRepository
+	retention    time.Duration
+	interval     time.Duration
+	now          func() time.Time
+}
+
+// NewPurgeService reads SOFT_DELETE_RETENTION_DAYS (default 30) and
+// PURGE_INTERVAL (a Go duration, default 24h) from the environment
+func NewPurgeService(customerRepo repositories.CustomerRepository, orderRepo repositories.OrderRepository) (PurgeService, error) {
+	retentionDays := defaultRetentionDays
+	if v := os.Getenv("SOFT_DELETE_RETENTION_DAYS"); v != "" {
+		days, err := strconv.Atoi(v)
+		if err != nil || days < 0 {
+			return nil, fmt.Errorf("SOFT_DELETE_RETENTION_DAYS must be a non-negative integer, got %q", v)
+		}
+		retentionDays = days
+	}
+
+	interval := defaultPurgeInterval
+	if v := os.Getenv("PURGE_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("PURGE_INTERVAL must be a positive duration, got %q", v)
+		}
+		interval = d
+	}
+
+	return &purgeService{
+		customerRepo: customerRepo,
+		orderRepo:    orderRepo,
+		retention:    time.Duration(retentionDays) * 24 * time.Hour,
+		interval:     interval,
+		now:          time.Now,
+	}, nil
+}
+
+func (s *purgeService) PurgeExpired(ctx context.Context) (int64, int64, error) {
+	cutoff := s.now().Add(-s.retention)
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	// Orders first so that orders deleted on their own are counted here
+	// rather than disappearing silently through the customer cascade
+	orders, err := s.orderRepo.Purge(ctx, cutoff)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	customers, err := s.customerRepo.Purge(ctx, cutoff)
+	if err != nil {
+		return 0, orders, err
+	}
+
+	return customers, orders, nil
+}
+
+// Run purges expired records immediately and then on every interval until ctx is cancelled
+func (s *purgeService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		customers, orders, err := s.PurgeExpired(ctx)
+		if err != nil {
+			log.Printf("⚠️ Purge of soft deleted records failed: %v", err)
+		} else if customers > 0 || orders > 0 {
+			log.Printf("🧹 Purged %d customer(s) and %d order(s) deleted more than %v ago", customers, orders, s.retention)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
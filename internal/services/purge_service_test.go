@@ -0,0 +1,56 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPurgeExpired(t *testing.T) {
+	mockCustomerRepo := new(MockCustomerRepo)
+	mockOrderRepo := new(MockOrderRepo)
+
+	now := time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)
+	cutoff := now.Add(-30 * 24 * time.Hour)
+
+	svc := &purgeService{
+		customerRepo: mockCustomerRepo,
+		orderRepo:    mockOrderRepo,
+		retention:    30 * 24 * time.Hour,
+		now:          func() time.Time { return now },
+	}
+
+	mockOrderRepo.On("Purge", mock.Anything, cutoff).Return(int64(4), nil)
+	mockCustomerRepo.On("Purge", mock.Anything, cutoff).Return(int64(2), nil)
+
+	customers, orders, err := svc.PurgeExpired(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), customers)
+	assert.Equal(t, int64(4), orders)
+	mockOrderRepo.AssertExpectations(t)
+	mockCustomerRepo.AssertExpectations(t)
+}
+
+func TestPurgeExpired_OrderPurgeFails(t *testing.T) {
+	mockCustomerRepo := new(MockCustomerRepo)
+	mockOrderRepo := new(MockOrderRepo)
+
+	svc := &purgeService{
+		customerRepo: mockCustomerRepo,
+		orderRepo:    mockOrderRepo,
+		retention:    time.Hour,
+		now:          time.Now,
+	}
+
+	mockOrderRepo.On("Purge", mock.Anything, mock.AnythingOfType("time.Time")).Return(int64(0), errors.New("db down"))
+
+	_, _, err := svc.PurgeExpired(context.Background())
+
+	assert.Error(t, err)
+	mockCustomerRepo.AssertNotCalled(t, "Purge", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,198 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/chesireabel/Technical-Interview/internal/payments"
+	"github.com/chesireabel/Technical-Interview/internal/repositories"
+)
+
+type RefundService interface {
+	// RefundOrder refunds part or, when Amount is zero, all of what is left
+	// to refund on an order. The money is returned through the payment
+	// provider and the customer told by SMS, both through the outbox.
+	// Payments from providers that only refund in full, like M-Pesa, cannot
+	// be partly refunded.
+	RefundOrder(ctx context.Context, refund *models.Refund) error
+	ListRefunds(ctx context.Context, orderID int64) ([]models.Refund, error)
+}
+
+type refundService struct {
+	repo      repositories.RefundRepository
+	orderRepo repositories.OrderRepository
+}
+
+func NewRefundService(repo repositories.RefundRepository, orderRepo repositories.OrderRepository) RefundService {
+	return &refundService{repo: repo, orderRepo: orderRepo}
+}
+
+func (s *refundService) RefundOrder(ctx context.Context, refund *models.Refund) error {
+	if refund.OrderID == 0 {
+		return errors.New("order id is required")
+	}
+	refund.Reason = strings.TrimSpace(refund.Reason)
+	if refund.Reason == "" {
+		return errors.New("reason is required")
+	}
+	if refund.CreatedBy == "" {
+		return errors.New("actor is required")
+	}
+	if refund.Amount.IsNegative() {
+		return errors.New("amount must not be negative")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	// Refunds are always in the order's currency
+	order, err := s.orderRepo.GetByID(ctx, refund.OrderID)
+	if err != nil {
+		return err
+	}
+	currency, err := models.LookupCurrency(order.Currency)
+	if err != nil {
+		return err
+	}
+	if !currency.Exact(refund.Amount) {
+		return fmt.Errorf("amount has more decimal places than %s allows", currency.Code)
+	}
+
+	return s.repo.Create(ctx, refund)
+}
+
+func (s *refundService) ListRefunds(ctx context.Context, orderID int64) ([]models.Refund, error) {
+	if orderID == 0 {
+		return nil, errors.New("order id is required")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if _, err := s.orderRepo.GetByID(ctx, orderID); err != nil {
+		return nil, err
+	}
+
+	return s.repo.ListByOrder(ctx, orderID)
+}
+
+// RefundPayer returns the money of a recorded refund through the provider
+// that collected the payment it was made against
+type RefundPayer interface {
+	// PayOut makes the provider refund at most once: a refund already paid
+	// out or requested, or recorded before payments were, is left alone. It
+	// reports whether the money has been returned, which is not yet the
+	// case when the provider reports the result of the payout later.
+	PayOut(ctx context.Context, refundID int64) (bool, error)
+	// HandlePayoutWebhook records the result a provider reports for a
+	// pending payout. A successful payout queues the refund notification.
+	HandlePayoutWebhook(ctx context.Context, provider string, hook payments.Webhook) error
+}
+
+type refundPayer struct {
+	repo        repositories.RefundRepository
+	paymentRepo repositories.PaymentRepository
+	providers   *payments.Registry
+}
+
+func NewRefundPayer(repo repositories.RefundRepository, paymentRepo repositories.PaymentRepository, providers *payments.Registry) RefundPayer {
+	return &refundPayer{repo: repo, paymentRepo: paymentRepo, providers: providers}
+}
+
+func (p *refundPayer) PayOut(ctx context.Context, refundID int64) (bool, error) {
+	refund, err := p.repo.GetByID(ctx, refundID)
+	if err != nil {
+		return false, err
+	}
+	if refund.PaidOutAt != nil {
+		return true, nil
+	}
+	if refund.PayoutFailure != "" || refund.ProviderReference != "" {
+		// The provider has the payout and reports its result through HandlePayoutWebhook
+		return false, nil
+	}
+	if refund.PaymentID == nil {
+		log.Printf("Refund %d of order %d is not against a payment, nothing to pay out", refund.ID, refund.OrderID)
+		return true, nil
+	}
+
+	list, err := p.paymentRepo.ListByOrder(ctx, refund.OrderID)
+	if err != nil {
+		return false, err
+	}
+	var payment *models.Payment
+	for i := range list {
+		if list[i].ID == *refund.PaymentID {
+			payment = &list[i]
+		}
+	}
+	if payment == nil {
+		return false, fmt.Errorf("payment with id %d: %w", *refund.PaymentID, models.ErrNotFound)
+	}
+
+	provider, err := p.providers.Get(payment.Provider)
+	if err != nil {
+		return false, err
+	}
+
+	result, err := provider.Refund(ctx, payments.RefundRequest{
+		Reference:     payment.Reference,
+		Receipt:       payment.Receipt,
+		Amount:        refund.Amount,
+		PaymentAmount: payment.Amount,
+		Currency:      refund.Currency,
+		Reason:        refund.Reason,
+	})
+	if errors.Is(err, payments.ErrInvalidRequest) {
+		return false, err
+	}
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", models.ErrPaymentProvider, err)
+	}
+
+	if result.Pending {
+		return false, p.repo.MarkPayoutRequested(ctx, refund.ID, result.Reference)
+	}
+	if err := p.repo.MarkPaidOut(ctx, refund.ID, result.Reference); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (p *refundPayer) HandlePayoutWebhook(ctx context.Context, name string, hook payments.Webhook) error {
+	provider, err := p.providers.Get(name)
+	if err != nil {
+		return err
+	}
+	verifier, ok := provider.(payments.RefundWebhookVerifier)
+	if !ok {
+		return fmt.Errorf("%w: %s does not report refund results", payments.ErrUnknownProvider, name)
+	}
+
+	outcome, err := verifier.VerifyRefundWebhook(ctx, hook)
+	if err != nil {
+		return err
+	}
+
+	var failure string
+	if !outcome.Succeeded {
+		failure = fmt.Sprintf("%s (code: %d)", outcome.ResultDesc, outcome.ResultCode)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	refund, changed, err := p.repo.CompletePayout(ctx, outcome.Reference, failure)
+	if err != nil {
+		return err
+	}
+	if changed && failure != "" {
+		log.Printf("⚠️ Payout of refund %d on order %d failed, the customer has not been refunded: %s", refund.ID, refund.OrderID, failure)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/chesireabel/Technical-Interview/internal/payments"
+	"github.com/chesireabel/Technical-Interview/internal/repositories"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRefundOrder(t *testing.T) {
+	mockRefundRepo := new(MockRefundRepo)
+	mockOrderRepo := new(MockOrderRepo)
+	service := NewRefundService(mockRefundRepo, mockOrderRepo)
+
+	order := &models.Order{ID: 3, Amount: decimal.NewFromInt(1000), Currency: "KES"}
+	mockOrderRepo.On("GetByID", mock.Anything, int64(3)).Return(order, nil)
+	mockRefundRepo.On("Create", mock.Anything, mock.MatchedBy(func(r *models.Refund) bool {
+		return r.OrderID == 3 && r.Amount.Equal(decimal.RequireFromString("250.50")) && r.Reason == "damaged box" && r.CreatedBy == "agent@example.com"
+	})).Return(nil)
+
+	refund := &models.Refund{OrderID: 3, Amount: decimal.RequireFromString("250.50"), Reason: " damaged box ", CreatedBy: "agent@example.com"}
+	err := service.RefundOrder(context.Background(), refund)
+
+	assert.NoError(t, err)
+	mockRefundRepo.AssertExpectations(t)
+}
+
+func TestRefundOrder_Invalid(t *testing.T) {
+	mockOrderRepo := new(MockOrderRepo)
+	service := NewRefundService(new(MockRefundRepo), mockOrderRepo)
+
+	mockOrderRepo.On("GetByID", mock.Anything, int64(4)).Return(&models.Order{ID: 4, Currency: "UGX"}, nil)
+
+	tests := []struct {
+		name   string
+		refund models.Refund
+		want   string
+	}{
+		{"missing order", models.Refund{Reason: "x", CreatedBy: "a"}, "order id is required"},
+		{"missing reason", models.Refund{OrderID: 4, Reason: "  ", CreatedBy: "a"}, "reason is required"},
+		{"missing actor", models.Refund{OrderID: 4, Reason: "x"}, "actor is required"},
+		{"negative", models.Refund{OrderID: 4, Reason: "x", CreatedBy: "a", Amount: decimal.NewFromInt(-1)}, "amount must not be negative"},
+		{"fractional UGX", models.Refund{OrderID: 4, Reason: "x", CreatedBy: "a", Amount: decimal.RequireFromString("10.5")}, "amount has more decimal places than UGX allows"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := service.RefundOrder(context.Background(), &tt.refund)
+			assert.EqualError(t, err, tt.want)
+		})
+	}
+}
+
+func TestRefundOrder_ExceedsPaid(t *testing.T) {
+	mockRefundRepo := new(MockRefundRepo)
+	mockOrderRepo := new(MockOrderRepo)
+	service := NewRefundService(mockRefundRepo, mockOrderRepo)
+
+	mockOrderRepo.On("GetByID", mock.Anything, int64(3)).Return(&models.Order{ID: 3, Currency: "KES"}, nil)
+	mockRefundRepo.On("Create", mock.Anything, mock.Anything).Return(&models.RefundError{
+		OrderID:    3,
+		Requested:  decimal.NewFromInt(900),
+		Refundable: decimal.NewFromInt(400),
+	})
+
+	err := service.RefundOrder(context.Background(), &models.Refund{OrderID: 3, Amount: decimal.NewFromInt(900), Reason: "x", CreatedBy: "a"})
+
+	assert.ErrorIs(t, err, models.ErrRefundExceedsPaid)
+	assert.EqualError(t, err, "cannot refund 900 on order 3: only 400 is refundable")
+}
+
+func TestCancelOrder_WithRefund(t *testing.T) {
+	mockOrderRepo := new(MockOrderRepo)
+	mockInventoryRepo := new(MockInventoryRepo)
+	mockRefundRepo := new(MockRefundRepo)
+	tx := &FakeTxManager{Repos: repositories.Repositories{Orders: mockOrderRepo, Inventory: mockInventoryRepo, Refunds: mockRefundRepo}}
+	service := NewOrderService(mockOrderRepo, nil, nil, tx)
+
+	order := &models.Order{ID: 3, CustomerID: "1", Amount: decimal.NewFromInt(1000), Currency: "KES", Status: models.OrderStatusConfirmed}
+	mockOrderRepo.On("GetByID", mock.Anything, int64(3)).Return(order, nil)
+	mockOrderRepo.On("Transition", mock.Anything, mock.MatchedBy(func(c *models.OrderStatusChange) bool {
+		return c.ToStatus == models.OrderStatusCancelled && c.Reason == "customer changed mind"
+	})).Return(nil)
+	mockInventoryRepo.On("Release", mock.Anything, int64(3), "agent@example.com").Return(nil)
+	mockRefundRepo.On("Create", mock.Anything, mock.MatchedBy(func(r *models.Refund) bool {
+		// A zero amount asks the repository to refund whatever is left
+		return r.OrderID == 3 && r.Amount.IsZero() && r.Reason == "customer changed mind" && r.CreatedBy == "agent@example.com"
+	})).Return(nil)
+
+	cancelled, refund, err := service.CancelOrder(context.Background(), 3, "agent@example.com", " customer changed mind ", true)
+
+	assert.NoError(t, err)
+	assert.Equal(t, models.OrderStatusCancelled, cancelled.Status)
+	assert.NotNil(t, refund)
+	assert.Equal(t, 1, tx.Calls)
+	mockRefundRepo.AssertExpectations(t)
+}
+
+func TestCancelOrder_AlreadyRefunded(t *testing.T) {
+	mockOrderRepo := new(MockOrderRepo)
+	mockInventoryRepo := new(MockInventoryRepo)
+	mockRefundRepo := new(MockRefundRepo)
+	tx := &FakeTxManager{Repos: repositories.Repositories{Orders: mockOrderRepo, Inventory: mockInventoryRepo, Refunds: mockRefundRepo}}
+	service := NewOrderService(mockOrderRepo, nil, nil, tx)
+
+	order := &models.Order{ID: 3, CustomerID: "1", Status: models.OrderStatusPending}
+	mockOrderRepo.On("GetByID", mock.Anything, int64(3)).Return(order, nil)
+	mockOrderRepo.On("Transition", mock.Anything, mock.Anything).Return(nil)
+	mockInventoryRepo.On("Release", mock.Anything, int64(3), "agent@example.com").Return(nil)
+	mockRefundRepo.On("Create", mock.Anything, mock.Anything).Return(&models.RefundError{OrderID: 3, Refundable: decimal.Zero})
+
+	cancelled, refund, err := service.CancelOrder(context.Background(), 3, "agent@example.com", "duplicate", true)
+
+	assert.NoError(t, err)
+	assert.Equal(t, models.OrderStatusCancelled, cancelled.Status)
+	assert.Nil(t, refund)
+}
+
+func TestCancelOrder_RequiresReason(t *testing.T) {
+	service := NewOrderService(nil, nil, nil, nil)
+
+	_, _, err := service.CancelOrder(context.Background(), 3, "agent@example.com", " ", false)
+
+	assert.EqualError(t, err, "reason is required")
+}
+
+func TestHandlePayoutWebhook(t *testing.T) {
+	mockRefundRepo := new(MockRefundRepo)
+	mpesa := payments.NewMpesa(payments.MpesaConfig{CallbackToken: "s3cret"}, nil)
+	payer := NewRefundPayer(mockRefundRepo, new(MockPaymentRepo), payments.NewRegistry(mpesa))
+
+	result := func(code int, desc string) payments.Webhook {
+		body := fmt.Sprintf(`{"Result":{"ResultType":0,"ResultCode":%d,"ResultDesc":%q,"ConversationID":"AG_1","TransactionID":"NLJ1"}}`, code, desc)
+		return payments.Webhook{Query: url.Values{"token": {"s3cret"}}, Body: []byte(body)}
+	}
+
+	mockRefundRepo.On("CompletePayout", mock.Anything, "AG_1", "").Return(&models.Refund{ID: 9, OrderID: 3}, true, nil).Once()
+	err := payer.HandlePayoutWebhook(context.Background(), "mpesa", result(0, "The service request is processed successfully."))
+	assert.NoError(t, err)
+
+	mockRefundRepo.On("CompletePayout", mock.Anything, "AG_1", "The initiator information is invalid. (code: 2001)").Return(&models.Refund{ID: 9, OrderID: 3}, true, nil).Once()
+	err = payer.HandlePayoutWebhook(context.Background(), "mpesa", result(2001, "The initiator information is invalid."))
+	assert.NoError(t, err)
+	mockRefundRepo.AssertExpectations(t)
+
+	forged := result(0, "ok")
+	forged.Query = url.Values{"token": {"guess"}}
+	err = payer.HandlePayoutWebhook(context.Background(), "mpesa", forged)
+	assert.ErrorIs(t, err, payments.ErrInvalidSignature)
+
+	err = payer.HandlePayoutWebhook(context.Background(), "paypal", result(0, "ok"))
+	assert.ErrorIs(t, err, payments.ErrUnknownProvider)
+}
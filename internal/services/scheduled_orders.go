@@ -0,0 +1,186 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/chesireabel/Technical-Interview/internal/repositories"
+)
+
+const (
+	defaultScheduledOrderInterval = time.Minute
+	scheduledOrderBatchSize       = 100
+	// scheduledOrderActor is recorded as the one who released an order when it fired
+	scheduledOrderActor = "scheduler"
+)
+
+// setInitialStatus decides the status a new order starts in. An order with a
+// scheduled_for in the future waits in scheduled, with its stock reserved,
+// until the ScheduledOrderReleaser confirms it.
+func setInitialStatus(order *models.Order, now time.Time) error {
+	if order.ScheduledFor == nil {
+		order.Status = models.OrderStatusPending
+		return nil
+	}
+	if !order.ScheduledFor.After(now) {
+		return errors.New("scheduled_for must be in the future")
+	}
+	order.Status = models.OrderStatusScheduled
+	return nil
+}
+
+func (s *orderService) RescheduleOrder(ctx context.Context, id int64, scheduledFor time.Time) (*models.Order, error) {
+	if id == 0 {
+		return nil, errors.New("id is required")
+	}
+	if scheduledFor.IsZero() {
+		return nil, errors.New("scheduled_for is required")
+	}
+	if !scheduledFor.After(time.Now()) {
+		return nil, errors.New("scheduled_for must be in the future")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	order, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if order.Status != models.OrderStatusScheduled {
+		return nil, models.ErrNotScheduled
+	}
+
+	err = s.repo.Reschedule(ctx, id, scheduledFor)
+	if errors.Is(err, models.ErrNotFound) {
+		// It fired or was cancelled between our read and the update
+		return nil, models.ErrNotScheduled
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	order.ScheduledFor = &scheduledFor
+	return order, nil
+}
+
+// ScheduledOrderReleaser confirms scheduled orders once their time comes.
+// The confirmation SMS goes out then rather than when the order was placed.
+// Only the replica holding the leader lock does the work.
+type ScheduledOrderReleaser interface {
+	// ReleaseDue confirms every scheduled order that is due and returns how many it confirmed
+	ReleaseDue(ctx context.Context) (int, error)
+	Run(ctx context.Context)
+}
+
+type scheduledOrderReleaser struct {
+	repo     repositories.OrderRepository
+	orders   OrderService
+	lock     repositories.LeaderLock
+	interval time.Duration
+	now      func() time.Time
+}
+
+// NewScheduledOrderReleaser reads SCHEDULED_ORDER_INTERVAL (a Go duration,
+// default 1m) from the environment
+func NewScheduledOrderReleaser(repo repositories.OrderRepository, orders OrderService, lock repositories.LeaderLock) (ScheduledOrderReleaser, error) {
+	interval, err := positiveDurationEnv("SCHEDULED_ORDER_INTERVAL", defaultScheduledOrderInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	return &scheduledOrderReleaser{
+		repo:     repo,
+		orders:   orders,
+		lock:     lock,
+		interval: interval,
+		now:      time.Now,
+	}, nil
+}
+
+// ReleaseDue moves due orders from scheduled to confirmed
+func (s *scheduledOrderReleaser) ReleaseDue(ctx context.Context) (int, error) {
+	released := 0
+	for {
+		listCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		ids, err := s.repo.ListDueScheduled(listCtx, s.now(), scheduledOrderBatchSize)
+		cancel()
+		if err != nil {
+			return released, err
+		}
+
+		moved := 0
+		for _, id := range ids {
+			ok, err := s.release(ctx, id)
+			if err != nil {
+				log.Printf("⚠️ Scheduled order %d was not released: %v", id, err)
+				continue
+			}
+			if ok {
+				moved++
+			}
+		}
+		released += moved
+
+		// Stop when the batch was the last one, or nothing in it could be
+		// released so listing again would return the same orders
+		if len(ids) < scheduledOrderBatchSize || moved == 0 {
+			return released, nil
+		}
+	}
+}
+
+// release confirms one listed order and reports whether it did. An order
+// rescheduled since it was listed is left alone; one cancelled since fails
+// its transition.
+func (s *scheduledOrderReleaser) release(ctx context.Context, id int64) (bool, error) {
+	getCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	order, err := s.repo.GetByID(getCtx, id)
+	cancel()
+	if err != nil {
+		return false, err
+	}
+	if order.Status == models.OrderStatusScheduled && order.ScheduledFor != nil && order.ScheduledFor.After(s.now()) {
+		return false, nil
+	}
+
+	if _, err := s.orders.TransitionOrder(ctx, id, models.OrderStatusConfirmed, scheduledOrderActor, "scheduled time reached"); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Run releases due orders immediately and then on every interval until ctx
+// is cancelled, doing the work only while it holds the leader lock
+func (s *scheduledOrderReleaser) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		leader, err := s.lock.Acquire(ctx)
+		if err != nil {
+			log.Printf("⚠️ Scheduled order leader election failed: %v", err)
+		}
+
+		if leader {
+			released, err := s.ReleaseDue(ctx)
+			if err != nil {
+				log.Printf("⚠️ Releasing scheduled orders failed: %v", err)
+			} else if released > 0 {
+				log.Printf("⏰ Released %d scheduled order(s)", released)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			if err := s.lock.Release(context.WithoutCancel(ctx)); err != nil {
+				log.Printf("⚠️ %v", err)
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
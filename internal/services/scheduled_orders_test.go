@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/chesireabel/Technical-Interview/internal/repositories"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCreateOrder_Scheduled(t *testing.T) {
+	mockOrderRepo := new(MockOrderRepo)
+	mockCustomerRepo := new(MockCustomerRepo)
+	service := NewOrderService(mockOrderRepo, mockCustomerRepo, nil, &FakeTxManager{Repos: repositories.Repositories{Orders: mockOrderRepo, TaxRates: noTaxRates()}})
+
+	scheduledFor := time.Now().Add(48 * time.Hour)
+	order := &models.Order{CustomerID: "1", Item: "Office water", Amount: decimal.NewFromInt(900), ScheduledFor: &scheduledFor}
+
+	mockCustomerRepo.On("GetByID", mock.Anything, int64(1)).Return(&models.Customer{ID: 1}, nil)
+	mockOrderRepo.On("Create", mock.Anything, order).Return(int64(8), nil)
+
+	_, err := service.CreateOrder(context.Background(), order)
+
+	assert.NoError(t, err)
+	assert.Equal(t, models.OrderStatusScheduled, order.Status)
+
+	past := time.Now().Add(-time.Minute)
+	_, err = service.CreateOrder(context.Background(), &models.Order{CustomerID: "1", Item: "Office water", Amount: decimal.NewFromInt(900), ScheduledFor: &past})
+	assert.EqualError(t, err, "scheduled_for must be in the future")
+}
+
+func TestRescheduleOrder(t *testing.T) {
+	mockOrderRepo := new(MockOrderRepo)
+	service := NewOrderService(mockOrderRepo, nil, nil, nil)
+
+	at := time.Now().Add(time.Hour)
+	later := at.Add(24 * time.Hour)
+	mockOrderRepo.On("GetByID", mock.Anything, int64(8)).Return(&models.Order{ID: 8, Status: models.OrderStatusScheduled, ScheduledFor: &at}, nil)
+	mockOrderRepo.On("Reschedule", mock.Anything, int64(8), later).Return(nil)
+
+	order, err := service.RescheduleOrder(context.Background(), 8, later)
+
+	assert.NoError(t, err)
+	assert.Equal(t, later, *order.ScheduledFor)
+
+	mockOrderRepo.On("GetByID", mock.Anything, int64(9)).Return(&models.Order{ID: 9, Status: models.OrderStatusConfirmed}, nil)
+	_, err = service.RescheduleOrder(context.Background(), 9, later)
+	assert.ErrorIs(t, err, models.ErrNotScheduled)
+
+	_, err = service.RescheduleOrder(context.Background(), 8, time.Now().Add(-time.Hour))
+	assert.EqualError(t, err, "scheduled_for must be in the future")
+}
+
+func TestReleaseDue(t *testing.T) {
+	mockOrderRepo := new(MockOrderRepo)
+	now := time.Date(2026, 11, 2, 5, 0, 0, 0, time.UTC)
+	releaser := &scheduledOrderReleaser{
+		repo:   mockOrderRepo,
+		orders: NewOrderService(mockOrderRepo, nil, nil, &FakeTxManager{Repos: repositories.Repositories{Orders: mockOrderRepo}}),
+		lock:   &FakeLeaderLock{Leader: true},
+		now:    func() time.Time { return now },
+	}
+
+	due := now.Add(-time.Minute)
+	moved := now.Add(time.Hour)
+	mockOrderRepo.On("ListDueScheduled", mock.Anything, now, scheduledOrderBatchSize).Return([]int64{3, 4}, nil)
+	mockOrderRepo.On("GetByID", mock.Anything, int64(3)).Return(&models.Order{ID: 3, Status: models.OrderStatusScheduled, ScheduledFor: &due}, nil)
+	// Order 4 was rescheduled after it was listed
+	mockOrderRepo.On("GetByID", mock.Anything, int64(4)).Return(&models.Order{ID: 4, Status: models.OrderStatusScheduled, ScheduledFor: &moved}, nil)
+	mockOrderRepo.On("Transition", mock.Anything, mock.MatchedBy(func(c *models.OrderStatusChange) bool {
+		return c.OrderID == 3 && c.FromStatus == models.OrderStatusScheduled && c.ToStatus == models.OrderStatusConfirmed && c.ChangedBy == scheduledOrderActor
+	})).Return(nil)
+
+	released, err := releaser.ReleaseDue(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, released)
+	mockOrderRepo.AssertNumberOfCalls(t, "Transition", 1)
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/chesireabel/Technical-Interview/internal/repositories"
+)
+
+const defaultSegmentRefreshInterval = 15 * time.Minute
+
+// SegmentRefresher keeps every segment's members up to date as customers
+// and their orders change. Only the replica holding the leader lock does the work.
+type SegmentRefresher interface {
+	// RefreshAll refreshes every segment and returns how many it refreshed
+	RefreshAll(ctx context.Context) (int, error)
+	Run(ctx context.Context)
+}
+
+type segmentRefresher struct {
+	repo     repositories.SegmentRepository
+	lock     repositories.LeaderLock
+	interval time.Duration
+}
+
+// NewSegmentRefresher reads SEGMENT_REFRESH_INTERVAL (a Go duration,
+// default 15m) from the environment
+func NewSegmentRefresher(repo repositories.SegmentRepository, lock repositories.LeaderLock) (SegmentRefresher, error) {
+	interval, err := positiveDurationEnv("SEGMENT_REFRESH_INTERVAL", defaultSegmentRefreshInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	return &segmentRefresher{repo: repo, lock: lock, interval: interval}, nil
+}
+
+// RefreshAll refreshes each segment on its own, so one failing segment does
+// not hold back the rest
+func (s *segmentRefresher) RefreshAll(ctx context.Context) (int, error) {
+	listCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	list, err := s.repo.List(listCtx)
+	cancel()
+	if err != nil {
+		return 0, err
+	}
+
+	refreshed := 0
+	for i := range list {
+		refreshCtx, cancel := context.WithTimeout(ctx, segmentRefreshTimeout)
+		err := refreshSegment(refreshCtx, s.repo, &list[i])
+		cancel()
+		if err != nil {
+			log.Printf("⚠️ Segment %d was not refreshed: %v", list[i].ID, err)
+			continue
+		}
+		refreshed++
+	}
+	return refreshed, nil
+}
+
+// Run refreshes segments immediately and then on every interval until ctx
+// is cancelled, doing the work only while it holds the leader lock
+func (s *segmentRefresher) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		leader, err := s.lock.Acquire(ctx)
+		if err != nil {
+			log.Printf("⚠️ Segment refresh leader election failed: %v", err)
+		}
+
+		if leader {
+			if _, err := s.RefreshAll(ctx); err != nil {
+				log.Printf("⚠️ Refreshing segments failed: %v", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			if err := s.lock.Release(context.WithoutCancel(ctx)); err != nil {
+				log.Printf("⚠️ %v", err)
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/chesireabel/Technical-Interview/internal/repositories"
+	"github.com/chesireabel/Technical-Interview/internal/segments"
+)
+
+const (
+	maxSegmentNameLength = 100
+	// segmentRefreshTimeout allows for rules that aggregate over every customer's orders
+	segmentRefreshTimeout = 30 * time.Second
+)
+
+// SegmentService manages saved customer segments, e.g. customers in Nairobi
+// who ordered more than 5000 KES in the last 30 days. See package segments
+// for the rule syntax. Members are materialised when a segment is saved and
+// then by the SegmentRefresher.
+type SegmentService interface {
+	CreateSegment(ctx context.Context, segment *models.Segment) (int64, error)
+	GetSegment(ctx context.Context, id int64) (*models.Segment, error)
+	ListSegments(ctx context.Context) ([]models.Segment, error)
+	UpdateSegment(ctx context.Context, segment *models.Segment) error
+	DeleteSegment(ctx context.Context, id int64) error
+	// RefreshSegment recomputes the segment's members now
+	RefreshSegment(ctx context.Context, id int64) (*models.Segment, error)
+	ListMembers(ctx context.Context, id int64) ([]models.Customer, error)
+}
+
+type segmentService struct {
+	repo repositories.SegmentRepository
+}
+
+func NewSegmentService(repo repositories.SegmentRepository) SegmentService {
+	return &segmentService{repo: repo}
+}
+
+func validateSegment(segment *models.Segment) (*segments.Rule, error) {
+	segment.Name = strings.TrimSpace(segment.Name)
+	segment.Description = strings.TrimSpace(segment.Description)
+	if segment.Name == "" {
+		return nil, errors.New("name is required")
+	}
+	if len([]rune(segment.Name)) > maxSegmentNameLength {
+		return nil, fmt.Errorf("name must be at most %d characters", maxSegmentNameLength)
+	}
+
+	rule, err := segments.Parse(segment.Rule)
+	if err != nil {
+		return nil, err
+	}
+	segment.Rule = rule.String()
+	return rule, nil
+}
+
+func (s *segmentService) CreateSegment(ctx context.Context, segment *models.Segment) (int64, error) {
+	rule, err := validateSegment(segment)
+	if err != nil {
+		return 0, err
+	}
+
+	createCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	id, err := s.repo.Create(createCtx, segment)
+	if err != nil {
+		return 0, err
+	}
+
+	s.refreshSaved(ctx, segment, rule)
+	return id, nil
+}
+
+func (s *segmentService) GetSegment(ctx context.Context, id int64) (*models.Segment, error) {
+	if id == 0 {
+		return nil, errors.New("id is required")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return s.repo.GetByID(ctx, id)
+}
+
+func (s *segmentService) ListSegments(ctx context.Context) ([]models.Segment, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	return s.repo.List(ctx)
+}
+
+func (s *segmentService) UpdateSegment(ctx context.Context, segment *models.Segment) error {
+	if segment.ID == 0 {
+		return errors.New("id is required for update")
+	}
+	rule, err := validateSegment(segment)
+	if err != nil {
+		return err
+	}
+
+	updateCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if err := s.repo.Update(updateCtx, segment); err != nil {
+		return err
+	}
+
+	s.refreshSaved(ctx, segment, rule)
+	return nil
+}
+
+// refreshSaved materialises a segment that was just saved so its members
+// match its rule straight away. A failure is only logged: the segment is
+// saved and the refresher will fill it in.
+func (s *segmentService) refreshSaved(ctx context.Context, segment *models.Segment, rule *segments.Rule) {
+	ctx, cancel := context.WithTimeout(ctx, segmentRefreshTimeout)
+	defer cancel()
+
+	count, err := s.repo.Refresh(ctx, segment.ID, rule)
+	if err != nil {
+		log.Printf("⚠️ Segment %d was saved but not refreshed: %v", segment.ID, err)
+		return
+	}
+
+	now := time.Now()
+	segment.MemberCount = count
+	segment.RefreshedAt = &now
+}
+
+func (s *segmentService) DeleteSegment(ctx context.Context, id int64) error {
+	if id == 0 {
+		return errors.New("id is required for delete")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return s.repo.Delete(ctx, id)
+}
+
+func (s *segmentService) RefreshSegment(ctx context.Context, id int64) (*models.Segment, error) {
+	if id == 0 {
+		return nil, errors.New("id is required")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, segmentRefreshTimeout)
+	defer cancel()
+
+	segment, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := refreshSegment(ctx, s.repo, segment); err != nil {
+		return nil, err
+	}
+	return segment, nil
+}
+
+// refreshSegment recomputes a stored segment's members from its rule
+func refreshSegment(ctx context.Context, repo repositories.SegmentRepository, segment *models.Segment) error {
+	rule, err := segments.Parse(segment.Rule)
+	if err != nil {
+		return err
+	}
+
+	count, err := repo.Refresh(ctx, segment.ID, rule)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	segment.MemberCount = count
+	segment.RefreshedAt = &now
+	return nil
+}
+
+func (s *segmentService) ListMembers(ctx context.Context, id int64) ([]models.Customer, error) {
+	if id == 0 {
+		return nil, errors.New("id is required")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	if _, err := s.repo.GetByID(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.repo.ListMembers(ctx, id)
+}
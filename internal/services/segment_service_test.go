@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/chesireabel/Technical-Interview/internal/segments"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func ruleFor(source string) interface{} {
+	return mock.MatchedBy(func(rule *segments.Rule) bool { return rule.String() == source })
+}
+
+func TestCreateSegment_RefreshesMembers(t *testing.T) {
+	mockSegmentRepo := new(MockSegmentRepo)
+	service := NewSegmentService(mockSegmentRepo)
+
+	segment := &models.Segment{
+		Name: " Nairobi big spenders ",
+		Rule: `  town = "Nairobi" AND order_total(30d) > 5000 `,
+	}
+
+	mockSegmentRepo.On("Create", mock.Anything, segment).Return(int64(3), nil).Run(func(args mock.Arguments) {
+		args.Get(1).(*models.Segment).ID = 3
+	})
+	mockSegmentRepo.On("Refresh", mock.Anything, int64(3), ruleFor(`town = "Nairobi" AND order_total(30d) > 5000`)).Return(12, nil)
+
+	id, err := service.CreateSegment(context.Background(), segment)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), id)
+	assert.Equal(t, "Nairobi big spenders", segment.Name)
+	assert.Equal(t, `town = "Nairobi" AND order_total(30d) > 5000`, segment.Rule)
+	assert.Equal(t, 12, segment.MemberCount)
+	assert.NotNil(t, segment.RefreshedAt)
+	mockSegmentRepo.AssertExpectations(t)
+}
+
+func TestCreateSegment_RefreshFailureStillSaves(t *testing.T) {
+	mockSegmentRepo := new(MockSegmentRepo)
+	service := NewSegmentService(mockSegmentRepo)
+
+	segment := &models.Segment{Name: "VIPs", Rule: `tag = "vip"`}
+
+	mockSegmentRepo.On("Create", mock.Anything, segment).Return(int64(4), nil).Run(func(args mock.Arguments) {
+		args.Get(1).(*models.Segment).ID = 4
+	})
+	mockSegmentRepo.On("Refresh", mock.Anything, int64(4), mock.Anything).Return(0, errors.New("statement timeout"))
+
+	id, err := service.CreateSegment(context.Background(), segment)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), id)
+	assert.Nil(t, segment.RefreshedAt)
+}
+
+func TestCreateSegment_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		segment models.Segment
+		wantErr string
+	}{
+		{"missing name", models.Segment{Rule: `tag = "vip"`}, "name is required"},
+		{"missing rule", models.Segment{Name: "VIPs"}, "invalid segment rule: rule is required"},
+		{"bad rule", models.Segment{Name: "VIPs", Rule: `spend > 5000`}, `invalid segment rule at position 1: unknown field "spend"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSegmentRepo := new(MockSegmentRepo)
+			service := NewSegmentService(mockSegmentRepo)
+
+			_, err := service.CreateSegment(context.Background(), &tt.segment)
+
+			assert.EqualError(t, err, tt.wantErr)
+			mockSegmentRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestListMembers_UnknownSegment(t *testing.T) {
+	mockSegmentRepo := new(MockSegmentRepo)
+	service := NewSegmentService(mockSegmentRepo)
+
+	mockSegmentRepo.On("GetByID", mock.Anything, int64(9)).Return(nil, fmt.Errorf("segment with id 9: %w", models.ErrNotFound))
+
+	_, err := service.ListMembers(context.Background(), 9)
+
+	assert.ErrorIs(t, err, models.ErrNotFound)
+	mockSegmentRepo.AssertNotCalled(t, "ListMembers", mock.Anything, mock.Anything)
+}
+
+func TestRefreshAll_ContinuesPastFailures(t *testing.T) {
+	mockSegmentRepo := new(MockSegmentRepo)
+	refresher := &segmentRefresher{repo: mockSegmentRepo, lock: &FakeLeaderLock{Leader: true}, interval: time.Minute}
+
+	mockSegmentRepo.On("List", mock.Anything).Return([]models.Segment{
+		{ID: 1, Rule: `tag = "vip"`},
+		{ID: 2, Rule: `order_count(7d) >= 1`},
+		{ID: 3, Rule: `county = "Kisumu"`},
+	}, nil)
+	mockSegmentRepo.On("Refresh", mock.Anything, int64(1), ruleFor(`tag = "vip"`)).Return(5, nil)
+	mockSegmentRepo.On("Refresh", mock.Anything, int64(2), mock.Anything).Return(0, errors.New("connection reset"))
+	mockSegmentRepo.On("Refresh", mock.Anything, int64(3), ruleFor(`county = "Kisumu"`)).Return(0, nil)
+
+	refreshed, err := refresher.RefreshAll(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, refreshed)
+	mockSegmentRepo.AssertExpectations(t)
+}
+
+func TestSegmentRefresher_RunOnlyRefreshesAsLeader(t *testing.T) {
+	mockSegmentRepo := new(MockSegmentRepo)
+	lock := &FakeLeaderLock{Leader: false}
+	refresher := &segmentRefresher{repo: mockSegmentRepo, lock: lock, interval: time.Hour}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	refresher.Run(ctx)
+
+	mockSegmentRepo.AssertNotCalled(t, "List", mock.Anything)
+	assert.True(t, lock.Released)
+}
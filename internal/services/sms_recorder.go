@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/chesireabel/Technical-Interview/internal/repositories"
+)
+
+// smsRecorder records each SMS its inner service sends so it appears on the
+// customer's timeline. Only messages that were actually sent are recorded.
+type smsRecorder struct {
+	inner SMSService
+	repo  repositories.SMSMessageRepository
+}
+
+// NewRecordingSMSService wraps inner so every SMS it sends is recorded
+func NewRecordingSMSService(inner SMSService, repo repositories.SMSMessageRepository) SMSService {
+	return &smsRecorder{inner: inner, repo: repo}
+}
+
+func (s *smsRecorder) SendOrderConfirmation(ctx context.Context, order *models.Order, customer *models.Customer) error {
+	if err := s.inner.SendOrderConfirmation(ctx, order, customer); err != nil {
+		return err
+	}
+	s.record(ctx, order, models.SMSKindOrderConfirmation, customer.Phone, orderConfirmationText(order, customer))
+	return nil
+}
+
+func (s *smsRecorder) SendOrderUpdate(ctx context.Context, order *models.Order, phoneNumber, status string) error {
+	if err := s.inner.SendOrderUpdate(ctx, order, phoneNumber, status); err != nil {
+		return err
+	}
+	s.record(ctx, order, models.SMSKindOrderUpdate, phoneNumber, orderUpdateText(order, status))
+	return nil
+}
+
+func (s *smsRecorder) SendRefundNotice(ctx context.Context, order *models.Order, refund *models.Refund, phoneNumber string) error {
+	if err := s.inner.SendRefundNotice(ctx, order, refund, phoneNumber); err != nil {
+		return err
+	}
+	s.record(ctx, order, models.SMSKindRefundNotice, phoneNumber, refundNoticeText(order, refund))
+	return nil
+}
+
+// record saves a sent SMS. A failure is only logged: the message has gone
+// out, and returning an error would make the outbox send it again.
+func (s *smsRecorder) record(ctx context.Context, order *models.Order, kind models.SMSKind, phone, body string) {
+	customerID, err := strconv.ParseInt(order.CustomerID, 10, 64)
+	if err != nil {
+		log.Printf("⚠️ SMS for order %d was sent but not recorded: invalid customer_id %q", order.ID, order.CustomerID)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	orderID := order.ID
+	message := &models.SMSMessage{
+		CustomerID: customerID,
+		OrderID:    &orderID,
+		Kind:       kind,
+		Phone:      phone,
+		Body:       body,
+	}
+	if err := s.repo.Create(ctx, message); err != nil {
+		log.Printf("⚠️ SMS for order %d was sent but not recorded: %v", order.ID, err)
+	}
+}
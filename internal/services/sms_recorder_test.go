@@ -0,0 +1,64 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRecordingSMSService_RecordsSentMessages(t *testing.T) {
+	mockSMS := new(MockSMSService)
+	mockMessageRepo := new(MockSMSMessageRepo)
+	service := NewRecordingSMSService(mockSMS, mockMessageRepo)
+
+	order := &models.Order{ID: 12, CustomerID: "4", Item: "Book", Amount: decimal.NewFromInt(500), Currency: "KES"}
+
+	mockSMS.On("SendOrderUpdate", mock.Anything, order, "+254700000000", "shipped").Return(nil)
+	mockMessageRepo.On("Create", mock.Anything, mock.MatchedBy(func(m *models.SMSMessage) bool {
+		return m.CustomerID == 4 && *m.OrderID == 12 && m.Kind == models.SMSKindOrderUpdate &&
+			m.Phone == "+254700000000" && m.Body == orderUpdateText(order, "shipped")
+	})).Return(nil)
+
+	err := service.SendOrderUpdate(context.Background(), order, "+254700000000", "shipped")
+
+	assert.NoError(t, err)
+	mockMessageRepo.AssertExpectations(t)
+}
+
+func TestRecordingSMSService_SkipsFailedSends(t *testing.T) {
+	mockSMS := new(MockSMSService)
+	mockMessageRepo := new(MockSMSMessageRepo)
+	service := NewRecordingSMSService(mockSMS, mockMessageRepo)
+
+	order := &models.Order{ID: 12, CustomerID: "4"}
+	customer := &models.Customer{Phone: "+254700000000"}
+
+	mockSMS.On("SendOrderConfirmation", mock.Anything, order, customer).Return(errors.New("SMS API error"))
+
+	err := service.SendOrderConfirmation(context.Background(), order, customer)
+
+	assert.EqualError(t, err, "SMS API error")
+	mockMessageRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestRecordingSMSService_RecordFailureIsNotAnError(t *testing.T) {
+	mockSMS := new(MockSMSService)
+	mockMessageRepo := new(MockSMSMessageRepo)
+	service := NewRecordingSMSService(mockSMS, mockMessageRepo)
+
+	order := &models.Order{ID: 12, CustomerID: "4", Amount: decimal.NewFromInt(500)}
+	refund := &models.Refund{Amount: decimal.NewFromInt(200), Reason: "damaged", Remaining: decimal.NewFromInt(300)}
+
+	mockSMS.On("SendRefundNotice", mock.Anything, order, refund, "+254700000000").Return(nil)
+	mockMessageRepo.On("Create", mock.Anything, mock.Anything).Return(errors.New("connection reset"))
+
+	err := service.SendRefundNotice(context.Background(), order, refund, "+254700000000")
+
+	assert.NoError(t, err)
+	mockMessageRepo.AssertExpectations(t)
+}
@@ -23,6 +23,7 @@ type HTTPClient interface {
 type SMSService interface {
 	SendOrderConfirmation(ctx context.Context, order *models.Order, customer *models.Customer) error
 	SendOrderUpdate(ctx context.Context, order *models.Order, phoneNumber, status string) error
+	SendRefundNotice(ctx context.Context, order *models.Order, refund *models.Refund, phoneNumber string) error
 }
 
 type smsService struct {
@@ -127,26 +128,94 @@ func (s *smsService) sendSMS(phoneNumber, message string) error {
 	return nil
 }
 
-func (s *smsService) SendOrderConfirmation(ctx context.Context, order *models.Order, customer *models.Customer) error {
-	message := fmt.Sprintf(
-		"Hello %s! Order #%d confirmed. Item: %s, Amount: KES %.2f. Thank you for your order!",
+// smsMaxListedItems keeps confirmations with many lines within a couple of SMS segments
+const smsMaxListedItems = 3
+
+// describeItems lists the order lines as "2x Shoes, 1x Socks", falling back to
+// the item summary for orders loaded without their lines
+func describeItems(order *models.Order) string {
+	if len(order.Items) == 0 {
+		return order.Item
+	}
+
+	parts := make([]string, 0, smsMaxListedItems+1)
+	for i, item := range order.Items {
+		if i == smsMaxListedItems {
+			parts = append(parts, fmt.Sprintf("+%d more", len(order.Items)-smsMaxListedItems))
+			break
+		}
+		parts = append(parts, fmt.Sprintf("%dx %s", item.Quantity, item.Description))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// orderConfirmationText is the SMS acknowledging a placed order. The order is
+// only pending at that point, so it says received; confirmation follows as an
+// order update. Scheduled orders only get it once they have been confirmed,
+// so for them it says confirmed.
+func orderConfirmationText(order *models.Order, customer *models.Customer) string {
+	state := "received"
+	if order.ScheduledFor != nil {
+		state = "confirmed"
+	}
+
+	label := "Item"
+	if len(order.Items) > 1 {
+		label = "Items"
+	}
+
+	total := models.FormatMoney(order.Amount, order.Currency)
+	if order.PromoCode != "" && order.Discount.IsPositive() {
+		total = fmt.Sprintf("%s (you saved %s with %s)", total, models.FormatMoney(order.Discount, order.Currency), order.PromoCode)
+	}
+
+	return fmt.Sprintf(
+		"Hello %s! Order #%d %s. %s: %s, Total: %s. Thank you for your order!",
 		customer.Customer_name,
 		order.ID,
-		order.Item,
-		order.Amount,
+		state,
+		label,
+		describeItems(order),
+		total,
 	)
+}
 
-	return s.sendSMS(customer.Phone, message)
+func (s *smsService) SendOrderConfirmation(ctx context.Context, order *models.Order, customer *models.Customer) error {
+	return s.sendSMS(customer.Phone, orderConfirmationText(order, customer))
 }
 
-func (s *smsService) SendOrderUpdate(ctx context.Context, order *models.Order, phoneNumber, status string) error {
-	message := fmt.Sprintf(
-		"Order Update: Your order #%d (%s) is now %s. Amount: KES %.2f",
+// orderUpdateText is the SMS sent when an order moves to a status customers see
+func orderUpdateText(order *models.Order, status string) string {
+	return fmt.Sprintf(
+		"Order Update: Your order #%d (%s) is now %s. Amount: %s",
 		order.ID,
 		order.Item,
 		status,
-		order.Amount,
+		models.FormatMoney(order.Amount, order.Currency),
 	)
+}
 
-	return s.sendSMS(phoneNumber, message)
-}
\ No newline at end of file
+func (s *smsService) SendOrderUpdate(ctx context.Context, order *models.Order, phoneNumber, status string) error {
+	return s.sendSMS(phoneNumber, orderUpdateText(order, status))
+}
+
+// refundNoticeText is the SMS sent when an order is refunded
+func refundNoticeText(order *models.Order, refund *models.Refund) string {
+	kind := "A refund"
+	if refund.Full() {
+		kind = "A full refund"
+	}
+
+	return fmt.Sprintf(
+		"%s of %s for your order #%d (%s) has been issued. Reason: %s",
+		kind,
+		models.FormatMoney(refund.Amount, refund.Currency),
+		order.ID,
+		order.Item,
+		refund.Reason,
+	)
+}
+
+func (s *smsService) SendRefundNotice(ctx context.Context, order *models.Order, refund *models.Refund, phoneNumber string) error {
+	return s.sendSMS(phoneNumber, refundNoticeText(order, refund))
+}
@@ -5,9 +5,12 @@ import (
 	"context"
 	"io"
 	"net/http"
+	"net/url"
 	"testing"
+	"time"
 
 	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -49,7 +52,7 @@ func TestSendOrderConfirmation(t *testing.T) {
 		httpClient: mockClient,
 	}
 
-	order := &models.Order{ID: 1, Item: "Book", Amount: 500}
+	order := &models.Order{ID: 1, Item: "Book", Amount: decimal.NewFromInt(500)}
 	customer := &models.Customer{Customer_name: "Jane", Phone: "+254700000000"}
 
 	err := svc.SendOrderConfirmation(context.Background(), order, customer)
@@ -86,10 +89,141 @@ func TestSendOrderUpdate(t *testing.T) {
 		httpClient: mockClient,
 	}
 
-	order := &models.Order{ID: 1, Item: "Book", Amount: 500}
+	order := &models.Order{ID: 1, Item: "Book", Amount: decimal.NewFromInt(500)}
 	phone := "+254700000000"
 	status := "Delivered"
 
 	err := svc.SendOrderUpdate(context.Background(), order, phone, status)
 	assert.NoError(t, err)
 }
+
+func TestSendOrderConfirmation_MultipleItems(t *testing.T) {
+	mockResp := `{"SMSMessageData": {"Message": "Sent", "Recipients": [{"statusCode": 101, "status": "Success"}]}}`
+
+	var sent string
+	mockClient := &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			body, _ := io.ReadAll(req.Body)
+			form, _ := url.ParseQuery(string(body))
+			sent = form.Get("message")
+			return &http.Response{
+				StatusCode: http.StatusCreated,
+				Body:       io.NopCloser(bytes.NewBufferString(mockResp)),
+			}, nil
+		},
+	}
+
+	svc := &smsService{
+		username:   "testuser",
+		apiKey:     "testkey",
+		baseURL:    "https://mockapi.test",
+		httpClient: mockClient,
+	}
+
+	order := &models.Order{ID: 9, Amount: decimal.NewFromInt(4100), Items: []models.OrderItem{
+		{Description: "Shoes", Quantity: 2},
+		{Description: "Socks", Quantity: 3},
+		{Description: "Laces", Quantity: 1},
+		{Description: "Polish", Quantity: 1},
+		{Description: "Brush", Quantity: 1},
+	}}
+	customer := &models.Customer{Customer_name: "Jane", Phone: "+254700000000"}
+
+	err := svc.SendOrderConfirmation(context.Background(), order, customer)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello Jane! Order #9 received. Items: 2x Shoes, 3x Socks, 1x Laces, +2 more, Total: KES 4,100.00. Thank you for your order!", sent)
+}
+
+func TestSendOrderConfirmation_MentionsDiscount(t *testing.T) {
+	var sent string
+	mockClient := &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			body, _ := io.ReadAll(req.Body)
+			form, _ := url.ParseQuery(string(body))
+			sent = form.Get("message")
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewBufferString(`{"SMSMessageData": {"Recipients": []}}`)),
+			}, nil
+		},
+	}
+
+	svc := &smsService{username: "testuser", apiKey: "testkey", baseURL: "https://mockapi.test", httpClient: mockClient}
+	order := &models.Order{
+		ID:        11,
+		Item:      "Kikoi",
+		Subtotal:  decimal.NewFromInt(1500),
+		Discount:  decimal.NewFromInt(150),
+		Amount:    decimal.NewFromInt(1350),
+		Currency:  "KES",
+		PromoCode: "JAMHURI",
+	}
+	customer := &models.Customer{Customer_name: "Jane", Phone: "+254700000000"}
+
+	err := svc.SendOrderConfirmation(context.Background(), order, customer)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello Jane! Order #11 received. Item: Kikoi, Total: KES 1,350.00 (you saved KES 150.00 with JAMHURI). Thank you for your order!", sent)
+
+	// A scheduled order is only confirmed by the time it is sent
+	at := time.Date(2026, 11, 2, 5, 0, 0, 0, time.UTC)
+	order.ScheduledFor = &at
+	order.Status = models.OrderStatusConfirmed
+	err = svc.SendOrderConfirmation(context.Background(), order, customer)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello Jane! Order #11 confirmed. Item: Kikoi, Total: KES 1,350.00 (you saved KES 150.00 with JAMHURI). Thank you for your order!", sent)
+}
+
+func TestSendOrderUpdate_FormatsCurrency(t *testing.T) {
+	var sent string
+	mockClient := &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			body, _ := io.ReadAll(req.Body)
+			form, _ := url.ParseQuery(string(body))
+			sent = form.Get("message")
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewBufferString(`{"SMSMessageData": {"Recipients": []}}`)),
+			}, nil
+		},
+	}
+
+	svc := &smsService{username: "testuser", apiKey: "testkey", baseURL: "https://mockapi.test", httpClient: mockClient}
+	order := &models.Order{ID: 4, Item: "Sugar", Amount: decimal.NewFromInt(1250000), Currency: "UGX"}
+
+	err := svc.SendOrderUpdate(context.Background(), order, "+256772123456", "shipped")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Order Update: Your order #4 (Sugar) is now shipped. Amount: UGX 1,250,000", sent)
+}
+
+func TestSendRefundNotice(t *testing.T) {
+	var sent []string
+	mockClient := &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			body, _ := io.ReadAll(req.Body)
+			form, _ := url.ParseQuery(string(body))
+			sent = append(sent, form.Get("message"))
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewBufferString(`{"SMSMessageData": {"Recipients": []}}`)),
+			}, nil
+		},
+	}
+
+	svc := &smsService{username: "testuser", apiKey: "testkey", baseURL: "https://mockapi.test", httpClient: mockClient}
+	order := &models.Order{ID: 8, Item: "Kikoi", Amount: decimal.NewFromInt(1500), Currency: "KES"}
+
+	partial := &models.Refund{Amount: decimal.NewFromInt(500), Currency: "KES", Reason: "damaged", Remaining: decimal.NewFromInt(1000)}
+	full := &models.Refund{Amount: decimal.NewFromInt(1000), Currency: "KES", Reason: "cancelled"}
+
+	assert.NoError(t, svc.SendRefundNotice(context.Background(), order, partial, "+254700000000"))
+	assert.NoError(t, svc.SendRefundNotice(context.Background(), order, full, "+254700000000"))
+
+	assert.Equal(t, []string{
+		"A refund of KES 500.00 for your order #8 (Kikoi) has been issued. Reason: damaged",
+		"A full refund of KES 1,000.00 for your order #8 (Kikoi) has been issued. Reason: cancelled",
+	}, sent)
+}
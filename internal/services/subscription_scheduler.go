@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/chesireabel/Technical-Interview/internal/repositories"
+)
+
+const (
+	defaultSubscriptionInterval = time.Minute
+	// subscriptionBatchSize bounds how many subscriptions are claimed in one transaction
+	subscriptionBatchSize = 50
+)
+
+// SubscriptionScheduler places the orders of subscriptions that are due.
+// Every replica runs it but only the one holding the leader lock does any
+// work; if the leader dies its lock is freed and another replica takes over.
+type SubscriptionScheduler interface {
+	// RunDue places an order for every subscription that is due and returns
+	// how many runs were started
+	RunDue(ctx context.Context) (int, error)
+	Run(ctx context.Context)
+}
+
+type subscriptionScheduler struct {
+	repo     repositories.SubscriptionRepository
+	orders   OrderService
+	tx       repositories.TxManager
+	lock     repositories.LeaderLock
+	interval time.Duration
+	now      func() time.Time
+}
+
+// NewSubscriptionScheduler reads SUBSCRIPTION_SCHEDULER_INTERVAL (a Go
+// duration, default 1m) from the environment
+func NewSubscriptionScheduler(repo repositories.SubscriptionRepository, orders OrderService, tx repositories.TxManager, lock repositories.LeaderLock) (SubscriptionScheduler, error) {
+	interval, err := positiveDurationEnv("SUBSCRIPTION_SCHEDULER_INTERVAL", defaultSubscriptionInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	return &subscriptionScheduler{
+		repo:     repo,
+		orders:   orders,
+		tx:       tx,
+		lock:     lock,
+		interval: interval,
+		now:      time.Now,
+	}, nil
+}
+
+type claimedRun struct {
+	subscription models.Subscription
+	run          *models.SubscriptionRun
+}
+
+// RunDue claims due subscriptions and moves their next run on before placing
+// any order, so a crash part way through skips a run rather than placing it
+// twice. A subscription that was due several times while nothing ran gets a
+// single order and is then scheduled from now.
+func (s *subscriptionScheduler) RunDue(ctx context.Context) (int, error) {
+	started := 0
+	for {
+		claimed, err := s.claimDue(ctx)
+		if err != nil {
+			return started, err
+		}
+
+		for _, c := range claimed {
+			s.place(ctx, &c.subscription, c.run)
+		}
+		started += len(claimed)
+
+		if len(claimed) < subscriptionBatchSize {
+			return started, nil
+		}
+	}
+}
+
+func (s *subscriptionScheduler) claimDue(ctx context.Context) ([]claimedRun, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	now := s.now()
+	var claimed []claimedRun
+	err := s.tx.WithinTx(ctx, func(ctx context.Context, repos repositories.Repositories) error {
+		claimed = nil
+		due, err := repos.Subscriptions.LockDue(ctx, now, subscriptionBatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, subscription := range due {
+			next, err := nextRun(&subscription, now)
+			if err != nil {
+				// Only possible if the stored schedule was edited by hand
+				log.Printf("⚠️ Pausing subscription %d: %v", subscription.ID, err)
+				if err := repos.Subscriptions.SetStatus(ctx, subscription.ID, models.SubscriptionPaused, nil); err != nil {
+					return err
+				}
+				continue
+			}
+
+			run, err := repos.Subscriptions.StartRun(ctx, &subscription, next)
+			if err != nil {
+				return err
+			}
+			claimed = append(claimed, claimedRun{subscription: subscription, run: run})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return claimed, nil
+}
+
+// place creates the run's order through the order service, so it gets the
+// same pricing, tax, stock and notifications as any other order
+func (s *subscriptionScheduler) place(ctx context.Context, subscription *models.Subscription, run *models.SubscriptionRun) {
+	orderID, err := s.orders.CreateOrder(ctx, subscription.Order())
+	if err != nil {
+		log.Printf("⚠️ Subscription %d run for %s failed: %v", subscription.ID, run.ScheduledFor.Format(time.RFC3339), err)
+		run.Error = err.Error()
+	} else {
+		run.OrderID = &orderID
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if err := s.repo.FinishRun(ctx, run); err != nil {
+		log.Printf("⚠️ Failed to record subscription %d run %d: %v", subscription.ID, run.ID, err)
+	}
+}
+
+// Run checks for due subscriptions immediately and then on every interval
+// until ctx is cancelled, doing the work only while it holds the leader lock
+func (s *subscriptionScheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	leading := false
+	for {
+		leader, err := s.lock.Acquire(ctx)
+		if err != nil {
+			log.Printf("⚠️ Subscription scheduler leader election failed: %v", err)
+		}
+		if leader != leading {
+			if leader {
+				log.Println("📅 Subscription scheduler is leading on this replica")
+			} else {
+				log.Println("📅 Subscription scheduler lost leadership on this replica")
+			}
+			leading = leader
+		}
+
+		if leading {
+			started, err := s.RunDue(ctx)
+			if err != nil {
+				log.Printf("⚠️ Subscription scheduler run failed: %v", err)
+			} else if started > 0 {
+				log.Printf("📅 Placed %d subscription run(s)", started)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			if err := s.lock.Release(context.WithoutCancel(ctx)); err != nil {
+				log.Printf("⚠️ %v", err)
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/chesireabel/Technical-Interview/internal/repositories"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newTestScheduler(subscriptionRepo *MockSubscriptionRepo, orderRepo *MockOrderRepo, customerRepo *MockCustomerRepo, lock repositories.LeaderLock) *subscriptionScheduler {
+	tx := &FakeTxManager{Repos: repositories.Repositories{
+		Orders:        orderRepo,
+		Subscriptions: subscriptionRepo,
+		TaxRates:      noTaxRates(),
+	}}
+	return &subscriptionScheduler{
+		repo:     subscriptionRepo,
+		orders:   NewOrderService(orderRepo, customerRepo, nil, tx),
+		tx:       tx,
+		lock:     lock,
+		interval: time.Minute,
+		now:      func() time.Time { return subscriptionNow },
+	}
+}
+
+func TestRunDue(t *testing.T) {
+	mockSubscriptionRepo := new(MockSubscriptionRepo)
+	mockOrderRepo := new(MockOrderRepo)
+	mockCustomerRepo := new(MockCustomerRepo)
+	scheduler := newTestScheduler(mockSubscriptionRepo, mockOrderRepo, mockCustomerRepo, &FakeLeaderLock{Leader: true})
+
+	// Due a week ago while nothing was running: one order, then schedule from now
+	due := subscriptionNow.Add(-7 * 24 * time.Hour)
+	subscription := models.Subscription{
+		ID:         5,
+		CustomerID: 4,
+		Currency:   "KES",
+		Schedule:   "@every 168h",
+		Timezone:   "Africa/Nairobi",
+		Status:     models.SubscriptionActive,
+		NextRunAt:  &due,
+		Items:      []models.SubscriptionItem{{Description: "Maize flour 2kg", Quantity: 20, UnitPrice: decimal.RequireFromString("185.50")}},
+	}
+	run := &models.SubscriptionRun{ID: 11, SubscriptionID: 5, ScheduledFor: due}
+
+	mockSubscriptionRepo.On("LockDue", mock.Anything, subscriptionNow, subscriptionBatchSize).Return([]models.Subscription{subscription}, nil)
+	mockSubscriptionRepo.On("StartRun", mock.Anything, mock.Anything, mock.MatchedBy(func(next time.Time) bool {
+		return next.Equal(subscriptionNow.Add(168 * time.Hour))
+	})).Return(run, nil)
+	mockCustomerRepo.On("GetByID", mock.Anything, int64(4)).Return(&models.Customer{ID: 4, Phone: "+254712345678"}, nil)
+	mockOrderRepo.On("Create", mock.Anything, mock.MatchedBy(func(o *models.Order) bool {
+		return o.CustomerID == "4" && o.Amount.Equal(decimal.NewFromInt(3710))
+	})).Return(int64(42), nil)
+	mockSubscriptionRepo.On("FinishRun", mock.Anything, run).Return(nil)
+
+	started, err := scheduler.RunDue(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, started)
+	if assert.NotNil(t, run.OrderID) {
+		assert.Equal(t, int64(42), *run.OrderID)
+	}
+	assert.Empty(t, run.Error)
+	mockSubscriptionRepo.AssertExpectations(t)
+	mockOrderRepo.AssertExpectations(t)
+}
+
+func TestRunDue_OrderFails(t *testing.T) {
+	mockSubscriptionRepo := new(MockSubscriptionRepo)
+	mockOrderRepo := new(MockOrderRepo)
+	mockCustomerRepo := new(MockCustomerRepo)
+	scheduler := newTestScheduler(mockSubscriptionRepo, mockOrderRepo, mockCustomerRepo, &FakeLeaderLock{Leader: true})
+
+	subscription := models.Subscription{
+		ID:         5,
+		CustomerID: 4,
+		Schedule:   "@daily",
+		Timezone:   "Africa/Nairobi",
+		NextRunAt:  &subscriptionNow,
+		Items:      []models.SubscriptionItem{{Description: "Sugar", Quantity: 1, UnitPrice: decimal.NewFromInt(200)}},
+	}
+	run := &models.SubscriptionRun{ID: 12, SubscriptionID: 5, ScheduledFor: subscriptionNow}
+
+	mockSubscriptionRepo.On("LockDue", mock.Anything, subscriptionNow, subscriptionBatchSize).Return([]models.Subscription{subscription}, nil)
+	mockSubscriptionRepo.On("StartRun", mock.Anything, mock.Anything, mock.AnythingOfType("time.Time")).Return(run, nil)
+	mockCustomerRepo.On("GetByID", mock.Anything, int64(4)).Return(nil, models.ErrNotFound)
+	mockSubscriptionRepo.On("FinishRun", mock.Anything, run).Return(nil)
+
+	started, err := scheduler.RunDue(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, started)
+	assert.Nil(t, run.OrderID)
+	assert.NotEmpty(t, run.Error)
+	mockOrderRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestSchedulerRun_NotLeader(t *testing.T) {
+	mockSubscriptionRepo := new(MockSubscriptionRepo)
+	lock := &FakeLeaderLock{Leader: false}
+	scheduler := newTestScheduler(mockSubscriptionRepo, new(MockOrderRepo), new(MockCustomerRepo), lock)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	scheduler.Run(ctx)
+
+	mockSubscriptionRepo.AssertNotCalled(t, "LockDue", mock.Anything, mock.Anything, mock.Anything)
+	assert.True(t, lock.Released)
+}
@@ -0,0 +1,228 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	// Subscriptions name IANA zones, which must resolve without a system zoneinfo
+	_ "time/tzdata"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/chesireabel/Technical-Interview/internal/repositories"
+	"github.com/robfig/cron/v3"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	defaultSubscriptionTimezone = "Africa/Nairobi"
+	// minSubscriptionInterval stops a typo like "* * * * *" placing an order every minute
+	minSubscriptionInterval = time.Hour
+)
+
+// SubscriptionService manages recurring orders. The orders themselves are
+// placed by the SubscriptionScheduler.
+type SubscriptionService interface {
+	CreateSubscription(ctx context.Context, subscription *models.Subscription) (int64, error)
+	GetSubscription(ctx context.Context, id int64) (*models.Subscription, error)
+	// ListSubscriptions returns every subscription, or only the customer's when customerID is not 0
+	ListSubscriptions(ctx context.Context, customerID int64) ([]models.Subscription, error)
+	PauseSubscription(ctx context.Context, id int64) (*models.Subscription, error)
+	// ResumeSubscription schedules the next run from now; runs missed while
+	// paused are not placed
+	ResumeSubscription(ctx context.Context, id int64) (*models.Subscription, error)
+	CancelSubscription(ctx context.Context, id int64) (*models.Subscription, error)
+	ListRuns(ctx context.Context, id int64) ([]models.SubscriptionRun, error)
+}
+
+type subscriptionService struct {
+	repo         repositories.SubscriptionRepository
+	customerRepo repositories.CustomerRepository
+	productRepo  repositories.ProductRepository
+	now          func() time.Time
+}
+
+func NewSubscriptionService(repo repositories.SubscriptionRepository, customerRepo repositories.CustomerRepository, productRepo repositories.ProductRepository) SubscriptionService {
+	return &subscriptionService{
+		repo:         repo,
+		customerRepo: customerRepo,
+		productRepo:  productRepo,
+		now:          time.Now,
+	}
+}
+
+// parseSchedule reads a standard five field cron expression, a descriptor
+// such as "@weekly", or an interval written as "@every 168h" or just "168h".
+// It returns the schedule in its canonical form.
+func parseSchedule(spec, timezone string) (string, cron.Schedule, *time.Location, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return "", nil, nil, errors.New("schedule is required")
+	}
+	if _, err := time.ParseDuration(spec); err == nil {
+		spec = "@every " + spec
+	}
+
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("invalid schedule %q: %v", spec, err)
+	}
+
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("unknown timezone %q", timezone)
+	}
+
+	return spec, schedule, location, nil
+}
+
+// nextRun is the first time after t the subscription is due
+func nextRun(subscription *models.Subscription, t time.Time) (time.Time, error) {
+	_, schedule, location, err := parseSchedule(subscription.Schedule, subscription.Timezone)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return schedule.Next(t.In(location)), nil
+}
+
+func (s *subscriptionService) CreateSubscription(ctx context.Context, subscription *models.Subscription) (int64, error) {
+	if subscription.CustomerID == 0 {
+		return 0, errors.New("customer_id is required")
+	}
+	if len(subscription.Items) == 0 {
+		return 0, errors.New("items is required")
+	}
+
+	subscription.Timezone = strings.TrimSpace(subscription.Timezone)
+	if subscription.Timezone == "" {
+		subscription.Timezone = defaultSubscriptionTimezone
+	}
+	spec, schedule, location, err := parseSchedule(subscription.Schedule, subscription.Timezone)
+	if err != nil {
+		return 0, err
+	}
+	subscription.Schedule = spec
+
+	now := s.now()
+	first := schedule.Next(now.In(location))
+	if first.IsZero() {
+		return 0, errors.New("schedule never runs")
+	}
+	if schedule.Next(first).Sub(first) < minSubscriptionInterval {
+		return 0, fmt.Errorf("schedule must not run more often than every %v", minSubscriptionInterval)
+	}
+	if subscription.NextRunAt == nil {
+		subscription.NextRunAt = &first
+	} else if !subscription.NextRunAt.After(now) {
+		return 0, errors.New("next_run_at must be in the future")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	// Validate the items exactly as the order each run places will be
+	order := subscription.Order()
+	if err := prepareOrder(ctx, s.productRepo, order); err != nil {
+		return 0, err
+	}
+	subscription.Currency = order.Currency
+	for i := range subscription.Items {
+		item := &subscription.Items[i]
+		item.ProductRef = strings.TrimSpace(item.ProductRef)
+		item.Description = strings.TrimSpace(item.Description)
+		if item.ProductID != nil {
+			item.UnitPrice = decimal.Zero
+		}
+	}
+
+	if _, err := s.customerRepo.GetByID(ctx, subscription.CustomerID); err != nil {
+		return 0, err
+	}
+
+	subscription.Status = models.SubscriptionActive
+	return s.repo.Create(ctx, subscription)
+}
+
+func (s *subscriptionService) GetSubscription(ctx context.Context, id int64) (*models.Subscription, error) {
+	if id == 0 {
+		return nil, errors.New("id is required")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return s.repo.GetByID(ctx, id)
+}
+
+func (s *subscriptionService) ListSubscriptions(ctx context.Context, customerID int64) ([]models.Subscription, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	return s.repo.List(ctx, customerID)
+}
+
+func (s *subscriptionService) PauseSubscription(ctx context.Context, id int64) (*models.Subscription, error) {
+	return s.setStatus(ctx, id, models.SubscriptionPaused)
+}
+
+func (s *subscriptionService) ResumeSubscription(ctx context.Context, id int64) (*models.Subscription, error) {
+	return s.setStatus(ctx, id, models.SubscriptionActive)
+}
+
+func (s *subscriptionService) CancelSubscription(ctx context.Context, id int64) (*models.Subscription, error) {
+	return s.setStatus(ctx, id, models.SubscriptionCancelled)
+}
+
+// setStatus moves a subscription to status. Only active subscriptions have a
+// next run; resuming an active subscription leaves its next run as it is.
+func (s *subscriptionService) setStatus(ctx context.Context, id int64, status models.SubscriptionStatus) (*models.Subscription, error) {
+	if id == 0 {
+		return nil, errors.New("id is required")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	subscription, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if subscription.Status == models.SubscriptionCancelled {
+		return nil, models.ErrSubscriptionCancelled
+	}
+	if subscription.Status == status {
+		return subscription, nil
+	}
+
+	var next *time.Time
+	if status == models.SubscriptionActive {
+		t, err := nextRun(subscription, s.now())
+		if err != nil {
+			return nil, err
+		}
+		next = &t
+	}
+
+	if err := s.repo.SetStatus(ctx, id, status, next); err != nil {
+		return nil, err
+	}
+
+	subscription.Status = status
+	subscription.NextRunAt = next
+	return subscription, nil
+}
+
+func (s *subscriptionService) ListRuns(ctx context.Context, id int64) ([]models.SubscriptionRun, error) {
+	if id == 0 {
+		return nil, errors.New("id is required")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	if _, err := s.repo.GetByID(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.repo.ListRuns(ctx, id)
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// subscriptionNow is a Monday morning in Nairobi
+var subscriptionNow = time.Date(2026, 10, 19, 7, 0, 0, 0, time.UTC)
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		spec string
+		want string
+		next time.Time
+	}{
+		{"0 8 * * MON", "0 8 * * MON", time.Date(2026, 10, 26, 5, 0, 0, 0, time.UTC)},
+		{"@weekly", "@weekly", time.Date(2026, 10, 24, 21, 0, 0, 0, time.UTC)},
+		{"168h", "@every 168h", subscriptionNow.Add(168 * time.Hour)},
+		{" @every 24h ", "@every 24h", subscriptionNow.Add(24 * time.Hour)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			spec, schedule, location, err := parseSchedule(tt.spec, "Africa/Nairobi")
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.want, spec)
+				assert.True(t, tt.next.Equal(schedule.Next(subscriptionNow.In(location))), "next run %v", schedule.Next(subscriptionNow.In(location)))
+			}
+		})
+	}
+
+	_, _, _, err := parseSchedule("every monday", "Africa/Nairobi")
+	assert.ErrorContains(t, err, `invalid schedule "every monday"`)
+
+	_, _, _, err = parseSchedule("@daily", "Mars/Olympus")
+	assert.EqualError(t, err, `unknown timezone "Mars/Olympus"`)
+
+	_, _, _, err = parseSchedule("", "Africa/Nairobi")
+	assert.EqualError(t, err, "schedule is required")
+}
+
+func TestCreateSubscription(t *testing.T) {
+	mockRepo := new(MockSubscriptionRepo)
+	mockCustomerRepo := new(MockCustomerRepo)
+
+	svc := &subscriptionService{repo: mockRepo, customerRepo: mockCustomerRepo, now: func() time.Time { return subscriptionNow }}
+
+	subscription := &models.Subscription{
+		CustomerID: 4,
+		Schedule:   "0 8 * * MON",
+		Items: []models.SubscriptionItem{
+			{Description: " Maize flour 2kg ", Quantity: 20, UnitPrice: decimal.RequireFromString("185.50")},
+		},
+	}
+
+	mockCustomerRepo.On("GetByID", mock.Anything, int64(4)).Return(&models.Customer{ID: 4}, nil)
+	mockRepo.On("Create", mock.Anything, subscription).Return(int64(9), nil)
+
+	id, err := svc.CreateSubscription(context.Background(), subscription)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(9), id)
+	assert.Equal(t, models.SubscriptionActive, subscription.Status)
+	assert.Equal(t, "Africa/Nairobi", subscription.Timezone)
+	assert.Equal(t, "KES", subscription.Currency)
+	assert.Equal(t, "Maize flour 2kg", subscription.Items[0].Description)
+	if assert.NotNil(t, subscription.NextRunAt) {
+		assert.True(t, subscription.NextRunAt.Equal(time.Date(2026, 10, 26, 5, 0, 0, 0, time.UTC)))
+	}
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateSubscription_Invalid(t *testing.T) {
+	svc := &subscriptionService{now: func() time.Time { return subscriptionNow }}
+	item := []models.SubscriptionItem{{Description: "Sugar", Quantity: 1, UnitPrice: decimal.NewFromInt(200)}}
+	past := subscriptionNow.Add(-time.Hour)
+
+	tests := []struct {
+		name         string
+		subscription models.Subscription
+		want         string
+	}{
+		{"missing customer", models.Subscription{Schedule: "@weekly", Items: item}, "customer_id is required"},
+		{"no items", models.Subscription{CustomerID: 1, Schedule: "@weekly"}, "items is required"},
+		{"every five minutes", models.Subscription{CustomerID: 1, Schedule: "*/5 * * * *", Items: item}, "schedule must not run more often than every 1h0m0s"},
+		{"short interval", models.Subscription{CustomerID: 1, Schedule: "30m", Items: item}, "schedule must not run more often than every 1h0m0s"},
+		{"first run in the past", models.Subscription{CustomerID: 1, Schedule: "@daily", NextRunAt: &past, Items: item}, "next_run_at must be in the future"},
+		{"bad item", models.Subscription{CustomerID: 1, Schedule: "@daily", Items: []models.SubscriptionItem{{Description: "Sugar", UnitPrice: decimal.NewFromInt(1)}}}, "items[0].quantity must be greater than 0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.CreateSubscription(context.Background(), &tt.subscription)
+			assert.EqualError(t, err, tt.want)
+		})
+	}
+}
+
+func TestPauseAndResumeSubscription(t *testing.T) {
+	mockRepo := new(MockSubscriptionRepo)
+	svc := &subscriptionService{repo: mockRepo, now: func() time.Time { return subscriptionNow }}
+
+	active := &models.Subscription{ID: 3, Schedule: "@every 24h", Timezone: "Africa/Nairobi", Status: models.SubscriptionActive}
+	mockRepo.On("GetByID", mock.Anything, int64(3)).Return(active, nil).Once()
+	mockRepo.On("SetStatus", mock.Anything, int64(3), models.SubscriptionPaused, (*time.Time)(nil)).Return(nil)
+
+	paused, err := svc.PauseSubscription(context.Background(), 3)
+	assert.NoError(t, err)
+	assert.Equal(t, models.SubscriptionPaused, paused.Status)
+	assert.Nil(t, paused.NextRunAt)
+
+	// Resuming schedules from now rather than catching up on missed runs
+	mockRepo.On("GetByID", mock.Anything, int64(3)).Return(paused, nil).Once()
+	next := subscriptionNow.Add(24 * time.Hour)
+	mockRepo.On("SetStatus", mock.Anything, int64(3), models.SubscriptionActive, mock.MatchedBy(func(t *time.Time) bool {
+		return t != nil && t.Equal(next)
+	})).Return(nil)
+
+	resumed, err := svc.ResumeSubscription(context.Background(), 3)
+	assert.NoError(t, err)
+	assert.Equal(t, models.SubscriptionActive, resumed.Status)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestResumeCancelledSubscription(t *testing.T) {
+	mockRepo := new(MockSubscriptionRepo)
+	svc := NewSubscriptionService(mockRepo, nil, nil)
+
+	mockRepo.On("GetByID", mock.Anything, int64(3)).Return(&models.Subscription{ID: 3, Status: models.SubscriptionCancelled}, nil)
+
+	_, err := svc.ResumeSubscription(context.Background(), 3)
+
+	assert.ErrorIs(t, err, models.ErrSubscriptionCancelled)
+	mockRepo.AssertNotCalled(t, "SetStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
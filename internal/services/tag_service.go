@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/chesireabel/Technical-Interview/internal/repositories"
+	"github.com/chesireabel/Technical-Interview/internal/segments"
+)
+
+const (
+	// maxTagLength matches the customer_tags.tag column
+	maxTagLength     = 50
+	maxTagsPerChange = 20
+)
+
+// TagService manages the free-form tags on customers, e.g. "vip" or
+// "wholesale". Tags are case-insensitive and segment rules can match them.
+type TagService interface {
+	ListTags(ctx context.Context, customerID int64) ([]string, error)
+	// AddTags tags the customer and returns all of their tags
+	AddTags(ctx context.Context, customerID int64, tags []string) ([]string, error)
+	RemoveTag(ctx context.Context, customerID int64, tag string) error
+}
+
+type tagService struct {
+	repo repositories.TagRepository
+}
+
+func NewTagService(repo repositories.TagRepository) TagService {
+	return &tagService{repo: repo}
+}
+
+// normalizeTags lower-cases and de-duplicates tags, rejecting blank and overlong ones
+func normalizeTags(tags []string) ([]string, error) {
+	if len(tags) == 0 {
+		return nil, errors.New("tags are required")
+	}
+	if len(tags) > maxTagsPerChange {
+		return nil, fmt.Errorf("at most %d tags can be added at once", maxTagsPerChange)
+	}
+
+	seen := make(map[string]bool, len(tags))
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = segments.NormalizeTag(tag)
+		if tag == "" {
+			return nil, errors.New("tags cannot be blank")
+		}
+		if len([]rune(tag)) > maxTagLength {
+			return nil, fmt.Errorf("tag %q is longer than %d characters", tag, maxTagLength)
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized, nil
+}
+
+func (s *tagService) ListTags(ctx context.Context, customerID int64) ([]string, error) {
+	if customerID == 0 {
+		return nil, errors.New("customer_id is required")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return s.repo.List(ctx, customerID)
+}
+
+func (s *tagService) AddTags(ctx context.Context, customerID int64, tags []string) ([]string, error) {
+	if customerID == 0 {
+		return nil, errors.New("customer_id is required")
+	}
+	tags, err := normalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if err := s.repo.Add(ctx, customerID, tags); err != nil {
+		return nil, err
+	}
+	return s.repo.List(ctx, customerID)
+}
+
+func (s *tagService) RemoveTag(ctx context.Context, customerID int64, tag string) error {
+	if customerID == 0 {
+		return errors.New("customer_id is required")
+	}
+	tag = segments.NormalizeTag(tag)
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	removed, err := s.repo.Remove(ctx, customerID, tag)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return fmt.Errorf("tag %q on customer %d: %w", tag, customerID, models.ErrNotFound)
+	}
+	return nil
+}
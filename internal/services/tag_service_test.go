@@ -0,0 +1,45 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAddTags(t *testing.T) {
+	mockTagRepo := new(MockTagRepo)
+	service := NewTagService(mockTagRepo)
+
+	mockTagRepo.On("Add", mock.Anything, int64(4), []string{"vip", "early adopter"}).Return(nil)
+	mockTagRepo.On("List", mock.Anything, int64(4)).Return([]string{"early adopter", "vip"}, nil)
+
+	tags, err := service.AddTags(context.Background(), 4, []string{" VIP", "Early   Adopter", "vip"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"early adopter", "vip"}, tags)
+	mockTagRepo.AssertExpectations(t)
+}
+
+func TestAddTags_Invalid(t *testing.T) {
+	service := NewTagService(new(MockTagRepo))
+
+	_, err := service.AddTags(context.Background(), 4, nil)
+	assert.EqualError(t, err, "tags are required")
+
+	_, err = service.AddTags(context.Background(), 4, []string{"vip", "  "})
+	assert.EqualError(t, err, "tags cannot be blank")
+}
+
+func TestRemoveTag_NotTagged(t *testing.T) {
+	mockTagRepo := new(MockTagRepo)
+	service := NewTagService(mockTagRepo)
+
+	mockTagRepo.On("Remove", mock.Anything, int64(4), "vip").Return(false, nil)
+
+	err := service.RemoveTag(context.Background(), 4, "VIP")
+
+	assert.ErrorIs(t, err, models.ErrNotFound)
+}
@@ -0,0 +1,234 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/chesireabel/Technical-Interview/internal/repositories"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	defaultTaxRegion = "KE"
+	defaultTaxName   = "VAT"
+	maxTaxNameLength = 50
+)
+
+var hundred = decimal.NewFromInt(100)
+
+// TaxService manages the tax rates finance configures per region and product
+// category. Tax is charged on orders by the order service when they are placed.
+type TaxService interface {
+	CreateTaxRate(ctx context.Context, rate *models.TaxRate) (int64, error)
+	ListTaxRates(ctx context.Context, region string) ([]models.TaxRate, error)
+	UpdateTaxRate(ctx context.Context, rate *models.TaxRate) error
+	DeleteTaxRate(ctx context.Context, id int64) error
+}
+
+type taxService struct {
+	repo repositories.TaxRateRepository
+}
+
+func NewTaxService(repo repositories.TaxRateRepository) TaxService {
+	return &taxService{repo: repo}
+}
+
+// taxDefaultRegion reads TAX_DEFAULT_REGION, the region orders are taxed in
+// when the customer has no country code (default KE)
+func taxDefaultRegion() string {
+	region := normaliseRegion(os.Getenv("TAX_DEFAULT_REGION"))
+	if region == "" {
+		return defaultTaxRegion
+	}
+	return region
+}
+
+func normaliseRegion(region string) string {
+	return strings.ToUpper(strings.TrimSpace(region))
+}
+
+// taxRegion is the region a customer's orders are taxed in
+func taxRegion(customer *models.Customer) string {
+	if customer != nil {
+		if region := normaliseRegion(customer.CountryCode); region != "" {
+			return region
+		}
+	}
+	return taxDefaultRegion()
+}
+
+func validateTaxRate(rate *models.TaxRate) error {
+	rate.Name = strings.TrimSpace(rate.Name)
+	rate.Region = normaliseRegion(rate.Region)
+	rate.Category = normaliseCategory(rate.Category)
+
+	if rate.Name == "" {
+		rate.Name = defaultTaxName
+	}
+	if len(rate.Name) > maxTaxNameLength {
+		return errors.New("name must be at most 50 characters")
+	}
+	if len(rate.Region) != 2 {
+		return errors.New("region must be a two letter country code")
+	}
+	if rate.Rate.IsNegative() || rate.Rate.GreaterThan(hundred) {
+		return errors.New("rate must be between 0 and 100")
+	}
+	if rate.Rate.Exponent() < -3 {
+		return errors.New("rate must have at most 3 decimal places")
+	}
+	return nil
+}
+
+// selectTaxRate picks the rate for a line: a rate for one of the product's
+// categories wins over the region's standard rate and, when several
+// categories have a rate, the lowest applies. It returns nil when the region
+// has no rates, i.e. the line is not taxed.
+func selectTaxRate(rates []models.TaxRate, categories []string) *models.TaxRate {
+	var standard, best *models.TaxRate
+	for i := range rates {
+		rate := &rates[i]
+		switch {
+		case rate.Category == "":
+			standard = rate
+		case slices.Contains(categories, rate.Category):
+			if best == nil || rate.Rate.LessThan(best.Rate) {
+				best = rate
+			}
+		}
+	}
+	if best != nil {
+		return best
+	}
+	return standard
+}
+
+// allocateDiscount spreads the order discount over its lines in proportion to
+// their totals so that tax is charged on what the customer actually pays. Each
+// share is rounded to the currency and the largest line absorbs the rounding
+// difference, so the shares always add up to the discount.
+func allocateDiscount(order *models.Order, currency models.Currency) {
+	largest := 0
+	allocated := decimal.Zero
+	for i := range order.Items {
+		item := &order.Items[i]
+		item.Discount = decimal.Zero
+		if order.Discount.IsPositive() && order.Subtotal.IsPositive() {
+			item.Discount = currency.Round(order.Discount.Mul(item.LineTotal).Div(order.Subtotal))
+		}
+		allocated = allocated.Add(item.Discount)
+		if item.LineTotal.GreaterThan(order.Items[largest].LineTotal) {
+			largest = i
+		}
+	}
+
+	if len(order.Items) > 0 {
+		order.Items[largest].Discount = order.Items[largest].Discount.Add(order.Discount.Sub(allocated))
+	}
+}
+
+// computeTax charges tax on every line of a priced and discounted order and
+// works out the order total. Tax is rounded per line. For tax inclusive rates
+// the tax is the part of the price that is tax, rate/(100+rate), and does not
+// change the total; exclusive tax is added on top.
+func computeTax(order *models.Order, rates []models.TaxRate) error {
+	currency, err := models.LookupCurrency(order.Currency)
+	if err != nil {
+		return err
+	}
+
+	allocateDiscount(order, currency)
+
+	order.Tax = decimal.Zero
+	order.Amount = order.Subtotal.Sub(order.Discount)
+	for i := range order.Items {
+		item := &order.Items[i]
+		item.TaxName, item.TaxRate, item.TaxInclusive, item.Tax = "", decimal.Zero, false, decimal.Zero
+
+		rate := selectTaxRate(rates, item.Categories)
+		if rate == nil {
+			continue
+		}
+		item.TaxName = rate.Name
+		item.TaxRate = rate.Rate
+		item.TaxInclusive = rate.Inclusive
+
+		taxable := item.LineTotal.Sub(item.Discount)
+		if rate.Inclusive {
+			item.Tax = currency.Round(taxable.Mul(rate.Rate).Div(hundred.Add(rate.Rate)))
+		} else {
+			item.Tax = currency.Round(taxable.Mul(rate.Rate).Div(hundred))
+			order.Amount = order.Amount.Add(item.Tax)
+		}
+		order.Tax = order.Tax.Add(item.Tax)
+	}
+
+	return nil
+}
+
+// applyTax charges the rates of the order's tax region
+func applyTax(ctx context.Context, repo repositories.TaxRateRepository, order *models.Order) error {
+	if order.TaxRegion == "" {
+		order.TaxRegion = taxDefaultRegion()
+	}
+
+	rates, err := repo.List(ctx, order.TaxRegion)
+	if err != nil {
+		return err
+	}
+	return computeTax(order, rates)
+}
+
+func (s *taxService) CreateTaxRate(ctx context.Context, rate *models.TaxRate) (int64, error) {
+	if err := validateTaxRate(rate); err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	id, err := s.repo.Create(ctx, rate)
+	if err != nil {
+		return 0, err
+	}
+	rate.ID = id
+
+	return id, nil
+}
+
+func (s *taxService) ListTaxRates(ctx context.Context, region string) ([]models.TaxRate, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return s.repo.List(ctx, normaliseRegion(region))
+}
+
+func (s *taxService) UpdateTaxRate(ctx context.Context, rate *models.TaxRate) error {
+	if rate.ID == 0 {
+		return errors.New("id is required for update")
+	}
+	if err := validateTaxRate(rate); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return s.repo.Update(ctx, rate)
+}
+
+func (s *taxService) DeleteTaxRate(ctx context.Context, id int64) error {
+	if id == 0 {
+		return errors.New("id is required for delete")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return s.repo.Delete(ctx, id)
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/chesireabel/Technical-Interview/internal/repositories"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestComputeTax(t *testing.T) {
+	vat := func(rate string, inclusive bool) []models.TaxRate {
+		return []models.TaxRate{{Name: "VAT", Region: "KE", Rate: decimal.RequireFromString(rate), Inclusive: inclusive}}
+	}
+	line := func(total string) models.OrderItem {
+		return models.OrderItem{Description: "Item", Quantity: 1, LineTotal: decimal.RequireFromString(total)}
+	}
+
+	tests := []struct {
+		name      string
+		currency  string
+		rates     []models.TaxRate
+		items     []models.OrderItem
+		discount  string
+		wantTaxes []string
+		wantTax   string
+		wantTotal string
+	}{
+		{"exclusive", "KES", vat("16", false), []models.OrderItem{line("100")}, "0", []string{"16"}, "16", "116"},
+		{"inclusive", "KES", vat("16", true), []models.OrderItem{line("100")}, "0", []string{"13.79"}, "13.79", "100"},
+		{"half rounds to even down", "KES", vat("10", false), []models.OrderItem{line("0.25")}, "0", []string{"0.02"}, "0.02", "0.27"},
+		{"half rounds to even up", "KES", vat("10", false), []models.OrderItem{line("0.35")}, "0", []string{"0.04"}, "0.04", "0.39"},
+		{"inclusive tax below a cent", "KES", vat("16", true), []models.OrderItem{line("0.01")}, "0", []string{"0"}, "0", "0.01"},
+		{"currency without minor unit", "UGX", vat("18", false), []models.OrderItem{line("1003")}, "0", []string{"181"}, "181", "1184"},
+		{"rounded per line not per order", "KES", vat("16", false), []models.OrderItem{line("0.03"), line("0.03"), line("0.03")}, "0", []string{"0", "0", "0"}, "0", "0.09"},
+		{"fractional rate", "KES", vat("7.5", false), []models.OrderItem{line("19.99")}, "0", []string{"1.5"}, "1.5", "21.49"},
+		{"tax on discounted lines", "KES", vat("16", false), []models.OrderItem{line("100"), line("200")}, "10", []string{"15.47", "30.93"}, "46.4", "336.4"},
+		{"inclusive with discount", "KES", vat("16", true), []models.OrderItem{line("116")}, "16", []string{"13.79"}, "13.79", "100"},
+		{"fully discounted", "KES", vat("16", false), []models.OrderItem{line("50")}, "50", []string{"0"}, "0", "0"},
+		{"no rates for region", "KES", nil, []models.OrderItem{line("100")}, "0", []string{"0"}, "0", "100"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := &models.Order{Currency: tt.currency, Items: tt.items, Discount: decimal.RequireFromString(tt.discount)}
+			for _, item := range order.Items {
+				order.Subtotal = order.Subtotal.Add(item.LineTotal)
+			}
+
+			assert.NoError(t, computeTax(order, tt.rates))
+
+			for i, want := range tt.wantTaxes {
+				assert.Equal(t, want, order.Items[i].Tax.String(), "line %d", i)
+			}
+			assert.Equal(t, tt.wantTax, order.Tax.String())
+			assert.Equal(t, tt.wantTotal, order.Amount.String())
+		})
+	}
+}
+
+func TestAllocateDiscount(t *testing.T) {
+	order := &models.Order{
+		Subtotal: decimal.NewFromInt(3),
+		Discount: decimal.NewFromInt(1),
+		Items: []models.OrderItem{
+			{LineTotal: decimal.NewFromInt(1)},
+			{LineTotal: decimal.NewFromInt(1)},
+			{LineTotal: decimal.NewFromInt(1)},
+		},
+	}
+
+	kes, _ := models.LookupCurrency("KES")
+	allocateDiscount(order, kes)
+
+	// 0.333.. rounds to 0.33 three times; the missing cent goes to one line
+	assert.Equal(t, "0.34", order.Items[0].Discount.String())
+	assert.Equal(t, "0.33", order.Items[1].Discount.String())
+	assert.Equal(t, "0.33", order.Items[2].Discount.String())
+}
+
+func TestSelectTaxRate(t *testing.T) {
+	rates := []models.TaxRate{
+		{Name: "VAT", Region: "KE", Rate: decimal.NewFromInt(16)},
+		{Name: "VAT", Region: "KE", Category: "books", Rate: decimal.NewFromInt(8)},
+		{Name: "VAT", Region: "KE", Category: "food", Rate: decimal.Zero},
+	}
+
+	assert.Equal(t, "16", selectTaxRate(rates, nil).Rate.String())
+	assert.Equal(t, "8", selectTaxRate(rates, []string{"books", "stationery"}).Rate.String())
+	assert.Equal(t, "0", selectTaxRate(rates, []string{"books", "food"}).Rate.String())
+	assert.Nil(t, selectTaxRate(nil, []string{"food"}))
+}
+
+func TestValidateTaxRate(t *testing.T) {
+	tests := []struct {
+		name string
+		rate models.TaxRate
+		want string
+	}{
+		{"missing region", models.TaxRate{Rate: decimal.NewFromInt(16)}, "region must be a two letter country code"},
+		{"negative rate", models.TaxRate{Region: "KE", Rate: decimal.NewFromInt(-1)}, "rate must be between 0 and 100"},
+		{"too precise", models.TaxRate{Region: "KE", Rate: decimal.RequireFromString("7.1234")}, "rate must have at most 3 decimal places"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.EqualError(t, validateTaxRate(&tt.rate), tt.want)
+		})
+	}
+
+	rate := models.TaxRate{Region: " ke", Category: " Food ", Rate: decimal.Zero}
+	assert.NoError(t, validateTaxRate(&rate))
+	assert.Equal(t, "VAT", rate.Name)
+	assert.Equal(t, "KE", rate.Region)
+	assert.Equal(t, "food", rate.Category)
+}
+
+func TestCreateOrder_ChargesTaxForCustomerRegion(t *testing.T) {
+	mockOrderRepo := new(MockOrderRepo)
+	mockCustomerRepo := new(MockCustomerRepo)
+	mockTaxRateRepo := new(MockTaxRateRepo)
+	tx := &FakeTxManager{Repos: repositories.Repositories{Orders: mockOrderRepo, TaxRates: mockTaxRateRepo}}
+	service := NewOrderService(mockOrderRepo, mockCustomerRepo, nil, tx)
+
+	order := &models.Order{CustomerID: "1", Currency: "UGX", Item: "Sugar", Amount: decimal.NewFromInt(10000)}
+
+	mockCustomerRepo.On("GetByID", mock.Anything, int64(1)).Return(&models.Customer{ID: 1, CountryCode: "ug"}, nil)
+	mockTaxRateRepo.On("List", mock.Anything, "UG").Return([]models.TaxRate{{Name: "VAT", Region: "UG", Rate: decimal.NewFromInt(18)}}, nil)
+	mockOrderRepo.On("Create", mock.Anything, order).Return(int64(3), nil)
+
+	_, err := service.CreateOrder(context.Background(), order)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "UG", order.TaxRegion)
+	assert.Equal(t, "10000", order.Subtotal.String())
+	assert.Equal(t, "1800", order.Tax.String())
+	assert.Equal(t, "11800", order.Amount.String())
+	mockTaxRateRepo.AssertExpectations(t)
+}
+
+func TestGetOrder_TaxBreakdown(t *testing.T) {
+	mockOrderRepo := new(MockOrderRepo)
+	service := NewOrderService(mockOrderRepo, nil, nil, nil)
+
+	mockOrderRepo.On("GetByID", mock.Anything, int64(8)).Return(&models.Order{ID: 8, Items: []models.OrderItem{
+		{LineTotal: decimal.NewFromInt(116), TaxName: "VAT", TaxRate: decimal.NewFromInt(16), TaxInclusive: true, Tax: decimal.NewFromInt(16)},
+		{LineTotal: decimal.NewFromInt(58), TaxName: "VAT", TaxRate: decimal.NewFromInt(16), TaxInclusive: true, Tax: decimal.NewFromInt(8)},
+		{LineTotal: decimal.NewFromInt(40), TaxName: "VAT", TaxRate: decimal.Zero, TaxInclusive: true},
+	}}, nil)
+
+	order, err := service.GetOrder(context.Background(), 8)
+
+	assert.NoError(t, err)
+	assert.Len(t, order.TaxBreakdown, 2)
+	assert.Equal(t, "150", order.TaxBreakdown[0].Net.String())
+	assert.Equal(t, "24", order.TaxBreakdown[0].Tax.String())
+	assert.Equal(t, "40", order.TaxBreakdown[1].Net.String())
+	assert.Equal(t, "0", order.TaxBreakdown[1].Tax.String())
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/chesireabel/Technical-Interview/internal/repositories"
+)
+
+const (
+	defaultTimelineLimit = 50
+	maxTimelineLimit     = 200
+)
+
+// ErrInvalidCursor is returned for a timeline cursor that was not issued by GetTimeline
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// TimelineService shows support staff everything that happened with a
+// customer in one place: their notes, orders, order status changes and the
+// SMS messages sent to them
+type TimelineService interface {
+	// GetTimeline returns up to limit events, newest first, with limit
+	// defaulting to 50 and capped at 200. before is the NextCursor of the
+	// previous page, or empty for the first page.
+	GetTimeline(ctx context.Context, customerID int64, before string, limit int) (*models.TimelinePage, error)
+}
+
+type timelineService struct {
+	repo         repositories.TimelineRepository
+	customerRepo repositories.CustomerRepository
+}
+
+func NewTimelineService(repo repositories.TimelineRepository, customerRepo repositories.CustomerRepository) TimelineService {
+	return &timelineService{repo: repo, customerRepo: customerRepo}
+}
+
+// encodeTimelineCursor makes an opaque cursor from the event a page ended on
+func encodeTimelineCursor(e *models.TimelineEvent) string {
+	raw := fmt.Sprintf("%d.%s.%d", e.OccurredAt.UnixNano(), e.Kind, e.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeTimelineCursor(cursor string) (*models.TimelineCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	parts := strings.Split(string(raw), ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidCursor
+	}
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	id, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &models.TimelineCursor{
+		OccurredAt: time.Unix(0, nanos).UTC(),
+		Kind:       models.TimelineEventKind(parts[1]),
+		ID:         id,
+	}, nil
+}
+
+func (s *timelineService) GetTimeline(ctx context.Context, customerID int64, before string, limit int) (*models.TimelinePage, error) {
+	if customerID == 0 {
+		return nil, errors.New("customer_id is required")
+	}
+	if limit <= 0 {
+		limit = defaultTimelineLimit
+	}
+	if limit > maxTimelineLimit {
+		limit = maxTimelineLimit
+	}
+
+	var cursor *models.TimelineCursor
+	if before != "" {
+		var err error
+		if cursor, err = decodeTimelineCursor(before); err != nil {
+			return nil, err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	if _, err := s.customerRepo.GetByID(ctx, customerID); err != nil {
+		return nil, err
+	}
+
+	// One extra event tells us whether there is another page
+	events, err := s.repo.List(ctx, customerID, cursor, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &models.TimelinePage{Events: events}
+	if len(events) > limit {
+		page.Events = events[:limit]
+		page.NextCursor = encodeTimelineCursor(&page.Events[limit-1])
+	}
+	for i := range page.Events {
+		page.Events[i].Summary = summarizeTimelineEvent(&page.Events[i])
+	}
+	return page, nil
+}
+
+// summarizeTimelineEvent describes the event in a line, e.g. "Order #12 moved from pending to confirmed"
+func summarizeTimelineEvent(e *models.TimelineEvent) string {
+	var orderID int64
+	if e.OrderID != nil {
+		orderID = *e.OrderID
+	}
+
+	switch e.Kind {
+	case models.TimelineNote:
+		if e.Pinned {
+			return fmt.Sprintf("Pinned note by %s", e.Actor)
+		}
+		return fmt.Sprintf("Note by %s", e.Actor)
+	case models.TimelineOrder:
+		summary := fmt.Sprintf("Order #%d placed: %s", orderID, e.Body)
+		if e.Amount != nil {
+			summary += ", " + models.FormatMoney(*e.Amount, e.Currency)
+		}
+		return summary
+	case models.TimelineStatusChange:
+		return fmt.Sprintf("Order #%d moved from %s to %s by %s", orderID, e.FromStatus, e.ToStatus, e.Actor)
+	case models.TimelineSMS:
+		switch e.SMSKind {
+		case models.SMSKindOrderConfirmation:
+			return fmt.Sprintf("Order confirmation SMS sent to %s", e.Phone)
+		case models.SMSKindOrderUpdate:
+			return fmt.Sprintf("Order update SMS sent to %s", e.Phone)
+		case models.SMSKindRefundNotice:
+			return fmt.Sprintf("Refund SMS sent to %s", e.Phone)
+		}
+		return fmt.Sprintf("SMS sent to %s", e.Phone)
+	}
+	return string(e.Kind)
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/chesireabel/Technical-Interview/internal/models"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGetTimeline_Pages(t *testing.T) {
+	mockTimelineRepo := new(MockTimelineRepo)
+	mockCustomerRepo := new(MockCustomerRepo)
+	service := NewTimelineService(mockTimelineRepo, mockCustomerRepo)
+
+	at := time.Date(2026, 3, 2, 9, 30, 0, 123456000, time.UTC)
+	orderID := int64(12)
+	amount := decimal.NewFromInt(1500)
+
+	mockCustomerRepo.On("GetByID", mock.Anything, int64(4)).Return(&models.Customer{ID: 4}, nil)
+	mockTimelineRepo.On("List", mock.Anything, int64(4), (*models.TimelineCursor)(nil), 3).Return([]models.TimelineEvent{
+		{Kind: models.TimelineNote, ID: 3, OccurredAt: at.Add(time.Hour), Actor: "agent@example.com", Body: "Called", Pinned: true},
+		{Kind: models.TimelineStatusChange, ID: 8, OccurredAt: at, OrderID: &orderID, Actor: "ops", FromStatus: models.OrderStatusPending, ToStatus: models.OrderStatusConfirmed},
+		{Kind: models.TimelineOrder, ID: 12, OccurredAt: at, OrderID: &orderID, Body: "Book", Amount: &amount, Currency: "KES"},
+	}, nil)
+
+	page, err := service.GetTimeline(context.Background(), 4, "", 2)
+
+	assert.NoError(t, err)
+	assert.Len(t, page.Events, 2)
+	assert.Equal(t, "Pinned note by agent@example.com", page.Events[0].Summary)
+	assert.Equal(t, "Order #12 moved from pending to confirmed by ops", page.Events[1].Summary)
+	assert.NotEmpty(t, page.NextCursor)
+
+	cursor, err := decodeTimelineCursor(page.NextCursor)
+	assert.NoError(t, err)
+	assert.Equal(t, &models.TimelineCursor{OccurredAt: at, Kind: models.TimelineStatusChange, ID: 8}, cursor)
+
+	mockTimelineRepo.On("List", mock.Anything, int64(4), cursor, 3).Return([]models.TimelineEvent{
+		{Kind: models.TimelineOrder, ID: 12, OccurredAt: at, OrderID: &orderID, Body: "Book", Amount: &amount, Currency: "KES"},
+	}, nil)
+
+	page, err = service.GetTimeline(context.Background(), 4, page.NextCursor, 2)
+
+	assert.NoError(t, err)
+	assert.Len(t, page.Events, 1)
+	assert.Equal(t, "Order #12 placed: Book, "+models.FormatMoney(amount, "KES"), page.Events[0].Summary)
+	assert.Empty(t, page.NextCursor)
+	mockTimelineRepo.AssertExpectations(t)
+}
+
+func TestGetTimeline_DefaultAndMaxLimit(t *testing.T) {
+	mockTimelineRepo := new(MockTimelineRepo)
+	mockCustomerRepo := new(MockCustomerRepo)
+	service := NewTimelineService(mockTimelineRepo, mockCustomerRepo)
+
+	mockCustomerRepo.On("GetByID", mock.Anything, int64(4)).Return(&models.Customer{ID: 4}, nil)
+	mockTimelineRepo.On("List", mock.Anything, int64(4), (*models.TimelineCursor)(nil), defaultTimelineLimit+1).Return([]models.TimelineEvent{}, nil)
+	mockTimelineRepo.On("List", mock.Anything, int64(4), (*models.TimelineCursor)(nil), maxTimelineLimit+1).Return([]models.TimelineEvent{}, nil)
+
+	_, err := service.GetTimeline(context.Background(), 4, "", 0)
+	assert.NoError(t, err)
+	_, err = service.GetTimeline(context.Background(), 4, "", 5000)
+	assert.NoError(t, err)
+	mockTimelineRepo.AssertExpectations(t)
+}
+
+func TestGetTimeline_InvalidCursor(t *testing.T) {
+	mockTimelineRepo := new(MockTimelineRepo)
+	service := NewTimelineService(mockTimelineRepo, new(MockCustomerRepo))
+
+	for _, cursor := range []string{"not base64!", "bm9wZQ"} {
+		_, err := service.GetTimeline(context.Background(), 4, cursor, 10)
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+	}
+	mockTimelineRepo.AssertNotCalled(t, "List", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGetTimeline_UnknownCustomer(t *testing.T) {
+	mockTimelineRepo := new(MockTimelineRepo)
+	mockCustomerRepo := new(MockCustomerRepo)
+	service := NewTimelineService(mockTimelineRepo, mockCustomerRepo)
+
+	mockCustomerRepo.On("GetByID", mock.Anything, int64(9)).Return(nil, fmt.Errorf("customer with id 9: %w", models.ErrNotFound))
+
+	_, err := service.GetTimeline(context.Background(), 9, "", 10)
+
+	assert.ErrorIs(t, err, models.ErrNotFound)
+	mockTimelineRepo.AssertNotCalled(t, "List", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
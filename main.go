@@ -8,6 +8,7 @@ import (
 	"github.com/chesireabel/Technical-Interview/config"
 	"github.com/chesireabel/Technical-Interview/database"
 	"github.com/chesireabel/Technical-Interview/internal/handlers"
+	"github.com/chesireabel/Technical-Interview/internal/payments"
 	"github.com/chesireabel/Technical-Interview/internal/repositories"
 	"github.com/chesireabel/Technical-Interview/internal/routes"
 	"github.com/chesireabel/Technical-Interview/internal/services"
@@ -36,6 +37,14 @@ func main() {
 		log.Fatal("Failed to run migrations:", err)
 	}
 
+	// One-off maintenance commands, e.g. `go run . backfill-phones`
+	if len(os.Args) > 1 {
+		if err := runCommand(context.Background(), os.Args[1], os.Args[2:]); err != nil {
+			log.Fatalf("❌ Command %s failed: %v", os.Args[1], err)
+		}
+		return
+	}
+
      oidc, err := config.InitOIDCWithDefaults(context.Background())
 	if err != nil {
 		log.Fatalf("❌ Failed to initialize OIDC: %v", err)
@@ -43,6 +52,12 @@ func main() {
 
 	returnToURL := config.GetReturnURL()
 
+	// Get port from .env
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "3000"
+	}
+
 	// Initialize SMS service
 	smsService, err := services.NewSMSService()
 	if err != nil {
@@ -56,14 +71,118 @@ func main() {
 	// Initialize repositories
 	customerRepo := repositories.NewCustomerRepository(database.DB)
 	orderRepo := repositories.NewOrderRepository(database.DB)
+	idempotencyRepo := repositories.NewIdempotencyRepository(database.DB)
+	outboxRepo := repositories.NewOutboxRepository(database.DB)
+	productRepo := repositories.NewProductRepository(database.DB)
+	inventoryRepo := repositories.NewInventoryRepository(database.DB)
+	promotionRepo := repositories.NewPromotionRepository(database.DB)
+	taxRateRepo := repositories.NewTaxRateRepository(database.DB)
+	invoiceRepo := repositories.NewInvoiceRepository(database.DB)
+	refundRepo := repositories.NewRefundRepository(database.DB)
+	paymentRepo := repositories.NewPaymentRepository(database.DB)
+	subscriptionRepo := repositories.NewSubscriptionRepository(database.DB)
+	addressRepo := repositories.NewAddressRepository(database.DB)
+	tagRepo := repositories.NewTagRepository(database.DB)
+	segmentRepo := repositories.NewSegmentRepository(database.DB)
+	noteRepo := repositories.NewNoteRepository(database.DB)
+	timelineRepo := repositories.NewTimelineRepository(database.DB)
+	mergeRepo := repositories.NewMergeRepository(database.DB)
+	txManager := repositories.NewTxManager(database.DB)
+
+	// Record every SMS sent so it shows on the customer's timeline
+	if smsService != nil {
+		smsService = services.NewRecordingSMSService(smsService, repositories.NewSMSMessageRepository(database.DB))
+	}
 
 	// Initialize services
-	customerService := services.NewCustomerService(customerRepo)
-	orderService := services.NewOrderService(orderRepo, customerRepo, smsService)
+	customerService := services.NewCustomerService(customerRepo, txManager)
+	orderService := services.NewOrderService(orderRepo, customerRepo, productRepo, txManager)
+	productService := services.NewProductService(productRepo)
+	inventoryService := services.NewInventoryService(inventoryRepo, productRepo)
+	promotionService := services.NewPromotionService(promotionRepo)
+	taxService := services.NewTaxService(taxRateRepo)
+	invoiceService := services.NewInvoiceService(invoiceRepo, orderRepo, customerRepo, config.GetCompany())
+	refundService := services.NewRefundService(refundRepo, orderRepo)
+	paymentProviders := initPaymentProviders(port)
+	paymentService := services.NewPaymentService(paymentRepo, orderRepo, customerRepo, txManager, paymentProviders)
+	subscriptionService := services.NewSubscriptionService(subscriptionRepo, customerRepo, productRepo)
+	addressService := services.NewAddressService(addressRepo)
+	tagService := services.NewTagService(tagRepo)
+	segmentService := services.NewSegmentService(segmentRepo)
+	noteService := services.NewNoteService(noteRepo)
+	timelineService := services.NewTimelineService(timelineRepo, customerRepo)
+	mergeService := services.NewMergeService(mergeRepo, customerRepo)
+
+	// Start purging soft deleted records past their retention period
+	purgeService, err := services.NewPurgeService(customerRepo, orderRepo)
+	if err != nil {
+		log.Fatalf("❌ Failed to initialize purge service: %v", err)
+	}
+	go purgeService.Run(context.Background())
+
+	// Idempotency keys let clients retry POST /orders without creating duplicates
+	idempotencyService, err := services.NewIdempotencyService(idempotencyRepo)
+	if err != nil {
+		log.Fatalf("❌ Failed to initialize idempotency service: %v", err)
+	}
+	go idempotencyService.Run(context.Background())
+
+	// Deliver order notifications and refund payouts queued in the outbox
+	refundPayer := services.NewRefundPayer(refundRepo, paymentRepo, paymentProviders)
+	outboxDispatcher, err := services.NewOutboxDispatcher(outboxRepo, orderRepo, customerRepo, smsService, refundPayer)
+	if err != nil {
+		log.Fatalf("❌ Failed to initialize outbox dispatcher: %v", err)
+	}
+	go outboxDispatcher.Run(context.Background())
+
+	// Background jobs elect a leader through advisory locks, all held on one
+	// connection outside the pool
+	leaderSession := repositories.NewLeaderSession(database.DB.Config().ConnConfig)
+	defer leaderSession.Close(context.Background())
+
+	// Flag payments whose record disagrees with their provider
+	reconciliationService, err := services.NewReconciliationService(paymentRepo, paymentProviders, leaderSession.Lock("payment-reconciliation"))
+	if err != nil {
+		log.Fatalf("❌ Failed to initialize payment reconciliation: %v", err)
+	}
+	go reconciliationService.Run(context.Background())
+
+	// Place recurring orders; replicas elect one leader through an advisory lock
+	subscriptionScheduler, err := services.NewSubscriptionScheduler(subscriptionRepo, orderService, txManager, leaderSession.Lock("subscription-scheduler"))
+	if err != nil {
+		log.Fatalf("❌ Failed to initialize subscription scheduler: %v", err)
+	}
+	go subscriptionScheduler.Run(context.Background())
+
+	// Confirm scheduled orders when their time comes
+	scheduledOrderReleaser, err := services.NewScheduledOrderReleaser(orderRepo, orderService, leaderSession.Lock("scheduled-orders"))
+	if err != nil {
+		log.Fatalf("❌ Failed to initialize scheduled order releaser: %v", err)
+	}
+	go scheduledOrderReleaser.Run(context.Background())
+
+	// Keep segment membership in step with customers and their orders
+	segmentRefresher, err := services.NewSegmentRefresher(segmentRepo, leaderSession.Lock("segment-refresh"))
+	if err != nil {
+		log.Fatalf("❌ Failed to initialize segment refresher: %v", err)
+	}
+	go segmentRefresher.Run(context.Background())
 
 	// Initialize handlers
 	customerHandler := handlers.NewCustomerHandler(customerService)
+	addressHandler := handlers.NewAddressHandler(addressService)
+	tagHandler := handlers.NewTagHandler(tagService)
+	noteHandler := handlers.NewNoteHandler(noteService, timelineService)
+	mergeHandler := handlers.NewMergeHandler(mergeService)
 	orderHandler := handlers.NewOrderHandler(orderService)
+	invoiceHandler := handlers.NewInvoiceHandler(invoiceService)
+	refundHandler := handlers.NewRefundHandler(refundService, refundPayer)
+	paymentHandler := handlers.NewPaymentHandler(paymentService)
+	subscriptionHandler := handlers.NewSubscriptionHandler(subscriptionService)
+	segmentHandler := handlers.NewSegmentHandler(segmentService)
+	productHandler := handlers.NewProductHandler(productService, inventoryService)
+	promotionHandler := handlers.NewPromotionHandler(promotionService)
+	taxRateHandler := handlers.NewTaxRateHandler(taxService)
 
 	// Setup a Gin router
 	r := gin.Default()
@@ -71,16 +190,46 @@ func main() {
 	r.Use(middleware.InitSessionStore())
 
 	// Register all routes
-	routes.RegisterRoutes(r, customerHandler, orderHandler, oidc ,returnToURL)
-
-	// Get port from .env
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "3000"
-	}
+	routes.RegisterRoutes(r, customerHandler, addressHandler, tagHandler, noteHandler, mergeHandler, orderHandler, invoiceHandler, refundHandler, paymentHandler, subscriptionHandler, segmentHandler, productHandler, promotionHandler, taxRateHandler, idempotencyService, oidc ,returnToURL)
 
 	log.Printf("🚀 Server is running on: http://localhost:%s", port)
 	if err := r.Run(":" + port); err != nil {
 		log.Fatal("Failed to start server:", err)
 	}
-}
\ No newline at end of file
+}
+
+// initPaymentProviders registers the payment providers that are configured
+func initPaymentProviders(port string) *payments.Registry {
+	registry := payments.NewRegistry()
+	if mpesa := initMpesa(port); mpesa != nil {
+		registry.Register(mpesa)
+	}
+	return registry
+}
+
+// initMpesa creates the M-Pesa client, or returns nil when it is not
+// configured. MPESA_SIMULATOR=true runs an in-process Daraja stand-in so
+// payments work locally without credentials or network access.
+func initMpesa(port string) *payments.Mpesa {
+	cfg := payments.MpesaConfigFromEnv()
+
+	if os.Getenv("MPESA_SIMULATOR") == "true" {
+		cfg = payments.SimulatorDefaults(cfg, "http://localhost:"+port+"/payments/mpesa/callback")
+		simulator := payments.NewSimulator(cfg)
+		baseURL, err := simulator.Start("127.0.0.1:0")
+		if err != nil {
+			log.Printf("⚠️ Warning: %v", err)
+			return nil
+		}
+		cfg.BaseURL = baseURL
+		log.Printf("🧪 M-Pesa simulator running on %s", baseURL)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		log.Printf("⚠️ Warning: M-Pesa payments disabled: %v", err)
+		return nil
+	}
+
+	log.Println("✅ M-Pesa payments initialized successfully")
+	return payments.NewMpesa(cfg, nil)
+}